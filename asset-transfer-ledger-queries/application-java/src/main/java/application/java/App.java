@@ -119,7 +119,7 @@ public class App {
 			// Rich Query (Only supported if CouchDB is used as state database):
 			System.out.println("\n");
 			System.out.println("Evaluate Transaction:QueryAssets assets of size 15");
-			result = contract.evaluateTransaction("QueryAssets", "{\"selector\":{\"size\":15}}");
+			result = contract.evaluateTransaction("QueryAssets", "{\"selector\":{\"docType\":\"asset\",\"size\":15}}");
 			System.out.println("result: " + new String(result));
 
 			// Rich Query with index design doc and index name specified (Only supported if CouchDB is used as state database):
@@ -209,7 +209,7 @@ async function main() {
 
 			// Rich Query (Only supported if CouchDB is used as state database):
 			console.log('\n--> Evaluate Transaction: QueryAssets, assets of size 15');
-			result = await contract.evaluateTransaction('QueryAssets', '{"selector":{"size":15}}');
+			result = await contract.evaluateTransaction('QueryAssets', '{"selector":{"docType":"asset","size":15}}');
 			console.log(`*** Result: ${prettyJSONString(result.toString())}`);
 
 			// Rich Query with index design doc and index name specified (Only supported if CouchDB is used as state database):
@@ -18,12 +18,12 @@ peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["ReadAsset","asset1"
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetsByRange","asset1","asset3"]}'
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetHistory","asset1"]}'
 
-Rich Query (Only supported if CouchDB is used as state database):
+Rich Query (Evaluated by the chaincode if LevelDB is used as state database):
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssetsByOwner","tom"]}'
-peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssets","{\"selector\":{\"owner\":\"tom\"}}"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssets","{\"selector\":{\"docType\":\"asset\",\"owner\":\"tom\"}}"]}'
 
-Rich Query with Pagination (Only supported if CouchDB is used as state database):
-peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssetsWithPagination","{\"selector\":{\"owner\":\"tom\"}}","3",""]}'
+Rich Query with Pagination (Evaluated by the chaincode if LevelDB is used as state database):
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssetsWithPagination","{\"selector\":{\"docType\":\"asset\",\"owner\":\"tom\"}}","3",""]}'
 
 INDEXES TO SUPPORT COUCHDB RICH QUERIES
 
@@ -34,8 +34,9 @@ text file with extension *.json with the index definition formatted in JSON foll
 CouchDB index JSON syntax as documented at:
 http://docs.couchdb.org/en/2.3.1/api/database/find.html#db-index
 
-This asset transfer ledger example chaincode demonstrates a packaged
-index which you can find in META-INF/statedb/couchdb/indexes/indexOwner.json.
+This asset transfer ledger example chaincode demonstrates packaged indexes
+which you can find in META-INF/statedb/couchdb/indexes/indexOwner.json and indexSize.json.
+Ad hoc queries are only accepted if one of the packaged indexes serves them, see assetQueryGuard.
 
 If you have access to the your peer's CouchDB state database in a development environment,
 you may want to iteratively test various indexes in support of your chaincode queries.  You
@@ -55,16 +56,16 @@ Example curl command line to define index in the CouchDB channel_chaincode datab
 curl -i -X POST -H "Content-Type: application/json" -d "{\"index\":{\"fields\":[\"docType\",\"owner\"]},\"name\":\"indexOwner\",\"ddoc\":\"indexOwnerDoc\",\"type\":\"json\"}" http://hostname:port/myc1_assets/_index
 
 
-Index for docType, owner, size (descending order).
+Index for docType, size.
 
 Example curl command line to define index in the CouchDB channel_chaincode database:
-curl -i -X POST -H "Content-Type: application/json" -d "{\"index\":{\"fields\":[{\"size\":\"desc\"},{\"docType\":\"desc\"},{\"owner\":\"desc\"}]},\"ddoc\":\"indexSizeSortDoc\", \"name\":\"indexSizeSortDesc\",\"type\":\"json\"}" http://hostname:port/myc1_assets/_index
+curl -i -X POST -H "Content-Type: application/json" -d "{\"index\":{\"fields\":[\"docType\",\"size\"]},\"name\":\"indexSize\",\"ddoc\":\"indexSizeDoc\",\"type\":\"json\"}" http://hostname:port/myc1_assets/_index
 
 Rich Query with index design doc and index name specified (Only supported if CouchDB is used as state database):
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssets","{\"selector\":{\"docType\":\"asset\",\"owner\":\"tom\"}, \"use_index\":[\"_design/indexOwnerDoc\", \"indexOwner\"]}"]}'
 
 Rich Query with index design doc specified only (Only supported if CouchDB is used as state database):
-peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssets","{\"selector\":{\"docType\":{\"$eq\":\"asset\"},\"owner\":{\"$eq\":\"tom\"},\"size\":{\"$gt\":0}},\"fields\":[\"docType\",\"owner\",\"size\"],\"sort\":[{\"size\":\"desc\"}],\"use_index\":\"_design/indexSizeSortDoc\"}"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssets","{\"selector\":{\"docType\":{\"$eq\":\"asset\"},\"owner\":{\"$eq\":\"tom\"},\"size\":{\"$gt\":0}},\"fields\":[\"docType\",\"owner\",\"size\"],\"sort\":[{\"docType\":\"desc\"},{\"size\":\"desc\"}],\"use_index\":\"_design/indexSizeDoc\"}"]}'
 */
 
 package main
@@ -78,6 +79,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery"
 )
 
 const index = "color~name"
@@ -96,6 +98,24 @@ type Asset struct {
 	AppraisedValue int    `json:"appraisedValue"`
 }
 
+// assetQueryGuard restricts the ad hoc queries of QueryAssets and QueryAssetsWithPagination
+// to assets, and requires them to use one of the indexes in META-INF/statedb/couchdb/indexes.
+// The indexes below are checked against the packaged index definitions by the tests.
+var assetQueryGuard = &richquery.Guard{
+	DocTypeField:  "docType",
+	DocType:       "asset",
+	AllowedFields: []string{"docType", "ID", "color", "size", "owner", "appraisedValue"},
+	MaxLimit:      100,
+	Indexes: []richquery.Index{
+		{DesignDoc: "indexOwnerDoc", Name: "indexOwner", Fields: []string{"docType", "owner"}},
+		{DesignDoc: "indexSizeDoc", Name: "indexSize", Fields: []string{"docType", "size"}},
+	},
+}
+
+// assetQueryFallback evaluates rich queries in the chaincode if the state database does not
+// support them. It reads at most 1000 keys, so it is only suitable for development
+var assetQueryFallback = &richquery.Fallback{MaxKeys: 1000}
+
 // HistoryQueryResult structure used for returning result of history query
 type HistoryQueryResult struct {
 	Record    *Asset    `json:"record"`
@@ -308,16 +328,31 @@ func (t *SimpleChaincode) QueryAssetsByOwner(ctx contractapi.TransactionContextI
 // Query string matching state database syntax is passed in and executed as is.
 // Supports ad hoc queries that can be defined at runtime by the client.
 // If this is not desired, follow the QueryAssetsForOwner example for parameterized queries.
+// The query must select assets using a packaged index, and its limit is capped by assetQueryGuard.
 // Only available on state databases that support rich query (e.g. CouchDB)
 // Example: Ad hoc rich query
 func (t *SimpleChaincode) QueryAssets(ctx contractapi.TransactionContextInterface, queryString string) ([]*Asset, error) {
-	return getQueryResultForQueryString(ctx, queryString)
+	queryString, err := assetQueryGuard.Check(queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := richquery.GetQueryResult(ctx.GetStub(), queryString, assetQueryFallback)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	// CouchDB ignores the limit of queries that are not paginated
+	return constructQueryResponseFromIterator(assetQueryGuard.Limit(resultsIterator))
 }
 
 // getQueryResultForQueryString executes the passed in query string.
 // The result set is built and returned as a byte array containing the JSON results.
+// If the state database does not support rich queries, the query is evaluated by the
+// chaincode against at most 1000 keys, which is only suitable for small data sets.
 func getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, queryString string) ([]*Asset, error) {
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	resultsIterator, err := richquery.GetQueryResult(ctx.GetStub(), queryString, assetQueryFallback)
 	if err != nil {
 		return nil, err
 	}
@@ -347,19 +382,25 @@ func (t *SimpleChaincode) GetAssetsByRangeWithPagination(ctx contractapi.Transac
 // The number of fetched records would be equal to or lesser than the specified page size.
 // Supports ad hoc queries that can be defined at runtime by the client.
 // If this is not desired, follow the QueryAssetsForOwner example for parameterized queries.
+// The query and page size are checked by assetQueryGuard like for QueryAssets.
 // Only available on state databases that support rich query (e.g. CouchDB)
 // Paginated queries are only valid for read only transactions.
 // Example: Pagination with Ad hoc Rich Query
 func (t *SimpleChaincode) QueryAssetsWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	queryString, err := assetQueryGuard.Check(queryString)
+	if err != nil {
+		return nil, err
+	}
 
-	return getQueryResultForQueryStringWithPagination(ctx, queryString, int32(pageSize), bookmark)
+	return getQueryResultForQueryStringWithPagination(ctx, queryString, assetQueryGuard.PageSize(int32(pageSize)), bookmark)
 }
 
 // getQueryResultForQueryStringWithPagination executes the passed in query string with
 // pagination info. The result set is built and returned as a byte array containing the JSON results.
+// Like getQueryResultForQueryString, the query is evaluated by the chaincode if needed.
 func getQueryResultForQueryStringWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
 
-	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	resultsIterator, responseMetadata, err := richquery.GetQueryResultWithPagination(ctx.GetStub(), queryString, pageSize, bookmark, assetQueryFallback)
 	if err != nil {
 		return nil, err
 	}
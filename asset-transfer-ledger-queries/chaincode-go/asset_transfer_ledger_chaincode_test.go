@@ -0,0 +1,80 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery/richquerytest"
+	"github.com/stretchr/testify/require"
+)
+
+// initLedgerContext returns a transaction context over a stub holding the assets of InitLedger,
+// that evaluates rich queries against its state
+func initLedgerContext(t *testing.T) contractapi.TransactionContextInterface {
+	ctx := new(contractapi.TransactionContext)
+	stub := richquerytest.NewMockStub("ledger", nil)
+	ctx.SetStub(stub)
+
+	stub.MockTransactionStart("tx1")
+	require.NoError(t, new(SimpleChaincode).InitLedger(ctx))
+	stub.MockTransactionEnd("tx1")
+	return ctx
+}
+
+func TestAssetQueryGuardMatchesPackagedIndexes(t *testing.T) {
+	indexes, err := richquerytest.ReadIndexes("META-INF/statedb/couchdb/indexes")
+	require.NoError(t, err)
+	require.NotEmpty(t, indexes)
+	require.ElementsMatch(t, indexes, assetQueryGuard.Indexes)
+}
+
+func TestQueryAssetsByOwner(t *testing.T) {
+	ctx := initLedgerContext(t)
+	chaincode := new(SimpleChaincode)
+
+	assets, err := chaincode.QueryAssetsByOwner(ctx, "Max")
+	require.NoError(t, err)
+	require.Equal(t, []*Asset{{DocType: "asset", ID: "asset4", Color: "yellow", Size: 10, Owner: "Max", AppraisedValue: 600}}, assets)
+
+	assets, err = chaincode.QueryAssetsByOwner(ctx, "Alice")
+	require.NoError(t, err)
+	require.Empty(t, assets)
+}
+
+func TestQueryAssets(t *testing.T) {
+	ctx := initLedgerContext(t)
+	chaincode := new(SimpleChaincode)
+
+	assets, err := chaincode.QueryAssets(ctx, `{"selector":{"docType":"asset","size":{"$gt":5}},"sort":[{"size":"desc"}],"limit":3}`)
+	require.NoError(t, err)
+	require.Len(t, assets, 3)
+	require.Equal(t, 15, assets[0].Size)
+	require.Equal(t, 15, assets[1].Size)
+	require.Equal(t, 10, assets[2].Size)
+
+	_, err = chaincode.QueryAssets(ctx, `{"selector":{"docType":"asset","color":"blue"}}`)
+	require.EqualError(t, err, "query is not served by any of the indexes packaged with the chaincode")
+}
+
+func TestQueryAssetsWithPagination(t *testing.T) {
+	ctx := initLedgerContext(t)
+	chaincode := new(SimpleChaincode)
+
+	query := `{"selector":{"docType":"asset","size":{"$gte":10}}}`
+	result, err := chaincode.QueryAssetsWithPagination(ctx, query, 3, "")
+	require.NoError(t, err)
+	require.Len(t, result.Records, 3)
+	require.Equal(t, []string{"asset3", "asset4", "asset5"}, []string{result.Records[0].ID, result.Records[1].ID, result.Records[2].ID})
+	require.Equal(t, int32(3), result.FetchedRecordsCount)
+	require.Equal(t, "asset6", result.Bookmark)
+
+	result, err = chaincode.QueryAssetsWithPagination(ctx, query, 3, result.Bookmark)
+	require.NoError(t, err)
+	require.Len(t, result.Records, 1)
+	require.Equal(t, "asset6", result.Records[0].ID)
+	require.Equal(t, "", result.Bookmark)
+}
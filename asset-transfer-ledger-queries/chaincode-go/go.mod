@@ -6,4 +6,8 @@ require (
 	github.com/golang/protobuf v1.3.2
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200511190512-bcfeb58dd83a
 	github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-samples/chaincode/richquery v0.0.0
+	github.com/stretchr/testify v1.5.1
 )
+
+replace github.com/hyperledger/fabric-samples/chaincode/richquery => ../../chaincode/richquery
@@ -0,0 +1,308 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const transferNegotiationObjectType = "transferNegotiation"
+
+const (
+	offerByBuyer = "buyer"
+	offerByOwner = "owner"
+)
+
+// TransferNegotiation tracks the negotiation of the appraised value between the owner and the buyer
+// of an asset. It is stored in the assetCollection, while the offered values are only stored in the
+// org specific collections of the buyer and the owner
+type TransferNegotiation struct {
+	ID          string `json:"assetID"`
+	BuyerMSP    string `json:"buyerMSP"`
+	OwnerMSP    string `json:"ownerMSP"`
+	Round       int    `json:"round"`
+	LastOfferBy string `json:"lastOfferBy"`
+	Accepted    bool   `json:"accepted"`
+}
+
+// CounterTransferOffer is used by the owner of the asset to answer the value proposed by the buyer
+// using AgreeToTransfer with a different appraised value. The counter offer replaces the appraised
+// value in the owners org specific collection. The buyer can accept the counter offer using
+// AcceptTransferOffer, or propose another value using AgreeToTransfer
+func (s *SmartContract) CounterTransferOffer(ctx contractapi.TransactionContextInterface) error {
+
+	valueJSON, err := getTransientAssetValue(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	err = verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("CounterTransferOffer cannot be performed: Error %v", err)
+	}
+
+	asset, err := s.ReadAsset(ctx, valueJSON.ID)
+	if err != nil {
+		return fmt.Errorf("error reading asset: %v", err)
+	}
+	if asset == nil {
+		return fmt.Errorf("%v does not exist", valueJSON.ID)
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+	if clientID != asset.Owner {
+		return fmt.Errorf("error: submitting client identity does not own asset")
+	}
+
+	negotiation, err := s.ReadTransferNegotiation(ctx, valueJSON.ID)
+	if err != nil {
+		return err
+	}
+	if negotiation == nil || negotiation.LastOfferBy != offerByBuyer {
+		return fmt.Errorf("there is no offer from a buyer to counter for %v", valueJSON.ID)
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed getting the client's MSPID: %v", err)
+	}
+
+	err = s.putAssetValue(ctx, valueJSON)
+	if err != nil {
+		return err
+	}
+
+	negotiation.OwnerMSP = clientMSPID
+	negotiation.Round++
+	negotiation.LastOfferBy = offerByOwner
+	negotiation.Accepted = false
+
+	return putTransferNegotiation(ctx, negotiation)
+}
+
+// AcceptTransferOffer is used by the owner to accept the value proposed by the buyer, or by the buyer
+// to accept the counter offer of the owner. The accepted value is stored in the org specific collection
+// of the client, after checking it against the hash of the value offered by the other party
+func (s *SmartContract) AcceptTransferOffer(ctx contractapi.TransactionContextInterface) error {
+
+	valueJSON, err := getTransientAssetValue(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	err = verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("AcceptTransferOffer cannot be performed: Error %v", err)
+	}
+
+	asset, err := s.ReadAsset(ctx, valueJSON.ID)
+	if err != nil {
+		return fmt.Errorf("error reading asset: %v", err)
+	}
+	if asset == nil {
+		return fmt.Errorf("%v does not exist", valueJSON.ID)
+	}
+
+	negotiation, err := s.ReadTransferNegotiation(ctx, valueJSON.ID)
+	if err != nil {
+		return err
+	}
+	if negotiation == nil {
+		return fmt.Errorf("there is no offer to accept for %v", valueJSON.ID)
+	}
+
+	transferAgreement, err := s.ReadTransferAgreement(ctx, valueJSON.ID)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	// The offer of the other party is the one to accept
+	var offerCollection string
+	switch {
+	case clientID == asset.Owner:
+		if negotiation.LastOfferBy != offerByBuyer {
+			return fmt.Errorf("the last offer for %v was made by the owner", valueJSON.ID)
+		}
+		offerCollection, err = s.buildCollectionName(ctx, negotiation.BuyerMSP)
+	case transferAgreement != nil && clientID == transferAgreement.BuyerID:
+		if negotiation.LastOfferBy != offerByOwner {
+			return fmt.Errorf("the last offer for %v was made by the buyer", valueJSON.ID)
+		}
+		offerCollection, err = s.buildCollectionName(ctx, negotiation.OwnerMSP)
+	default:
+		return fmt.Errorf("error: submitting client identity is not a party to the transfer of %v", valueJSON.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	valueJSONasBytes, err := json.Marshal(valueJSON)
+	if err != nil {
+		return fmt.Errorf("failed to marshal into JSON: %v", err)
+	}
+
+	offerHash, err := ctx.GetStub().GetPrivateDataHash(offerCollection, valueJSON.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get hash of offered value from collection %v: %v", offerCollection, err)
+	}
+	acceptedHash := sha256.Sum256(valueJSONasBytes)
+	if !bytes.Equal(offerHash, acceptedHash[:]) {
+		return fmt.Errorf("appraised value does not match the value offered by the %v in round %v", negotiation.LastOfferBy, negotiation.Round)
+	}
+
+	err = s.putAssetValue(ctx, valueJSON)
+	if err != nil {
+		return err
+	}
+
+	negotiation.Accepted = true
+
+	return putTransferNegotiation(ctx, negotiation)
+}
+
+// ReadTransferNegotiation reads the state of the negotiation of the appraised value of an asset
+func (s *SmartContract) ReadTransferNegotiation(ctx contractapi.TransactionContextInterface, assetID string) (*TransferNegotiation, error) {
+	log.Printf("ReadTransferNegotiation: collection %v, ID %v", assetCollection, assetID)
+	negotiationKey, err := ctx.GetStub().CreateCompositeKey(transferNegotiationObjectType, []string{assetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	negotiationJSON, err := ctx.GetStub().GetPrivateData(assetCollection, negotiationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TransferNegotiation: %v", err)
+	}
+	if negotiationJSON == nil {
+		log.Printf("TransferNegotiation for %v does not exist", assetID)
+		return nil, nil
+	}
+
+	var negotiation *TransferNegotiation
+	err = json.Unmarshal(negotiationJSON, &negotiation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+
+	return negotiation, nil
+}
+
+// getTransientAssetValue is an internal helper function to read the appraised value offered by the client
+func getTransientAssetValue(ctx contractapi.TransactionContextInterface) (*AssetPrivateDetails, error) {
+	// Value is private, therefore it gets passed in transient field
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+
+	valueJSONasBytes, ok := transientMap["asset_value"]
+	if !ok {
+		return nil, fmt.Errorf("asset_value key not found in the transient map")
+	}
+
+	var valueJSON AssetPrivateDetails
+	err = json.Unmarshal(valueJSONasBytes, &valueJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+
+	if len(valueJSON.ID) == 0 {
+		return nil, fmt.Errorf("assetID field must be a non-empty string")
+	}
+	if valueJSON.AppraisedValue <= 0 {
+		return nil, fmt.Errorf("appraisedValue field must be a positive integer")
+	}
+
+	return &valueJSON, nil
+}
+
+// putAssetValue is an internal helper function to store an offered value in the org specific
+// collection of the client. The value is marshaled the same way as in CreateAsset, so that
+// equal values have equal hashes
+func (s *SmartContract) putAssetValue(ctx contractapi.TransactionContextInterface, valueJSON *AssetPrivateDetails) error {
+	valueJSONasBytes, err := json.Marshal(valueJSON)
+	if err != nil {
+		return fmt.Errorf("failed to marshal into JSON: %v", err)
+	}
+
+	orgCollection, err := s.getCollectionName(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
+	}
+
+	log.Printf("Put: collection %v, ID %v", orgCollection, valueJSON.ID)
+	err = ctx.GetStub().PutPrivateData(orgCollection, valueJSON.ID, valueJSONasBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put asset value: %v", err)
+	}
+
+	return nil
+}
+
+// putTransferNegotiation is an internal helper function to store the state of a negotiation
+func putTransferNegotiation(ctx contractapi.TransactionContextInterface, negotiation *TransferNegotiation) error {
+	negotiationKey, err := ctx.GetStub().CreateCompositeKey(transferNegotiationObjectType, []string{negotiation.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	negotiationJSON, err := json.Marshal(negotiation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal into JSON: %v", err)
+	}
+
+	log.Printf("Put: collection %v, ID %v, Key %v", assetCollection, negotiation.ID, negotiationKey)
+	err = ctx.GetStub().PutPrivateData(assetCollection, negotiationKey, negotiationJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put transfer negotiation: %v", err)
+	}
+
+	return nil
+}
+
+// deleteTransferNegotiation is an internal helper function to remove the negotiation of an asset
+func deleteTransferNegotiation(ctx contractapi.TransactionContextInterface, assetID string) error {
+	negotiationKey, err := ctx.GetStub().CreateCompositeKey(transferNegotiationObjectType, []string{assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	return ctx.GetStub().DelPrivateData(assetCollection, negotiationKey)
+}
+
+// negotiationMismatchReason is an internal helper function describing why the values of the owner
+// and the buyer do not match, based on the state of the negotiation
+func (s *SmartContract) negotiationMismatchReason(ctx contractapi.TransactionContextInterface, assetID string) string {
+	negotiation, err := s.ReadTransferNegotiation(ctx, assetID)
+	if err != nil || negotiation == nil || negotiation.ID != assetID {
+		return "the buyer has not agreed to the appraised value of the owner"
+	}
+
+	switch negotiation.LastOfferBy {
+	case offerByBuyer:
+		return fmt.Sprintf("the owner has not accepted the value proposed by the buyer in round %v", negotiation.Round)
+	case offerByOwner:
+		return fmt.Sprintf("the buyer has not accepted the counter offer of the owner in round %v", negotiation.Round)
+	}
+
+	return "the buyer has not agreed to the appraised value of the owner"
+}
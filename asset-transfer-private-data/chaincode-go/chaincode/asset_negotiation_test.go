@@ -0,0 +1,169 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+package chaincode_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+For details on generating the mocks, see comments in the file asset_transfer_test.go
+*/
+var org1Asset = chaincode.Asset{
+	ID:    "id1",
+	Type:  "testfulasset",
+	Color: "gray",
+	Size:  7,
+	Owner: myOrg1Clientid,
+}
+
+func TestAgreeToTransferStartsNegotiationRound(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := chaincode.SmartContract{}
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 500})
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 1, chaincode.TransferNegotiation{ID: "id1", OwnerMSP: myOrg1Msp, Round: 2, LastOfferBy: "owner"})
+
+	err := assetTransferCC.AgreeToTransfer(transactionContext)
+	require.NoError(t, err)
+
+	calledCollection, _, calledWithBytes := chaincodeStub.PutPrivateDataArgsForCall(2)
+	require.Equal(t, assetCollectionName, calledCollection)
+	requireNegotiation(t, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, OwnerMSP: myOrg1Msp, Round: 3, LastOfferBy: "buyer"}, calledWithBytes)
+}
+
+func TestCounterTransferOffer(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+	counterOffer := &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 600}
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, counterOffer)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 1, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, Round: 1, LastOfferBy: "buyer"})
+
+	err := assetTransferCC.CounterTransferOffer(transactionContext)
+	require.NoError(t, err)
+
+	//the counter offer replaces the value in the owners collection
+	expectedBytes, err := json.Marshal(counterOffer)
+	require.NoError(t, err)
+	calledCollection, calledId, calledWithBytes := chaincodeStub.PutPrivateDataArgsForCall(0)
+	require.Equal(t, myOrg1PrivCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+	require.Equal(t, expectedBytes, calledWithBytes)
+
+	calledCollection, _, calledWithBytes = chaincodeStub.PutPrivateDataArgsForCall(1)
+	require.Equal(t, assetCollectionName, calledCollection)
+	requireNegotiation(t, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, OwnerMSP: myOrg1Msp, Round: 2, LastOfferBy: "owner"}, calledWithBytes)
+}
+
+func TestCounterTransferOfferBadInput(t *testing.T) {
+	assetTransferCC := chaincode.SmartContract{}
+
+	//only the owner can counter
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 600})
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	err := assetTransferCC.CounterTransferOffer(transactionContext)
+	require.EqualError(t, err, "error: submitting client identity does not own asset")
+
+	//the owner can not counter its own offer
+	transactionContext, chaincodeStub = prepMocksAsOrg1()
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 600})
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 1, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, OwnerMSP: myOrg1Msp, Round: 2, LastOfferBy: "owner"})
+	err = assetTransferCC.CounterTransferOffer(transactionContext)
+	require.EqualError(t, err, "there is no offer from a buyer to counter for id1")
+	require.Equal(t, 0, chaincodeStub.PutPrivateDataCallCount())
+}
+
+func TestAcceptTransferOfferByBuyer(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := chaincode.SmartContract{}
+	accepted := &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 600}
+	acceptedBytes := setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, accepted)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 1, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, OwnerMSP: myOrg1Msp, Round: 2, LastOfferBy: "owner"})
+	chaincodeStub.GetPrivateDataReturnsOnCall(2, []byte(myOrg2Clientid), nil)
+	acceptedHash := sha256.Sum256(acceptedBytes)
+	chaincodeStub.GetPrivateDataHashReturns(acceptedHash[:], nil)
+
+	err := assetTransferCC.AcceptTransferOffer(transactionContext)
+	require.NoError(t, err)
+
+	//the value is checked against the counter offer of the owner
+	calledCollection, calledId := chaincodeStub.GetPrivateDataHashArgsForCall(0)
+	require.Equal(t, myOrg1PrivCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+
+	calledCollection, _, calledWithBytes := chaincodeStub.PutPrivateDataArgsForCall(0)
+	require.Equal(t, myOrg2PrivCollection, calledCollection)
+	require.Equal(t, acceptedBytes, calledWithBytes)
+
+	calledCollection, _, calledWithBytes = chaincodeStub.PutPrivateDataArgsForCall(1)
+	require.Equal(t, assetCollectionName, calledCollection)
+	requireNegotiation(t, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, OwnerMSP: myOrg1Msp, Round: 2, LastOfferBy: "owner", Accepted: true}, calledWithBytes)
+}
+
+func TestAcceptTransferOfferByOwnerWithDifferentValue(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 600})
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 1, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, Round: 3, LastOfferBy: "buyer"})
+	chaincodeStub.GetPrivateDataReturnsOnCall(2, []byte(myOrg2Clientid), nil)
+	chaincodeStub.GetPrivateDataHashReturns([]byte("hash of 550"), nil)
+
+	err := assetTransferCC.AcceptTransferOffer(transactionContext)
+	require.EqualError(t, err, "appraised value does not match the value offered by the buyer in round 3")
+	calledCollection, _ := chaincodeStub.GetPrivateDataHashArgsForCall(0)
+	require.Equal(t, myOrg2PrivCollection, calledCollection)
+	require.Equal(t, 0, chaincodeStub.PutPrivateDataCallCount())
+}
+
+func TestAcceptTransferOfferByOtherClient(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks(myOrg2Msp, "myOrg2OtherUserid")
+	assetTransferCC := chaincode.SmartContract{}
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 600})
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 1, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, OwnerMSP: myOrg1Msp, Round: 2, LastOfferBy: "owner"})
+	chaincodeStub.GetPrivateDataReturnsOnCall(2, []byte(myOrg2Clientid), nil)
+
+	err := assetTransferCC.AcceptTransferOffer(transactionContext)
+	require.EqualError(t, err, "error: submitting client identity is not a party to the transfer of id1")
+}
+
+func TestTransferAssetReportsNegotiationState(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+	setReturnAssetOwnerInTransientMap(t, chaincodeStub, &assetTransferTransientInput{ID: "id1", BuyerMSP: myOrg2Msp})
+	setReturnPrivateDataOnCall(t, chaincodeStub, 0, org1Asset)
+	setReturnPrivateDataOnCall(t, chaincodeStub, 1, chaincode.TransferNegotiation{ID: "id1", BuyerMSP: myOrg2Msp, Round: 1, LastOfferBy: "buyer"})
+	chaincodeStub.GetPrivateDataHashReturnsOnCall(0, []byte("datahash1"), nil)
+	chaincodeStub.GetPrivateDataHashReturnsOnCall(1, []byte("datahash2"), nil)
+
+	err := assetTransferCC.TransferAsset(transactionContext)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the owner has not accepted the value proposed by the buyer in round 1")
+}
+
+func setReturnPrivateDataOnCall(t *testing.T, chaincodeStub *mocks.ChaincodeStub, i int, value interface{}) {
+	valueBytes, err := json.Marshal(value)
+	require.NoError(t, err)
+	chaincodeStub.GetPrivateDataReturnsOnCall(i, valueBytes, nil)
+}
+
+func requireNegotiation(t *testing.T, expected chaincode.TransferNegotiation, negotiationBytes []byte) {
+	var negotiation chaincode.TransferNegotiation
+	err := json.Unmarshal(negotiationBytes, &negotiation)
+	require.NoError(t, err)
+	require.Equal(t, expected, negotiation)
+}
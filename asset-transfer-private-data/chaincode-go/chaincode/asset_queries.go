@@ -11,9 +11,23 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery"
 )
 
+// PaginatedQueryResult structure used for returning paginated query results and metadata.
+// The bookmark is the key of the first asset of the next page, and is empty on the last page
+type PaginatedQueryResult struct {
+	Records             []*Asset `json:"records"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
+
+// assetQueryFallback evaluates rich queries in the chaincode if the state database does not
+// support them. It reads at most 1000 keys, so it is only suitable for development
+var assetQueryFallback = &richquery.Fallback{MaxKeys: 1000}
+
 // ReadAsset reads the information from collection
 func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, assetID string) (*Asset, error) {
 
@@ -60,6 +74,36 @@ func (s *SmartContract) ReadAssetPrivateDetails(ctx contractapi.TransactionConte
 	return assetDetails, nil
 }
 
+// GetAssetPrivateDetailsBatch reads the asset private details of several assets from the
+// organization specific collection of the client. Assets without private details are skipped
+func (s *SmartContract) GetAssetPrivateDetailsBatch(ctx contractapi.TransactionContextInterface, assetIDs []string) ([]*AssetPrivateDetails, error) {
+	// Verify that the client is submitting request to peer in their organization
+	err := verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetAssetPrivateDetailsBatch cannot be performed: Error %v", err)
+	}
+
+	orgCollection, err := s.getCollectionName(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to infer private collection name for the org: %v", err)
+	}
+
+	results := []*AssetPrivateDetails{}
+	for _, assetID := range assetIDs {
+		assetDetails, err := s.ReadAssetPrivateDetails(ctx, orgCollection, assetID)
+		if err != nil {
+			return nil, err
+		}
+		if assetDetails == nil {
+			continue
+		}
+
+		results = append(results, assetDetails)
+	}
+
+	return results, nil
+}
+
 // ReadTransferAgreement gets the buyer's identity from the transfer agreement from collection
 func (s *SmartContract) ReadTransferAgreement(ctx contractapi.TransactionContextInterface, assetID string) (*TransferAgreement, error) {
 	log.Printf("ReadTransferAgreement: collection %v, ID %v", assetCollection, assetID)
@@ -116,12 +160,35 @@ func (s *SmartContract) GetAssetByRange(ctx contractapi.TransactionContextInterf
 
 }
 
+// GetAssetByRangeWithPagination performs a range query based on the start and end keys provided,
+// returning at most pageSize assets. Private data range queries do not support pagination,
+// so the bookmark is used as the start key of the next page instead.
+func (s *SmartContract) GetAssetByRangeWithPagination(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be a positive integer")
+	}
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(assetCollection, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return getAssetPage(resultsIterator, pageSize)
+}
+
 // =======Rich queries =========================================================================
 // Two examples of rich queries are provided below (parameterized query and ad hoc query).
 // Rich queries pass a query string to the state database.
 // Rich queries are only supported by state database implementations
 //  that support rich query (e.g. CouchDB).
 // The query string is in the syntax of the underlying state database.
+// On other state databases (e.g. LevelDB) the richquery package evaluates the
+//  query in the chaincode instead, by reading the keys of the collection up to
+//  the limit of assetQueryFallback. This is only suitable for development and small data sets.
 // With rich queries there is no guarantee that the result set hasn't changed between
 //  endorsement time and commit time, aka 'phantom reads'.
 // Therefore, rich queries should not be used in update transactions, unless the
@@ -138,15 +205,74 @@ func (s *SmartContract) GetAssetByRange(ctx contractapi.TransactionContextInterf
 // =========================================================================================
 func (s *SmartContract) QueryAssetByOwner(ctx contractapi.TransactionContextInterface, assetType string, owner string) ([]*Asset, error) {
 
-	queryString := fmt.Sprintf("{\"selector\":{\"objectType\":\"%v\",\"owner\":\"%v\"}}", assetType, owner)
+	// Build the query as JSON so that the parameters cannot alter the selector
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"objectType": assetType,
+			"owner":      owner,
+		},
+	}
+	queryString, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %v", err)
+	}
 
-	queryResults, err := s.getQueryResultForQueryString(ctx, queryString)
+	queryResults, err := s.getQueryResultForQueryString(ctx, string(queryString))
 	if err != nil {
 		return nil, err
 	}
 	return queryResults, nil
 }
 
+// QueryAssetByOwnerWithPagination queries for assets based on assetType, owner, returning at most
+// pageSize assets ordered by asset ID. The bookmark is the asset ID to resume the query from.
+// Only available on state databases that support rich query (e.g. CouchDB)
+func (s *SmartContract) QueryAssetByOwnerWithPagination(ctx contractapi.TransactionContextInterface, assetType string, owner string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be a positive integer")
+	}
+
+	// The sort is served by the indexOwnerAssetID index
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"objectType": assetType,
+			"owner":      owner,
+			"assetID":    map[string]interface{}{"$gte": bookmark},
+		},
+		"sort": []map[string]string{
+			{"objectType": "asc"},
+			{"owner": "asc"},
+			{"assetID": "asc"},
+		},
+		"use_index": []string{"indexOwnerAssetIDDoc", "indexOwnerAssetID"},
+	}
+	queryString, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal query: %v", err)
+	}
+
+	resultsIterator, err := richquery.GetPrivateDataQueryResult(ctx.GetStub(), assetCollection, string(queryString), assetQueryFallback)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return getAssetPage(resultsIterator, pageSize)
+}
+
+// QueryMyAssets queries for the assets of assetType owned by the submitting client identity,
+// returning at most pageSize assets. See QueryAssetByOwnerWithPagination.
+// Only available on state databases that support rich query (e.g. CouchDB)
+func (s *SmartContract) QueryMyAssets(ctx contractapi.TransactionContextInterface, assetType string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	// Get ID of submitting client identity
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	return s.QueryAssetByOwnerWithPagination(ctx, assetType, clientID, pageSize, bookmark)
+}
+
 // QueryAssets uses a query string to perform a query for assets.
 // Query string matching state database syntax is passed in and executed as is.
 // Supports ad hoc queries that can be defined at runtime by the client.
@@ -164,7 +290,7 @@ func (s *SmartContract) QueryAssets(ctx contractapi.TransactionContextInterface,
 // getQueryResultForQueryString executes the passed in query string.
 func (s *SmartContract) getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, queryString string) ([]*Asset, error) {
 
-	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(assetCollection, queryString)
+	resultsIterator, err := richquery.GetPrivateDataQueryResult(ctx.GetStub(), assetCollection, queryString, assetQueryFallback)
 	if err != nil {
 		return nil, err
 	}
@@ -188,3 +314,34 @@ func (s *SmartContract) getQueryResultForQueryString(ctx contractapi.Transaction
 	}
 	return results, nil
 }
+
+// getAssetPage reads at most pageSize assets from the iterator. If there are more results,
+// the key of the next asset is returned as the bookmark of the next page.
+func getAssetPage(resultsIterator shim.StateQueryIteratorInterface, pageSize int) (*PaginatedQueryResult, error) {
+	result := &PaginatedQueryResult{
+		Records: []*Asset{},
+	}
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(result.Records) == pageSize {
+			result.Bookmark = response.Key
+			break
+		}
+
+		var asset *Asset
+		err = json.Unmarshal(response.Value, &asset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+
+		result.Records = append(result.Records, asset)
+	}
+	result.FetchedRecordsCount = int32(len(result.Records))
+
+	return result, nil
+}
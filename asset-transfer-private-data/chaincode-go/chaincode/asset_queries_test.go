@@ -14,6 +14,7 @@ import (
 
 	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode"
 	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode/mocks"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery/richquerytest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -88,64 +89,58 @@ func TestReadTransferAgreement(t *testing.T) {
 }
 
 func TestQueryAssetByOwner(t *testing.T) {
-	transactionContext, chaincodeStub := prepMocksAsOrg1()
-
-	asset := &chaincode.Asset{Type: "valuableasset", ID: "asset1", Owner: "user1"}
-	asset1Bytes, err := json.Marshal(asset)
-	require.NoError(t, err)
-
-	iterator := &mocks.StateQueryIterator{}
-	iterator.HasNextReturnsOnCall(0, true)
-	iterator.HasNextReturnsOnCall(1, false)
-	iterator.NextReturns(&queryresult.KV{Value: asset1Bytes}, nil)
-	chaincodeStub.GetPrivateDataQueryResultReturns(iterator, nil)
+	asset1 := &chaincode.Asset{Type: "valuableasset", ID: "asset1", Color: "blue", Size: 5, Owner: "user1"}
+	asset2 := &chaincode.Asset{Type: "valuableasset", ID: "asset2", Color: "red", Size: 10, Owner: "user2"}
+	asset3 := &chaincode.Asset{Type: "otherasset", ID: "asset3", Color: "blue", Size: 15, Owner: "user1"}
+	transactionContext := prepRichQueryMocksAsOrg1(t, []*chaincode.Asset{asset1, asset2, asset3})
 
 	assetTransferCC := &chaincode.SmartContract{}
 	assets, err := assetTransferCC.QueryAssetByOwner(transactionContext, "valuableasset", "user1")
 	require.NoError(t, err)
-	require.Equal(t, []*chaincode.Asset{asset}, assets)
+	require.Equal(t, []*chaincode.Asset{asset1}, assets)
 
+	// Quotes in the parameters cannot change the selector
+	assets, err = assetTransferCC.QueryAssetByOwner(transactionContext, "valuableasset", `user1","owner":{"$gt":null}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{}, assets)
+
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	iterator := &mocks.StateQueryIterator{}
 	iterator.HasNextReturns(true)
 	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
+	chaincodeStub.GetPrivateDataQueryResultReturns(iterator, nil)
 	assets, err = assetTransferCC.QueryAssetByOwner(transactionContext, "valuableasset", "user1")
 	require.EqualError(t, err, "failed retrieving next item")
 	require.Nil(t, assets)
-
 }
 
 func TestQueryAssets(t *testing.T) {
-	transactionContext, chaincodeStub := prepMocksAsOrg1()
-	//Iterator with no records
-	iterator := &mocks.StateQueryIterator{}
-	iterator.HasNextReturns(false)
-	chaincodeStub.GetPrivateDataQueryResultReturns(iterator, nil)
+	asset1 := &chaincode.Asset{Type: "valuableasset", ID: "asset1", Color: "blue", Size: 5, Owner: "user1"}
+	asset2 := &chaincode.Asset{Type: "valuableasset", ID: "asset2", Color: "red", Size: 10, Owner: "user1"}
+	asset3 := &chaincode.Asset{Type: "valuableasset", ID: "asset3", Color: "blue", Size: 15, Owner: "user2"}
+	transactionContext := prepRichQueryMocksAsOrg1(t, []*chaincode.Asset{asset1, asset2, asset3})
 
 	assetTransferCC := &chaincode.SmartContract{}
-	assets, err := assetTransferCC.QueryAssets(transactionContext, "querystr")
+	assets, err := assetTransferCC.QueryAssets(transactionContext, `{"selector":{"color":"blue"},"sort":[{"size":"desc"}]}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset3, asset1}, assets)
+
+	//Query with no matching records
+	assets, err = assetTransferCC.QueryAssets(transactionContext, `{"selector":{"color":"green"}}`)
 	require.NoError(t, err)
 	require.Equal(t, []*chaincode.Asset{}, assets)
 
-	iterator = &mocks.StateQueryIterator{}
+	_, err = assetTransferCC.QueryAssets(transactionContext, "querystr")
+	require.Error(t, err)
+
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	iterator := &mocks.StateQueryIterator{}
 	chaincodeStub.GetPrivateDataQueryResultReturns(iterator, nil)
 	iterator.HasNextReturns(true)
 	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
 	assets, err = assetTransferCC.QueryAssets(transactionContext, "querystr")
 	require.EqualError(t, err, "failed retrieving next item")
 	require.Nil(t, assets)
-
-	asset := &chaincode.Asset{Type: "valuableasset", ID: "asset1", Owner: "user1"}
-	asset1Bytes, err := json.Marshal(asset)
-	require.NoError(t, err)
-
-	iterator = &mocks.StateQueryIterator{}
-	chaincodeStub.GetPrivateDataQueryResultReturns(iterator, nil)
-	iterator.HasNextReturnsOnCall(0, true)
-	iterator.HasNextReturnsOnCall(1, false)
-	iterator.NextReturns(&queryresult.KV{Value: asset1Bytes}, nil)
-
-	assets, err = assetTransferCC.QueryAssets(transactionContext, "querystr")
-	require.NoError(t, err)
-	require.Equal(t, []*chaincode.Asset{asset}, assets)
 }
 
 func TestGetAssetByRange(t *testing.T) {
@@ -183,3 +178,141 @@ func TestGetAssetByRange(t *testing.T) {
 	require.Equal(t, []*chaincode.Asset{asset}, assets)
 
 }
+
+func TestGetAssetByRangeWithPagination(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := &chaincode.SmartContract{}
+
+	_, err := assetTransferCC.GetAssetByRangeWithPagination(transactionContext, "asset0", "asset9", 0, "")
+	require.EqualError(t, err, "pageSize must be a positive integer")
+
+	assets := []*chaincode.Asset{
+		{Type: "valuableasset", ID: "asset1", Owner: "user1"},
+		{Type: "valuableasset", ID: "asset2", Owner: "user1"},
+		{Type: "valuableasset", ID: "asset3", Owner: "user2"},
+	}
+	iterator := setReturnAssetsInIterator(t, assets)
+	chaincodeStub.GetPrivateDataByRangeReturns(iterator, nil)
+
+	result, err := assetTransferCC.GetAssetByRangeWithPagination(transactionContext, "asset0", "asset9", 2, "")
+	require.NoError(t, err)
+	require.Equal(t, assets[:2], result.Records)
+	require.Equal(t, int32(2), result.FetchedRecordsCount)
+	require.Equal(t, "asset3", result.Bookmark)
+	require.Equal(t, 1, iterator.CloseCallCount())
+
+	// The bookmark is used as start key of the next page
+	iterator = setReturnAssetsInIterator(t, assets[2:])
+	chaincodeStub.GetPrivateDataByRangeReturns(iterator, nil)
+	result, err = assetTransferCC.GetAssetByRangeWithPagination(transactionContext, "asset0", "asset9", 2, "asset3")
+	require.NoError(t, err)
+	require.Equal(t, assets[2:], result.Records)
+	require.Equal(t, "", result.Bookmark)
+	collection, startKey, endKey := chaincodeStub.GetPrivateDataByRangeArgsForCall(1)
+	require.Equal(t, assetCollectionName, collection)
+	require.Equal(t, "asset3", startKey)
+	require.Equal(t, "asset9", endKey)
+}
+
+func TestQueryMyAssets(t *testing.T) {
+	assets := []*chaincode.Asset{
+		{Type: "valuableasset", ID: "asset1", Owner: myOrg1Clientid},
+		{Type: "valuableasset", ID: "asset2", Owner: myOrg2Clientid},
+		{Type: "valuableasset", ID: "asset3", Owner: myOrg1Clientid},
+		{Type: "valuableasset", ID: "asset4", Owner: myOrg1Clientid},
+	}
+	transactionContext := prepRichQueryMocksAsOrg1(t, assets)
+	assetTransferCC := &chaincode.SmartContract{}
+
+	result, err := assetTransferCC.QueryMyAssets(transactionContext, "valuableasset", 1, "")
+	require.NoError(t, err)
+	require.Equal(t, assets[:1], result.Records)
+	require.Equal(t, "asset3", result.Bookmark)
+
+	result, err = assetTransferCC.QueryMyAssets(transactionContext, "valuableasset", 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, assets[2:], result.Records)
+	require.Equal(t, "", result.Bookmark)
+}
+
+func TestGetAssetPrivateDetailsBatch(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := &chaincode.SmartContract{}
+
+	details := &chaincode.AssetPrivateDetails{ID: "asset1", AppraisedValue: 500}
+	detailsBytes, err := json.Marshal(details)
+	require.NoError(t, err)
+	chaincodeStub.GetPrivateDataReturnsOnCall(0, detailsBytes, nil)
+	chaincodeStub.GetPrivateDataReturnsOnCall(1, nil, nil)
+
+	results, err := assetTransferCC.GetAssetPrivateDetailsBatch(transactionContext, []string{"asset1", "asset2"})
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.AssetPrivateDetails{details}, results)
+	for i := 0; i < 2; i++ {
+		collection, _ := chaincodeStub.GetPrivateDataArgsForCall(i)
+		require.Equal(t, myOrg1PrivCollection, collection)
+	}
+
+	chaincodeStub.GetPrivateDataReturnsOnCall(2, nil, fmt.Errorf("collection not found"))
+	_, err = assetTransferCC.GetAssetPrivateDetailsBatch(transactionContext, []string{"asset1"})
+	require.EqualError(t, err, "failed to read asset details: collection not found")
+}
+
+func TestQueryAssetsOnLevelDB(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	chaincodeStub.GetPrivateDataQueryResultReturns(nil, fmt.Errorf("ExecuteQuery not supported for leveldb"))
+
+	//the query is evaluated against all the assets of the collection
+	asset1 := &chaincode.Asset{Type: "valuableasset", ID: "asset1", Color: "blue", Size: 5, Owner: "user1"}
+	asset2 := &chaincode.Asset{Type: "valuableasset", ID: "asset2", Color: "red", Size: 10, Owner: "user1"}
+	asset3 := &chaincode.Asset{Type: "valuableasset", ID: "asset3", Color: "blue", Size: 15, Owner: "user2"}
+	chaincodeStub.GetPrivateDataByRangeReturns(setReturnAssetsInIterator(t, []*chaincode.Asset{asset1, asset2, asset3}), nil)
+
+	assetTransferCC := &chaincode.SmartContract{}
+	assets, err := assetTransferCC.QueryAssets(transactionContext, `{"selector":{"color":"blue"},"sort":[{"size":"desc"}]}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset3, asset1}, assets)
+	collection, startKey, endKey := chaincodeStub.GetPrivateDataByRangeArgsForCall(0)
+	require.Equal(t, assetCollectionName, collection)
+	require.Equal(t, "", startKey)
+	require.Equal(t, "", endKey)
+
+	chaincodeStub.GetPrivateDataByRangeReturns(setReturnAssetsInIterator(t, []*chaincode.Asset{asset1, asset2, asset3}), nil)
+	result, err := assetTransferCC.QueryAssetByOwnerWithPagination(transactionContext, "valuableasset", "user1", 1, "")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset1}, result.Records)
+	require.Equal(t, "asset2", result.Bookmark)
+
+	_, err = assetTransferCC.QueryAssets(transactionContext, `{"selector":{"size":{"$near":5}}}`)
+	require.EqualError(t, err, "unsupported operator $near")
+}
+
+// prepRichQueryMocksAsOrg1 returns the transaction context of a client of Org1 over a stub holding
+// the assets in the asset collection, that evaluates rich queries against the collection
+func prepRichQueryMocksAsOrg1(t *testing.T, assets []*chaincode.Asset) *mocks.TransactionContext {
+	transactionContext, _ := prepMocksAsOrg1()
+
+	stub := richquerytest.NewMockStub("private", nil)
+	stub.MockTransactionStart("tx1")
+	for _, asset := range assets {
+		assetBytes, err := json.Marshal(asset)
+		require.NoError(t, err)
+		require.NoError(t, stub.PutPrivateData(assetCollectionName, asset.ID, assetBytes))
+	}
+	stub.MockTransactionEnd("tx1")
+
+	transactionContext.GetStubReturns(stub)
+	return transactionContext
+}
+
+func setReturnAssetsInIterator(t *testing.T, assets []*chaincode.Asset) *mocks.StateQueryIterator {
+	iterator := &mocks.StateQueryIterator{}
+	for i, asset := range assets {
+		assetBytes, err := json.Marshal(asset)
+		require.NoError(t, err)
+		iterator.HasNextReturnsOnCall(i, true)
+		iterator.NextReturnsOnCall(i, &queryresult.KV{Key: asset.ID, Value: assetBytes}, nil)
+	}
+	iterator.HasNextReturnsOnCall(len(assets), false)
+	return iterator
+}
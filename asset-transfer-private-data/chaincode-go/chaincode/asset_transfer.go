@@ -19,11 +19,43 @@ import (
 const assetCollection = "assetCollection"
 const transferAgreementObjectType = "transferAgreement"
 
+// collectionModeKey is the public state key recording which org specific collections are used
+const collectionModeKey = "collectionMode"
+const implicitCollectionMode = "implicit"
+
 // SmartContract of this fabric sample
 type SmartContract struct {
 	contractapi.Contract
 }
 
+// InitImplicitCollections stores the org specific data in the implicit collection of each org,
+// instead of the <MSPID>PrivateCollection defined in collections_config.json. Implicit collections
+// exist for every org on the channel, so any number of orgs can take part in transfers.
+// The mode is recorded on the ledger so that the peers of all orgs use the same collections.
+// It is meant to be invoked as the init function of the chaincode, and cannot be set once
+// assets have been created since their appraised values would be left in the other collections
+func (s *SmartContract) InitImplicitCollections(ctx contractapi.TransactionContextInterface) error {
+	mode, err := ctx.GetStub().GetState(collectionModeKey)
+	if err != nil {
+		return fmt.Errorf("failed to read collection mode: %v", err)
+	}
+	if mode != nil {
+		return fmt.Errorf("collection mode is already set to %s", mode)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(assetCollection, "", "")
+	if err != nil {
+		return fmt.Errorf("failed to read assets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	if resultsIterator.HasNext() {
+		return fmt.Errorf("collection mode cannot be changed after assets have been created")
+	}
+
+	return ctx.GetStub().PutState(collectionModeKey, []byte(implicitCollectionMode))
+}
+
 // Asset describes main asset details that are visible to all organizations
 type Asset struct {
 	Type  string `json:"objectType"` //Type is used to distinguish the various types of objects in state database
@@ -149,7 +181,7 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface)
 	}
 
 	// Get collection name for this organization.
-	orgCollection, err := getCollectionName(ctx)
+	orgCollection, err := s.getCollectionName(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
@@ -164,9 +196,10 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface)
 }
 
 // AgreeToTransfer is used by the potential buyer of the asset to agree to the
-// asset value. The agreed to appraisal value is stored in the buying orgs
-// org specifc collection, while the the buyer client ID is stored in the asset collection
-// using a composite key
+// asset value, or to propose a different value to the owner. The agreed to appraisal value
+// is stored in the buying orgs org specifc collection, while the the buyer client ID is stored
+// in the asset collection using a composite key. Each call starts a new round of the negotiation,
+// see CounterTransferOffer and AcceptTransferOffer
 func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterface) error {
 
 	// Get ID of submitting client identity
@@ -176,30 +209,9 @@ func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterf
 	}
 
 	// Value is private, therefore it gets passed in transient field
-	transientMap, err := ctx.GetStub().GetTransient()
+	valueJSON, err := getTransientAssetValue(ctx)
 	if err != nil {
-		return fmt.Errorf("error getting transient: %v", err)
-	}
-
-	// Persist the JSON bytes as-is so that there is no risk of nondeterministic marshaling.
-	valueJSONasBytes, ok := transientMap["asset_value"]
-	if !ok {
-		return fmt.Errorf("asset_value key not found in the transient map")
-	}
-
-	// Unmarshal the tranisent map to get the asset ID.
-	var valueJSON AssetPrivateDetails
-	err = json.Unmarshal(valueJSONasBytes, &valueJSON)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %v", err)
-	}
-
-	// Do some error checking since we get the chance
-	if len(valueJSON.ID) == 0 {
-		return fmt.Errorf("assetID field must be a non-empty string")
-	}
-	if valueJSON.AppraisedValue <= 0 {
-		return fmt.Errorf("appraisedValue field must be a positive integer")
+		return err
 	}
 
 	// Read asset from the private data collection
@@ -216,17 +228,10 @@ func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("AgreeToTransfer cannot be performed: Error %v", err)
 	}
 
-	// Get collection name for this organization. Needs to be read by a member of the organization.
-	orgCollection, err := getCollectionName(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
-	}
-
-	log.Printf("AgreeToTransfer Put: collection %v, ID %v", orgCollection, valueJSON.ID)
 	// Put agreed value in the org specifc private data collection
-	err = ctx.GetStub().PutPrivateData(orgCollection, valueJSON.ID, valueJSONasBytes)
+	err = s.putAssetValue(ctx, valueJSON)
 	if err != nil {
-		return fmt.Errorf("failed to put asset bid: %v", err)
+		return err
 	}
 
 	// Create agreeement that indicates which identity has agreed to purchase
@@ -243,10 +248,30 @@ func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("failed to put asset bid: %v", err)
 	}
 
-	return nil
+	// Record the proposal of the buyer as the next round of the negotiation
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed getting the client's MSPID: %v", err)
+	}
+
+	negotiation, err := s.ReadTransferNegotiation(ctx, valueJSON.ID)
+	if err != nil {
+		return err
+	}
+	if negotiation == nil {
+		negotiation = &TransferNegotiation{ID: valueJSON.ID}
+	}
+	negotiation.BuyerMSP = clientMSPID
+	negotiation.Round++
+	negotiation.LastOfferBy = offerByBuyer
+	negotiation.Accepted = false
+
+	return putTransferNegotiation(ctx, negotiation)
 }
 
-// TransferAsset transfers the asset to the new owner by setting a new owner ID
+// TransferAsset transfers the asset to the new owner by setting a new owner ID.
+// The appraised value is removed from the seller's org collection, unless
+// retainPrivateDetails is set in the transient input
 func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface) error {
 
 	transientMap, err := ctx.GetStub().GetTransient()
@@ -261,8 +286,9 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 	}
 
 	type assetTransferTransientInput struct {
-		ID       string `json:"assetID"`
-		BuyerMSP string `json:"buyerMSP"`
+		ID                   string `json:"assetID"`
+		BuyerMSP             string `json:"buyerMSP"`
+		RetainPrivateDetails bool   `json:"retainPrivateDetails"`
 	}
 
 	var assetTransferInput assetTransferTransientInput
@@ -321,15 +347,18 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 	}
 
 	// Get collection name for this organization
-	ownersCollection, err := getCollectionName(ctx)
+	ownersCollection, err := s.getCollectionName(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
 
 	// Delete the asset appraised value from this organization's private data collection
-	err = ctx.GetStub().DelPrivateData(ownersCollection, assetTransferInput.ID)
-	if err != nil {
-		return err
+	if !assetTransferInput.RetainPrivateDetails {
+		log.Printf("TransferAsset Delete: collection %v, ID %v", ownersCollection, assetTransferInput.ID)
+		err = ctx.GetStub().DelPrivateData(ownersCollection, assetTransferInput.ID)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Delete the transfer agreement from the asset collection
@@ -343,6 +372,12 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 		return err
 	}
 
+	// The negotiation ends with the transfer
+	err = deleteTransferNegotiation(ctx, assetTransferInput.ID)
+	if err != nil {
+		return err
+	}
+
 	return nil
 
 }
@@ -367,12 +402,15 @@ func (s *SmartContract) verifyAgreement(ctx contractapi.TransactionContextInterf
 	// Check 2: verify that the buyer has agreed to the appraised value
 
 	// Get collection names
-	collectionOwner, err := getCollectionName(ctx) // get owner collection from caller identity
+	collectionOwner, err := s.getCollectionName(ctx) // get owner collection from caller identity
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
 
-	collectionBuyer := buyerMSP + "PrivateCollection" // get buyers collection
+	collectionBuyer, err := s.buildCollectionName(ctx, buyerMSP) // get buyers collection
+	if err != nil {
+		return err
+	}
 
 	// Get hash of owners agreed to value
 	ownerAppraisedValueHash, err := ctx.GetStub().GetPrivateDataHash(collectionOwner, assetID)
@@ -394,7 +432,7 @@ func (s *SmartContract) verifyAgreement(ctx contractapi.TransactionContextInterf
 
 	// Verify that the two hashes match
 	if !bytes.Equal(ownerAppraisedValueHash, buyerAppraisedValueHash) {
-		return fmt.Errorf("hash for appraised value for owner %x does not value for seller %x", ownerAppraisedValueHash, buyerAppraisedValueHash)
+		return fmt.Errorf("hash for appraised value for owner %x does not value for seller %x: %v", ownerAppraisedValueHash, buyerAppraisedValueHash, s.negotiationMismatchReason(ctx, assetID))
 	}
 
 	return nil
@@ -403,15 +441,95 @@ func (s *SmartContract) verifyAgreement(ctx contractapi.TransactionContextInterf
 // DeleteAsset can be used by the owner of the asset to delete the asset
 func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface) error {
 
+	assetID, ownerCollection, err := s.readAssetToDelete(ctx, "asset_delete", "DeleteAsset")
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Deleting Asset: %v", assetID)
+	valAsbytes, err := ctx.GetStub().GetPrivateData(assetCollection, assetID) //get the asset from chaincode state
+	if err != nil {
+		return fmt.Errorf("failed to read asset: %v", err)
+	}
+	if valAsbytes == nil {
+		return fmt.Errorf("asset not found: %v", assetID)
+	}
+
+	//check the asset is in the caller org's private collection
+	valAsbytes, err = ctx.GetStub().GetPrivateData(ownerCollection, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to read asset from owner's Collection: %v", err)
+	}
+	if valAsbytes == nil {
+		return fmt.Errorf("asset not found in owner's private Collection %v: %v", ownerCollection, assetID)
+	}
+
+	return delAssetRecords(ctx, assetID, ownerCollection)
+}
+
+// DeleteAssetPrivateRecords can be used by the owner of the asset to remove all private records of the asset
+// from the owner's collections: the asset and any pending transfer agreement in the assetCollection,
+// and the appraised value in the owner's org collection. Unlike DeleteAsset, the appraised value does
+// not need to exist, since it may already have expired from the org collection (see blockToLive in
+// collections_config.json).
+// The records are deleted, not purged: the private data remains in the private data history of the
+// peers and its hashes remain on the channel ledger. Purging private data requires Fabric v2.5 or later.
+func (s *SmartContract) DeleteAssetPrivateRecords(ctx contractapi.TransactionContextInterface) error {
+
+	assetID, ownerCollection, err := s.readAssetToDelete(ctx, "asset_private_delete", "DeleteAssetPrivateRecords")
+	if err != nil {
+		return err
+	}
+
+	asset, err := s.ReadAsset(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("error reading asset: %v", err)
+	}
+	if asset == nil {
+		return fmt.Errorf("%v does not exist", assetID)
+	}
+
+	// Only the owner can delete the private records of the asset
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+	if clientID != asset.Owner {
+		return fmt.Errorf("error: submitting client identity does not own asset")
+	}
+
+	log.Printf("Deleting private records of Asset: %v", assetID)
+	err = delAssetRecords(ctx, assetID, ownerCollection)
+	if err != nil {
+		return err
+	}
+
+	// Remove the pending transfer agreement and negotiation, if any
+	transferAgreeKey, err := ctx.GetStub().CreateCompositeKey(transferAgreementObjectType, []string{assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	err = ctx.GetStub().DelPrivateData(assetCollection, transferAgreeKey)
+	if err != nil {
+		return err
+	}
+
+	return deleteTransferNegotiation(ctx, assetID)
+}
+
+// readAssetToDelete returns the ID of the asset to delete, passed in the transient field under
+// transientKey, and the collection of the client's org, which must be the org of the peer
+func (s *SmartContract) readAssetToDelete(ctx contractapi.TransactionContextInterface, transientKey string, function string) (string, string, error) {
+
 	transientMap, err := ctx.GetStub().GetTransient()
 	if err != nil {
-		return fmt.Errorf("Error getting transient: %v", err)
+		return "", "", fmt.Errorf("Error getting transient: %v", err)
 	}
 
 	// Asset properties are private, therefore they get passed in transient field
-	transientDeleteJSON, ok := transientMap["asset_delete"]
+	transientDeleteJSON, ok := transientMap[transientKey]
 	if !ok {
-		return fmt.Errorf("asset to delete not found in the transient map")
+		return "", "", fmt.Errorf("asset to delete not found in the transient map")
 	}
 
 	type assetDelete struct {
@@ -421,56 +539,37 @@ func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface)
 	var assetDeleteInput assetDelete
 	err = json.Unmarshal(transientDeleteJSON, &assetDeleteInput)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %v", err)
+		return "", "", fmt.Errorf("failed to unmarshal JSON: %v", err)
 	}
 
 	if len(assetDeleteInput.ID) == 0 {
-		return fmt.Errorf("assetID field must be a non-empty string")
+		return "", "", fmt.Errorf("assetID field must be a non-empty string")
 	}
 
 	// Verify that the client is submitting request to peer in their organization
 	err = verifyClientOrgMatchesPeerOrg(ctx)
 	if err != nil {
-		return fmt.Errorf("DeleteAsset cannot be performed: Error %v", err)
+		return "", "", fmt.Errorf("%s cannot be performed: Error %v", function, err)
 	}
 
-	log.Printf("Deleting Asset: %v", assetDeleteInput.ID)
-	valAsbytes, err := ctx.GetStub().GetPrivateData(assetCollection, assetDeleteInput.ID) //get the asset from chaincode state
+	ownerCollection, err := s.getCollectionName(ctx) // Get owners collection
 	if err != nil {
-		return fmt.Errorf("failed to read asset: %v", err)
-	}
-	if valAsbytes == nil {
-		return fmt.Errorf("asset not found: %v", assetDeleteInput.ID)
+		return "", "", fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
 
-	ownerCollection, err := getCollectionName(ctx) // Get owners collection
-	if err != nil {
-		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
-	}
-
-	//check the asset is in the caller org's private collection
-	valAsbytes, err = ctx.GetStub().GetPrivateData(ownerCollection, assetDeleteInput.ID)
-	if err != nil {
-		return fmt.Errorf("failed to read asset from owner's Collection: %v", err)
-	}
-	if valAsbytes == nil {
-		return fmt.Errorf("asset not found in owner's private Collection %v: %v", ownerCollection, assetDeleteInput.ID)
-	}
+	return assetDeleteInput.ID, ownerCollection, nil
+}
 
+// delAssetRecords deletes an asset from the assetCollection and its private details from the owner's collection
+func delAssetRecords(ctx contractapi.TransactionContextInterface, assetID string, ownerCollection string) error {
 	// delete the asset from state
-	err = ctx.GetStub().DelPrivateData(assetCollection, assetDeleteInput.ID)
+	err := ctx.GetStub().DelPrivateData(assetCollection, assetID)
 	if err != nil {
 		return fmt.Errorf("failed to delete state: %v", err)
 	}
 
 	// Finally, delete private details of asset
-	err = ctx.GetStub().DelPrivateData(ownerCollection, assetDeleteInput.ID)
-	if err != nil {
-		return err
-	}
-
-	return nil
-
+	return ctx.GetStub().DelPrivateData(ownerCollection, assetID)
 }
 
 // DeleteTranferAgreement can be used by the buyer to withdraw a proposal from
@@ -508,7 +607,7 @@ func (s *SmartContract) DeleteTranferAgreement(ctx contractapi.TransactionContex
 		return fmt.Errorf("DeleteTranferAgreement cannot be performed: Error %v", err)
 	}
 	// Delete private details of agreement
-	orgCollection, err := getCollectionName(ctx) // Get proposers collection.
+	orgCollection, err := s.getCollectionName(ctx) // Get proposers collection.
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
@@ -538,12 +637,18 @@ func (s *SmartContract) DeleteTranferAgreement(ctx contractapi.TransactionContex
 		return err
 	}
 
+	// Withdrawing the agreement ends the negotiation
+	err = deleteTransferNegotiation(ctx, assetDeleteInput.ID)
+	if err != nil {
+		return err
+	}
+
 	return nil
 
 }
 
 // getCollectionName is an internal helper function to get collection of submitting client identity.
-func getCollectionName(ctx contractapi.TransactionContextInterface) (string, error) {
+func (s *SmartContract) getCollectionName(ctx contractapi.TransactionContextInterface) (string, error) {
 
 	// Get the MSP ID of submitting client identity
 	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
@@ -552,9 +657,22 @@ func getCollectionName(ctx contractapi.TransactionContextInterface) (string, err
 	}
 
 	// Create the collection name
-	orgCollection := clientMSPID + "PrivateCollection"
+	return s.buildCollectionName(ctx, clientMSPID)
+}
+
+// buildCollectionName is an internal helper function to get the org specific collection of an org,
+// depending on the collection mode recorded on the ledger.
+func (s *SmartContract) buildCollectionName(ctx contractapi.TransactionContextInterface, mspID string) (string, error) {
+	mode, err := ctx.GetStub().GetState(collectionModeKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read collection mode: %v", err)
+	}
+
+	if string(mode) == implicitCollectionMode {
+		return "_implicit_org_" + mspID, nil
+	}
 
-	return orgCollection, nil
+	return mspID + "PrivateCollection", nil
 }
 
 // verifyClientOrgMatchesPeerOrg is an internal function used verify client org id and matches peer org id.
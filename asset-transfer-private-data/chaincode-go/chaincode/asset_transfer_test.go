@@ -54,6 +54,11 @@ const myOrg1PrivCollection = "Org1TestmspPrivateCollection"
 const myOrg2Msp = "Org2Testmsp"
 const myOrg2Clientid = "myOrg2Userid"
 const myOrg2PrivCollection = "Org2TestmspPrivateCollection"
+const myOrg3Msp = "Org3Testmsp"
+const myOrg3Clientid = "myOrg3Userid"
+const myOrg1ImplicitCollection = "_implicit_org_Org1Testmsp"
+const myOrg2ImplicitCollection = "_implicit_org_Org2Testmsp"
+const myOrg3ImplicitCollection = "_implicit_org_Org3Testmsp"
 
 type assetTransientInput struct {
 	Type           string `json:"objectType"`
@@ -64,8 +69,9 @@ type assetTransientInput struct {
 }
 
 type assetTransferTransientInput struct {
-	ID       string `json:"assetID"`
-	BuyerMSP string `json:"buyerMSP"`
+	ID                   string `json:"assetID"`
+	BuyerMSP             string `json:"buyerMSP"`
+	RetainPrivateDetails bool   `json:"retainPrivateDetails"`
 }
 
 func TestCreateAssetBadInput(t *testing.T) {
@@ -281,6 +287,36 @@ func TestTransferAssetSuccessful(t *testing.T) {
 
 }
 
+func TestTransferAssetRetainingPrivateDetails(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+	assetNewOwner := &assetTransferTransientInput{
+		ID:                   "id1",
+		BuyerMSP:             myOrg2Msp,
+		RetainPrivateDetails: true,
+	}
+	setReturnAssetOwnerInTransientMap(t, chaincodeStub, assetNewOwner)
+	origAsset := chaincode.Asset{
+		ID:    "id1",
+		Type:  "testfulasset",
+		Color: "gray",
+		Size:  7,
+		Owner: myOrg1Clientid,
+	}
+	setReturnPrivateDataInStub(t, chaincodeStub, &origAsset)
+	chaincodeStub.GetPrivateDataHashReturns([]byte("datahash"), nil)
+	chaincodeStub.GetPrivateDataReturnsOnCall(1, []byte(myOrg2Clientid), nil)
+	chaincodeStub.CreateCompositeKeyReturns(transferAgreementObjectType+"id1", nil)
+
+	err := assetTransferCC.TransferAsset(transactionContext)
+	require.NoError(t, err)
+	//only the transfer agreement and negotiation are deleted, the seller keeps the appraised value
+	require.Equal(t, 2, chaincodeStub.DelPrivateDataCallCount())
+	calledCollection, calledId := chaincodeStub.DelPrivateDataArgsForCall(0)
+	require.Equal(t, assetCollectionName, calledCollection)
+	require.Equal(t, transferAgreementObjectType+"id1", calledId)
+}
+
 func TestTransferAssetByNonOwner(t *testing.T) {
 	transactionContext, chaincodeStub := prepMocksAsOrg1()
 	assetTransferCC := chaincode.SmartContract{}
@@ -355,6 +391,173 @@ func TestTransferAssetNonMatchingAppraisalValue(t *testing.T) {
 	require.Contains(t, err.Error(), "failed transfer verification: hash for appraised value")
 }
 
+func TestDeleteAssetPrivateRecordsBadInput(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	// No transient map
+	err := assetTransferCC.DeleteAssetPrivateRecords(transactionContext)
+	require.EqualError(t, err, "asset to delete not found in the transient map")
+
+	setReturnAssetPrivateDeleteInTransientMap(t, chaincodeStub, "")
+	err = assetTransferCC.DeleteAssetPrivateRecords(transactionContext)
+	require.EqualError(t, err, "assetID field must be a non-empty string")
+
+	//asset does not exist
+	setReturnAssetPrivateDeleteInTransientMap(t, chaincodeStub, "id1")
+	setReturnPrivateDataInStub(t, chaincodeStub, nil)
+	err = assetTransferCC.DeleteAssetPrivateRecords(transactionContext)
+	require.EqualError(t, err, "id1 does not exist")
+}
+
+func TestDeleteAssetPrivateRecordsSuccessful(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+	setReturnAssetPrivateDeleteInTransientMap(t, chaincodeStub, "id1")
+	setReturnPrivateDataInStub(t, chaincodeStub, &chaincode.Asset{
+		ID:    "id1",
+		Type:  "testfulasset",
+		Color: "gray",
+		Size:  7,
+		Owner: myOrg1Clientid,
+	})
+	chaincodeStub.CreateCompositeKeyReturns(transferAgreementObjectType+"id1", nil)
+
+	err := assetTransferCC.DeleteAssetPrivateRecords(transactionContext)
+	require.NoError(t, err)
+	//Validate DelPrivateData calls cover both of the owner's collections
+	require.Equal(t, 4, chaincodeStub.DelPrivateDataCallCount())
+	calledCollection, calledId := chaincodeStub.DelPrivateDataArgsForCall(0)
+	require.Equal(t, assetCollectionName, calledCollection)
+	require.Equal(t, "id1", calledId)
+	calledCollection, calledId = chaincodeStub.DelPrivateDataArgsForCall(1)
+	require.Equal(t, myOrg1PrivCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+	calledCollection, calledId = chaincodeStub.DelPrivateDataArgsForCall(2)
+	require.Equal(t, assetCollectionName, calledCollection)
+	require.Equal(t, transferAgreementObjectType+"id1", calledId)
+	calledCollection, _ = chaincodeStub.DelPrivateDataArgsForCall(3)
+	require.Equal(t, assetCollectionName, calledCollection)
+	//the asset details in the private collection do not need to exist
+	require.Equal(t, 1, chaincodeStub.GetPrivateDataCallCount())
+}
+
+func TestDeleteAssetPrivateRecordsByNonOwner(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := chaincode.SmartContract{}
+	setReturnAssetPrivateDeleteInTransientMap(t, chaincodeStub, "id1")
+	setReturnPrivateDataInStub(t, chaincodeStub, &chaincode.Asset{
+		ID:    "id1",
+		Type:  "testfulasset",
+		Color: "gray",
+		Size:  7,
+		Owner: myOrg1Clientid,
+	})
+
+	err := assetTransferCC.DeleteAssetPrivateRecords(transactionContext)
+	require.EqualError(t, err, "error: submitting client identity does not own asset")
+	require.Equal(t, 0, chaincodeStub.DelPrivateDataCallCount())
+}
+
+func TestInitImplicitCollections(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	chaincodeStub.GetStateReturns([]byte("implicit"), nil)
+	err := assetTransferCC.InitImplicitCollections(transactionContext)
+	require.EqualError(t, err, "collection mode is already set to implicit")
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturns(true)
+	chaincodeStub.GetPrivateDataByRangeReturns(iterator, nil)
+	err = assetTransferCC.InitImplicitCollections(transactionContext)
+	require.EqualError(t, err, "collection mode cannot be changed after assets have been created")
+
+	iterator.HasNextReturns(false)
+	err = assetTransferCC.InitImplicitCollections(transactionContext)
+	require.NoError(t, err)
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, "collectionMode", key)
+	require.Equal(t, []byte("implicit"), value)
+}
+
+func TestThreeOrgTransfersWithImplicitCollections(t *testing.T) {
+	assetTransferCC := chaincode.SmartContract{}
+
+	// Org3 creates the asset, storing the appraised value in its implicit collection
+	transactionContext, chaincodeStub := prepMocks(myOrg3Msp, myOrg3Clientid)
+	chaincodeStub.GetStateReturns([]byte("implicit"), nil)
+	setReturnAssetPropsInTransientMap(t, chaincodeStub, &assetTransientInput{
+		ID:             "id1",
+		Type:           "testfulasset",
+		Color:          "gray",
+		Size:           7,
+		AppraisedValue: 500,
+	})
+	err := assetTransferCC.CreateAsset(transactionContext)
+	require.NoError(t, err)
+	calledCollection, calledId, _ := chaincodeStub.PutPrivateDataArgsForCall(1)
+	require.Equal(t, myOrg3ImplicitCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+
+	// Org1 agrees to buy the asset from Org3
+	org3Asset := chaincode.Asset{
+		ID:    "id1",
+		Type:  "testfulasset",
+		Color: "gray",
+		Size:  7,
+		Owner: myOrg3Clientid,
+	}
+	transactionContext, chaincodeStub = prepMocksAsOrg1()
+	chaincodeStub.GetStateReturns([]byte("implicit"), nil)
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, &chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 500})
+	setReturnPrivateDataInStub(t, chaincodeStub, &org3Asset)
+	chaincodeStub.CreateCompositeKeyReturns(transferAgreementObjectType+"id1", nil)
+	err = assetTransferCC.AgreeToTransfer(transactionContext)
+	require.NoError(t, err)
+	calledCollection, calledId, _ = chaincodeStub.PutPrivateDataArgsForCall(0)
+	require.Equal(t, myOrg1ImplicitCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+
+	// Org3 transfers the asset to Org1, comparing the hashes in both implicit collections
+	transactionContext, chaincodeStub = prepMocks(myOrg3Msp, myOrg3Clientid)
+	chaincodeStub.GetStateReturns([]byte("implicit"), nil)
+	setReturnAssetOwnerInTransientMap(t, chaincodeStub, &assetTransferTransientInput{ID: "id1", BuyerMSP: myOrg1Msp})
+	setReturnPrivateDataInStub(t, chaincodeStub, &org3Asset)
+	chaincodeStub.GetPrivateDataHashReturns([]byte("datahash"), nil)
+	chaincodeStub.GetPrivateDataReturnsOnCall(1, []byte(myOrg1Clientid), nil)
+	chaincodeStub.CreateCompositeKeyReturns(transferAgreementObjectType+"id1", nil)
+	err = assetTransferCC.TransferAsset(transactionContext)
+	require.NoError(t, err)
+	calledCollection, calledId = chaincodeStub.GetPrivateDataHashArgsForCall(0)
+	require.Equal(t, myOrg3ImplicitCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+	calledCollection, calledId = chaincodeStub.GetPrivateDataHashArgsForCall(1)
+	require.Equal(t, myOrg1ImplicitCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+	calledCollection, calledId = chaincodeStub.DelPrivateDataArgsForCall(0)
+	require.Equal(t, myOrg3ImplicitCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+
+	// Org1 can pass the asset on to Org2 in the same way
+	org1Asset := org3Asset
+	org1Asset.Owner = myOrg1Clientid
+	transactionContext, chaincodeStub = prepMocksAsOrg1()
+	chaincodeStub.GetStateReturns([]byte("implicit"), nil)
+	setReturnAssetOwnerInTransientMap(t, chaincodeStub, &assetTransferTransientInput{ID: "id1", BuyerMSP: myOrg2Msp})
+	setReturnPrivateDataInStub(t, chaincodeStub, &org1Asset)
+	chaincodeStub.GetPrivateDataHashReturns([]byte("datahash"), nil)
+	chaincodeStub.GetPrivateDataReturnsOnCall(1, []byte(myOrg2Clientid), nil)
+	chaincodeStub.CreateCompositeKeyReturns(transferAgreementObjectType+"id1", nil)
+	err = assetTransferCC.TransferAsset(transactionContext)
+	require.NoError(t, err)
+	calledCollection, _ = chaincodeStub.GetPrivateDataHashArgsForCall(1)
+	require.Equal(t, myOrg2ImplicitCollection, calledCollection)
+	calledCollection, _ = chaincodeStub.DelPrivateDataArgsForCall(0)
+	require.Equal(t, myOrg1ImplicitCollection, calledCollection)
+}
+
 func prepMocksAsOrg1() (*mocks.TransactionContext, *mocks.ChaincodeStub) {
 	return prepMocks(myOrg1Msp, myOrg1Clientid)
 }
@@ -403,6 +606,16 @@ func setReturnAssetOwnerInTransientMap(t *testing.T, chaincodeStub *mocks.Chainc
 	return assetOwnerBytes
 }
 
+func setReturnAssetPrivateDeleteInTransientMap(t *testing.T, chaincodeStub *mocks.ChaincodeStub, assetID string) []byte {
+	assetDeleteBytes, err := json.Marshal(map[string]string{"assetID": assetID})
+	require.NoError(t, err)
+	assetPropMap := map[string][]byte{
+		"asset_private_delete": assetDeleteBytes,
+	}
+	chaincodeStub.GetTransientReturns(assetPropMap, nil)
+	return assetDeleteBytes
+}
+
 func setReturnAssetPropsInTransientMap(t *testing.T, chaincodeStub *mocks.ChaincodeStub, testAsset *assetTransientInput) []byte {
 	assetBytes := []byte{}
 	if testAsset != nil {
@@ -30,6 +30,9 @@
 //   settings.
 //         ===> from directory /fabric-samples/test-network
 //         ./network.sh deployCC -ccn secured -ccl go -ccep "OR('Org1MSP.peer','Org2MSP.peer')"
+//   This application transfers assets without payment. Adding "-cci SetEscrowOrg" to the deploy
+//   command enables payments from escrowed accounts, which this application does not fund, see
+//   asset-transfer-secured-agreement/chaincode-go/README.md
 //
 // - Be sure that node.js is installed
 //         ===> from directory /fabric-samples/asset-transfer-sbe/application-javascript
@@ -209,6 +212,8 @@ async function main() {
 		const randomNumber = Math.floor(Math.random() * 100) + 1;
 		// use a random key so that we can run multiple times
 		const assetKey = `asset-${randomNumber}`;
+		// offers to sell or buy the asset expire after one hour
+		const offerExpiry = new Date(Date.now() + 60 * 60 * 1000).toISOString();
 
 		/** ******* Fabric client init: Using Org1 identity to Org1 Peer ******* */
 		const gatewayOrg1 = await initGatewayForOrg1();
@@ -314,7 +319,7 @@ async function main() {
 				transaction.setTransient({
 					asset_price: Buffer.from(asset_price_string)
 				});
-				await transaction.submit(assetKey);
+				await transaction.submit(assetKey, offerExpiry);
 				console.log(`*** Result: committed, Org1 has agreed to sell asset ${assetKey} for 110`);
 			} catch (sellError) {
 				console.log(`${RED}*** Failed: AgreeToSell - ${sellError}${RESET}`);
@@ -367,7 +372,7 @@ async function main() {
 				transaction.setTransient({
 					asset_price: Buffer.from(asset_price_string)
 				});
-				await transaction.submit(assetKey);
+				await transaction.submit(assetKey, offerExpiry);
 				console.log(`*** Result: committed, Org2 has agreed to buy asset ${assetKey} for 100`);
 			} catch (buyError) {
 				console.log(`${RED}*** Failed: AgreeToBuy - ${buyError}${RESET}`);
@@ -439,7 +444,7 @@ async function main() {
 				transaction.setTransient({
 					asset_price: Buffer.from(asset_price_string)
 				});
-				await transaction.submit(assetKey);
+				await transaction.submit(assetKey, offerExpiry);
 				console.log(`*** Result: committed, Org1 has agreed to sell asset ${assetKey} for 100`);
 			} catch (sellError) {
 				console.log(`${RED}*** Failed: AgreeToSell - ${sellError}${RESET}`);
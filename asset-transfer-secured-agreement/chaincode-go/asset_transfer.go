@@ -23,6 +23,14 @@ const (
 	typeAssetBid         = "B"
 	typeAssetSaleReceipt = "SR"
 	typeAssetBuyReceipt  = "BR"
+	typeOffer            = "O"
+	typeAccount          = "A"
+	typeEscrow           = "E"
+	typeAuction          = "AU"
+	typeAuctionBid       = "AB"
+
+	// ownerIndex is the composite key index of assets by owner org
+	ownerIndex = "ownerOrg~assetID"
 )
 
 type SmartContract struct {
@@ -35,11 +43,27 @@ type Asset struct {
 	ID                string `json:"assetID"`
 	OwnerOrg          string `json:"ownerOrg"`
 	PublicDescription string `json:"publicDescription"`
+	// AuctionID is set while the asset is in an open auction, which locks it against direct transfers
+	AuctionID string `json:"auctionID,omitempty"`
 }
 
-type receipt struct {
-	price     int
-	timestamp time.Time
+// Offer records the expiry of an org's ask or bid for an asset in the public state, so that
+// the expiry can be checked by the peers of both the seller and buyer. The price of the
+// offer is kept private in the org's implicit private data collection
+type Offer struct {
+	AssetID   string    `json:"assetID"`
+	OrgID     string    `json:"orgID"`
+	OfferType string    `json:"offerType"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+// Receipt records the sale or purchase of an asset in the implicit private data collection of the seller or buyer
+type Receipt struct {
+	AssetID         string    `json:"assetID"`
+	Price           int       `json:"price"`
+	Timestamp       time.Time `json:"timestamp"`
+	CounterpartyOrg string    `json:"counterpartyOrg"`
+	TxID            string    `json:"txID"`
 }
 
 // CreateAsset creates an asset and sets it as owned by the client's org
@@ -84,6 +108,11 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("failed setting state based endorsement for owner: %v", err)
 	}
 
+	err = putOwnerIndex(ctx, clientOrgID, asset.ID)
+	if err != nil {
+		return err
+	}
+
 	// Persist private immutable asset properties to owner's private data collection
 	collection := buildCollectionName(clientOrgID)
 	err = ctx.GetStub().PutPrivateData(collection, asset.ID, immutablePropertiesJSON)
@@ -121,8 +150,30 @@ func (s *SmartContract) ChangePublicDescription(ctx contractapi.TransactionConte
 	return ctx.GetStub().PutState(assetID, updatedAssetJSON)
 }
 
-// AgreeToSell adds seller's asking price to seller's implicit private data collection
-func (s *SmartContract) AgreeToSell(ctx contractapi.TransactionContextInterface, assetID string) error {
+// IndexAsset adds an asset to the owner index. Assets created before the owner index was
+// introduced are missing from it until their owner indexes them, or until they are transferred.
+// Indexing an asset that is already indexed has no effect. Only the current owner can index an asset
+func (s *SmartContract) IndexAsset(ctx contractapi.TransactionContextInterface, assetID string) error {
+	clientOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	asset, err := s.ReadAsset(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to get asset: %v", err)
+	}
+
+	if clientOrgID != asset.OwnerOrg {
+		return fmt.Errorf("a client from %s cannot index an asset owned by %s", clientOrgID, asset.OwnerOrg)
+	}
+
+	return putOwnerIndex(ctx, asset.OwnerOrg, asset.ID)
+}
+
+// AgreeToSell adds seller's asking price to seller's implicit private data collection.
+// The offer expires at the passed RFC3339 expiry time
+func (s *SmartContract) AgreeToSell(ctx contractapi.TransactionContextInterface, assetID string, expiry string) error {
 	asset, err := s.ReadAsset(ctx, assetID)
 	if err != nil {
 		return err
@@ -138,16 +189,18 @@ func (s *SmartContract) AgreeToSell(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("a client from %s cannot sell an asset owned by %s", clientOrgID, asset.OwnerOrg)
 	}
 
-	return agreeToPrice(ctx, assetID, typeAssetForSale)
+	return agreeToPrice(ctx, assetID, typeAssetForSale, expiry)
 }
 
-// AgreeToBuy adds buyer's bid price to buyer's implicit private data collection
-func (s *SmartContract) AgreeToBuy(ctx contractapi.TransactionContextInterface, assetID string) error {
-	return agreeToPrice(ctx, assetID, typeAssetBid)
+// AgreeToBuy adds buyer's bid price to buyer's implicit private data collection.
+// The bid expires at the passed RFC3339 expiry time
+func (s *SmartContract) AgreeToBuy(ctx contractapi.TransactionContextInterface, assetID string, expiry string) error {
+	return agreeToPrice(ctx, assetID, typeAssetBid, expiry)
 }
 
 // agreeToPrice adds a bid or ask price to caller's implicit private data collection
-func agreeToPrice(ctx contractapi.TransactionContextInterface, assetID string, priceType string) error {
+// and records the expiry of the offer in the public state
+func agreeToPrice(ctx contractapi.TransactionContextInterface, assetID string, priceType string, expiry string) error {
 	// In this scenario, client is only authorized to read/write private data from its own peer.
 	clientOrgID, err := getClientOrgID(ctx, true)
 	if err != nil {
@@ -181,6 +234,98 @@ func agreeToPrice(ctx contractapi.TransactionContextInterface, assetID string, p
 		return fmt.Errorf("failed to put asset bid: %v", err)
 	}
 
+	expiryTime, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		return fmt.Errorf("failed to parse expiry %s, expected RFC3339 format: %v", expiry, err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !expiryTime.After(txTime) {
+		return fmt.Errorf("expiry %s must be after the transaction time %s", expiry, txTime.Format(time.RFC3339))
+	}
+
+	offer := Offer{
+		AssetID:   assetID,
+		OrgID:     clientOrgID,
+		OfferType: priceType,
+		Expiry:    expiryTime.UTC(),
+	}
+	offerJSON, err := json.Marshal(offer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal offer: %v", err)
+	}
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(typeOffer, []string{clientOrgID, priceType, assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(offerKey, offerJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put offer in public data: %v", err)
+	}
+
+	// Only the offering org can update or withdraw the offer
+	err = setAssetStateBasedEndorsement(ctx, offerKey, clientOrgID)
+	if err != nil {
+		return fmt.Errorf("failed setting state based endorsement for offer: %v", err)
+	}
+
+	return nil
+}
+
+// WithdrawSellOffer removes the seller's asking price and offer for an asset
+func (s *SmartContract) WithdrawSellOffer(ctx contractapi.TransactionContextInterface, assetID string) error {
+	return withdrawOffer(ctx, assetID, typeAssetForSale)
+}
+
+// WithdrawBid removes the buyer's bid price and offer for an asset
+func (s *SmartContract) WithdrawBid(ctx contractapi.TransactionContextInterface, assetID string) error {
+	return withdrawOffer(ctx, assetID, typeAssetBid)
+}
+
+// withdrawOffer removes a bid or ask price from caller's implicit private data collection
+// along with the public offer
+func withdrawOffer(ctx contractapi.TransactionContextInterface, assetID string, priceType string) error {
+	// In this scenario, client is only authorized to read/write private data from its own peer.
+	clientOrgID, err := getClientOrgID(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	collection := buildCollectionName(clientOrgID)
+
+	assetPriceKey, err := ctx.GetStub().CreateCompositeKey(priceType, []string{assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	price, err := ctx.GetStub().GetPrivateData(collection, assetPriceKey)
+	if err != nil {
+		return fmt.Errorf("failed to read asset price from implicit private data collection: %v", err)
+	}
+	if price == nil {
+		return fmt.Errorf("asset price does not exist: %s", assetID)
+	}
+
+	err = ctx.GetStub().DelPrivateData(collection, assetPriceKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete asset price from implicit private data collection: %v", err)
+	}
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(typeOffer, []string{clientOrgID, priceType, assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(offerKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete offer from public data: %v", err)
+	}
+
 	return nil
 }
 
@@ -204,12 +349,23 @@ func (s *SmartContract) VerifyAssetProperties(ctx contractapi.TransactionContext
 	}
 
 	collectionOwner := buildCollectionName(asset.OwnerOrg)
+	err = verifyPropertiesHash(ctx, collectionOwner, assetID, immutablePropertiesJSON)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// verifyPropertiesHash verifies that the hash of the passed immutable properties
+// matches the on-chain hash of the properties in the owner's collection
+func verifyPropertiesHash(ctx contractapi.TransactionContextInterface, collectionOwner string, assetID string, immutablePropertiesJSON []byte) error {
 	immutablePropertiesOnChainHash, err := ctx.GetStub().GetPrivateDataHash(collectionOwner, assetID)
 	if err != nil {
-		return false, fmt.Errorf("failed to read asset private properties hash from seller's collection: %v", err)
+		return fmt.Errorf("failed to read asset private properties hash from seller's collection: %v", err)
 	}
 	if immutablePropertiesOnChainHash == nil {
-		return false, fmt.Errorf("asset private properties hash does not exist: %s", assetID)
+		return fmt.Errorf("asset private properties hash does not exist: %s", assetID)
 	}
 
 	hash := sha256.New()
@@ -218,17 +374,18 @@ func (s *SmartContract) VerifyAssetProperties(ctx contractapi.TransactionContext
 
 	// verify that the hash of the passed immutable properties matches the on-chain hash
 	if !bytes.Equal(immutablePropertiesOnChainHash, calculatedPropertiesHash) {
-		return false, fmt.Errorf("hash %x for passed immutable properties %s does not match on-chain hash %x",
+		return fmt.Errorf("hash %x for passed immutable properties %s does not match on-chain hash %x",
 			calculatedPropertiesHash,
 			immutablePropertiesJSON,
 			immutablePropertiesOnChainHash,
 		)
 	}
 
-	return true, nil
+	return nil
 }
 
 // TransferAsset checks transfer conditions and then transfers asset state to buyer.
+// If the escrow org is set, the agreed price is paid from the buyer's account in the same transaction.
 // TransferAsset can only be called by current owner
 func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface, assetID string, buyerOrgID string) error {
 	clientOrgID, err := getClientOrgID(ctx, false)
@@ -262,6 +419,11 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("failed to get asset: %v", err)
 	}
 
+	// An asset in an open auction can only be transferred by closing the auction
+	if asset.AuctionID != "" {
+		return fmt.Errorf("asset %s is in an open auction and cannot be transferred until the auction is closed", assetID)
+	}
+
 	err = verifyTransferConditions(ctx, asset, immutablePropertiesJSON, clientOrgID, buyerOrgID, priceJSON)
 	if err != nil {
 		return fmt.Errorf("failed transfer verification: %v", err)
@@ -272,6 +434,12 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("failed asset transfer: %v", err)
 	}
 
+	// Pay for the asset in the same transaction, so that the asset is only delivered against payment
+	err = transferPayment(ctx, buyerOrgID, clientOrgID, agreement.Price)
+	if err != nil {
+		return fmt.Errorf("failed payment: %v", err)
+	}
+
 	return nil
 
 }
@@ -293,25 +461,9 @@ func verifyTransferConditions(ctx contractapi.TransactionContextInterface,
 	// CHECK2: Verify that the hash of the passed immutable properties matches the on-chain hash
 
 	collectionSeller := buildCollectionName(clientOrgID)
-	immutablePropertiesOnChainHash, err := ctx.GetStub().GetPrivateDataHash(collectionSeller, asset.ID)
+	err := verifyPropertiesHash(ctx, collectionSeller, asset.ID, immutablePropertiesJSON)
 	if err != nil {
-		return fmt.Errorf("failed to read asset private properties hash from seller's collection: %v", err)
-	}
-	if immutablePropertiesOnChainHash == nil {
-		return fmt.Errorf("asset private properties hash does not exist: %s", asset.ID)
-	}
-
-	hash := sha256.New()
-	hash.Write(immutablePropertiesJSON)
-	calculatedPropertiesHash := hash.Sum(nil)
-
-	// verify that the hash of the passed immutable properties matches the on-chain hash
-	if !bytes.Equal(immutablePropertiesOnChainHash, calculatedPropertiesHash) {
-		return fmt.Errorf("hash %x for passed immutable properties %s does not match on-chain hash %x",
-			calculatedPropertiesHash,
-			immutablePropertiesJSON,
-			immutablePropertiesOnChainHash,
-		)
+		return err
 	}
 
 	// CHECK3: Verify that seller and buyer agreed on the same price
@@ -343,7 +495,7 @@ func verifyTransferConditions(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("buyer price for %s does not exist", asset.ID)
 	}
 
-	hash = sha256.New()
+	hash := sha256.New()
 	hash.Write(priceJSON)
 	calculatedPriceHash := hash.Sum(nil)
 
@@ -365,6 +517,51 @@ func verifyTransferConditions(ctx contractapi.TransactionContextInterface,
 		)
 	}
 
+	// CHECK4: Verify that neither the seller's nor the buyer's offer has expired
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = verifyOfferNotExpired(ctx, asset.ID, clientOrgID, typeAssetForSale, txTime)
+	if err != nil {
+		return err
+	}
+
+	err = verifyOfferNotExpired(ctx, asset.ID, buyerOrgID, typeAssetBid, txTime)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// verifyOfferNotExpired checks that the offer of an org for an asset expires after the transaction time
+func verifyOfferNotExpired(ctx contractapi.TransactionContextInterface, assetID string, orgID string, priceType string, txTime time.Time) error {
+	offerKey, err := ctx.GetStub().CreateCompositeKey(typeOffer, []string{orgID, priceType, assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	offerJSON, err := ctx.GetStub().GetState(offerKey)
+	if err != nil {
+		return fmt.Errorf("failed to read offer from world state: %v", err)
+	}
+	if offerJSON == nil {
+		return fmt.Errorf("offer from %s for %s does not exist", orgID, assetID)
+	}
+
+	var offer Offer
+	err = json.Unmarshal(offerJSON, &offer)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal offer JSON: %v", err)
+	}
+
+	if !txTime.Before(offer.Expiry) {
+		return fmt.Errorf("offer from %s for %s expired at %s", orgID, assetID, offer.Expiry.Format(time.RFC3339))
+	}
+
 	return nil
 }
 
@@ -381,6 +578,17 @@ func transferAssetState(ctx contractapi.TransactionContextInterface, asset *Asse
 		return fmt.Errorf("failed to write asset for buyer: %v", err)
 	}
 
+	// Move the asset to the new owner in the owner index
+	err = deleteOwnerIndex(ctx, clientOrgID, asset.ID)
+	if err != nil {
+		return err
+	}
+
+	err = putOwnerIndex(ctx, buyerOrgID, asset.ID)
+	if err != nil {
+		return err
+	}
+
 	// Change the endorsement policy to the new owner
 	err = setAssetStateBasedEndorsement(ctx, asset.ID, buyerOrgID)
 	if err != nil {
@@ -422,42 +630,74 @@ func transferAssetState(ctx contractapi.TransactionContextInterface, asset *Asse
 		return fmt.Errorf("failed to delete asset price from implicit private data collection for buyer: %v", err)
 	}
 
+	// Delete the offers of seller and buyer
+	offerKey, err := ctx.GetStub().CreateCompositeKey(typeOffer, []string{clientOrgID, typeAssetForSale, asset.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key for seller: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(offerKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete offer for seller: %v", err)
+	}
+
+	offerKey, err = ctx.GetStub().CreateCompositeKey(typeOffer, []string{buyerOrgID, typeAssetBid, asset.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key for buyer: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(offerKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete offer for buyer: %v", err)
+	}
+
 	// Keep record for a 'receipt' in both buyers and sellers private data collection to record the sale price and date.
 	// Persist the agreed to price in a collection sub-namespace based on receipt key prefix.
-	receiptBuyKey, err := ctx.GetStub().CreateCompositeKey(typeAssetBuyReceipt, []string{asset.ID, ctx.GetStub().GetTxID()})
+	txID := ctx.GetStub().GetTxID()
+	receiptBuyKey, err := ctx.GetStub().CreateCompositeKey(typeAssetBuyReceipt, []string{asset.ID, txID})
 	if err != nil {
 		return fmt.Errorf("failed to create composite key for receipt: %v", err)
 	}
 
-	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	timestamp, err := getTxTime(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create timestamp for receipt: %v", err)
 	}
 
-	timestamp, err := ptypes.Timestamp(txTimestamp)
-	if err != nil {
-		return err
-	}
-	assetReceipt := receipt{
-		price:     price,
-		timestamp: timestamp,
-	}
-	receipt, err := json.Marshal(assetReceipt)
+	// The buyer's receipt records the seller as counterparty, the seller's receipt records the buyer
+	buyReceipt, err := json.Marshal(Receipt{
+		AssetID:         asset.ID,
+		Price:           price,
+		Timestamp:       timestamp,
+		CounterpartyOrg: clientOrgID,
+		TxID:            txID,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to marshal receipt: %v", err)
 	}
 
-	err = ctx.GetStub().PutPrivateData(collectionBuyer, receiptBuyKey, receipt)
+	err = ctx.GetStub().PutPrivateData(collectionBuyer, receiptBuyKey, buyReceipt)
 	if err != nil {
 		return fmt.Errorf("failed to put private asset receipt for buyer: %v", err)
 	}
 
-	receiptSaleKey, err := ctx.GetStub().CreateCompositeKey(typeAssetSaleReceipt, []string{ctx.GetStub().GetTxID(), asset.ID})
+	receiptSaleKey, err := ctx.GetStub().CreateCompositeKey(typeAssetSaleReceipt, []string{txID, asset.ID})
 	if err != nil {
 		return fmt.Errorf("failed to create composite key for receipt: %v", err)
 	}
 
-	err = ctx.GetStub().PutPrivateData(collectionSeller, receiptSaleKey, receipt)
+	saleReceipt, err := json.Marshal(Receipt{
+		AssetID:         asset.ID,
+		Price:           price,
+		Timestamp:       timestamp,
+		CounterpartyOrg: buyerOrgID,
+		TxID:            txID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal receipt: %v", err)
+	}
+
+	err = ctx.GetStub().PutPrivateData(collectionSeller, receiptSaleKey, saleReceipt)
 	if err != nil {
 		return fmt.Errorf("failed to put private asset receipt for seller: %v", err)
 	}
@@ -465,6 +705,16 @@ func transferAssetState(ctx contractapi.TransactionContextInterface, asset *Asse
 	return nil
 }
 
+// getTxTime returns the transaction timestamp, which is the same on all endorsing peers
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return ptypes.Timestamp(txTimestamp)
+}
+
 // getClientOrgID gets the client org ID.
 // The client org ID can optionally be verified against the peer org ID, to ensure that a client
 // from another org doesn't attempt to read or write private data from this peer.
@@ -526,6 +776,37 @@ func setAssetStateBasedEndorsement(ctx contractapi.TransactionContextInterface,
 	return nil
 }
 
+// putOwnerIndex adds an asset to the index of assets by owner org. Only the key is needed,
+// the value is a single null byte since a nil value would delete the key
+func putOwnerIndex(ctx contractapi.TransactionContextInterface, ownerOrgID string, assetID string) error {
+	ownerIndexKey, err := ctx.GetStub().CreateCompositeKey(ownerIndex, []string{ownerOrgID, assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key for owner index: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(ownerIndexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put owner index: %v", err)
+	}
+
+	return nil
+}
+
+// deleteOwnerIndex removes an asset from the index of assets by owner org
+func deleteOwnerIndex(ctx contractapi.TransactionContextInterface, ownerOrgID string, assetID string) error {
+	ownerIndexKey, err := ctx.GetStub().CreateCompositeKey(ownerIndex, []string{ownerOrgID, assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key for owner index: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(ownerIndexKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete owner index: %v", err)
+	}
+
+	return nil
+}
+
 func buildCollectionName(clientOrgID string) string {
 	return fmt.Sprintf("_implicit_org_%s", clientOrgID)
 }
@@ -0,0 +1,523 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	auctionOpen   = "open"
+	auctionClosed = "closed"
+)
+
+// Auction is the public record of an auction of an asset by its owner. Bids are sealed until the
+// bidding deadline and can be revealed until the reveal deadline, after which the owner closes the auction
+type Auction struct {
+	ID              string    `json:"auctionID"`
+	AssetID         string    `json:"assetID"`
+	SellerOrg       string    `json:"sellerOrg"`
+	BiddingDeadline time.Time `json:"biddingDeadline"`
+	RevealDeadline  time.Time `json:"revealDeadline"`
+	Status          string    `json:"status"`
+	WinnerOrg       string    `json:"winnerOrg"`
+	Price           int       `json:"price"`
+}
+
+// AuctionBid is the public record of an org's bid in an auction. The bid price is kept
+// in the bidder's implicit private data collection until the bid is revealed
+type AuctionBid struct {
+	AssetID   string `json:"assetID"`
+	AuctionID string `json:"auctionID"`
+	BidderOrg string `json:"bidderOrg"`
+	Revealed  bool   `json:"revealed"`
+	Price     int    `json:"price"`
+}
+
+// OpenAuction opens an auction of an asset. Bids can be submitted until the RFC3339
+// bidding deadline and revealed until the RFC3339 reveal deadline. The asset cannot
+// be transferred with TransferAsset while the auction is open
+func (s *SmartContract) OpenAuction(ctx contractapi.TransactionContextInterface, assetID string, biddingDeadline string, revealDeadline string) error {
+	clientOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	asset, err := s.ReadAsset(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	if clientOrgID != asset.OwnerOrg {
+		return fmt.Errorf("a client from %s cannot auction an asset owned by %s", clientOrgID, asset.OwnerOrg)
+	}
+
+	auction, err := getAuction(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	if auction != nil && auction.Status == auctionOpen {
+		return fmt.Errorf("auction for %s is already open", assetID)
+	}
+
+	bidding, err := time.Parse(time.RFC3339, biddingDeadline)
+	if err != nil {
+		return fmt.Errorf("failed to parse bidding deadline %s, expected RFC3339 format: %v", biddingDeadline, err)
+	}
+
+	reveal, err := time.Parse(time.RFC3339, revealDeadline)
+	if err != nil {
+		return fmt.Errorf("failed to parse reveal deadline %s, expected RFC3339 format: %v", revealDeadline, err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !bidding.After(txTime) {
+		return fmt.Errorf("bidding deadline %s must be after the transaction time %s", biddingDeadline, txTime.Format(time.RFC3339))
+	}
+	if !reveal.After(bidding) {
+		return fmt.Errorf("reveal deadline %s must be after the bidding deadline %s", revealDeadline, biddingDeadline)
+	}
+
+	auction = &Auction{
+		ID:              ctx.GetStub().GetTxID(),
+		AssetID:         assetID,
+		SellerOrg:       clientOrgID,
+		BiddingDeadline: bidding.UTC(),
+		RevealDeadline:  reveal.UTC(),
+		Status:          auctionOpen,
+	}
+
+	err = putAuction(ctx, auction)
+	if err != nil {
+		return err
+	}
+
+	asset.AuctionID = auction.ID
+	return putAsset(ctx, asset)
+}
+
+// SubmitAuctionBid adds a sealed bid to the bidder's implicit private data collection and
+// records the bid in the public state. The bid is passed in the transient field as
+// asset_bid JSON, in the same format as an asset_price
+func (s *SmartContract) SubmitAuctionBid(ctx contractapi.TransactionContextInterface, assetID string) error {
+	// In this scenario, client is only authorized to read/write private data from its own peer.
+	clientOrgID, err := getClientOrgID(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	transMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient: %v", err)
+	}
+
+	// The bid must be retrieved from the transient field as it is private until revealed
+	bidJSON, ok := transMap["asset_bid"]
+	if !ok {
+		return fmt.Errorf("asset_bid key not found in the transient map")
+	}
+
+	auction, err := getOpenAuction(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	if clientOrgID == auction.SellerOrg {
+		return fmt.Errorf("a client from %s cannot bid in its own auction", clientOrgID)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !txTime.Before(auction.BiddingDeadline) {
+		return fmt.Errorf("bidding for %s closed at %s", assetID, auction.BiddingDeadline.Format(time.RFC3339))
+	}
+
+	bidKey, err := ctx.GetStub().CreateCompositeKey(typeAuctionBid, []string{assetID, auction.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	// The bid hash will be verified when revealed, therefore always pass and persist bid bytes as is
+	err = ctx.GetStub().PutPrivateData(buildCollectionName(clientOrgID), bidKey, bidJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put auction bid: %v", err)
+	}
+
+	return putAuctionBid(ctx, &AuctionBid{AssetID: assetID, AuctionID: auction.ID, BidderOrg: clientOrgID})
+}
+
+// RevealAuctionBid reveals the price of the client org's sealed bid after the bidding deadline.
+// The bid is passed in the transient field as asset_bid JSON and must match the on-chain hash
+func (s *SmartContract) RevealAuctionBid(ctx contractapi.TransactionContextInterface, assetID string) error {
+	clientOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	transMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient: %v", err)
+	}
+
+	bidJSON, ok := transMap["asset_bid"]
+	if !ok {
+		return fmt.Errorf("asset_bid key not found in the transient map")
+	}
+
+	auction, err := getOpenAuction(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if txTime.Before(auction.BiddingDeadline) {
+		return fmt.Errorf("bids for %s cannot be revealed before %s", assetID, auction.BiddingDeadline.Format(time.RFC3339))
+	}
+	if !txTime.Before(auction.RevealDeadline) {
+		return fmt.Errorf("revealing bids for %s closed at %s", assetID, auction.RevealDeadline.Format(time.RFC3339))
+	}
+
+	bid, err := getAuctionBid(ctx, assetID, auction.ID, clientOrgID)
+	if err != nil {
+		return err
+	}
+	if bid == nil {
+		return fmt.Errorf("bid from %s for %s does not exist", clientOrgID, assetID)
+	}
+
+	bidKey, err := ctx.GetStub().CreateCompositeKey(typeAuctionBid, []string{assetID, auction.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	bidOnChainHash, err := ctx.GetStub().GetPrivateDataHash(buildCollectionName(clientOrgID), bidKey)
+	if err != nil {
+		return fmt.Errorf("failed to read bid hash from bidder's collection: %v", err)
+	}
+	if bidOnChainHash == nil {
+		return fmt.Errorf("bid hash for %s does not exist", assetID)
+	}
+
+	hash := sha256.New()
+	hash.Write(bidJSON)
+	calculatedBidHash := hash.Sum(nil)
+
+	// Verify that the hash of the passed bid matches the on-chain sealed bid hash
+	if !bytes.Equal(calculatedBidHash, bidOnChainHash) {
+		return fmt.Errorf("hash %x for passed bid JSON %s does not match on-chain hash %x",
+			calculatedBidHash,
+			bidJSON,
+			bidOnChainHash,
+		)
+	}
+
+	var agreement Agreement
+	err = json.Unmarshal(bidJSON, &agreement)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal bid JSON: %v", err)
+	}
+	if agreement.ID != assetID {
+		return fmt.Errorf("bid is for asset %s, not %s", agreement.ID, assetID)
+	}
+	if agreement.Price <= 0 {
+		return fmt.Errorf("bid price must be a positive integer")
+	}
+
+	bid.Revealed = true
+	bid.Price = agreement.Price
+
+	return putAuctionBid(ctx, bid)
+}
+
+// CloseAuction closes an auction after the reveal deadline and transfers the asset to the highest
+// revealed bidder that can pay for it. If the escrow org is set, the winning bid is paid from the
+// winner's account in the same transaction. The asset properties are passed in the transient field as asset_properties
+// JSON. The asset is not transferred if there is no valid bid, but is unlocked either way
+func (s *SmartContract) CloseAuction(ctx contractapi.TransactionContextInterface, assetID string) error {
+	clientOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	transMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("error getting transient data: %v", err)
+	}
+
+	immutablePropertiesJSON, ok := transMap["asset_properties"]
+	if !ok {
+		return fmt.Errorf("asset_properties key not found in the transient map")
+	}
+
+	asset, err := s.ReadAsset(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to get asset: %v", err)
+	}
+
+	auction, err := getOpenAuction(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	if clientOrgID != auction.SellerOrg || clientOrgID != asset.OwnerOrg {
+		return fmt.Errorf("a client from %s cannot close an auction of an asset owned by %s", clientOrgID, asset.OwnerOrg)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if txTime.Before(auction.RevealDeadline) {
+		return fmt.Errorf("auction for %s cannot be closed before %s", assetID, auction.RevealDeadline.Format(time.RFC3339))
+	}
+
+	err = verifyPropertiesHash(ctx, buildCollectionName(clientOrgID), assetID, immutablePropertiesJSON)
+	if err != nil {
+		return err
+	}
+
+	bids, err := getAuctionBids(ctx, assetID, auction.ID)
+	if err != nil {
+		return err
+	}
+
+	// The highest revealed bid wins, ties are broken by bidder org
+	sort.Slice(bids, func(i, j int) bool {
+		if bids[i].Price != bids[j].Price {
+			return bids[i].Price > bids[j].Price
+		}
+		return bids[i].BidderOrg < bids[j].BidderOrg
+	})
+
+	auction.Status = auctionClosed
+	asset.AuctionID = ""
+
+	for _, bid := range bids {
+		if !bid.Revealed {
+			continue
+		}
+
+		// A bid is only valid if the bidder can pay for the asset, when payments are enabled
+		_, _, err = getPaymentAccounts(ctx, bid.BidderOrg, clientOrgID, bid.Price)
+		if err != nil {
+			continue
+		}
+
+		auction.WinnerOrg = bid.BidderOrg
+		auction.Price = bid.Price
+		break
+	}
+
+	if auction.WinnerOrg == "" {
+		err = putAsset(ctx, asset)
+		if err != nil {
+			return err
+		}
+
+		return putAuction(ctx, auction)
+	}
+
+	err = transferAssetState(ctx, asset, immutablePropertiesJSON, clientOrgID, auction.WinnerOrg, auction.Price)
+	if err != nil {
+		return fmt.Errorf("failed asset transfer: %v", err)
+	}
+
+	// The asset is only delivered against payment of the winning bid
+	err = transferPayment(ctx, auction.WinnerOrg, clientOrgID, auction.Price)
+	if err != nil {
+		return fmt.Errorf("failed payment: %v", err)
+	}
+
+	return putAuction(ctx, auction)
+}
+
+// ReadAuction returns the latest auction of an asset
+func (s *SmartContract) ReadAuction(ctx contractapi.TransactionContextInterface, assetID string) (*Auction, error) {
+	auction, err := getAuction(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if auction == nil {
+		return nil, fmt.Errorf("auction for %s does not exist", assetID)
+	}
+
+	return auction, nil
+}
+
+// QueryAuctionBids returns the bids in the latest auction of an asset
+func (s *SmartContract) QueryAuctionBids(ctx contractapi.TransactionContextInterface, assetID string) ([]*AuctionBid, error) {
+	auction, err := s.ReadAuction(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	return getAuctionBids(ctx, assetID, auction.ID)
+}
+
+// putAsset writes the public asset record, keeping the owner's endorsement policy
+func putAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	assetJSON, err := json.Marshal(asset)
+	if err != nil {
+		return fmt.Errorf("failed to marshal asset: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(asset.ID, assetJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put asset in public data: %v", err)
+	}
+
+	return nil
+}
+
+func getAuction(ctx contractapi.TransactionContextInterface, assetID string) (*Auction, error) {
+	auctionKey, err := ctx.GetStub().CreateCompositeKey(typeAuction, []string{assetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	auctionJSON, err := ctx.GetStub().GetState(auctionKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auction from world state: %v", err)
+	}
+	if auctionJSON == nil {
+		return nil, nil
+	}
+
+	var auction Auction
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal auction JSON: %v", err)
+	}
+
+	return &auction, nil
+}
+
+func getOpenAuction(ctx contractapi.TransactionContextInterface, assetID string) (*Auction, error) {
+	auction, err := getAuction(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if auction == nil || auction.Status != auctionOpen {
+		return nil, fmt.Errorf("no open auction for %s", assetID)
+	}
+
+	return auction, nil
+}
+
+// putAuction writes the auction with an endorsement policy
+// requiring the seller's peer to endorse updates
+func putAuction(ctx contractapi.TransactionContextInterface, auction *Auction) error {
+	auctionKey, err := ctx.GetStub().CreateCompositeKey(typeAuction, []string{auction.AssetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	auctionJSON, err := json.Marshal(auction)
+	if err != nil {
+		return fmt.Errorf("failed to marshal auction: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(auctionKey, auctionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put auction in public data: %v", err)
+	}
+
+	err = setAssetStateBasedEndorsement(ctx, auctionKey, auction.SellerOrg)
+	if err != nil {
+		return fmt.Errorf("failed setting state based endorsement for auction: %v", err)
+	}
+
+	return nil
+}
+
+func getAuctionBid(ctx contractapi.TransactionContextInterface, assetID string, auctionID string, bidderOrgID string) (*AuctionBid, error) {
+	bidKey, err := ctx.GetStub().CreateCompositeKey(typeAuctionBid, []string{assetID, auctionID, bidderOrgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	bidJSON, err := ctx.GetStub().GetState(bidKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bid from world state: %v", err)
+	}
+	if bidJSON == nil {
+		return nil, nil
+	}
+
+	var bid AuctionBid
+	err = json.Unmarshal(bidJSON, &bid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bid JSON: %v", err)
+	}
+
+	return &bid, nil
+}
+
+func getAuctionBids(ctx contractapi.TransactionContextInterface, assetID string, auctionID string) ([]*AuctionBid, error) {
+	bidsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(typeAuctionBid, []string{assetID, auctionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer bidsIterator.Close()
+
+	var bids []*AuctionBid
+	for bidsIterator.HasNext() {
+		resp, err := bidsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var bid AuctionBid
+		err = json.Unmarshal(resp.Value, &bid)
+		if err != nil {
+			return nil, err
+		}
+
+		bids = append(bids, &bid)
+	}
+
+	return bids, nil
+}
+
+// putAuctionBid writes the public bid record with an endorsement
+// policy requiring the bidder's peer to endorse updates
+func putAuctionBid(ctx contractapi.TransactionContextInterface, bid *AuctionBid) error {
+	bidKey, err := ctx.GetStub().CreateCompositeKey(typeAuctionBid, []string{bid.AssetID, bid.AuctionID, bid.BidderOrg})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	bidJSON, err := json.Marshal(bid)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bid: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(bidKey, bidJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put bid in public data: %v", err)
+	}
+
+	err = setAssetStateBasedEndorsement(ctx, bidKey, bid.BidderOrg)
+	if err != nil {
+		return fmt.Errorf("failed setting state based endorsement for bid: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+const myOrg3Msp = "Org3Testmsp"
+
+func TestAuction(t *testing.T) {
+	network := newTestNetworkWithAuction(t)
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(myOrg1Msp, bidTransient(100), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.SubmitAuctionBid(ctx, testAssetID)
+	})
+	require.EqualError(t, err, "a client from Org1Testmsp cannot bid in its own auction")
+
+	submitBid(t, network, myOrg2Msp, 120)
+	submitBid(t, network, myOrg3Msp, 150)
+
+	// The asset cannot be sold outside of the auction while it is open
+	err = network.submit(myOrg1Msp, transferTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "asset asset1 is in an open auction and cannot be transferred until the auction is closed")
+
+	err = network.submit(myOrg2Msp, bidTransient(120), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.RevealAuctionBid(ctx, testAssetID)
+	})
+	require.EqualError(t, err, "bids for asset1 cannot be revealed before 2020-09-01T13:00:00Z")
+
+	network.now = network.now.Add(90 * time.Minute)
+
+	err = network.submit(myOrg2Msp, bidTransient(120), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.SubmitAuctionBid(ctx, testAssetID)
+	})
+	require.EqualError(t, err, "bidding for asset1 closed at 2020-09-01T13:00:00Z")
+
+	// A bidder cannot reveal a different price than the sealed bid
+	err = network.submit(myOrg3Msp, bidTransient(90), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.RevealAuctionBid(ctx, testAssetID)
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not match on-chain hash")
+
+	revealBid(t, network, myOrg2Msp, 120)
+	revealBid(t, network, myOrg3Msp, 150)
+
+	fundAccount(t, network, myOrg2Msp, 200)
+	fundAccount(t, network, myOrg3Msp, 200)
+
+	closeAuction := func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.CloseAuction(ctx, testAssetID)
+	}
+	err = network.submit(myOrg1Msp, propertiesTransient(), closeAuction)
+	require.EqualError(t, err, "auction for asset1 cannot be closed before 2020-09-01T14:00:00Z")
+
+	network.now = network.now.Add(time.Hour)
+	err = network.submit(myOrg2Msp, propertiesTransient(), closeAuction)
+	require.EqualError(t, err, "a client from Org2Testmsp cannot close an auction of an asset owned by Org1Testmsp")
+
+	// Paying from the winner's account requires the winner's endorsement
+	err = network.submit(myOrg1Msp, propertiesTransient(), closeAuction, myOrg1Msp, myOrg3Msp)
+	require.NoError(t, err)
+	requireBalance(t, network, myOrg1Msp, 150)
+	requireBalance(t, network, myOrg3Msp, 50)
+
+	err = network.evaluate(myOrg3Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		asset, err := assetTransferCC.ReadAsset(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, myOrg3Msp, asset.OwnerOrg)
+		require.Empty(t, asset.AuctionID)
+
+		properties, err := assetTransferCC.GetAssetPrivateProperties(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, testProperties, properties)
+
+		auction, err := assetTransferCC.ReadAuction(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, auctionClosed, auction.Status)
+		require.Equal(t, myOrg3Msp, auction.WinnerOrg)
+		require.Equal(t, 150, auction.Price)
+
+		bids, err := assetTransferCC.QueryAuctionBids(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Len(t, bids, 2)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAuctionSkipsBidsThatCannotBePaid(t *testing.T) {
+	network := newTestNetworkWithAuction(t)
+	assetTransferCC := SmartContract{}
+
+	fundAccount(t, network, myOrg2Msp, 200)
+	fundAccount(t, network, myOrg3Msp, 100)
+
+	submitBid(t, network, myOrg2Msp, 120)
+	submitBid(t, network, myOrg3Msp, 150)
+	network.now = network.now.Add(90 * time.Minute)
+	revealBid(t, network, myOrg2Msp, 120)
+	revealBid(t, network, myOrg3Msp, 150)
+	network.now = network.now.Add(time.Hour)
+
+	// Paying from the winner's account requires the winner's endorsement
+	err := network.submit(myOrg1Msp, propertiesTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.CloseAuction(ctx, testAssetID)
+	}, myOrg1Msp, myOrg2Msp)
+	require.NoError(t, err)
+
+	requireBalance(t, network, myOrg1Msp, 120)
+	requireBalance(t, network, myOrg2Msp, 80)
+	requireBalance(t, network, myOrg3Msp, 100)
+}
+
+func TestAuctionWithoutRevealedBids(t *testing.T) {
+	network := newTestNetworkWithAuction(t)
+	assetTransferCC := SmartContract{}
+
+	submitBid(t, network, myOrg2Msp, 120)
+	network.now = network.now.Add(3 * time.Hour)
+
+	err := network.submit(myOrg1Msp, propertiesTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.CloseAuction(ctx, testAssetID)
+	})
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		asset, err := assetTransferCC.ReadAsset(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, myOrg1Msp, asset.OwnerOrg)
+		require.Empty(t, asset.AuctionID)
+
+		auction, err := assetTransferCC.ReadAuction(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, auctionClosed, auction.Status)
+		require.Empty(t, auction.WinnerOrg)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The owner can open a new auction once the previous one is closed
+	err = network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.OpenAuction(ctx, testAssetID, "2020-09-01T16:00:00Z", "2020-09-01T17:00:00Z")
+	})
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		bids, err := assetTransferCC.QueryAuctionBids(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Empty(t, bids)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestOpenAuction(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	openAuction := func(clientOrg, biddingDeadline, revealDeadline string) error {
+		return network.submit(clientOrg, nil, func(ctx contractapi.TransactionContextInterface) error {
+			return assetTransferCC.OpenAuction(ctx, testAssetID, biddingDeadline, revealDeadline)
+		})
+	}
+
+	err := openAuction(myOrg2Msp, "2020-09-01T13:00:00Z", "2020-09-01T14:00:00Z")
+	require.EqualError(t, err, "a client from Org2Testmsp cannot auction an asset owned by Org1Testmsp")
+
+	err = openAuction(myOrg1Msp, "2020-09-01T11:00:00Z", "2020-09-01T14:00:00Z")
+	require.EqualError(t, err, "bidding deadline 2020-09-01T11:00:00Z must be after the transaction time 2020-09-01T12:00:00Z")
+
+	err = openAuction(myOrg1Msp, "2020-09-01T13:00:00Z", "2020-09-01T13:00:00Z")
+	require.EqualError(t, err, "reveal deadline 2020-09-01T13:00:00Z must be after the bidding deadline 2020-09-01T13:00:00Z")
+
+	err = openAuction(myOrg1Msp, "2020-09-01T13:00:00Z", "2020-09-01T14:00:00Z")
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		asset, err := assetTransferCC.ReadAsset(ctx, testAssetID)
+		require.NoError(t, err)
+
+		auction, err := assetTransferCC.ReadAuction(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, auction.ID, asset.AuctionID)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = openAuction(myOrg1Msp, "2020-09-01T13:00:00Z", "2020-09-01T14:00:00Z")
+	require.EqualError(t, err, "auction for asset1 is already open")
+}
+
+func newTestNetworkWithAuction(t *testing.T) *testNetwork {
+	network := newTestNetworkWithAsset(t)
+
+	err := network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return (&SmartContract{}).OpenAuction(ctx, testAssetID, "2020-09-01T13:00:00Z", "2020-09-01T14:00:00Z")
+	})
+	require.NoError(t, err)
+
+	return network
+}
+
+func submitBid(t *testing.T, network *testNetwork, orgID string, price int) {
+	err := network.submit(orgID, bidTransient(price), func(ctx contractapi.TransactionContextInterface) error {
+		return (&SmartContract{}).SubmitAuctionBid(ctx, testAssetID)
+	})
+	require.NoError(t, err)
+}
+
+func revealBid(t *testing.T, network *testNetwork, orgID string, price int) {
+	err := network.submit(orgID, bidTransient(price), func(ctx contractapi.TransactionContextInterface) error {
+		return (&SmartContract{}).RevealAuctionBid(ctx, testAssetID)
+	})
+	require.NoError(t, err)
+}
+
+func bidTransient(price int) map[string][]byte {
+	bid := fmt.Sprintf(`{"asset_id":"asset1","trade_id":"6f8db599de986fab7a21625b7916589c","price":%d}`, price)
+	return map[string][]byte{"asset_bid": []byte(bid)}
+}
@@ -0,0 +1,317 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Account holds the funds an org has deposited in escrow to pay for assets, and the proceeds of its sales.
+// Payments are optional: assets are only paid for from accounts once the escrow org is set.
+// Accounts are kept in the public state so that the peers of both the seller and buyer can update them
+// in the transfer transaction, therefore the balance changes reveal the price of a transfer.
+// Each account can only be updated with the endorsement of its org, see putAccount
+type Account struct {
+	OrgID   string `json:"orgID"`
+	Balance int    `json:"balance"`
+}
+
+// maxBalance is the largest balance an account can hold
+const maxBalance = int(^uint(0) >> 1)
+
+// SetEscrowOrg makes the org of the client the escrow org, which holds the funds backing the
+// accounts, and enables payments for transfers. Only clients of the escrow org can deposit funds,
+// after the escrow org has received them off-chain. The escrow org can only be set once, by an
+// admin, so SetEscrowOrg should be the init function of a chaincode definition that requires
+// initialization, which the admin deploying the chaincode invokes before any other transaction:
+//
+//	./network.sh deployCC -ccn secured -ccl go -ccep "OR('Org1MSP.peer','Org2MSP.peer')" -cci SetEscrowOrg
+func (s *SmartContract) SetEscrowOrg(ctx contractapi.TransactionContextInterface) error {
+	escrowOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	admin, err := isAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("only an admin can set the escrow org")
+	}
+
+	current, err := getEscrowOrg(ctx)
+	if err != nil {
+		return err
+	}
+	if current != "" {
+		return fmt.Errorf("escrow org is already set to %s", current)
+	}
+
+	escrowKey, err := ctx.GetStub().CreateCompositeKey(typeEscrow, []string{})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(escrowKey, []byte(escrowOrgID))
+	if err != nil {
+		return fmt.Errorf("failed to put escrow org: %v", err)
+	}
+
+	return nil
+}
+
+// ReadEscrowOrg returns the org that holds the funds backing the accounts
+func (s *SmartContract) ReadEscrowOrg(ctx contractapi.TransactionContextInterface) (string, error) {
+	escrowOrgID, err := getEscrowOrg(ctx)
+	if err != nil {
+		return "", err
+	}
+	if escrowOrgID == "" {
+		return "", fmt.Errorf("escrow org is not set")
+	}
+
+	return escrowOrgID, nil
+}
+
+// DepositFunds adds funds received by the escrow org to an org's account, opening the account if needed.
+// Only clients of the escrow org can deposit funds
+func (s *SmartContract) DepositFunds(ctx contractapi.TransactionContextInterface, orgID string, amount int) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be a positive integer")
+	}
+
+	clientOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	escrowOrgID, err := s.ReadEscrowOrg(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrgID != escrowOrgID {
+		return fmt.Errorf("a client from %s cannot deposit funds, only the escrow org %s can", clientOrgID, escrowOrgID)
+	}
+
+	account, err := getAccount(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		account = &Account{OrgID: orgID}
+	}
+
+	err = credit(account, amount)
+	if err != nil {
+		return err
+	}
+
+	return putAccount(ctx, account)
+}
+
+// WithdrawFunds removes funds from the client org's account
+func (s *SmartContract) WithdrawFunds(ctx contractapi.TransactionContextInterface, amount int) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be a positive integer")
+	}
+
+	clientOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	account, err := getAccount(ctx, clientOrgID)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return fmt.Errorf("account for %s does not exist", clientOrgID)
+	}
+	if account.Balance < amount {
+		return fmt.Errorf("account for %s has insufficient funds to withdraw %d", clientOrgID, amount)
+	}
+
+	account.Balance -= amount
+
+	return putAccount(ctx, account)
+}
+
+// ReadAccount returns the account of an org
+func (s *SmartContract) ReadAccount(ctx contractapi.TransactionContextInterface, orgID string) (*Account, error) {
+	account, err := getAccount(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account for %s does not exist", orgID)
+	}
+
+	return account, nil
+}
+
+// transferPayment pays the price of a transferred asset from the buyer's account to the seller's account,
+// if the escrow org is set. The buyer must then have an account with sufficient funds, so the asset is
+// never delivered without payment. Both accounts are updated, which requires the endorsement of the buyer
+// and seller orgs, who endorse the transfer anyway
+func transferPayment(ctx contractapi.TransactionContextInterface, buyerOrgID string, sellerOrgID string, price int) error {
+	buyerAccount, sellerAccount, err := getPaymentAccounts(ctx, buyerOrgID, sellerOrgID, price)
+	if err != nil {
+		return err
+	}
+	if buyerAccount == nil {
+		return nil
+	}
+
+	buyerAccount.Balance -= price
+	sellerAccount.Balance += price
+
+	err = putAccount(ctx, buyerAccount)
+	if err != nil {
+		return err
+	}
+
+	return putAccount(ctx, sellerAccount)
+}
+
+// getPaymentAccounts returns the accounts of the buyer and seller for paying the price of an asset,
+// or no accounts if the escrow org is not set and assets are transferred without payment.
+// The buyer must have an account with sufficient funds, the seller's balance must not overflow,
+// and a new account is returned if the seller has none
+func getPaymentAccounts(ctx contractapi.TransactionContextInterface, buyerOrgID string, sellerOrgID string, price int) (*Account, *Account, error) {
+	if price < 0 {
+		return nil, nil, fmt.Errorf("price %d must not be negative", price)
+	}
+
+	escrowOrgID, err := getEscrowOrg(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if escrowOrgID == "" {
+		return nil, nil, nil
+	}
+
+	buyerAccount, err := getAccount(ctx, buyerOrgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if buyerAccount == nil {
+		return nil, nil, fmt.Errorf("buyer %s has no account to pay from", buyerOrgID)
+	}
+	if buyerAccount.Balance < price {
+		return nil, nil, fmt.Errorf("buyer %s has insufficient funds to pay %d", buyerOrgID, price)
+	}
+
+	sellerAccount, err := getAccount(ctx, sellerOrgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sellerAccount == nil {
+		sellerAccount = &Account{OrgID: sellerOrgID}
+	}
+	if sellerAccount.Balance > maxBalance-price {
+		return nil, nil, fmt.Errorf("account for %s cannot receive %d without overflowing", sellerOrgID, price)
+	}
+
+	return buyerAccount, sellerAccount, nil
+}
+
+// credit adds an amount to the balance of an account, unless the balance would overflow
+func credit(account *Account, amount int) error {
+	if account.Balance > maxBalance-amount {
+		return fmt.Errorf("account for %s cannot receive %d without overflowing", account.OrgID, amount)
+	}
+
+	account.Balance += amount
+
+	return nil
+}
+
+// isAdmin reports whether the client has the admin role, given by the admin
+// organizational unit of its certificate when node OUs are enabled
+func isAdmin(ctx contractapi.TransactionContextInterface) (bool, error) {
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if cert == nil {
+		return false, nil
+	}
+
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if ou == "admin" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func getEscrowOrg(ctx contractapi.TransactionContextInterface) (string, error) {
+	escrowKey, err := ctx.GetStub().CreateCompositeKey(typeEscrow, []string{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	escrowOrgID, err := ctx.GetStub().GetState(escrowKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read escrow org from world state: %v", err)
+	}
+
+	return string(escrowOrgID), nil
+}
+
+func getAccount(ctx contractapi.TransactionContextInterface, orgID string) (*Account, error) {
+	accountKey, err := ctx.GetStub().CreateCompositeKey(typeAccount, []string{orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	accountJSON, err := ctx.GetStub().GetState(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account from world state: %v", err)
+	}
+	if accountJSON == nil {
+		return nil, nil
+	}
+
+	var account Account
+	err = json.Unmarshal(accountJSON, &account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account JSON: %v", err)
+	}
+
+	return &account, nil
+}
+
+// putAccount writes the account with an endorsement policy requiring the account owner's peer
+// to endorse updates, so that no org can change the balance of another org on its own.
+// Deposits must therefore be endorsed by the escrow org and the org of the account
+func putAccount(ctx contractapi.TransactionContextInterface, account *Account) error {
+	accountKey, err := ctx.GetStub().CreateCompositeKey(typeAccount, []string{account.OrgID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(accountKey, accountJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put account for %s: %v", account.OrgID, err)
+	}
+
+	err = setAssetStateBasedEndorsement(ctx, accountKey, account.OrgID)
+	if err != nil {
+		return fmt.Errorf("failed setting state based endorsement for account: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,240 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+const escrowMsp = "EscrowTestmsp"
+
+func TestDepositAndWithdrawFunds(t *testing.T) {
+	network := newTestNetwork()
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(escrowMsp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.DepositFunds(ctx, myOrg1Msp, 100)
+	})
+	require.EqualError(t, err, "escrow org is not set")
+
+	// Only an admin can set the escrow org
+	err = network.submit(escrowMsp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.SetEscrowOrg(ctx)
+	})
+	require.EqualError(t, err, "only an admin can set the escrow org")
+
+	setEscrowOrg(t, network)
+
+	err = network.submitAsAdmin(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.SetEscrowOrg(ctx)
+	})
+	require.EqualError(t, err, "escrow org is already set to EscrowTestmsp")
+
+	// Only the escrow org can deposit funds, so that every balance is backed by funds held in escrow
+	err = network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.DepositFunds(ctx, myOrg1Msp, 100)
+	})
+	require.EqualError(t, err, "a client from Org1Testmsp cannot deposit funds, only the escrow org EscrowTestmsp can")
+
+	err = network.submit(escrowMsp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.DepositFunds(ctx, myOrg1Msp, 0)
+	})
+	require.EqualError(t, err, "amount must be a positive integer")
+
+	err = network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.WithdrawFunds(ctx, 10)
+	})
+	require.EqualError(t, err, "account for Org1Testmsp does not exist")
+
+	fundAccount(t, network, myOrg1Msp, 100)
+
+	// Only the account owner's peer can endorse changes to the account
+	err = network.submit(escrowMsp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.DepositFunds(ctx, myOrg1Msp, 10)
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not endorsed by Org1Testmsp")
+
+	err = network.submit(escrowMsp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.DepositFunds(ctx, myOrg1Msp, maxBalance)
+	}, escrowMsp, myOrg1Msp)
+	require.EqualError(t, err, fmt.Sprintf("account for Org1Testmsp cannot receive %d without overflowing", maxBalance))
+
+	err = network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.WithdrawFunds(ctx, 150)
+	})
+	require.EqualError(t, err, "account for Org1Testmsp has insufficient funds to withdraw 150")
+
+	err = network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.WithdrawFunds(ctx, 40)
+	}, myOrg2Msp)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not endorsed by Org1Testmsp")
+
+	err = network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.WithdrawFunds(ctx, 40)
+	})
+	require.NoError(t, err)
+
+	requireBalance(t, network, myOrg1Msp, 60)
+}
+
+func TestTransferAssetPaysSeller(t *testing.T) {
+	network := newTestNetworkWithAgreedPrice(t)
+	assetTransferCC := SmartContract{}
+
+	fundAccount(t, network, myOrg2Msp, 200)
+
+	err := network.submit(myOrg1Msp, transferTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.NoError(t, err)
+
+	requireBalance(t, network, myOrg1Msp, 110)
+	requireBalance(t, network, myOrg2Msp, 90)
+}
+
+func TestTransferAssetRequiresPayment(t *testing.T) {
+	network := newTestNetworkWithAgreedPrice(t)
+	assetTransferCC := SmartContract{}
+
+	transfer := func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}
+
+	// Once the escrow org is set, payment is required even if neither org has an account
+	err := network.submit(myOrg1Msp, transferTransient(), transfer, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed payment: buyer Org2Testmsp has no account to pay from")
+
+	fundAccount(t, network, myOrg1Msp, 10)
+	fundAccount(t, network, myOrg2Msp, 100)
+
+	err = network.submit(myOrg1Msp, transferTransient(), transfer, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed payment: buyer Org2Testmsp has insufficient funds to pay 110")
+
+	// Neither the asset nor the funds have moved
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		asset, err := assetTransferCC.ReadAsset(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, myOrg1Msp, asset.OwnerOrg)
+		return nil
+	})
+	require.NoError(t, err)
+	requireBalance(t, network, myOrg1Msp, 10)
+	requireBalance(t, network, myOrg2Msp, 100)
+}
+
+func TestTransferAssetWithoutEscrow(t *testing.T) {
+	network := newTestNetwork()
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(myOrg1Msp, propertiesTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.CreateAsset(ctx, testAssetID, "a blue asset")
+	})
+	require.NoError(t, err)
+	err = network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+	err = network.submit(myOrg2Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	// Payments are only enabled by setting the escrow org, so the asset is transferred without accounts
+	err = network.submit(myOrg1Msp, transferTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		asset, err := assetTransferCC.ReadAsset(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, myOrg2Msp, asset.OwnerOrg)
+
+		_, err = assetTransferCC.ReadAccount(ctx, myOrg1Msp)
+		require.EqualError(t, err, "account for Org1Testmsp does not exist")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestTransferAssetRejectsNegativePrice(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+	negativePrice := `{"asset_id":"asset1","trade_id":"109f4b3c50d7b0df729d299bc6f8e9ef9066971f","price":-110}`
+
+	err := network.submit(myOrg1Msp, priceTransient(negativePrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	err = network.submit(myOrg2Msp, priceTransient(negativePrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	fundAccount(t, network, myOrg2Msp, 100)
+
+	transient := propertiesTransient()
+	transient["asset_price"] = []byte(negativePrice)
+	err = network.submit(myOrg1Msp, transient, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed payment: price -110 must not be negative")
+}
+
+func newTestNetworkWithAgreedPrice(t *testing.T) *testNetwork {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	err = network.submit(myOrg2Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	return network
+}
+
+func transferTransient() map[string][]byte {
+	transient := propertiesTransient()
+	transient["asset_price"] = []byte(testPrice)
+	return transient
+}
+
+// setEscrowOrg runs the init transaction that makes escrowMsp the escrow org, as an admin of escrowMsp
+func setEscrowOrg(t *testing.T, network *testNetwork) {
+	err := network.submitAsAdmin(escrowMsp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return (&SmartContract{}).SetEscrowOrg(ctx)
+	})
+	require.NoError(t, err)
+}
+
+// fundAccount deposits funds in an org's account as the escrow org, endorsed by the account owner
+func fundAccount(t *testing.T, network *testNetwork, orgID string, amount int) {
+	err := network.submit(escrowMsp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return (&SmartContract{}).DepositFunds(ctx, orgID, amount)
+	}, escrowMsp, orgID)
+	require.NoError(t, err)
+}
+
+func requireBalance(t *testing.T, network *testNetwork, orgID string, balance int) {
+	err := network.evaluate(orgID, nil, func(ctx contractapi.TransactionContextInterface) error {
+		account, err := (&SmartContract{}).ReadAccount(ctx, orgID)
+		require.NoError(t, err)
+		require.Equal(t, balance, account.Balance)
+		return nil
+	})
+	require.NoError(t, err)
+}
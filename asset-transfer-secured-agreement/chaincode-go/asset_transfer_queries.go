@@ -7,6 +7,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
@@ -20,6 +21,29 @@ type QueryResult struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// PaginatedQueryResult structure used for returning paginated query results and metadata
+type PaginatedQueryResult struct {
+	Records             []*Asset `json:"records"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
+
+// AssetChange is an entry in the timeline of changes to the public description and owner of an asset
+type AssetChange struct {
+	TxId              string    `json:"txId"`
+	Timestamp         time.Time `json:"timestamp"`
+	ChangeType        string    `json:"changeType"`
+	OwnerOrg          string    `json:"ownerOrg"`
+	PublicDescription string    `json:"publicDescription"`
+	PreviousValue     string    `json:"previousValue"`
+}
+
+const (
+	changeCreated     = "CREATED"
+	changeDescription = "DESCRIPTION"
+	changeOwner       = "OWNER"
+)
+
 type Agreement struct {
 	ID      string `json:"asset_id"`
 	Price   int    `json:"price"`
@@ -139,6 +163,185 @@ func queryAgreementsByType(ctx contractapi.TransactionContextInterface, agreeTyp
 	return agreements, nil
 }
 
+// QueryOpenOffers returns the client organization's asks and bids that have not expired
+func (s *SmartContract) QueryOpenOffers(ctx contractapi.TransactionContextInterface) ([]Offer, error) {
+	clientOrgID, err := getClientOrgID(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verified OrgID: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offersIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(typeOffer, []string{clientOrgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer offersIterator.Close()
+
+	var offers []Offer
+	for offersIterator.HasNext() {
+		resp, err := offersIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var offer Offer
+		err = json.Unmarshal(resp.Value, &offer)
+		if err != nil {
+			return nil, err
+		}
+
+		if txTime.Before(offer.Expiry) {
+			offers = append(offers, offer)
+		}
+	}
+
+	return offers, nil
+}
+
+// GetAssetSalesReceipts returns the receipts of all assets sold by the client's organization
+func (s *SmartContract) GetAssetSalesReceipts(ctx contractapi.TransactionContextInterface) ([]Receipt, error) {
+	return queryReceiptsByType(ctx, typeAssetSaleReceipt)
+}
+
+// GetAssetBuyReceipts returns the receipts of all assets bought by the client's organization
+func (s *SmartContract) GetAssetBuyReceipts(ctx contractapi.TransactionContextInterface) ([]Receipt, error) {
+	return queryReceiptsByType(ctx, typeAssetBuyReceipt)
+}
+
+func queryReceiptsByType(ctx contractapi.TransactionContextInterface, receiptType string) ([]Receipt, error) {
+	collection, err := getClientImplicitCollectionName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query for any object type starting with `receiptType`
+	receiptsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(collection, receiptType, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from private data collection: %v", err)
+	}
+	defer receiptsIterator.Close()
+
+	var receipts []Receipt
+	for receiptsIterator.HasNext() {
+		resp, err := receiptsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var receipt Receipt
+		err = json.Unmarshal(resp.Value, &receipt)
+		if err != nil {
+			return nil, err
+		}
+
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, nil
+}
+
+// QueryAssetsByOwner returns a page of the assets owned by an org, using the owner index rather than
+// a rich query so that paging does not depend on the state database. The number of returned records
+// may be less than the fetched records count if the index references assets that have since moved.
+// Assets created before the owner index was introduced are only returned once indexed with IndexAsset.
+// Paginated queries are only valid for read only transactions
+func (s *SmartContract) QueryAssetsByOwner(ctx contractapi.TransactionContextInterface, ownerOrgID string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be a positive integer")
+	}
+
+	indexIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(ownerIndex, []string{ownerOrgID}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read owner index: %v", err)
+	}
+	defer indexIterator.Close()
+
+	assets := []*Asset{}
+	for indexIterator.HasNext() {
+		responseRange, err := indexIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		asset, err := s.ReadAsset(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip index entries which do not match the current owner
+		if asset.OwnerOrg != ownerOrgID {
+			continue
+		}
+
+		assets = append(assets, asset)
+	}
+
+	return &PaginatedQueryResult{
+		Records:             assets,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// QueryAssetTimeline returns the changes to the public description and owner of an asset in
+// chronological order. A transaction changing both results in an entry for each change
+func (s *SmartContract) QueryAssetTimeline(ctx contractapi.TransactionContextInterface, assetID string) ([]AssetChange, error) {
+	history, err := s.QueryAssetHistory(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The order in which the history is returned depends on the Fabric version
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+
+	var timeline []AssetChange
+	var previous *Asset
+	for _, result := range history {
+		asset := result.Record
+		if asset == nil {
+			continue
+		}
+
+		change := AssetChange{
+			TxId:              result.TxId,
+			Timestamp:         result.Timestamp,
+			OwnerOrg:          asset.OwnerOrg,
+			PublicDescription: asset.PublicDescription,
+		}
+
+		if previous == nil {
+			change.ChangeType = changeCreated
+			timeline = append(timeline, change)
+		} else {
+			if asset.OwnerOrg != previous.OwnerOrg {
+				change.ChangeType = changeOwner
+				change.PreviousValue = previous.OwnerOrg
+				timeline = append(timeline, change)
+			}
+			if asset.PublicDescription != previous.PublicDescription {
+				change.ChangeType = changeDescription
+				change.PreviousValue = previous.PublicDescription
+				timeline = append(timeline, change)
+			}
+		}
+
+		previous = asset
+	}
+
+	return timeline, nil
+}
+
 // QueryAssetHistory returns the chain of custody for a asset since issuance
 func (s *SmartContract) QueryAssetHistory(ctx contractapi.TransactionContextInterface, assetID string) ([]QueryResult, error) {
 	resultsIterator, err := ctx.GetStub().GetHistoryForKey(assetID)
@@ -0,0 +1,142 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryAssetsByOwner(t *testing.T) {
+	network := newTestNetworkWithAgreedPrice(t)
+	assetTransferCC := SmartContract{}
+
+	for _, assetID := range []string{"asset2", "asset3"} {
+		err := network.submit(myOrg1Msp, propertiesTransient(), func(ctx contractapi.TransactionContextInterface) error {
+			return assetTransferCC.CreateAsset(ctx, assetID, "another asset")
+		})
+		require.NoError(t, err)
+	}
+
+	fundAccount(t, network, myOrg2Msp, 200)
+
+	err := network.submit(myOrg1Msp, transferTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		// Page through the assets of Org1 one at a time
+		var assetIDs []string
+		bookmark := ""
+		for {
+			result, err := assetTransferCC.QueryAssetsByOwner(ctx, myOrg1Msp, 1, bookmark)
+			require.NoError(t, err)
+			require.Equal(t, int32(1), result.FetchedRecordsCount)
+			for _, asset := range result.Records {
+				require.Equal(t, myOrg1Msp, asset.OwnerOrg)
+				assetIDs = append(assetIDs, asset.ID)
+			}
+			if result.Bookmark == "" {
+				break
+			}
+			bookmark = result.Bookmark
+		}
+		require.Equal(t, []string{"asset2", "asset3"}, assetIDs)
+
+		result, err := assetTransferCC.QueryAssetsByOwner(ctx, myOrg2Msp, 10, "")
+		require.NoError(t, err)
+		require.Len(t, result.Records, 1)
+		require.Equal(t, testAssetID, result.Records[0].ID)
+		require.Equal(t, "", result.Bookmark)
+
+		_, err = assetTransferCC.QueryAssetsByOwner(ctx, myOrg2Msp, 0, "")
+		require.EqualError(t, err, "page size must be a positive integer")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestQueryAssetTimeline(t *testing.T) {
+	network := newTestNetworkWithAgreedPrice(t)
+	assetTransferCC := SmartContract{}
+	createdAt := network.now
+	fundAccount(t, network, myOrg2Msp, 200)
+
+	network.now = network.now.Add(time.Minute)
+	err := network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.ChangePublicDescription(ctx, testAssetID, "a shiny blue asset")
+	})
+	require.NoError(t, err)
+	describedAt := network.now
+
+	network.now = network.now.Add(time.Minute)
+	err = network.submit(myOrg1Msp, transferTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.NoError(t, err)
+	transferredAt := network.now
+
+	network.now = network.now.Add(time.Minute)
+	err = network.submit(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.ChangePublicDescription(ctx, testAssetID, "an asset owned by Org2")
+	})
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		timeline, err := assetTransferCC.QueryAssetTimeline(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, []AssetChange{
+			{TxId: "tx2", Timestamp: createdAt, ChangeType: changeCreated, OwnerOrg: myOrg1Msp, PublicDescription: "a blue asset"},
+			{TxId: "tx6", Timestamp: describedAt, ChangeType: changeDescription, OwnerOrg: myOrg1Msp, PublicDescription: "a shiny blue asset", PreviousValue: "a blue asset"},
+			{TxId: "tx7", Timestamp: transferredAt, ChangeType: changeOwner, OwnerOrg: myOrg2Msp, PublicDescription: "a shiny blue asset", PreviousValue: myOrg1Msp},
+			{TxId: "tx8", Timestamp: network.now, ChangeType: changeDescription, OwnerOrg: myOrg2Msp, PublicDescription: "an asset owned by Org2", PreviousValue: "a shiny blue asset"},
+		}, timeline)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestIndexAsset(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	// Simulate an asset created before the owner index was introduced
+	indexKey, err := shim.CreateCompositeKey(ownerIndex, []string{myOrg1Msp, testAssetID})
+	require.NoError(t, err)
+	delete(network.state, indexKey)
+
+	queryOwnedAssets := func() []*Asset {
+		var assets []*Asset
+		err := network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+			result, err := assetTransferCC.QueryAssetsByOwner(ctx, myOrg1Msp, 10, "")
+			require.NoError(t, err)
+			assets = result.Records
+			return nil
+		})
+		require.NoError(t, err)
+		return assets
+	}
+	require.Empty(t, queryOwnedAssets())
+
+	indexAsset := func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.IndexAsset(ctx, testAssetID)
+	}
+	err = network.submit(myOrg2Msp, nil, indexAsset)
+	require.EqualError(t, err, "a client from Org2Testmsp cannot index an asset owned by Org1Testmsp")
+
+	err = network.submit(myOrg1Msp, nil, indexAsset)
+	require.NoError(t, err)
+	require.Len(t, queryOwnedAssets(), 1)
+
+	// Indexing again does not duplicate the asset
+	err = network.submit(myOrg1Msp, nil, indexAsset)
+	require.NoError(t, err)
+	require.Len(t, queryOwnedAssets(), 1)
+}
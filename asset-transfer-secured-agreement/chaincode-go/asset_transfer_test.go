@@ -0,0 +1,504 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/hyperledger/fabric-samples/chaincode/tradingMarbles/mocks"
+)
+
+/*
+These unit tests use mocks to simulate chaincode-api & fabric interactions
+The mocks are generated using counterfeiter directives in the comments (starting with "go:generate counterfeiter")
+All files in mocks/* are generated by running following, in the directory with your directive:
+	`go generate`
+*/
+
+//go:generate counterfeiter -o mocks/transaction.go -fake-name TransactionContext . transactionContext
+type transactionContext interface {
+	contractapi.TransactionContextInterface
+}
+
+//go:generate counterfeiter -o mocks/chaincodestub.go -fake-name ChaincodeStub . chaincodeStub
+type chaincodeStub interface {
+	shim.ChaincodeStubInterface
+}
+
+//go:generate counterfeiter -o mocks/statequeryiterator.go -fake-name StateQueryIterator . stateQueryIterator
+type stateQueryIterator interface {
+	shim.StateQueryIteratorInterface
+}
+
+//go:generate counterfeiter -o mocks/clientIdentity.go -fake-name ClientIdentity . clientIdentity
+type clientIdentity interface {
+	cid.ClientIdentity
+}
+
+const myOrg1Msp = "Org1Testmsp"
+const myOrg1Collection = "_implicit_org_Org1Testmsp"
+const myOrg2Msp = "Org2Testmsp"
+const myOrg2Collection = "_implicit_org_Org2Testmsp"
+
+const testAssetID = "asset1"
+const testProperties = `{"object_type":"asset_properties","asset_id":"asset1","color":"blue","size":35,"salt":"a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"}`
+const testPrice = `{"asset_id":"asset1","trade_id":"109f4b3c50d7b0df729d299bc6f8e9ef9066971f","price":110}`
+const testExpiry = "2020-09-02T12:00:00Z"
+
+func TestTransferAssetWritesReceipts(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := SmartContract{}
+
+	assetJSON, err := json.Marshal(Asset{ObjectType: "asset", ID: testAssetID, OwnerOrg: myOrg1Msp, PublicDescription: "a blue asset"})
+	require.NoError(t, err)
+	buyerAccountKey, err := shim.CreateCompositeKey(typeAccount, []string{myOrg2Msp})
+	require.NoError(t, err)
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		if key == testAssetID {
+			return assetJSON, nil
+		}
+		if key == buyerAccountKey {
+			return json.Marshal(Account{OrgID: myOrg2Msp, Balance: 200})
+		}
+		if strings.HasPrefix(key, "\x00"+typeOffer+"\x00") {
+			return json.Marshal(Offer{Expiry: time.Date(2020, 9, 2, 12, 0, 0, 0, time.UTC)})
+		}
+		return nil, nil
+	}
+	chaincodeStub.GetTransientReturns(map[string][]byte{
+		"asset_properties": []byte(testProperties),
+		"asset_price":      []byte(testPrice),
+	}, nil)
+	chaincodeStub.GetPrivateDataHashStub = func(collection string, key string) ([]byte, error) {
+		if key == testAssetID {
+			return hashOf(testProperties), nil
+		}
+		return hashOf(testPrice), nil
+	}
+	txTime := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	chaincodeStub.GetTxIDReturns("tx1")
+	chaincodeStub.GetTxTimestampReturns(timestamppb.New(txTime), nil)
+
+	err = assetTransferCC.TransferAsset(transactionContext, testAssetID, myOrg2Msp)
+	require.NoError(t, err)
+
+	buyReceiptKey, err := shim.CreateCompositeKey(typeAssetBuyReceipt, []string{testAssetID, "tx1"})
+	require.NoError(t, err)
+	saleReceiptKey, err := shim.CreateCompositeKey(typeAssetSaleReceipt, []string{"tx1", testAssetID})
+	require.NoError(t, err)
+
+	receipts := map[string][]byte{}
+	for i := 0; i < chaincodeStub.PutPrivateDataCallCount(); i++ {
+		collection, key, value := chaincodeStub.PutPrivateDataArgsForCall(i)
+		receipts[collection+"/"+key] = value
+	}
+
+	var buyReceipt Receipt
+	require.NoError(t, json.Unmarshal(receipts[myOrg2Collection+"/"+buyReceiptKey], &buyReceipt))
+	require.Equal(t, Receipt{AssetID: testAssetID, Price: 110, Timestamp: txTime, CounterpartyOrg: myOrg1Msp, TxID: "tx1"}, buyReceipt)
+
+	var saleReceipt Receipt
+	require.NoError(t, json.Unmarshal(receipts[myOrg1Collection+"/"+saleReceiptKey], &saleReceipt))
+	require.Equal(t, Receipt{AssetID: testAssetID, Price: 110, Timestamp: txTime, CounterpartyOrg: myOrg2Msp, TxID: "tx1"}, saleReceipt)
+}
+
+func TestGetAssetSalesReceipts(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := SmartContract{}
+
+	receipt := Receipt{AssetID: testAssetID, Price: 110, Timestamp: time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC), CounterpartyOrg: myOrg2Msp, TxID: "tx1"}
+	receiptJSON, err := json.Marshal(receipt)
+	require.NoError(t, err)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturnsOnCall(0, true)
+	iterator.HasNextReturnsOnCall(1, false)
+	iterator.NextReturns(&queryresult.KV{Value: receiptJSON}, nil)
+	chaincodeStub.GetPrivateDataByPartialCompositeKeyReturns(iterator, nil)
+
+	receipts, err := assetTransferCC.GetAssetSalesReceipts(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, []Receipt{receipt}, receipts)
+
+	collection, objectType, attributes := chaincodeStub.GetPrivateDataByPartialCompositeKeyArgsForCall(0)
+	require.Equal(t, myOrg1Collection, collection)
+	require.Equal(t, typeAssetSaleReceipt, objectType)
+	require.Empty(t, attributes)
+
+	chaincodeStub.GetPrivateDataByPartialCompositeKeyReturns(nil, fmt.Errorf("failed retrieving receipts"))
+	receipts, err = assetTransferCC.GetAssetSalesReceipts(transactionContext)
+	require.EqualError(t, err, "failed to read from private data collection: failed retrieving receipts")
+	require.Nil(t, receipts)
+}
+
+func TestGetAssetBuyReceipts(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := SmartContract{}
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturnsOnCall(0, true)
+	iterator.HasNextReturnsOnCall(1, false)
+	iterator.NextReturns(&queryresult.KV{Value: []byte("not a receipt")}, nil)
+	chaincodeStub.GetPrivateDataByPartialCompositeKeyReturns(iterator, nil)
+
+	_, err := assetTransferCC.GetAssetBuyReceipts(transactionContext)
+	require.Error(t, err)
+
+	collection, objectType, _ := chaincodeStub.GetPrivateDataByPartialCompositeKeyArgsForCall(0)
+	require.Equal(t, myOrg2Collection, collection)
+	require.Equal(t, typeAssetBuyReceipt, objectType)
+
+	// A client may only read receipts from its own org's peer
+	transactionContext, chaincodeStub = prepMocksAsOrg2()
+	os.Setenv("CORE_PEER_LOCALMSPID", myOrg1Msp)
+	_, err = assetTransferCC.GetAssetBuyReceipts(transactionContext)
+	require.EqualError(t, err, "failed to get verified OrgID: client from org Org2Testmsp is not authorized to read or write private data from an org Org1Testmsp peer")
+	require.Equal(t, 0, chaincodeStub.GetPrivateDataByPartialCompositeKeyCallCount())
+}
+
+func prepMocksAsOrg1() (*mocks.TransactionContext, *mocks.ChaincodeStub) {
+	return prepMocks(myOrg1Msp)
+}
+func prepMocksAsOrg2() (*mocks.TransactionContext, *mocks.ChaincodeStub) {
+	return prepMocks(myOrg2Msp)
+}
+func prepMocks(orgMSP string) (*mocks.TransactionContext, *mocks.ChaincodeStub) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.CreateCompositeKeyStub = shim.CreateCompositeKey
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	clientIdentity := &mocks.ClientIdentity{}
+	clientIdentity.GetMSPIDReturns(orgMSP, nil)
+	//set matching msp ID using peer shim env variable
+	os.Setenv("CORE_PEER_LOCALMSPID", orgMSP)
+	transactionContext.GetClientIdentityReturns(clientIdentity)
+	return transactionContext, chaincodeStub
+}
+
+func hashOf(value string) []byte {
+	hash := sha256.Sum256([]byte(value))
+	return hash[:]
+}
+
+func TestSecuredTransfer(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	// The buyer verifies the private properties against the hash in the seller's collection
+	err = network.evaluate(myOrg2Msp, propertiesTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		verified, err := assetTransferCC.VerifyAssetProperties(ctx, testAssetID)
+		require.True(t, verified)
+		return err
+	})
+	require.NoError(t, err)
+
+	err = network.submit(myOrg2Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	fundAccount(t, network, myOrg2Msp, 200)
+
+	transferTransient := propertiesTransient()
+	transferTransient["asset_price"] = []byte(testPrice)
+	err = network.submit(myOrg1Msp, transferTransient, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		asset, err := assetTransferCC.ReadAsset(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, myOrg2Msp, asset.OwnerOrg)
+
+		properties, err := assetTransferCC.GetAssetPrivateProperties(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, testProperties, properties)
+
+		_, err = assetTransferCC.GetAssetBidPrice(ctx, testAssetID)
+		require.EqualError(t, err, "asset price does not exist: asset1")
+
+		receipts, err := assetTransferCC.GetAssetBuyReceipts(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []Receipt{{AssetID: testAssetID, Price: 110, Timestamp: network.now, CounterpartyOrg: myOrg1Msp, TxID: "tx6"}}, receipts)
+
+		history, err := assetTransferCC.QueryAssetHistory(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := assetTransferCC.GetAssetPrivateProperties(ctx, testAssetID)
+		require.EqualError(t, err, "asset private details does not exist in client org's collection: asset1")
+
+		_, err = assetTransferCC.GetAssetSalesPrice(ctx, testAssetID)
+		require.EqualError(t, err, "asset price does not exist: asset1")
+
+		receipts, err := assetTransferCC.GetAssetSalesReceipts(ctx)
+		require.NoError(t, err)
+		require.Len(t, receipts, 1)
+		require.Equal(t, myOrg2Msp, receipts[0].CounterpartyOrg)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The new owner's peer must now endorse updates to the asset
+	err = network.submit(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.ChangePublicDescription(ctx, testAssetID, "an asset owned by Org2")
+	}, myOrg1Msp)
+	require.EqualError(t, err, "endorsement policy failure for key asset1: not endorsed by Org2Testmsp")
+
+	err = network.submit(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.ChangePublicDescription(ctx, testAssetID, "an asset owned by Org2")
+	})
+	require.NoError(t, err)
+}
+
+func TestTransferAssetWithoutAgreedPrice(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	transferTransient := propertiesTransient()
+	transferTransient["asset_price"] = []byte(testPrice)
+	transfer := func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}
+
+	err := network.submit(myOrg1Msp, transferTransient, transfer, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed transfer verification: seller price for asset1 does not exist")
+
+	err = network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	err = network.submit(myOrg1Msp, transferTransient, transfer, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed transfer verification: buyer price for asset1 does not exist")
+
+	lowerPrice := `{"asset_id":"asset1","trade_id":"109f4b3c50d7b0df729d299bc6f8e9ef9066971f","price":100}`
+	err = network.submit(myOrg2Msp, priceTransient(lowerPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	err = network.submit(myOrg1Msp, transferTransient, transfer, myOrg1Msp, myOrg2Msp)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "buyer hasn't agreed to the passed trade id and price")
+
+	// Only the owner can transfer the asset
+	err = network.submit(myOrg2Msp, transferTransient, transfer, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed transfer verification: a client from Org2Testmsp cannot transfer a asset owned by Org1Testmsp")
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		asset, err := assetTransferCC.ReadAsset(ctx, testAssetID)
+		require.NoError(t, err)
+		require.Equal(t, myOrg1Msp, asset.OwnerOrg)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestPrivateDataIsOnlyReadableFromOwnPeer(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	_, err := network.execute("query", myOrg2Msp, myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := assetTransferCC.GetAssetPrivateProperties(ctx, testAssetID)
+		return err
+	})
+	require.EqualError(t, err, "failed to get verified OrgID: client from org Org2Testmsp is not authorized to read or write private data from an org Org1Testmsp peer")
+
+	err = network.evaluate(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := ctx.GetStub().GetPrivateData(myOrg1Collection, testAssetID)
+		return err
+	})
+	require.EqualError(t, err, "peer of Org2Testmsp is not a member of collection _implicit_org_Org1Testmsp")
+}
+
+func TestOfferExpiry(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, "2020-09-01T11:00:00Z")
+	})
+	require.EqualError(t, err, "expiry 2020-09-01T11:00:00Z must be after the transaction time 2020-09-01T12:00:00Z")
+
+	err = network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, "tomorrow")
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to parse expiry tomorrow")
+
+	err = network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, "2020-09-01T13:00:00Z")
+	})
+	require.NoError(t, err)
+	err = network.submit(myOrg2Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, "2020-09-01T15:00:00Z")
+	})
+	require.NoError(t, err)
+	fundAccount(t, network, myOrg2Msp, 200)
+
+	transferTransient := propertiesTransient()
+	transferTransient["asset_price"] = []byte(testPrice)
+	transfer := func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}
+
+	network.now = network.now.Add(90 * time.Minute)
+	err = network.submit(myOrg1Msp, transferTransient, transfer, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed transfer verification: offer from Org1Testmsp for asset1 expired at 2020-09-01T13:00:00Z")
+
+	// The seller renews the ask
+	err = network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, "2020-09-01T16:00:00Z")
+	})
+	require.NoError(t, err)
+
+	network.now = network.now.Add(2 * time.Hour)
+	err = network.submit(myOrg1Msp, transferTransient, transfer, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed transfer verification: offer from Org2Testmsp for asset1 expired at 2020-09-01T15:00:00Z")
+
+	err = network.submit(myOrg2Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, "2020-09-01T16:00:00Z")
+	})
+	require.NoError(t, err)
+
+	// Deleting the buyer's offer requires the endorsement of the buyer's peer
+	err = network.submit(myOrg1Msp, transferTransient, transfer, myOrg1Msp)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not endorsed by Org2Testmsp")
+
+	err = network.submit(myOrg1Msp, transferTransient, transfer, myOrg1Msp, myOrg2Msp)
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		offers, err := assetTransferCC.QueryOpenOffers(ctx)
+		require.NoError(t, err)
+		require.Empty(t, offers)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestWithdrawOffers(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(myOrg1Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToSell(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+	err = network.submit(myOrg2Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, testExpiry)
+	})
+	require.NoError(t, err)
+
+	withdrawBid := func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.WithdrawBid(ctx, testAssetID)
+	}
+	err = network.submit(myOrg2Msp, nil, withdrawBid)
+	require.NoError(t, err)
+	err = network.submit(myOrg2Msp, nil, withdrawBid)
+	require.EqualError(t, err, "asset price does not exist: asset1")
+
+	transferTransient := propertiesTransient()
+	transferTransient["asset_price"] = []byte(testPrice)
+	err = network.submit(myOrg1Msp, transferTransient, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.TransferAsset(ctx, testAssetID, myOrg2Msp)
+	}, myOrg1Msp, myOrg2Msp)
+	require.EqualError(t, err, "failed transfer verification: buyer price for asset1 does not exist")
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		offers, err := assetTransferCC.QueryOpenOffers(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []Offer{{AssetID: testAssetID, OrgID: myOrg1Msp, OfferType: typeAssetForSale, Expiry: time.Date(2020, 9, 2, 12, 0, 0, 0, time.UTC)}}, offers)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = network.submit(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.WithdrawSellOffer(ctx, testAssetID)
+	})
+	require.NoError(t, err)
+
+	err = network.evaluate(myOrg1Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := assetTransferCC.GetAssetSalesPrice(ctx, testAssetID)
+		require.EqualError(t, err, "asset price does not exist: asset1")
+
+		offers, err := assetTransferCC.QueryOpenOffers(ctx)
+		require.NoError(t, err)
+		require.Empty(t, offers)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestQueryOpenOffersExcludesExpiredOffers(t *testing.T) {
+	network := newTestNetworkWithAsset(t)
+	assetTransferCC := SmartContract{}
+
+	err := network.submit(myOrg2Msp, priceTransient(testPrice), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.AgreeToBuy(ctx, testAssetID, "2020-09-01T13:00:00Z")
+	})
+	require.NoError(t, err)
+
+	queryOffers := func(expected int) {
+		err := network.evaluate(myOrg2Msp, nil, func(ctx contractapi.TransactionContextInterface) error {
+			offers, err := assetTransferCC.QueryOpenOffers(ctx)
+			require.Len(t, offers, expected)
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	queryOffers(1)
+	network.now = network.now.Add(time.Hour)
+	queryOffers(0)
+}
+
+func newTestNetworkWithAsset(t *testing.T) *testNetwork {
+	network := newTestNetwork()
+	assetTransferCC := SmartContract{}
+
+	setEscrowOrg(t, network)
+
+	err := network.submit(myOrg1Msp, propertiesTransient(), func(ctx contractapi.TransactionContextInterface) error {
+		return assetTransferCC.CreateAsset(ctx, testAssetID, "a blue asset")
+	})
+	require.NoError(t, err)
+
+	return network
+}
+
+func propertiesTransient() map[string][]byte {
+	return map[string][]byte{"asset_properties": []byte(testProperties)}
+}
+
+func priceTransient(price string) map[string][]byte {
+	return map[string][]byte{"asset_price": []byte(price)}
+}
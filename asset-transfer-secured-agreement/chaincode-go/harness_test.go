@@ -0,0 +1,537 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/hyperledger/fabric-samples/chaincode/tradingMarbles/mocks"
+)
+
+/*
+The test network is an in-memory simulation of a channel shared by the peers of several orgs.
+Transactions are executed against a testStub implementing shim.ChaincodeStubInterface:
+  - reads return the committed state, writes are only committed when the transaction is valid
+  - a peer can only read private data from its own org's implicit collection,
+    but can read the hash of private data in any collection
+  - a submitted transaction is executed on a peer of each endorsing org, the write sets
+    of all peers must match, and writes to keys with a state-based endorsement policy
+    must be endorsed by all orgs in the policy
+*/
+
+// txFunc is a transaction function executed with the context of a single peer
+type txFunc func(ctx contractapi.TransactionContextInterface) error
+
+type kvWrite struct {
+	value    []byte
+	isDelete bool
+}
+
+// testNetwork holds the committed public and private state of the channel
+type testNetwork struct {
+	state      map[string][]byte
+	validation map[string][]byte
+	private    map[string]map[string][]byte
+	history    map[string][]*queryresult.KeyModification
+	// now is the timestamp of the next transaction
+	now     time.Time
+	txCount int
+	// clientRole is the node OU of the clients of the next transactions, client by default
+	clientRole string
+}
+
+func newTestNetwork() *testNetwork {
+	return &testNetwork{
+		state:      map[string][]byte{},
+		validation: map[string][]byte{},
+		private:    map[string]map[string][]byte{},
+		history:    map[string][]*queryresult.KeyModification{},
+		now:        time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// submit executes fn as a client of clientOrg on a peer of each endorsing org, which defaults
+// to the client's org, validates the endorsements and commits the writes
+func (n *testNetwork) submit(clientOrg string, transient map[string][]byte, fn txFunc, endorsingOrgs ...string) error {
+	if len(endorsingOrgs) == 0 {
+		endorsingOrgs = []string{clientOrg}
+	}
+
+	n.txCount++
+	txID := fmt.Sprintf("tx%d", n.txCount)
+
+	var endorsed *testStub
+	for _, peerOrg := range endorsingOrgs {
+		stub, err := n.execute(txID, clientOrg, peerOrg, transient, fn)
+		if err != nil {
+			return err
+		}
+
+		if endorsed != nil && !endorsed.sameWrites(stub) {
+			return fmt.Errorf("endorsement mismatch: peers of %s and %s produced different write sets", endorsingOrgs[0], peerOrg)
+		}
+		endorsed = stub
+	}
+
+	err := n.validate(endorsed, endorsingOrgs)
+	if err != nil {
+		return err
+	}
+
+	n.commit(endorsed)
+
+	return nil
+}
+
+// submitAsAdmin submits a transaction as an admin of clientOrg
+func (n *testNetwork) submitAsAdmin(clientOrg string, transient map[string][]byte, fn txFunc, endorsingOrgs ...string) error {
+	n.clientRole = "admin"
+	defer func() { n.clientRole = "" }()
+
+	return n.submit(clientOrg, transient, fn, endorsingOrgs...)
+}
+
+// evaluate executes fn as a client of clientOrg on a peer of the client's org without committing
+func (n *testNetwork) evaluate(clientOrg string, transient map[string][]byte, fn txFunc) error {
+	_, err := n.execute("query", clientOrg, clientOrg, transient, fn)
+	return err
+}
+
+func (n *testNetwork) execute(txID, clientOrg, peerOrg string, transient map[string][]byte, fn txFunc) (*testStub, error) {
+	txTimestamp, err := ptypes.TimestampProto(n.now)
+	if err != nil {
+		return nil, err
+	}
+
+	stub := &testStub{
+		network:          n,
+		peerOrg:          peerOrg,
+		txID:             txID,
+		txTimestamp:      txTimestamp,
+		transient:        transient,
+		writes:           map[string]kvWrite{},
+		privateWrites:    map[string]map[string]kvWrite{},
+		validationWrites: map[string][]byte{},
+		events:           map[string][]byte{},
+	}
+
+	clientRole := n.clientRole
+	if clientRole == "" {
+		clientRole = "client"
+	}
+	clientIdentity := &mocks.ClientIdentity{}
+	clientIdentity.GetMSPIDReturns(clientOrg, nil)
+	clientIdentity.GetX509CertificateReturns(&x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{clientRole}}}, nil)
+
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(clientIdentity)
+
+	// The peer's org is read by the shim from the peer's environment
+	os.Setenv("CORE_PEER_LOCALMSPID", peerOrg)
+
+	err = fn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return stub, nil
+}
+
+// validate checks that every public key written by the transaction which has a state-based
+// endorsement policy was endorsed by all orgs in the policy
+func (n *testNetwork) validate(stub *testStub, endorsingOrgs []string) error {
+	endorsed := map[string]bool{}
+	for _, org := range endorsingOrgs {
+		endorsed[org] = true
+	}
+
+	keys := []string{}
+	for key := range stub.writes {
+		keys = append(keys, key)
+	}
+	for key := range stub.validationWrites {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		policy, ok := n.validation[key]
+		if !ok {
+			continue
+		}
+
+		ep, err := statebased.NewStateEP(policy)
+		if err != nil {
+			return err
+		}
+
+		for _, org := range ep.ListOrgs() {
+			if !endorsed[org] {
+				return fmt.Errorf("endorsement policy failure for key %s: not endorsed by %s", key, org)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (n *testNetwork) commit(stub *testStub) {
+	for key, write := range stub.writes {
+		if write.isDelete {
+			// the validation parameter is metadata of the key and deleted with it
+			delete(n.state, key)
+			delete(n.validation, key)
+		} else {
+			n.state[key] = write.value
+		}
+
+		n.history[key] = append(n.history[key], &queryresult.KeyModification{
+			TxId:      stub.txID,
+			Value:     write.value,
+			Timestamp: stub.txTimestamp,
+			IsDelete:  write.isDelete,
+		})
+	}
+
+	for key, policy := range stub.validationWrites {
+		n.validation[key] = policy
+	}
+
+	for collection, writes := range stub.privateWrites {
+		if n.private[collection] == nil {
+			n.private[collection] = map[string][]byte{}
+		}
+
+		for key, write := range writes {
+			if write.isDelete {
+				delete(n.private[collection], key)
+			} else {
+				n.private[collection][key] = write.value
+			}
+		}
+	}
+}
+
+// testStub implements shim.ChaincodeStubInterface for a
+// single transaction executed on a peer of peerOrg
+type testStub struct {
+	network          *testNetwork
+	peerOrg          string
+	txID             string
+	txTimestamp      *timestamp.Timestamp
+	transient        map[string][]byte
+	writes           map[string]kvWrite
+	privateWrites    map[string]map[string]kvWrite
+	validationWrites map[string][]byte
+	events           map[string][]byte
+}
+
+func (s *testStub) sameWrites(other *testStub) bool {
+	return reflect.DeepEqual(s.writes, other.writes) &&
+		reflect.DeepEqual(s.privateWrites, other.privateWrites) &&
+		reflect.DeepEqual(s.validationWrites, other.validationWrites) &&
+		reflect.DeepEqual(s.events, other.events)
+}
+
+func (s *testStub) isMember(collection string) bool {
+	return collection == buildCollectionName(s.peerOrg)
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	return nil
+}
+
+func (s *testStub) GetStringArgs() []string {
+	return nil
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	return "", nil
+}
+
+func (s *testStub) GetArgsSlice() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testStub) GetTxID() string {
+	return s.txID
+}
+
+func (s *testStub) GetChannelID() string {
+	return "testchannel"
+}
+
+func (s *testStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	return shim.Error("InvokeChaincode is not supported by the test stub")
+}
+
+func (s *testStub) GetState(key string) ([]byte, error) {
+	return s.network.state[key], nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	s.writes[key] = kvWrite{value: value}
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	s.writes[key] = kvWrite{isDelete: true}
+	return nil
+}
+
+func (s *testStub) SetStateValidationParameter(key string, ep []byte) error {
+	s.validationWrites[key] = ep
+	return nil
+}
+
+func (s *testStub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.network.validation[key], nil
+}
+
+func (s *testStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	return newTestIterator(s.network.state, startKey, endKey), nil
+}
+
+func (s *testStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return newTestPage(s.network.state, startKey, endKey, pageSize, bookmark)
+}
+
+func (s *testStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return newTestIterator(s.network.state, startKey, endKey), nil
+}
+
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newTestPage(s.network.state, startKey, endKey, pageSize, bookmark)
+}
+
+func (s *testStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+func (s *testStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(compositeKey, "\x00"), "\x00"), "\x00")
+	return parts[0], parts[1:], nil
+}
+
+func (s *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("GetQueryResult is not supported by the test stub")
+}
+
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return nil, nil, fmt.Errorf("GetQueryResultWithPagination is not supported by the test stub")
+}
+
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &testHistoryIterator{modifications: s.network.history[key]}, nil
+}
+
+func (s *testStub) GetPrivateData(collection, key string) ([]byte, error) {
+	if !s.isMember(collection) {
+		return nil, fmt.Errorf("peer of %s is not a member of collection %s", s.peerOrg, collection)
+	}
+	return s.network.private[collection][key], nil
+}
+
+func (s *testStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	value, ok := s.network.private[collection][key]
+	if !ok {
+		return nil, nil
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+func (s *testStub) PutPrivateData(collection string, key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if s.privateWrites[collection] == nil {
+		s.privateWrites[collection] = map[string]kvWrite{}
+	}
+	s.privateWrites[collection][key] = kvWrite{value: value}
+	return nil
+}
+
+func (s *testStub) DelPrivateData(collection, key string) error {
+	if s.privateWrites[collection] == nil {
+		s.privateWrites[collection] = map[string]kvWrite{}
+	}
+	s.privateWrites[collection][key] = kvWrite{isDelete: true}
+	return nil
+}
+
+func (s *testStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return fmt.Errorf("SetPrivateDataValidationParameter is not supported by the test stub")
+}
+
+func (s *testStub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return nil, fmt.Errorf("GetPrivateDataValidationParameter is not supported by the test stub")
+}
+
+func (s *testStub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if !s.isMember(collection) {
+		return nil, fmt.Errorf("peer of %s is not a member of collection %s", s.peerOrg, collection)
+	}
+	return newTestIterator(s.network.private[collection], startKey, endKey), nil
+}
+
+func (s *testStub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPrivateDataByRange(collection, startKey, endKey)
+}
+
+func (s *testStub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("GetPrivateDataQueryResult is not supported by the test stub")
+}
+
+func (s *testStub) GetCreator() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *testStub) GetBinding() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *testStub) GetDecorations() map[string][]byte {
+	return nil
+}
+
+func (s *testStub) GetSignedProposal() (*pb.SignedProposal, error) {
+	return nil, nil
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return s.txTimestamp, nil
+}
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	s.events[name] = payload
+	return nil
+}
+
+// partialCompositeKeyRange returns the range of keys starting with the partial composite key
+func partialCompositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	partialKey, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+	return partialKey, partialKey + string(utf8MaxRune), nil
+}
+
+const utf8MaxRune = '\U0010FFFF'
+
+// testIterator iterates over a sorted snapshot of the
+// keys from startKey (inclusive) to endKey (exclusive)
+type testIterator struct {
+	kvs []*queryresult.KV
+}
+
+func newTestIterator(values map[string][]byte, startKey, endKey string) *testIterator {
+	keys := []string{}
+	for key := range values {
+		if key >= startKey && (endKey == "" || key < endKey) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	iterator := &testIterator{}
+	for _, key := range keys {
+		iterator.kvs = append(iterator.kvs, &queryresult.KV{Key: key, Value: values[key]})
+	}
+	return iterator
+}
+
+// newTestPage returns an iterator over at most pageSize keys in the range, starting at the
+// bookmark if one is given. Like the peer, the bookmark is the key of the next result
+func newTestPage(values map[string][]byte, startKey, endKey string, pageSize int32, bookmark string) (*testIterator, *pb.QueryResponseMetadata, error) {
+	if pageSize <= 0 {
+		return nil, nil, fmt.Errorf("page size must be greater than zero")
+	}
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	iterator := newTestIterator(values, startKey, endKey)
+	metadata := &pb.QueryResponseMetadata{}
+	if len(iterator.kvs) > int(pageSize) {
+		metadata.Bookmark = iterator.kvs[pageSize].Key
+		iterator.kvs = iterator.kvs[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(iterator.kvs))
+	return iterator, metadata, nil
+}
+
+func (i *testIterator) HasNext() bool {
+	return len(i.kvs) > 0
+}
+
+func (i *testIterator) Next() (*queryresult.KV, error) {
+	if len(i.kvs) == 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+	kv := i.kvs[0]
+	i.kvs = i.kvs[1:]
+	return kv, nil
+}
+
+func (i *testIterator) Close() error {
+	return nil
+}
+
+type testHistoryIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (i *testHistoryIterator) HasNext() bool {
+	return len(i.modifications) > 0
+}
+
+func (i *testHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	if len(i.modifications) == 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+	modification := i.modifications[0]
+	i.modifications = i.modifications[1:]
+	return modification, nil
+}
+
+func (i *testHistoryIterator) Close() error {
+	return nil
+}
@@ -17,10 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 
-	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 )
@@ -36,17 +36,24 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 
 	//
 	// Demonstrate the use of Attribute-Based Access Control (ABAC) by checking
-	// to see if the caller has the "abac.init" attribute with a value of true;
+	// the caller against the rule of the init function. Unless the rule was changed
+	// with setPolicy, the caller must have the "abac.init" attribute with a value of true;
 	// if not, return an error.
 	//
-	err := cid.AssertAttributeValue(stub, "abac.init", "true")
+	err := checkAccess(stub, "init")
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Only admins of the organization of the caller may delete entities or change the rules
+	err = bindOwnerRules(stub)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
 	_, args := stub.GetFunctionAndParameters()
-	var A, B string    // Entities
-	var Aval, Bval int // Asset holdings
+	var A, B string      // Entities
+	var Aval, Bval int64 // Asset holdings
 
 	if len(args) != 4 {
 		return shim.Error("Incorrect number of arguments. Expecting 4")
@@ -54,24 +61,28 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 
 	// Initialize the chaincode
 	A = args[0]
-	Aval, err = strconv.Atoi(args[1])
+	B = args[2]
+	err = checkEntities(A, B)
 	if err != nil {
-		return shim.Error("Expecting integer value for asset holding")
+		return shim.Error(err.Error())
 	}
-	B = args[2]
-	Bval, err = strconv.Atoi(args[3])
+	Aval, err = parseAmount(args[1])
 	if err != nil {
-		return shim.Error("Expecting integer value for asset holding")
+		return shim.Error(err.Error())
+	}
+	Bval, err = parseAmount(args[3])
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 	fmt.Printf("Aval = %d, Bval = %d\n", Aval, Bval)
 
 	// Write the state to the ledger
-	err = stub.PutState(A, []byte(strconv.Itoa(Aval)))
+	err = stub.PutState(A, []byte(strconv.FormatInt(Aval, 10)))
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	err = stub.PutState(B, []byte(strconv.Itoa(Bval)))
+	err = stub.PutState(B, []byte(strconv.FormatInt(Bval, 10)))
 	if err != nil {
 		return shim.Error(err.Error())
 	}
@@ -82,6 +93,16 @@ func (t *SimpleChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
 func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	fmt.Println("abac Invoke")
 	function, args := stub.GetFunctionAndParameters()
+	if function == "init" || defaultPolicy[function] == "" {
+		return shim.Error("Invalid invoke function name. Expecting \"invoke\" \"delete\" \"query\" \"statement\" \"setPolicy\" \"getPolicy\"")
+	}
+
+	// Every function is guarded by a rule over the identity of the caller, see policy.go
+	err := checkAccess(stub, function)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
 	if function == "invoke" {
 		// Make payment of X units from A to B
 		return t.invoke(stub, args)
@@ -91,66 +112,97 @@ func (t *SimpleChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	} else if function == "query" {
 		// the old "Query" is now implemtned in invoke
 		return t.query(stub, args)
+	} else if function == "statement" {
+		// Lists the transfers of an entity
+		return t.statement(stub, args)
+	} else if function == "setPolicy" {
+		// Replaces the rule of a function
+		return t.setPolicy(stub, args)
+	}
+
+	// Returns the rule of a function
+	return t.getPolicy(stub, args)
+}
+
+// setPolicy replaces the access rule of a function, see Rule for the syntax of rules
+func (t *SimpleChaincode) setPolicy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting function name and rule")
+	}
+
+	rule, err := ParseRule([]byte(args[1]))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = putRule(stub, args[0], rule)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// getPolicy returns the access rule of a function
+func (t *SimpleChaincode) getPolicy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting function name")
 	}
 
-	return shim.Error("Invalid invoke function name. Expecting \"invoke\" \"delete\" \"query\"")
+	rule, err := getRule(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success([]byte(rule.String()))
 }
 
 // Transaction makes payment of X units from A to B
 func (t *SimpleChaincode) invoke(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	var A, B string    // Entities
-	var Aval, Bval int // Asset holdings
-	var X int          // Transaction value
-	var err error
-
 	if len(args) != 3 {
 		return shim.Error("Incorrect number of arguments. Expecting 3")
 	}
 
-	A = args[0]
-	B = args[1]
-
-	// Get the state from the ledger
-	// TODO: will be nice to have a GetAllState call to ledger
-	Avalbytes, err := stub.GetState(A)
+	X, err := strconv.ParseInt(args[2], 10, 64)
 	if err != nil {
-		return shim.Error("Failed to get state")
+		return shim.Error("Invalid transaction amount, expecting a integer value")
 	}
-	if Avalbytes == nil {
-		return shim.Error("Entity not found")
+	err = checkEntities(args[0], args[1])
+	if err != nil {
+		return shim.Error(err.Error())
 	}
-	Aval, _ = strconv.Atoi(string(Avalbytes))
 
-	Bvalbytes, err := stub.GetState(B)
+	// Perform the execution, see transfers.go
+	err = transfer(stub, args[0], args[1], X)
 	if err != nil {
-		return shim.Error("Failed to get state")
+		return shim.Error(err.Error())
 	}
-	if Bvalbytes == nil {
-		return shim.Error("Entity not found")
+
+	return shim.Success(nil)
+}
+
+// statement returns the transfers from and to an entity as a JSON array, oldest first
+func (t *SimpleChaincode) statement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting name of the person to query")
 	}
-	Bval, _ = strconv.Atoi(string(Bvalbytes))
 
-	// Perform the execution
-	X, err = strconv.Atoi(args[2])
+	err := checkEntities(args[0])
 	if err != nil {
-		return shim.Error("Invalid transaction amount, expecting a integer value")
+		return shim.Error(err.Error())
 	}
-	Aval = Aval - X
-	Bval = Bval + X
-	fmt.Printf("Aval = %d, Bval = %d\n", Aval, Bval)
 
-	// Write the state back to the ledger
-	err = stub.PutState(A, []byte(strconv.Itoa(Aval)))
+	transfers, err := getStatement(stub, args[0])
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	err = stub.PutState(B, []byte(strconv.Itoa(Bval)))
+	transfersJSON, err := json.Marshal(transfers)
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 
-	return shim.Success(nil)
+	return shim.Success(transfersJSON)
 }
 
 // Deletes an entity from state
@@ -160,9 +212,13 @@ func (t *SimpleChaincode) delete(stub shim.ChaincodeStubInterface, args []string
 	}
 
 	A := args[0]
+	err := checkEntities(A)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
 	// Delete the key from the state in ledger
-	err := stub.DelState(A)
+	err = stub.DelState(A)
 	if err != nil {
 		return shim.Error("Failed to delete state")
 	}
@@ -180,6 +236,10 @@ func (t *SimpleChaincode) query(stub shim.ChaincodeStubInterface, args []string)
 	}
 
 	A = args[0]
+	err = checkEntities(A)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 
 	// Get the state from the ledger
 	Avalbytes, err := stub.GetState(A)
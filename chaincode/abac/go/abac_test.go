@@ -8,6 +8,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
 
 	"github.com/gogo/protobuf/proto"
@@ -37,6 +38,14 @@ w8Ou1Sh9IjeXj/SDAA==
 -----END CERTIFICATE-----
 `
 
+var txCount int
+
+// nextTxID returns a new transaction ID, since transfers are logged by transaction ID
+func nextTxID() string {
+	txCount++
+	return "tx" + strconv.Itoa(txCount)
+}
+
 func checkInit(t *testing.T, stub *shimtest.MockStub, args [][]byte) {
 	res := stub.MockInit("1", args)
 	if res.Status != shim.OK {
@@ -74,7 +83,7 @@ func checkQuery(t *testing.T, stub *shimtest.MockStub, name string, value string
 }
 
 func checkInvoke(t *testing.T, stub *shimtest.MockStub, args [][]byte) {
-	res := stub.MockInvoke("1", args)
+	res := stub.MockInvoke(nextTxID(), args)
 	if res.Status != shim.OK {
 		fmt.Println("Invoke", args, "failed", string(res.Message))
 		t.FailNow()
@@ -0,0 +1,383 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// policyIndex is the object type of the composite keys that hold the rule of each function
+const policyIndex = "abac~policy"
+
+// Rule is an access rule over the identity of the client. A rule either combines
+// other rules with allOf (AND), anyOf (OR) or not, or is a condition on a field of
+// the identity:
+//
+//	mspid        the MSP ID of the client
+//	ou           the organizational units of the client certificate
+//	role         the hf.Type attribute set by the Fabric CA, or a node OU
+//	             (client, peer, admin or orderer) of the client certificate
+//	attr.<name>  the value of a certificate attribute, e.g. attr.abac.init
+//
+// The operators are eq, ne, in, exists, and the numeric comparisons lt, lte, gt and gte.
+// A condition on a field that is missing only holds for ne, and for exists with value "false".
+// An empty allOf holds for any client.
+//
+//	{"anyOf":[{"field":"mspid","op":"eq","value":"Org1MSP"},
+//	          {"allOf":[{"field":"role","op":"eq","value":"client"},
+//	                    {"field":"attr.clearance","op":"gte","value":"3"}]}]}
+type Rule struct {
+	AllOf  []*Rule  `json:"allOf,omitempty"`
+	AnyOf  []*Rule  `json:"anyOf,omitempty"`
+	Not    *Rule    `json:"not,omitempty"`
+	Field  string   `json:"field,omitempty"`
+	Op     string   `json:"op,omitempty"`
+	Value  string   `json:"value,omitempty"`
+	Values []string `json:"values,omitempty"`
+}
+
+// defaultPolicy holds the rules of the functions that have no rule in the chaincode state.
+// The rules of ownerFunctions deny every client until init binds them to the owner of the
+// chaincode, query is open to any client of the channel
+var defaultPolicy = map[string]string{
+	"init":      `{"field":"attr.abac.init","op":"eq","value":"true"}`,
+	"invoke":    `{"field":"role","op":"eq","value":"client"}`,
+	"delete":    `{"not":{"allOf":[]}}`,
+	"query":     `{"allOf":[]}`,
+	"statement": `{"allOf":[]}`,
+	"setPolicy": `{"not":{"allOf":[]}}`,
+	"getPolicy": `{"allOf":[]}`,
+}
+
+// ownerFunctions can only be called by an admin of the organization that owns the chaincode,
+// which is the organization of the client that initialized it. An admin attribute alone is
+// not enough, since the CA of any organization of the channel can issue it
+var ownerFunctions = []string{"delete", "setPolicy"}
+
+// nodeOUs are the organizational units that Fabric uses to classify identities
+var nodeOUs = map[string]bool{"client": true, "peer": true, "admin": true, "orderer": true}
+
+// ParseRule decodes and validates a rule
+func ParseRule(ruleJSON []byte) (*Rule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(ruleJSON))
+	decoder.DisallowUnknownFields()
+
+	rule := &Rule{}
+	if err := decoder.Decode(rule); err != nil {
+		return nil, fmt.Errorf("invalid rule: %s", err)
+	}
+	if err := rule.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rule: %s", err)
+	}
+	return rule, nil
+}
+
+func (r *Rule) validate() error {
+	kinds := 0
+	if r.AllOf != nil {
+		kinds++
+	}
+	if r.AnyOf != nil {
+		kinds++
+	}
+	if r.Not != nil {
+		kinds++
+	}
+	if r.Field != "" || r.Op != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("a rule must have exactly one of allOf, anyOf, not or a condition")
+	}
+
+	for _, rule := range append(r.AllOf, r.AnyOf...) {
+		if rule == nil {
+			return fmt.Errorf("a rule must not be null")
+		}
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	if r.Not != nil {
+		return r.Not.validate()
+	}
+	if r.AllOf != nil || r.AnyOf != nil {
+		return nil
+	}
+
+	switch {
+	case r.Field == "mspid", r.Field == "ou", r.Field == "role":
+	case strings.HasPrefix(r.Field, "attr.") && len(r.Field) > len("attr."):
+	default:
+		return fmt.Errorf("unknown field %q, expecting mspid, ou, role or attr.<name>", r.Field)
+	}
+
+	switch r.Op {
+	case "eq", "ne":
+	case "in":
+		if len(r.Values) == 0 {
+			return fmt.Errorf("operator in of field %s requires values", r.Field)
+		}
+		return nil
+	case "exists":
+		if r.Value != "true" && r.Value != "false" {
+			return fmt.Errorf("operator exists of field %s requires value true or false", r.Field)
+		}
+	case "lt", "lte", "gt", "gte":
+		if _, err := strconv.ParseFloat(r.Value, 64); err != nil {
+			return fmt.Errorf("operator %s of field %s requires a numeric value", r.Op, r.Field)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q of field %s", r.Op, r.Field)
+	}
+	if len(r.Values) > 0 {
+		return fmt.Errorf("operator %s of field %s takes a single value", r.Op, r.Field)
+	}
+	return nil
+}
+
+// Evaluate reports whether the client satisfies the rule. If not, the reason
+// explains which conditions the client does not meet
+func (r *Rule) Evaluate(client cid.ClientIdentity) (bool, string, error) {
+	switch {
+	case r.AllOf != nil:
+		for _, rule := range r.AllOf {
+			ok, reason, err := rule.Evaluate(client)
+			if err != nil || !ok {
+				return false, reason, err
+			}
+		}
+		return true, "", nil
+	case r.AnyOf != nil:
+		var reasons []string
+		for _, rule := range r.AnyOf {
+			ok, reason, err := rule.Evaluate(client)
+			if err != nil || ok {
+				return ok, "", err
+			}
+			reasons = append(reasons, reason)
+		}
+		return false, "none of the alternatives hold: " + strings.Join(reasons, "; "), nil
+	case r.Not != nil:
+		ok, _, err := r.Not.Evaluate(client)
+		if err != nil || !ok {
+			return !ok, "", err
+		}
+		return false, fmt.Sprintf("%s must not hold", r.Not), nil
+	}
+
+	values, err := fieldValues(client, r.Field)
+	if err != nil {
+		return false, "", err
+	}
+	if r.matches(values) {
+		return true, "", nil
+	}
+	if len(values) == 0 {
+		return false, fmt.Sprintf("%s is required, but the client has no %s", r, r.Field), nil
+	}
+	return false, fmt.Sprintf("%s is required, but %s is %s", r, r.Field, strings.Join(values, ",")), nil
+}
+
+// matches evaluates a condition. Fields with several values, such as ou, match
+// if any of their values does, apart from ne that requires all values to differ
+func (r *Rule) matches(values []string) bool {
+	switch r.Op {
+	case "exists":
+		return (len(values) > 0) == (r.Value == "true")
+	case "ne":
+		for _, value := range values {
+			if value == r.Value {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, value := range values {
+		switch r.Op {
+		case "eq":
+			if value == r.Value {
+				return true
+			}
+		case "in":
+			for _, v := range r.Values {
+				if value == v {
+					return true
+				}
+			}
+		default:
+			if compareNumbers(value, r.Op, r.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compareNumbers(value, op, limit string) bool {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	l, _ := strconv.ParseFloat(limit, 64)
+
+	switch op {
+	case "lt":
+		return v < l
+	case "lte":
+		return v <= l
+	case "gt":
+		return v > l
+	case "gte":
+		return v >= l
+	}
+	return false
+}
+
+// fieldValues returns the values of a field of the client identity
+func fieldValues(client cid.ClientIdentity, field string) ([]string, error) {
+	switch field {
+	case "mspid":
+		mspID, err := client.GetMSPID()
+		if err != nil {
+			return nil, err
+		}
+		return []string{mspID}, nil
+	case "ou", "role":
+		cert, err := client.GetX509Certificate()
+		if err != nil {
+			return nil, err
+		}
+		var values []string
+		if field == "role" {
+			if hfType, found, _ := client.GetAttributeValue("hf.Type"); found {
+				values = append(values, hfType)
+			}
+		}
+		if cert == nil {
+			return values, nil
+		}
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if field == "ou" || nodeOUs[ou] {
+				values = append(values, ou)
+			}
+		}
+		return values, nil
+	}
+
+	value, found, err := client.GetAttributeValue(strings.TrimPrefix(field, "attr."))
+	if err != nil || !found {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+// MarshalJSON keeps an empty allOf, which omitempty would drop
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	if r.AllOf != nil && len(r.AllOf) == 0 {
+		return []byte(`{"allOf":[]}`), nil
+	}
+	type rule Rule
+	return json.Marshal((*rule)(r))
+}
+
+// String returns the rule in its JSON form, used in denial reasons
+func (r *Rule) String() string {
+	ruleJSON, _ := json.Marshal(r)
+	return string(ruleJSON)
+}
+
+// getRule returns the rule of a function from the chaincode state, or its default rule
+func getRule(stub shim.ChaincodeStubInterface, function string) (*Rule, error) {
+	key, err := stub.CreateCompositeKey(policyIndex, []string{function})
+	if err != nil {
+		return nil, err
+	}
+	ruleJSON, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the rule of %s: %s", function, err)
+	}
+	if ruleJSON == nil {
+		defaultRule, ok := defaultPolicy[function]
+		if !ok {
+			return nil, fmt.Errorf("no rule for function %s", function)
+		}
+		ruleJSON = []byte(defaultRule)
+	}
+	return ParseRule(ruleJSON)
+}
+
+// putRule stores the rule of a function in the chaincode state
+func putRule(stub shim.ChaincodeStubInterface, function string, rule *Rule) error {
+	if _, ok := defaultPolicy[function]; !ok {
+		return fmt.Errorf("unknown function %s", function)
+	}
+	key, err := stub.CreateCompositeKey(policyIndex, []string{function})
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, []byte(rule.String()))
+}
+
+// bindOwnerRules stores the rules of ownerFunctions for the organization of the client,
+// unless they are already in the chaincode state, so that calling init again, for example
+// when the chaincode is upgraded, does not hand the chaincode over to another organization
+func bindOwnerRules(stub shim.ChaincodeStubInterface) error {
+	ownerMSP, err := cid.GetMSPID(stub)
+	if err != nil {
+		return fmt.Errorf("failed to get the client MSP ID: %s", err)
+	}
+	rule := &Rule{AllOf: []*Rule{
+		{Field: "mspid", Op: "eq", Value: ownerMSP},
+		{Field: "attr.admin", Op: "eq", Value: "true"},
+	}}
+
+	for _, function := range ownerFunctions {
+		key, err := stub.CreateCompositeKey(policyIndex, []string{function})
+		if err != nil {
+			return err
+		}
+		ruleJSON, err := stub.GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to get the rule of %s: %s", function, err)
+		}
+		if ruleJSON != nil {
+			continue
+		}
+		if err := putRule(stub, function, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAccess returns an error with the reason of the denial if the client
+// is not allowed to call the function
+func checkAccess(stub shim.ChaincodeStubInterface, function string) error {
+	rule, err := getRule(stub, function)
+	if err != nil {
+		return err
+	}
+	client, err := cid.New(stub)
+	if err != nil {
+		return fmt.Errorf("failed to get the client identity: %s", err)
+	}
+	ok, reason, err := rule.Evaluate(client)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate the rule of %s: %s", function, err)
+	}
+	if !ok {
+		return fmt.Errorf("access to %s denied: %s", function, reason)
+	}
+	return nil
+}
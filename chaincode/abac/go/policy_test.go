@@ -0,0 +1,251 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// generateCert creates a self-signed certificate with the organizational units
+// and the attribute extension that the Fabric CA adds to enrollment certificates
+func generateCert(t *testing.T, ous []string, attrs map[string]string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "user1", OrganizationalUnit: ous},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if attrs != nil {
+		err = attrmgr.New().AddAttributesToCert(&attrmgr.Attributes{Attrs: attrs}, template)
+		if err != nil {
+			t.Fatalf("failed to add attributes: %s", err)
+		}
+		// The extensions of a template are ignored, only the extra extensions are added to the certificate
+		template.ExtraExtensions, template.Extensions = template.Extensions, nil
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func newPolicyStub(t *testing.T) *shimtest.MockStub {
+	stub := shimtest.NewMockStub("abac", new(SimpleChaincode))
+	setCreator(t, stub, "org1MSP", []byte(certWithAttrs))
+	checkInit(t, stub, [][]byte{[]byte("init"), []byte("A"), []byte("100"), []byte("B"), []byte("200")})
+	return stub
+}
+
+func invoke(stub *shimtest.MockStub, args ...string) (int32, string, string) {
+	var byteArgs [][]byte
+	for _, arg := range args {
+		byteArgs = append(byteArgs, []byte(arg))
+	}
+	res := stub.MockInvoke(nextTxID(), byteArgs)
+	return res.Status, res.Message, string(res.Payload)
+}
+
+func TestPolicy_DefaultRules(t *testing.T) {
+	stub := newPolicyStub(t)
+
+	// A client without attributes may query and transfer, but not delete or change the policy
+	setCreator(t, stub, "org1MSP", generateCert(t, []string{"client"}, nil))
+	if status, message, _ := invoke(stub, "query", "A"); status != shim.OK {
+		t.Fatalf("query failed: %s", message)
+	}
+	if status, message, _ := invoke(stub, "invoke", "A", "B", "10"); status != shim.OK {
+		t.Fatalf("invoke failed: %s", message)
+	}
+	status, message, _ := invoke(stub, "delete", "A")
+	expected := `access to delete denied: {"field":"attr.admin","op":"eq","value":"true"} is required, but the client has no attr.admin`
+	if status == shim.OK || message != expected {
+		t.Fatalf("unexpected response to delete: %d %s", status, message)
+	}
+	status, message, _ = invoke(stub, "setPolicy", "query", `{"allOf":[]}`)
+	if status == shim.OK || !strings.HasPrefix(message, "access to setPolicy denied") {
+		t.Fatalf("unexpected response to setPolicy: %d %s", status, message)
+	}
+
+	// An admin of another organization may not delete or change the policy
+	setCreator(t, stub, "org2MSP", generateCert(t, []string{"client"}, map[string]string{"admin": "true"}))
+	status, message, _ = invoke(stub, "delete", "A")
+	expected = `access to delete denied: {"field":"mspid","op":"eq","value":"org1MSP"} is required, but mspid is org2MSP`
+	if status == shim.OK || message != expected {
+		t.Fatalf("unexpected response to delete: %d %s", status, message)
+	}
+	status, message, _ = invoke(stub, "setPolicy", "setPolicy", `{"allOf":[]}`)
+	expected = `access to setPolicy denied: {"field":"mspid","op":"eq","value":"org1MSP"} is required, but mspid is org2MSP`
+	if status == shim.OK || message != expected {
+		t.Fatalf("unexpected response to setPolicy: %d %s", status, message)
+	}
+
+	// A peer may not transfer
+	setCreator(t, stub, "org1MSP", generateCert(t, []string{"peer"}, nil))
+	status, message, _ = invoke(stub, "invoke", "A", "B", "10")
+	expected = `access to invoke denied: {"field":"role","op":"eq","value":"client"} is required, but role is peer`
+	if status == shim.OK || message != expected {
+		t.Fatalf("unexpected response to invoke: %d %s", status, message)
+	}
+
+	// The hf.Type attribute of the Fabric CA also gives the role
+	setCreator(t, stub, "org1MSP", generateCert(t, nil, map[string]string{"hf.Type": "client", "admin": "true"}))
+	if status, message, _ := invoke(stub, "invoke", "A", "B", "10"); status != shim.OK {
+		t.Fatalf("invoke failed: %s", message)
+	}
+	if status, message, _ := invoke(stub, "delete", "A"); status != shim.OK {
+		t.Fatalf("delete failed: %s", message)
+	}
+}
+
+func TestPolicy_InitBindsOwnerRules(t *testing.T) {
+	stub := shimtest.NewMockStub("abac", new(SimpleChaincode))
+	setCreator(t, stub, "org1MSP", []byte(certWithAttrs))
+	if status, _, payload := invoke(stub, "getPolicy", "setPolicy"); status != shim.OK || payload != `{"not":{"allOf":[]}}` {
+		t.Fatalf("unexpected rule for setPolicy before init: %d %s", status, payload)
+	}
+
+	checkInit(t, stub, [][]byte{[]byte("init"), []byte("A"), []byte("100"), []byte("B"), []byte("200")})
+
+	// Calling init again from another organization does not change the owner
+	setCreator(t, stub, "org2MSP", []byte(certWithAttrs))
+	checkInit(t, stub, [][]byte{[]byte("init"), []byte("A"), []byte("100"), []byte("B"), []byte("200")})
+
+	ownerRule := `{"allOf":[{"field":"mspid","op":"eq","value":"org1MSP"},{"field":"attr.admin","op":"eq","value":"true"}]}`
+	for _, function := range ownerFunctions {
+		if status, _, payload := invoke(stub, "getPolicy", function); status != shim.OK || payload != ownerRule {
+			t.Fatalf("unexpected rule for %s: %d %s", function, status, payload)
+		}
+	}
+}
+
+func TestPolicy_InitRequiresAttribute(t *testing.T) {
+	stub := shimtest.NewMockStub("abac", new(SimpleChaincode))
+	setCreator(t, stub, "org1MSP", generateCert(t, []string{"client"}, map[string]string{"abac.init": "false"}))
+
+	res := stub.MockInit("1", [][]byte{[]byte("init"), []byte("A"), []byte("100"), []byte("B"), []byte("200")})
+	expected := `access to init denied: {"field":"attr.abac.init","op":"eq","value":"true"} is required, but attr.abac.init is false`
+	if res.Status == shim.OK || res.Message != expected {
+		t.Fatalf("unexpected response to init: %d %s", res.Status, res.Message)
+	}
+}
+
+func TestPolicy_SetPolicy(t *testing.T) {
+	stub := newPolicyStub(t)
+
+	rule := `{"anyOf":[{"field":"mspid","op":"eq","value":"org2MSP"},{"allOf":[{"field":"ou","op":"in","values":["sales","audit"]},{"field":"attr.clearance","op":"gte","value":"3"}]}]}`
+	if status, message, _ := invoke(stub, "setPolicy", "query", rule); status != shim.OK {
+		t.Fatalf("setPolicy failed: %s", message)
+	}
+	if status, _, payload := invoke(stub, "getPolicy", "query"); status != shim.OK || payload != rule {
+		t.Fatalf("unexpected rule for query: %d %s", status, payload)
+	}
+	ownerRule := `{"allOf":[{"field":"mspid","op":"eq","value":"org1MSP"},{"field":"attr.admin","op":"eq","value":"true"}]}`
+	if status, _, payload := invoke(stub, "getPolicy", "delete"); status != shim.OK || payload != ownerRule {
+		t.Fatalf("unexpected rule for delete: %d %s", status, payload)
+	}
+
+	tests := []struct {
+		mspID   string
+		ous     []string
+		attrs   map[string]string
+		allowed bool
+	}{
+		{"org2MSP", nil, nil, true},
+		{"org1MSP", []string{"client", "sales"}, map[string]string{"clearance": "3"}, true},
+		{"org1MSP", []string{"client", "audit"}, map[string]string{"clearance": "10"}, true},
+		{"org1MSP", []string{"client", "sales"}, map[string]string{"clearance": "2"}, false},
+		{"org1MSP", []string{"client", "sales"}, map[string]string{"clearance": "high"}, false},
+		{"org1MSP", []string{"client"}, map[string]string{"clearance": "5"}, false},
+	}
+	for _, test := range tests {
+		setCreator(t, stub, test.mspID, generateCert(t, test.ous, test.attrs))
+		status, message, _ := invoke(stub, "query", "A")
+		if (status == shim.OK) != test.allowed {
+			t.Fatalf("unexpected response to query for %s %v %v: %d %s", test.mspID, test.ous, test.attrs, status, message)
+		}
+	}
+
+	setCreator(t, stub, "org1MSP", generateCert(t, []string{"client", "sales"}, map[string]string{"clearance": "2"}))
+	_, message, _ := invoke(stub, "query", "A")
+	expected := `access to query denied: none of the alternatives hold: ` +
+		`{"field":"mspid","op":"eq","value":"org2MSP"} is required, but mspid is org1MSP; ` +
+		`{"field":"attr.clearance","op":"gte","value":"3"} is required, but attr.clearance is 2`
+	if message != expected {
+		t.Fatalf("unexpected denial reason: %s", message)
+	}
+}
+
+func TestPolicy_Not(t *testing.T) {
+	stub := newPolicyStub(t)
+
+	rule := `{"allOf":[{"field":"role","op":"eq","value":"client"},{"not":{"field":"attr.suspended","op":"exists","value":"true"}}]}`
+	if status, message, _ := invoke(stub, "setPolicy", "invoke", rule); status != shim.OK {
+		t.Fatalf("setPolicy failed: %s", message)
+	}
+
+	setCreator(t, stub, "org1MSP", generateCert(t, []string{"client"}, map[string]string{"suspended": "yes"}))
+	status, message, _ := invoke(stub, "invoke", "A", "B", "10")
+	expected := `access to invoke denied: {"field":"attr.suspended","op":"exists","value":"true"} must not hold`
+	if status == shim.OK || message != expected {
+		t.Fatalf("unexpected response to invoke: %d %s", status, message)
+	}
+
+	setCreator(t, stub, "org1MSP", generateCert(t, []string{"client"}, map[string]string{"other": "yes"}))
+	if status, message, _ := invoke(stub, "invoke", "A", "B", "10"); status != shim.OK {
+		t.Fatalf("invoke failed: %s", message)
+	}
+}
+
+func TestPolicy_InvalidRules(t *testing.T) {
+	stub := newPolicyStub(t)
+
+	tests := []struct {
+		function string
+		rule     string
+		err      string
+	}{
+		{"query", `{}`, "invalid rule: a rule must have exactly one of allOf, anyOf, not or a condition"},
+		{"query", `{"allOf":[],"field":"mspid","op":"eq"}`, "invalid rule: a rule must have exactly one of allOf, anyOf, not or a condition"},
+		{"query", `{"anyOf":[null]}`, "invalid rule: a rule must not be null"},
+		{"query", `{"field":"name","op":"eq","value":"x"}`, `invalid rule: unknown field "name", expecting mspid, ou, role or attr.<name>`},
+		{"query", `{"field":"mspid","op":"like","value":"x"}`, `invalid rule: unknown operator "like" of field mspid`},
+		{"query", `{"field":"attr.level","op":"gt","value":"x"}`, "invalid rule: operator gt of field attr.level requires a numeric value"},
+		{"query", `{"field":"ou","op":"in"}`, "invalid rule: operator in of field ou requires values"},
+		{"query", `{"field":"ou","op":"eq","values":["a"]}`, "invalid rule: operator eq of field ou takes a single value"},
+		{"query", `{"field":"ou","op":"exists","value":"yes"}`, "invalid rule: operator exists of field ou requires value true or false"},
+		{"query", `{"not":{"fields":"ou"}}`, `invalid rule: json: unknown field "fields"`},
+		{"transfer", `{"allOf":[]}`, "unknown function transfer"},
+	}
+	for _, test := range tests {
+		status, message, _ := invoke(stub, "setPolicy", test.function, test.rule)
+		if status == shim.OK || message != test.err {
+			t.Fatalf("unexpected response to setPolicy %s: %d %s", test.rule, status, message)
+		}
+	}
+
+	if status, message, _ := invoke(stub, "init", "A", "1", "B", "2"); status == shim.OK || !strings.HasPrefix(message, "Invalid invoke function name") {
+		t.Fatalf("unexpected response to init: %d %s", status, message)
+	}
+}
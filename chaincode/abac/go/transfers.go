@@ -0,0 +1,221 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Object types of the composite keys of the transfer log. Each transfer is stored once
+// under its transaction ID, and indexed under the transaction ID for the payer and the payee
+const (
+	transferObjectType = "transfer"
+	accountIndex       = "account~txID"
+
+	// compositeKeyNamespace is the first character of the composite keys created by the shim
+	compositeKeyNamespace = "\x00"
+)
+
+// Transfer records a payment. Transfers are only ever added to the log
+type Transfer struct {
+	TxID      string    `json:"txID"`
+	Timestamp time.Time `json:"timestamp"`
+	Payer     string    `json:"payer"`
+	Payee     string    `json:"payee"`
+	Amount    int64     `json:"amount"`
+	Submitter string    `json:"submitter"`
+}
+
+// parseAmount parses an amount of asset holdings, which must not be negative
+func parseAmount(value string) (int64, error) {
+	amount, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Expecting integer value for asset holding, got %q", value)
+	}
+	if amount < 0 {
+		return 0, fmt.Errorf("Expecting non-negative value for asset holding, got %d", amount)
+	}
+	return amount, nil
+}
+
+// checkEntities rejects entity names that start with the null character. The keys of the
+// transfer log and of the policy are composite keys, which start with it, so they
+// cannot be read, overwritten or deleted as entities
+func checkEntities(entities ...string) error {
+	for _, entity := range entities {
+		if strings.HasPrefix(entity, compositeKeyNamespace) {
+			return fmt.Errorf("Entity name %q must not start with the null character", entity)
+		}
+	}
+	return nil
+}
+
+// getBalance reads the asset holding of an entity from the ledger
+func getBalance(stub shim.ChaincodeStubInterface, entity string) (int64, error) {
+	valbytes, err := stub.GetState(entity)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get state for %s", entity)
+	}
+	if valbytes == nil {
+		return 0, fmt.Errorf("Entity not found: %s", entity)
+	}
+	balance, err := parseAmount(string(valbytes))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid asset holding of %s: %s", entity, err)
+	}
+	return balance, nil
+}
+
+// transfer moves X units from A to B, refusing overdrafts and overflows, and appends the transfer to the log
+func transfer(stub shim.ChaincodeStubInterface, A, B string, X int64) error {
+	if X <= 0 {
+		return fmt.Errorf("Transaction amount must be positive, got %d", X)
+	}
+	if A == B {
+		return fmt.Errorf("Payer and payee must be different entities")
+	}
+
+	Aval, err := getBalance(stub, A)
+	if err != nil {
+		return err
+	}
+	Bval, err := getBalance(stub, B)
+	if err != nil {
+		return err
+	}
+
+	if Aval < X {
+		return fmt.Errorf("Insufficient funds: %s holds %d, transfer of %d requested", A, Aval, X)
+	}
+	if Bval > math.MaxInt64-X {
+		return fmt.Errorf("Overflow: %s holds %d, cannot receive %d more", B, Bval, X)
+	}
+	Aval -= X
+	Bval += X
+	fmt.Printf("Aval = %d, Bval = %d\n", Aval, Bval)
+
+	// Write the state back to the ledger
+	err = stub.PutState(A, []byte(strconv.FormatInt(Aval, 10)))
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(B, []byte(strconv.FormatInt(Bval, 10)))
+	if err != nil {
+		return err
+	}
+
+	return logTransfer(stub, A, B, X)
+}
+
+// logTransfer appends a transfer of the current transaction to the log
+func logTransfer(stub shim.ChaincodeStubInterface, payer, payee string, amount int64) error {
+	txID := stub.GetTxID()
+	transferKey, err := stub.CreateCompositeKey(transferObjectType, []string{txID})
+	if err != nil {
+		return err
+	}
+	existing, err := stub.GetState(transferKey)
+	if err != nil {
+		return fmt.Errorf("Failed to get transfer %s", txID)
+	}
+	if existing != nil {
+		return fmt.Errorf("Transfer %s is already recorded", txID)
+	}
+
+	submitter, err := cid.GetID(stub)
+	if err != nil {
+		return fmt.Errorf("Failed to get submitter identity: %s", err)
+	}
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("Failed to get transaction timestamp: %s", err)
+	}
+
+	record := &Transfer{
+		TxID:      txID,
+		Timestamp: time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(),
+		Payer:     payer,
+		Payee:     payee,
+		Amount:    amount,
+		Submitter: submitter,
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	err = stub.PutState(transferKey, recordJSON)
+	if err != nil {
+		return err
+	}
+
+	// Index the transfer for both accounts. Only the key is needed, the value must not be nil
+	for _, account := range []string{payer, payee} {
+		indexKey, err := stub.CreateCompositeKey(accountIndex, []string{account, txID})
+		if err != nil {
+			return err
+		}
+		err = stub.PutState(indexKey, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getStatement returns the transfers from and to an account, oldest first
+func getStatement(stub shim.ChaincodeStubInterface, account string) ([]*Transfer, error) {
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(accountIndex, []string{account})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	statement := []*Transfer{}
+	for resultsIterator.HasNext() {
+		indexEntry, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, keyParts, err := stub.SplitCompositeKey(indexEntry.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		transferKey, err := stub.CreateCompositeKey(transferObjectType, []string{keyParts[1]})
+		if err != nil {
+			return nil, err
+		}
+		recordJSON, err := stub.GetState(transferKey)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get transfer %s", keyParts[1])
+		}
+		if recordJSON == nil {
+			return nil, fmt.Errorf("Transfer %s is indexed but not recorded", keyParts[1])
+		}
+
+		record := &Transfer{}
+		err = json.Unmarshal(recordJSON, record)
+		if err != nil {
+			return nil, err
+		}
+		statement = append(statement, record)
+	}
+
+	// The index is ordered by transaction ID, which is unrelated to the order of the transfers
+	sort.SliceStable(statement, func(i, j int) bool {
+		return statement[i].Timestamp.Before(statement[j].Timestamp)
+	})
+	return statement, nil
+}
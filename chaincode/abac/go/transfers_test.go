@@ -0,0 +1,141 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+func TestTransfers_Errors(t *testing.T) {
+	stub := newPolicyStub(t)
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"invoke", "A", "B", "101"}, "Insufficient funds: A holds 100, transfer of 101 requested"},
+		{[]string{"invoke", "A", "B", "0"}, "Transaction amount must be positive, got 0"},
+		{[]string{"invoke", "A", "B", "-5"}, "Transaction amount must be positive, got -5"},
+		{[]string{"invoke", "A", "B", "ten"}, "Invalid transaction amount, expecting a integer value"},
+		{[]string{"invoke", "A", "A", "1"}, "Payer and payee must be different entities"},
+		{[]string{"invoke", "A", "C", "1"}, "Entity not found: C"},
+	}
+	for _, test := range tests {
+		status, message, _ := invoke(stub, test.args...)
+		if status == shim.OK || message != test.err {
+			t.Fatalf("unexpected response to %v: %d %s", test.args, status, message)
+		}
+	}
+	checkState(t, stub, "A", "100")
+	checkState(t, stub, "B", "200")
+
+	// Balances that were not written by the chaincode are rejected
+	stub.State["A"] = []byte("lots")
+	status, message, _ := invoke(stub, "invoke", "A", "B", "1")
+	expected := `Invalid asset holding of A: Expecting integer value for asset holding, got "lots"`
+	if status == shim.OK || message != expected {
+		t.Fatalf("unexpected response to invoke: %d %s", status, message)
+	}
+}
+
+func TestTransfers_Overflow(t *testing.T) {
+	stub := newPolicyStub(t)
+	stub.State["B"] = []byte(strconv.FormatInt(math.MaxInt64-50, 10))
+
+	status, message, _ := invoke(stub, "invoke", "A", "B", "51")
+	expected := "Overflow: B holds 9223372036854775757, cannot receive 51 more"
+	if status == shim.OK || message != expected {
+		t.Fatalf("unexpected response to invoke: %d %s", status, message)
+	}
+
+	checkInvoke(t, stub, [][]byte{[]byte("invoke"), []byte("A"), []byte("B"), []byte("50")})
+	checkState(t, stub, "B", strconv.FormatInt(math.MaxInt64, 10))
+
+	res := stub.MockInit(nextTxID(), [][]byte{[]byte("init"), []byte("A"), []byte("-1"), []byte("B"), []byte("1")})
+	if res.Status == shim.OK || res.Message != "Expecting non-negative value for asset holding, got -1" {
+		t.Fatalf("unexpected response to init: %d %s", res.Status, res.Message)
+	}
+}
+
+func TestTransfers_Statement(t *testing.T) {
+	stub := newPolicyStub(t)
+
+	checkInvoke(t, stub, [][]byte{[]byte("invoke"), []byte("A"), []byte("B"), []byte("30")})
+	checkInvoke(t, stub, [][]byte{[]byte("invoke"), []byte("B"), []byte("A"), []byte("5")})
+
+	status, message, payload := invoke(stub, "statement", "A")
+	if status != shim.OK {
+		t.Fatalf("statement failed: %s", message)
+	}
+	var transfers []*Transfer
+	if err := json.Unmarshal([]byte(payload), &transfers); err != nil {
+		t.Fatalf("failed to decode statement: %s", err)
+	}
+	if len(transfers) != 2 {
+		t.Fatalf("expected 2 transfers, got %s", payload)
+	}
+	submitter := base64.StdEncoding.EncodeToString([]byte("x509::CN=admin-org1,OU=client+OU=org1,O=Hyperledger,ST=North Carolina,C=US::CN=rca-org1-admin,OU=client,O=Hyperledger,ST=North Carolina,C=US"))
+	if first := transfers[0]; first.Payer != "A" || first.Payee != "B" || first.Amount != 30 || first.Submitter != submitter || first.TxID == "" {
+		t.Fatalf("unexpected first transfer: %+v", first)
+	}
+	if second := transfers[1]; second.Payer != "B" || second.Payee != "A" || second.Amount != 5 || second.Timestamp.Before(transfers[0].Timestamp) {
+		t.Fatalf("unexpected second transfer: %+v", second)
+	}
+
+	status, _, payload = invoke(stub, "statement", "C")
+	if status != shim.OK || payload != "[]" {
+		t.Fatalf("unexpected statement for C: %d %s", status, payload)
+	}
+
+	// The log is append only, a transaction cannot record a second transfer
+	stub.MockTransactionStart("tx-replay")
+	if err := transfer(stub, "A", "B", 1); err != nil {
+		t.Fatalf("transfer failed: %s", err)
+	}
+	if err := transfer(stub, "A", "B", 1); err == nil || err.Error() != "Transfer tx-replay is already recorded" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stub.MockTransactionEnd("tx-replay")
+}
+
+func TestTransfers_CompositeKeysAreNotEntities(t *testing.T) {
+	stub := newPolicyStub(t)
+	stub.MockTransactionStart("tx-logged")
+	if err := transfer(stub, "A", "B", 10); err != nil {
+		t.Fatalf("transfer failed: %s", err)
+	}
+	stub.MockTransactionEnd("tx-logged")
+
+	transferKey, _ := stub.CreateCompositeKey(transferObjectType, []string{"tx-logged"})
+	indexKey, _ := stub.CreateCompositeKey(accountIndex, []string{"A", "tx-logged"})
+	policyKey, _ := stub.CreateCompositeKey(policyIndex, []string{"delete"})
+	if stub.State[policyKey] == nil {
+		t.Fatalf("the rule of delete is not stored under %q", policyKey)
+	}
+
+	// Even an admin of the organization cannot read, pay from or delete the transfer log and the policy
+	setCreator(t, stub, "org1MSP", generateCert(t, []string{"client"}, map[string]string{"admin": "true"}))
+	for _, key := range []string{transferKey, indexKey, policyKey} {
+		expected := fmt.Sprintf("Entity name %q must not start with the null character", key)
+		for _, args := range [][]string{{"delete", key}, {"query", key}, {"invoke", key, "B", "1"}, {"invoke", "A", key, "1"}} {
+			status, message, _ := invoke(stub, args...)
+			if status == shim.OK || message != expected {
+				t.Fatalf("unexpected response to %q: %d %s", args, status, message)
+			}
+		}
+		if stub.State[key] == nil {
+			t.Fatalf("%q was deleted", key)
+		}
+	}
+	checkState(t, stub, "A", "90")
+	checkState(t, stub, "B", "210")
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -29,18 +30,30 @@ type ABstore struct {
 	contractapi.Contract
 }
 
-func (t *ABstore) Init(ctx contractapi.TransactionContextInterface, A string, Aval int, B string, Bval int) error {
+// Init sets the asset holdings of A and B. The MSP of the client that first initializes
+// the chaincode becomes the minter MSP of TokenContract
+func (t *ABstore) Init(ctx contractapi.TransactionContextInterface, A string, Aval int64, B string, Bval int64) error {
 	fmt.Println("ABstore Init")
-	var err error
+	err := checkEntities(A, B)
+	if err != nil {
+		return err
+	}
 	// Initialize the chaincode
+	if Aval < 0 || Bval < 0 {
+		return fmt.Errorf("Expecting non-negative values for asset holdings")
+	}
+	err = setMinterMSP(ctx)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("Aval = %d, Bval = %d\n", Aval, Bval)
 	// Write the state to the ledger
-	err = ctx.GetStub().PutState(A, []byte(strconv.Itoa(Aval)))
+	err = ctx.GetStub().PutState(A, []byte(strconv.FormatInt(Aval, 10)))
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(B, []byte(strconv.Itoa(Bval)))
+	err = ctx.GetStub().PutState(B, []byte(strconv.FormatInt(Bval, 10)))
 	if err != nil {
 		return err
 	}
@@ -48,55 +61,57 @@ func (t *ABstore) Init(ctx contractapi.TransactionContextInterface, A string, Av
 	return nil
 }
 
-// Transaction makes payment of X units from A to B
-func (t *ABstore) Invoke(ctx contractapi.TransactionContextInterface, A, B string, X int) error {
-	var err error
-	var Aval int
-	var Bval int
-	// Get the state from the ledger
-	// TODO: will be nice to have a GetAllState call to ledger
-	Avalbytes, err := ctx.GetStub().GetState(A)
+// Transaction makes payment of X units from A to B. The payment fails if A does not hold
+// X units or if the holding of B would overflow, and is recorded in the transfer log
+func (t *ABstore) Invoke(ctx contractapi.TransactionContextInterface, A, B string, X int64) error {
+	err := checkEntities(A, B)
 	if err != nil {
-		return fmt.Errorf("Failed to get state")
+		return err
 	}
-	if Avalbytes == nil {
-		return fmt.Errorf("Entity not found")
+	if A == B {
+		return fmt.Errorf("Payer and payee must be different entities")
 	}
-	Aval, _ = strconv.Atoi(string(Avalbytes))
 
-	Bvalbytes, err := ctx.GetStub().GetState(B)
+	// Get the state from the ledger
+	Aval, err := readBalance(ctx, A)
 	if err != nil {
-		return fmt.Errorf("Failed to get state")
+		return err
 	}
-	if Bvalbytes == nil {
-		return fmt.Errorf("Entity not found")
+	Bval, err := readBalance(ctx, B)
+	if err != nil {
+		return err
 	}
-	Bval, _ = strconv.Atoi(string(Bvalbytes))
 
 	// Perform the execution
-	Aval = Aval - X
-	Bval = Bval + X
+	Aval, Bval, err = checkTransfer(A, Aval, B, Bval, X)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("Aval = %d, Bval = %d\n", Aval, Bval)
 
 	// Write the state back to the ledger
-	err = ctx.GetStub().PutState(A, []byte(strconv.Itoa(Aval)))
+	err = ctx.GetStub().PutState(A, []byte(strconv.FormatInt(Aval, 10)))
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(B, []byte(strconv.Itoa(Bval)))
+	err = ctx.GetStub().PutState(B, []byte(strconv.FormatInt(Bval, 10)))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return recordTransfer(ctx, A, B, X)
 }
 
-// Delete  an entity from state
+// Delete  an entity from state. The transfer log and the token ledger cannot be deleted
 func (t *ABstore) Delete(ctx contractapi.TransactionContextInterface, A string) error {
+	err := checkEntities(A)
+	if err != nil {
+		return err
+	}
 
 	// Delete the key from the state in ledger
-	err := ctx.GetStub().DelState(A)
+	err = ctx.GetStub().DelState(A)
 	if err != nil {
 		return fmt.Errorf("Failed to delete state")
 	}
@@ -106,7 +121,10 @@ func (t *ABstore) Delete(ctx contractapi.TransactionContextInterface, A string)
 
 // Query callback representing the query of a chaincode
 func (t *ABstore) Query(ctx contractapi.TransactionContextInterface, A string) (string, error) {
-	var err error
+	err := checkEntities(A)
+	if err != nil {
+		return "", err
+	}
 	// Get the state from the ledger
 	Avalbytes, err := ctx.GetStub().GetState(A)
 	if err != nil {
@@ -124,8 +142,20 @@ func (t *ABstore) Query(ctx contractapi.TransactionContextInterface, A string) (
 	return string(Avalbytes), nil
 }
 
+// checkEntities rejects entity names that start with the null character. The keys of the
+// transfer log and of TokenContract are composite keys, which start with it, so they
+// cannot be read, overwritten or deleted as entities
+func checkEntities(entities ...string) error {
+	for _, entity := range entities {
+		if strings.HasPrefix(entity, compositeKeyNamespace) {
+			return fmt.Errorf("Entity name %q must not start with the null character", entity)
+		}
+	}
+	return nil
+}
+
 func main() {
-	cc, err := contractapi.NewChaincode(new(ABstore))
+	cc, err := contractapi.NewChaincode(new(ABstore), new(TokenContract))
 	if err != nil {
 		panic(err.Error())
 	}
@@ -0,0 +1,203 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/x509"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// clientIdentity is the identity of the client that submits the transactions of a test
+type clientIdentity struct {
+	id    string
+	mspID string
+}
+
+func (ci *clientIdentity) GetID() (string, error) {
+	return ci.id, nil
+}
+
+func (ci *clientIdentity) GetMSPID() (string, error) {
+	return ci.mspID, nil
+}
+
+func (ci *clientIdentity) GetAttributeValue(string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (ci *clientIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	return fmt.Errorf("attribute %s not found", attrName)
+}
+
+func (ci *clientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+var txCount = 0
+
+// newContext starts a new transaction on the stub, one second after the previous one,
+// submitted by a client of an organization
+func newContext(t *testing.T, stub *shimtest.MockStub, id, mspID string) contractapi.TransactionContextInterface {
+	txCount++
+	stub.MockTransactionStart(strconv.Itoa(txCount))
+	txTimestamp, err := ptypes.TimestampProto(time.Unix(int64(txCount), 0))
+	if err != nil {
+		t.Fatalf("failed to create timestamp: %s", err)
+	}
+	stub.TxTimestamp = txTimestamp
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(&clientIdentity{id: id, mspID: mspID})
+	return ctx
+}
+
+func newABstore(t *testing.T) (*ABstore, *shimtest.MockStub) {
+	stub := shimtest.NewMockStub("abstore", nil)
+	abstore := new(ABstore)
+	if err := abstore.Init(newContext(t, stub, "admin", "Org1MSP"), "A", 100, "B", 200); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+	return abstore, stub
+}
+
+func checkBalance(t *testing.T, abstore *ABstore, stub *shimtest.MockStub, name, expected string) {
+	balance, err := abstore.Query(newContext(t, stub, "auditor", "Org1MSP"), name)
+	if err != nil || balance != expected {
+		t.Fatalf("unexpected balance of %s: %s %v, expecting %s", name, balance, err, expected)
+	}
+}
+
+func TestABstore_InvokeErrors(t *testing.T) {
+	abstore, stub := newABstore(t)
+
+	tests := []struct {
+		A, B string
+		X    int64
+		err  string
+	}{
+		{"A", "B", 101, "Insufficient funds: A holds 100, transfer of 101 requested"},
+		{"A", "B", 0, "Transaction amount must be positive, got 0"},
+		{"A", "B", -5, "Transaction amount must be positive, got -5"},
+		{"A", "A", 1, "Payer and payee must be different entities"},
+		{"A", "C", 1, "Entity not found: C"},
+	}
+	for _, test := range tests {
+		err := abstore.Invoke(newContext(t, stub, "user1", "Org1MSP"), test.A, test.B, test.X)
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("unexpected response to Invoke %s %s %d: %v", test.A, test.B, test.X, err)
+		}
+	}
+	checkBalance(t, abstore, stub, "A", "100")
+	checkBalance(t, abstore, stub, "B", "200")
+
+	// Balances that were not written by the chaincode are rejected
+	stub.State["A"] = []byte("lots")
+	err := abstore.Invoke(newContext(t, stub, "user1", "Org1MSP"), "A", "B", 1)
+	if err == nil || err.Error() != `Invalid asset holding of A: "lots"` {
+		t.Fatalf("unexpected response to Invoke: %v", err)
+	}
+}
+
+func TestABstore_InvokeOverflow(t *testing.T) {
+	abstore, stub := newABstore(t)
+	stub.State["B"] = []byte(strconv.FormatInt(math.MaxInt64-50, 10))
+
+	err := abstore.Invoke(newContext(t, stub, "user1", "Org1MSP"), "A", "B", 51)
+	if err == nil || err.Error() != "Overflow: B holds 9223372036854775757, cannot receive 51 more" {
+		t.Fatalf("unexpected response to Invoke: %v", err)
+	}
+
+	if err := abstore.Invoke(newContext(t, stub, "user1", "Org1MSP"), "A", "B", 50); err != nil {
+		t.Fatalf("Invoke failed: %s", err)
+	}
+	checkBalance(t, abstore, stub, "A", "50")
+	checkBalance(t, abstore, stub, "B", strconv.FormatInt(math.MaxInt64, 10))
+}
+
+func TestABstore_Statement(t *testing.T) {
+	abstore, stub := newABstore(t)
+	if err := abstore.Init(newContext(t, stub, "admin", "Org1MSP"), "C", 0, "D", 0); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	transfers := []struct {
+		submitter, A, B string
+		X               int64
+	}{
+		{"user1", "A", "B", 30},
+		{"user2", "B", "C", 20},
+		{"user1", "A", "C", 5},
+	}
+	for _, transfer := range transfers {
+		if err := abstore.Invoke(newContext(t, stub, transfer.submitter, "Org1MSP"), transfer.A, transfer.B, transfer.X); err != nil {
+			t.Fatalf("Invoke failed: %s", err)
+		}
+	}
+	checkBalance(t, abstore, stub, "A", "65")
+	checkBalance(t, abstore, stub, "B", "210")
+	checkBalance(t, abstore, stub, "C", "25")
+
+	statement, err := abstore.Statement(newContext(t, stub, "auditor", "Org1MSP"), "C")
+	if err != nil {
+		t.Fatalf("Statement failed: %s", err)
+	}
+	if len(statement) != 2 {
+		t.Fatalf("unexpected statement of C: %v", statement)
+	}
+	first, second := statement[0], statement[1]
+	if first.Payer != "B" || first.Payee != "C" || first.Amount != 20 || first.Submitter != "user2" {
+		t.Fatalf("unexpected first transfer of C: %+v", first)
+	}
+	if second.Payer != "A" || second.Payee != "C" || second.Amount != 5 || second.Submitter != "user1" {
+		t.Fatalf("unexpected second transfer of C: %+v", second)
+	}
+	if !first.Timestamp.Before(second.Timestamp) {
+		t.Fatalf("transfers of C are not in order: %s %s", first.Timestamp, second.Timestamp)
+	}
+
+	statement, err = abstore.Statement(newContext(t, stub, "auditor", "Org1MSP"), "D")
+	if err != nil || len(statement) != 0 {
+		t.Fatalf("unexpected statement of D: %v %v", statement, err)
+	}
+}
+
+func TestABstore_DeleteKeepsTransferLog(t *testing.T) {
+	abstore, stub := newABstore(t)
+	ctx := newContext(t, stub, "user1", "Org1MSP")
+	if err := abstore.Invoke(ctx, "A", "B", 10); err != nil {
+		t.Fatalf("Invoke failed: %s", err)
+	}
+	txID := ctx.GetStub().GetTxID()
+
+	transferKey, _ := stub.CreateCompositeKey(transferObjectType, []string{txID})
+	indexKey, _ := stub.CreateCompositeKey(accountIndex, []string{"A", txID})
+	for _, key := range []string{transferKey, indexKey} {
+		err := abstore.Delete(newContext(t, stub, "admin", "Org1MSP"), key)
+		if err == nil || err.Error() != fmt.Sprintf("Entity name %q must not start with the null character", key) {
+			t.Fatalf("unexpected response to Delete %q: %v", key, err)
+		}
+		if stub.State[key] == nil {
+			t.Fatalf("%q was deleted", key)
+		}
+	}
+
+	// Deleting an entity keeps its transfers
+	if err := abstore.Delete(newContext(t, stub, "admin", "Org1MSP"), "A"); err != nil {
+		t.Fatalf("Delete failed: %s", err)
+	}
+	statement, err := abstore.Statement(newContext(t, stub, "auditor", "Org1MSP"), "A")
+	if err != nil || len(statement) != 1 || statement[0].TxID != txID {
+		t.Fatalf("unexpected statement of A: %v %v", statement, err)
+	}
+}
@@ -0,0 +1,477 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TokenContract is a fungible token ledger. Unlike ABstore, which moves value between
+// two entities named in Init, any client can open an account, which is keyed by the
+// client identity. Tokens are minted and burned by clients of the minter MSP, which is
+// the MSP of the client that initialized the chaincode with ABstore:Init. Only a client
+// of the minter MSP can then initialize the token.
+//
+// The functions of the contract are prefixed with its name, for example:
+//
+//	peer chaincode invoke -C mychannel -n abstore --isInit -c '{"Args":["ABstore:Init","A","100","B","100"]}'
+//	peer chaincode invoke -C mychannel -n abstore -c '{"Args":["TokenContract:Initialize","Sample token","SMP"]}'
+//	peer chaincode invoke -C mychannel -n abstore -c '{"Args":["TokenContract:CreateAccount"]}'
+//	peer chaincode query -C mychannel -n abstore -c '{"Args":["TokenContract:ClientAccountID"]}'
+//	peer chaincode invoke -C mychannel -n abstore -c '{"Args":["TokenContract:Mint","<account ID>","1000"]}'
+//	peer chaincode invoke -C mychannel -n abstore -c '{"Args":["TokenContract:Transfer","<account ID>","100"]}'
+type TokenContract struct {
+	contractapi.Contract
+}
+
+// Keys of the token ledger. They are composite keys, which start with the null character,
+// and ABstore rejects entity names that start with it, so the functions of ABstore cannot
+// read or write the token ledger
+const (
+	tokenKey          = "token"
+	minterKey         = "minter"
+	accountObjectType = "account"
+	allowanceIndex    = "owner~spender"
+)
+
+// Token describes the token
+type Token struct {
+	Name        string `json:"name"`
+	Symbol      string `json:"symbol"`
+	MinterMSP   string `json:"minterMSP"`
+	TotalSupply int64  `json:"totalSupply"`
+}
+
+// TransferEvent is emitted by transfers, mints and burns. The sender of minted tokens
+// and the recipient of burned tokens are empty
+type TransferEvent struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value int64  `json:"value"`
+}
+
+// ApprovalEvent is emitted when an owner sets the allowance of a spender
+type ApprovalEvent struct {
+	Owner   string `json:"owner"`
+	Spender string `json:"spender"`
+	Value   int64  `json:"value"`
+}
+
+// Initialize sets the name and symbol of the token. Only clients of the minter MSP can initialize the token
+func (c *TokenContract) Initialize(ctx contractapi.TransactionContextInterface, name string, symbol string) error {
+	token, err := readToken(ctx)
+	if err != nil {
+		return err
+	}
+	if token != nil {
+		return fmt.Errorf("Token is already initialized")
+	}
+	if name == "" || symbol == "" {
+		return fmt.Errorf("Token name and symbol must be non-empty strings")
+	}
+
+	minterMSP, err := readMinterMSP(ctx)
+	if err != nil {
+		return err
+	}
+	if minterMSP == "" {
+		return fmt.Errorf("Minter MSP is not set, the chaincode must be initialized with ABstore:Init first")
+	}
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to get client MSP ID: %s", err)
+	}
+	if clientMSP != minterMSP {
+		return fmt.Errorf("Client of %s is not authorized to initialize the token, only clients of %s are", clientMSP, minterMSP)
+	}
+
+	return putToken(ctx, &Token{Name: name, Symbol: symbol, MinterMSP: minterMSP})
+}
+
+// GetToken returns the description and total supply of the token
+func (c *TokenContract) GetToken(ctx contractapi.TransactionContextInterface) (*Token, error) {
+	token, err := readToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, fmt.Errorf("Token is not initialized")
+	}
+	return token, nil
+}
+
+// TotalSupply returns the number of tokens in circulation
+func (c *TokenContract) TotalSupply(ctx contractapi.TransactionContextInterface) (int64, error) {
+	token, err := c.GetToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return token.TotalSupply, nil
+}
+
+// CreateAccount opens an account with no tokens for the client
+func (c *TokenContract) CreateAccount(ctx contractapi.TransactionContextInterface) error {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("Failed to get client identity: %s", err)
+	}
+
+	_, exists, err := readAccount(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("Account already exists: %s", clientID)
+	}
+
+	return putAccount(ctx, clientID, 0)
+}
+
+// ClientAccountID returns the ID of the client account, which other clients use to send tokens to it
+func (c *TokenContract) ClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get client identity: %s", err)
+	}
+	return clientID, nil
+}
+
+// BalanceOf returns the number of tokens held by an account
+func (c *TokenContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (int64, error) {
+	balance, exists, err := readAccount(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("Account not found: %s", account)
+	}
+	return balance, nil
+}
+
+// Mint creates tokens in an account. Only clients of the minter MSP can mint tokens
+func (c *TokenContract) Mint(ctx contractapi.TransactionContextInterface, account string, amount int64) error {
+	token, err := authorizeMinter(ctx)
+	if err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Mint amount must be positive, got %d", amount)
+	}
+	if token.TotalSupply > math.MaxInt64-amount {
+		return fmt.Errorf("Overflow: total supply is %d, cannot mint %d more", token.TotalSupply, amount)
+	}
+
+	balance, err := c.BalanceOf(ctx, account)
+	if err != nil {
+		return err
+	}
+	// The balance of an account never exceeds the total supply, so it cannot overflow either
+	err = putAccount(ctx, account, balance+amount)
+	if err != nil {
+		return err
+	}
+
+	token.TotalSupply += amount
+	err = putToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, "Transfer", &TransferEvent{To: account, Value: amount})
+}
+
+// Burn destroys tokens of the client account. Only clients of the minter MSP can burn tokens
+func (c *TokenContract) Burn(ctx contractapi.TransactionContextInterface, amount int64) error {
+	token, err := authorizeMinter(ctx)
+	if err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return fmt.Errorf("Burn amount must be positive, got %d", amount)
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("Failed to get client identity: %s", err)
+	}
+	balance, err := c.BalanceOf(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if balance < amount {
+		return fmt.Errorf("Insufficient funds: %s holds %d, burn of %d requested", clientID, balance, amount)
+	}
+
+	err = putAccount(ctx, clientID, balance-amount)
+	if err != nil {
+		return err
+	}
+
+	token.TotalSupply -= amount
+	err = putToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, "Transfer", &TransferEvent{From: clientID, Value: amount})
+}
+
+// Transfer sends tokens from the client account to another account
+func (c *TokenContract) Transfer(ctx contractapi.TransactionContextInterface, recipient string, amount int64) error {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("Failed to get client identity: %s", err)
+	}
+
+	err = c.transfer(ctx, clientID, recipient, amount)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, "Transfer", &TransferEvent{From: clientID, To: recipient, Value: amount})
+}
+
+// Approve allows a spender to transfer up to amount tokens from the client account.
+// The allowance replaces any previous allowance of the spender
+func (c *TokenContract) Approve(ctx contractapi.TransactionContextInterface, spender string, amount int64) error {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("Failed to get client identity: %s", err)
+	}
+	if amount < 0 {
+		return fmt.Errorf("Allowance must not be negative, got %d", amount)
+	}
+	if _, err := c.BalanceOf(ctx, clientID); err != nil {
+		return err
+	}
+
+	err = putAllowance(ctx, clientID, spender, amount)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, "Approval", &ApprovalEvent{Owner: clientID, Spender: spender, Value: amount})
+}
+
+// Allowance returns the number of tokens that a spender may still transfer from the account of an owner
+func (c *TokenContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int64, error) {
+	return readAllowance(ctx, owner, spender)
+}
+
+// TransferFrom sends tokens from the account of an owner, within the allowance of the client
+func (c *TokenContract) TransferFrom(ctx contractapi.TransactionContextInterface, owner string, recipient string, amount int64) error {
+	spender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("Failed to get client identity: %s", err)
+	}
+
+	allowance, err := readAllowance(ctx, owner, spender)
+	if err != nil {
+		return err
+	}
+	if allowance < amount {
+		return fmt.Errorf("Insufficient allowance: %d, transfer of %d requested", allowance, amount)
+	}
+
+	err = c.transfer(ctx, owner, recipient, amount)
+	if err != nil {
+		return err
+	}
+
+	err = putAllowance(ctx, owner, spender, allowance-amount)
+	if err != nil {
+		return err
+	}
+
+	return setEvent(ctx, "Transfer", &TransferEvent{From: owner, To: recipient, Value: amount})
+}
+
+// transfer moves tokens between two existing accounts. The caller emits the Transfer event
+func (c *TokenContract) transfer(ctx contractapi.TransactionContextInterface, from string, to string, amount int64) error {
+	if from == to {
+		return fmt.Errorf("Sender and recipient must be different accounts")
+	}
+
+	fromBalance, err := c.BalanceOf(ctx, from)
+	if err != nil {
+		return err
+	}
+	toBalance, err := c.BalanceOf(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	// Same checks as the transfers between ABstore entities
+	fromBalance, toBalance, err = checkTransfer(from, fromBalance, to, toBalance, amount)
+	if err != nil {
+		return err
+	}
+
+	err = putAccount(ctx, from, fromBalance)
+	if err != nil {
+		return err
+	}
+	return putAccount(ctx, to, toBalance)
+}
+
+// authorizeMinter returns the token if the client belongs to the minter MSP
+func authorizeMinter(ctx contractapi.TransactionContextInterface) (*Token, error) {
+	token, err := readToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, fmt.Errorf("Token is not initialized")
+	}
+
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get client MSP ID: %s", err)
+	}
+	if clientMSP != token.MinterMSP {
+		return nil, fmt.Errorf("Client of %s is not authorized to mint or burn tokens, only clients of %s are", clientMSP, token.MinterMSP)
+	}
+	return token, nil
+}
+
+// setMinterMSP makes the MSP of the client the minter MSP, unless a minter MSP is already set
+func setMinterMSP(ctx contractapi.TransactionContextInterface) error {
+	minterMSP, err := readMinterMSP(ctx)
+	if err != nil {
+		return err
+	}
+	if minterMSP != "" {
+		return nil
+	}
+
+	clientMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("Failed to get client MSP ID: %s", err)
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(minterKey, []string{})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, []byte(clientMSP))
+}
+
+func readMinterMSP(ctx contractapi.TransactionContextInterface) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(minterKey, []string{})
+	if err != nil {
+		return "", err
+	}
+	minterMSP, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get minter MSP: %s", err)
+	}
+	return string(minterMSP), nil
+}
+
+func readToken(ctx contractapi.TransactionContextInterface) (*Token, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(tokenKey, []string{})
+	if err != nil {
+		return nil, err
+	}
+	tokenJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get token: %s", err)
+	}
+	if tokenJSON == nil {
+		return nil, nil
+	}
+
+	token := &Token{}
+	err = json.Unmarshal(tokenJSON, token)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func putToken(ctx contractapi.TransactionContextInterface, token *Token) error {
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	key, err := ctx.GetStub().CreateCompositeKey(tokenKey, []string{})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, tokenJSON)
+}
+
+// readAccount returns the balance of an account and whether the account exists
+func readAccount(ctx contractapi.TransactionContextInterface, account string) (int64, bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(accountObjectType, []string{account})
+	if err != nil {
+		return 0, false, err
+	}
+	balanceBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, false, fmt.Errorf("Failed to get account %s: %s", account, err)
+	}
+	if balanceBytes == nil {
+		return 0, false, nil
+	}
+
+	balance, err := strconv.ParseInt(string(balanceBytes), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("Invalid balance of account %s: %q", account, balanceBytes)
+	}
+	return balance, true, nil
+}
+
+func putAccount(ctx contractapi.TransactionContextInterface, account string, balance int64) error {
+	key, err := ctx.GetStub().CreateCompositeKey(accountObjectType, []string{account})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, []byte(strconv.FormatInt(balance, 10)))
+}
+
+func readAllowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int64, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(allowanceIndex, []string{owner, spender})
+	if err != nil {
+		return 0, err
+	}
+	allowanceBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get allowance: %s", err)
+	}
+	if allowanceBytes == nil {
+		return 0, nil
+	}
+
+	allowance, err := strconv.ParseInt(string(allowanceBytes), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid allowance of %s for %s: %q", owner, spender, allowanceBytes)
+	}
+	return allowance, nil
+}
+
+func putAllowance(ctx contractapi.TransactionContextInterface, owner string, spender string, allowance int64) error {
+	key, err := ctx.GetStub().CreateCompositeKey(allowanceIndex, []string{owner, spender})
+	if err != nil {
+		return err
+	}
+	if allowance == 0 {
+		return ctx.GetStub().DelState(key)
+	}
+	return ctx.GetStub().PutState(key, []byte(strconv.FormatInt(allowance, 10)))
+}
+
+// setEvent emits an event. A transaction can only emit one event, so this is the last step of a transaction
+func setEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().SetEvent(name, payloadJSON)
+}
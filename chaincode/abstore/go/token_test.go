@@ -0,0 +1,269 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+const (
+	minter = "x509::CN=minter::CN=ca.org1"
+	alice  = "x509::CN=alice::CN=ca.org1"
+	bob    = "x509::CN=bob::CN=ca.org2"
+)
+
+// newToken initializes the chaincode and the token as clients of Org1MSP, and opens the accounts of the test clients
+func newToken(t *testing.T) (*TokenContract, *shimtest.MockStub) {
+	_, stub := newABstore(t)
+	token := new(TokenContract)
+	if err := token.Initialize(newContext(t, stub, minter, "Org1MSP"), "Sample token", "SMP"); err != nil {
+		t.Fatalf("Initialize failed: %s", err)
+	}
+	for _, client := range []struct{ id, mspID string }{{minter, "Org1MSP"}, {alice, "Org1MSP"}, {bob, "Org2MSP"}} {
+		if err := token.CreateAccount(newContext(t, stub, client.id, client.mspID)); err != nil {
+			t.Fatalf("CreateAccount failed: %s", err)
+		}
+	}
+	return token, stub
+}
+
+func checkTokenBalance(t *testing.T, token *TokenContract, stub *shimtest.MockStub, account string, expected int64) {
+	balance, err := token.BalanceOf(newContext(t, stub, alice, "Org1MSP"), account)
+	if err != nil || balance != expected {
+		t.Fatalf("unexpected balance of %s: %d %v, expecting %d", account, balance, err, expected)
+	}
+}
+
+// checkEvent checks the last event emitted by the stub
+func checkEvent(t *testing.T, stub *shimtest.MockStub, name string, expected interface{}) {
+	event := <-stub.ChaincodeEventsChannel
+	for len(stub.ChaincodeEventsChannel) > 0 {
+		event = <-stub.ChaincodeEventsChannel
+	}
+	if event.EventName != name {
+		t.Fatalf("unexpected event %s, expecting %s", event.EventName, name)
+	}
+
+	payload := reflect.New(reflect.TypeOf(expected).Elem()).Interface()
+	if err := json.Unmarshal(event.Payload, payload); err != nil {
+		t.Fatalf("failed to unmarshal event: %s", err)
+	}
+	if !reflect.DeepEqual(payload, expected) {
+		t.Fatalf("unexpected %s event: %+v, expecting %+v", name, payload, expected)
+	}
+}
+
+func TestToken_Initialize(t *testing.T) {
+	stub := shimtest.NewMockStub("abstore", nil)
+	token := new(TokenContract)
+
+	err := token.Initialize(newContext(t, stub, minter, "Org1MSP"), "Sample token", "SMP")
+	if err == nil || err.Error() != "Minter MSP is not set, the chaincode must be initialized with ABstore:Init first" {
+		t.Fatalf("unexpected response to Initialize: %v", err)
+	}
+
+	// The organization that initializes the chaincode is the minter, calling Init again does not change it
+	abstore := new(ABstore)
+	if err := abstore.Init(newContext(t, stub, "admin", "Org1MSP"), "A", 100, "B", 200); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+	if err := abstore.Init(newContext(t, stub, "admin", "Org2MSP"), "A", 100, "B", 200); err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	err = token.Initialize(newContext(t, stub, bob, "Org2MSP"), "Bob's token", "BOB")
+	if err == nil || err.Error() != "Client of Org2MSP is not authorized to initialize the token, only clients of Org1MSP are" {
+		t.Fatalf("unexpected response to Initialize: %v", err)
+	}
+
+	if err := token.Initialize(newContext(t, stub, minter, "Org1MSP"), "Sample token", "SMP"); err != nil {
+		t.Fatalf("Initialize failed: %s", err)
+	}
+	err = token.Initialize(newContext(t, stub, minter, "Org1MSP"), "Other token", "OTH")
+	if err == nil || err.Error() != "Token is already initialized" {
+		t.Fatalf("unexpected response to Initialize: %v", err)
+	}
+
+	description, err := token.GetToken(newContext(t, stub, bob, "Org2MSP"))
+	if err != nil {
+		t.Fatalf("GetToken failed: %s", err)
+	}
+	if !reflect.DeepEqual(description, &Token{Name: "Sample token", Symbol: "SMP", MinterMSP: "Org1MSP"}) {
+		t.Fatalf("unexpected token: %+v", description)
+	}
+}
+
+func TestToken_MintAndBurn(t *testing.T) {
+	token, stub := newToken(t)
+
+	if err := token.Mint(newContext(t, stub, minter, "Org1MSP"), alice, 1000); err != nil {
+		t.Fatalf("Mint failed: %s", err)
+	}
+	checkEvent(t, stub, "Transfer", &TransferEvent{To: alice, Value: 1000})
+	checkTokenBalance(t, token, stub, alice, 1000)
+
+	tests := []struct {
+		mspID   string
+		account string
+		amount  int64
+		err     string
+	}{
+		{"Org2MSP", bob, 10, "Client of Org2MSP is not authorized to mint or burn tokens, only clients of Org1MSP are"},
+		{"Org1MSP", alice, 0, "Mint amount must be positive, got 0"},
+		{"Org1MSP", "carol", 10, "Account not found: carol"},
+		{"Org1MSP", alice, math.MaxInt64 - 999, "Overflow: total supply is 1000, cannot mint 9223372036854774808 more"},
+	}
+	for _, test := range tests {
+		err := token.Mint(newContext(t, stub, minter, test.mspID), test.account, test.amount)
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("unexpected response to Mint %s %d: %v", test.account, test.amount, err)
+		}
+	}
+
+	if err := token.Mint(newContext(t, stub, minter, "Org1MSP"), minter, 50); err != nil {
+		t.Fatalf("Mint failed: %s", err)
+	}
+	err := token.Burn(newContext(t, stub, minter, "Org1MSP"), 51)
+	if err == nil || err.Error() != "Insufficient funds: "+minter+" holds 50, burn of 51 requested" {
+		t.Fatalf("unexpected response to Burn: %v", err)
+	}
+	err = token.Burn(newContext(t, stub, bob, "Org2MSP"), 1)
+	if err == nil || err.Error() != "Client of Org2MSP is not authorized to mint or burn tokens, only clients of Org1MSP are" {
+		t.Fatalf("unexpected response to Burn: %v", err)
+	}
+
+	if err := token.Burn(newContext(t, stub, minter, "Org1MSP"), 20); err != nil {
+		t.Fatalf("Burn failed: %s", err)
+	}
+	checkEvent(t, stub, "Transfer", &TransferEvent{From: minter, Value: 20})
+	checkTokenBalance(t, token, stub, minter, 30)
+
+	supply, err := token.TotalSupply(newContext(t, stub, bob, "Org2MSP"))
+	if err != nil || supply != 1030 {
+		t.Fatalf("unexpected total supply: %d %v", supply, err)
+	}
+}
+
+func TestToken_Transfer(t *testing.T) {
+	token, stub := newToken(t)
+	if err := token.Mint(newContext(t, stub, minter, "Org1MSP"), alice, 100); err != nil {
+		t.Fatalf("Mint failed: %s", err)
+	}
+
+	if err := token.Transfer(newContext(t, stub, alice, "Org1MSP"), bob, 40); err != nil {
+		t.Fatalf("Transfer failed: %s", err)
+	}
+	checkEvent(t, stub, "Transfer", &TransferEvent{From: alice, To: bob, Value: 40})
+	checkTokenBalance(t, token, stub, alice, 60)
+	checkTokenBalance(t, token, stub, bob, 40)
+
+	tests := []struct {
+		recipient string
+		amount    int64
+		err       string
+	}{
+		{bob, 61, "Insufficient funds: " + alice + " holds 60, transfer of 61 requested"},
+		{bob, 0, "Transaction amount must be positive, got 0"},
+		{alice, 1, "Sender and recipient must be different accounts"},
+		{"carol", 1, "Account not found: carol"},
+	}
+	for _, test := range tests {
+		err := token.Transfer(newContext(t, stub, alice, "Org1MSP"), test.recipient, test.amount)
+		if err == nil || err.Error() != test.err {
+			t.Fatalf("unexpected response to Transfer %s %d: %v", test.recipient, test.amount, err)
+		}
+	}
+	checkTokenBalance(t, token, stub, alice, 60)
+	checkTokenBalance(t, token, stub, bob, 40)
+}
+
+func TestToken_Allowances(t *testing.T) {
+	token, stub := newToken(t)
+	if err := token.Mint(newContext(t, stub, minter, "Org1MSP"), alice, 100); err != nil {
+		t.Fatalf("Mint failed: %s", err)
+	}
+
+	err := token.Approve(newContext(t, stub, alice, "Org1MSP"), bob, -1)
+	if err == nil || err.Error() != "Allowance must not be negative, got -1" {
+		t.Fatalf("unexpected response to Approve: %v", err)
+	}
+	err = token.Approve(newContext(t, stub, "carol", "Org1MSP"), bob, 10)
+	if err == nil || err.Error() != "Account not found: carol" {
+		t.Fatalf("unexpected response to Approve: %v", err)
+	}
+
+	if err := token.Approve(newContext(t, stub, alice, "Org1MSP"), bob, 30); err != nil {
+		t.Fatalf("Approve failed: %s", err)
+	}
+	checkEvent(t, stub, "Approval", &ApprovalEvent{Owner: alice, Spender: bob, Value: 30})
+
+	err = token.TransferFrom(newContext(t, stub, bob, "Org2MSP"), alice, bob, 31)
+	if err == nil || err.Error() != "Insufficient allowance: 30, transfer of 31 requested" {
+		t.Fatalf("unexpected response to TransferFrom: %v", err)
+	}
+	err = token.TransferFrom(newContext(t, stub, minter, "Org1MSP"), alice, minter, 1)
+	if err == nil || err.Error() != "Insufficient allowance: 0, transfer of 1 requested" {
+		t.Fatalf("unexpected response to TransferFrom: %v", err)
+	}
+
+	if err := token.TransferFrom(newContext(t, stub, bob, "Org2MSP"), alice, minter, 20); err != nil {
+		t.Fatalf("TransferFrom failed: %s", err)
+	}
+	checkEvent(t, stub, "Transfer", &TransferEvent{From: alice, To: minter, Value: 20})
+	checkTokenBalance(t, token, stub, alice, 80)
+	checkTokenBalance(t, token, stub, minter, 20)
+
+	allowance, err := token.Allowance(newContext(t, stub, bob, "Org2MSP"), alice, bob)
+	if err != nil || allowance != 10 {
+		t.Fatalf("unexpected allowance: %d %v", allowance, err)
+	}
+
+	// Spending the whole allowance removes it
+	if err := token.TransferFrom(newContext(t, stub, bob, "Org2MSP"), alice, bob, 10); err != nil {
+		t.Fatalf("TransferFrom failed: %s", err)
+	}
+	allowanceKey, _ := stub.CreateCompositeKey(allowanceIndex, []string{alice, bob})
+	if stub.State[allowanceKey] != nil {
+		t.Fatalf("spent allowance was not removed")
+	}
+}
+
+func TestToken_LedgerIsNotReachableFromABstore(t *testing.T) {
+	token, stub := newToken(t)
+	if err := token.Mint(newContext(t, stub, minter, "Org1MSP"), alice, 100); err != nil {
+		t.Fatalf("Mint failed: %s", err)
+	}
+	abstore := new(ABstore)
+
+	accountKey, _ := stub.CreateCompositeKey(accountObjectType, []string{alice})
+	expected := "Entity name \"\\x00account\\x00" + alice + "\\x00\" must not start with the null character"
+
+	if _, err := abstore.Query(newContext(t, stub, alice, "Org1MSP"), accountKey); err == nil || err.Error() != expected {
+		t.Fatalf("unexpected response to Query: %v", err)
+	}
+	if err := abstore.Invoke(newContext(t, stub, alice, "Org1MSP"), accountKey, "A", 10); err == nil || err.Error() != expected {
+		t.Fatalf("unexpected response to Invoke: %v", err)
+	}
+	if err := abstore.Invoke(newContext(t, stub, "admin", "Org1MSP"), "A", accountKey, 10); err == nil || err.Error() != expected {
+		t.Fatalf("unexpected response to Invoke: %v", err)
+	}
+	if err := abstore.Delete(newContext(t, stub, alice, "Org1MSP"), accountKey); err == nil || err.Error() != expected {
+		t.Fatalf("unexpected response to Delete: %v", err)
+	}
+	if err := abstore.Init(newContext(t, stub, "admin", "Org1MSP"), accountKey, 1000000, "C", 0); err == nil || err.Error() != expected {
+		t.Fatalf("unexpected response to Init: %v", err)
+	}
+	if _, err := abstore.Statement(newContext(t, stub, alice, "Org1MSP"), accountKey); err == nil || err.Error() != expected {
+		t.Fatalf("unexpected response to Statement: %v", err)
+	}
+
+	checkTokenBalance(t, token, stub, alice, 100)
+	checkBalance(t, abstore, stub, "A", "100")
+}
@@ -0,0 +1,184 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// The transfer log keeps one record per transaction, and an index entry
+// per account involved so that the statement of an account is a range query
+const (
+	transferObjectType = "transfer"
+	accountIndex       = "account~txID"
+
+	// compositeKeyNamespace is the first character of the composite keys created by the shim
+	compositeKeyNamespace = "\x00"
+)
+
+// Transfer is an entry of the append-only transfer log
+type Transfer struct {
+	TxID      string    `json:"txID"`
+	Timestamp time.Time `json:"timestamp"`
+	Payer     string    `json:"payer"`
+	Payee     string    `json:"payee"`
+	Amount    int64     `json:"amount"`
+	Submitter string    `json:"submitter"`
+}
+
+// Statement returns the transfers from and to an account, oldest first
+func (t *ABstore) Statement(ctx contractapi.TransactionContextInterface, account string) ([]*Transfer, error) {
+	if err := checkEntities(account); err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(accountIndex, []string{account})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	statement := []*Transfer{}
+	for resultsIterator.HasNext() {
+		indexEntry, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(indexEntry.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := readTransfer(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			return nil, fmt.Errorf("Transfer %s is indexed but not recorded", keyParts[1])
+		}
+		statement = append(statement, record)
+	}
+
+	// Transaction IDs are random, so the index does not keep the transfers in order
+	sort.SliceStable(statement, func(i, j int) bool {
+		return statement[i].Timestamp.Before(statement[j].Timestamp)
+	})
+	return statement, nil
+}
+
+// readBalance reads the asset holding of an entity, rejecting values that are not valid holdings
+func readBalance(ctx contractapi.TransactionContextInterface, entity string) (int64, error) {
+	valbytes, err := ctx.GetStub().GetState(entity)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get state for %s", entity)
+	}
+	if valbytes == nil {
+		return 0, fmt.Errorf("Entity not found: %s", entity)
+	}
+
+	balance, err := strconv.ParseInt(string(valbytes), 10, 64)
+	if err != nil || balance < 0 {
+		return 0, fmt.Errorf("Invalid asset holding of %s: %q", entity, valbytes)
+	}
+	return balance, nil
+}
+
+// checkTransfer returns the asset holdings of A and B after a transfer of X units,
+// or an error if A cannot afford the transfer or the holding of B would overflow
+func checkTransfer(A string, Aval int64, B string, Bval int64, X int64) (int64, int64, error) {
+	if X <= 0 {
+		return 0, 0, fmt.Errorf("Transaction amount must be positive, got %d", X)
+	}
+	if Aval < X {
+		return 0, 0, fmt.Errorf("Insufficient funds: %s holds %d, transfer of %d requested", A, Aval, X)
+	}
+	if Bval > math.MaxInt64-X {
+		return 0, 0, fmt.Errorf("Overflow: %s holds %d, cannot receive %d more", B, Bval, X)
+	}
+	return Aval - X, Bval + X, nil
+}
+
+// readTransfer returns the transfer recorded by a transaction, or nil
+func readTransfer(ctx contractapi.TransactionContextInterface, txID string) (*Transfer, error) {
+	transferKey, err := ctx.GetStub().CreateCompositeKey(transferObjectType, []string{txID})
+	if err != nil {
+		return nil, err
+	}
+	recordJSON, err := ctx.GetStub().GetState(transferKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get transfer %s", txID)
+	}
+	if recordJSON == nil {
+		return nil, nil
+	}
+
+	record := &Transfer{}
+	err = json.Unmarshal(recordJSON, record)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// recordTransfer adds the transfer made by the current transaction to the log
+func recordTransfer(ctx contractapi.TransactionContextInterface, payer, payee string, amount int64) error {
+	txID := ctx.GetStub().GetTxID()
+	existing, err := readTransfer(ctx, txID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("Transfer %s is already recorded", txID)
+	}
+
+	submitter, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("Failed to get submitter identity: %s", err)
+	}
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("Failed to get transaction timestamp: %s", err)
+	}
+
+	recordJSON, err := json.Marshal(&Transfer{
+		TxID:      txID,
+		Timestamp: time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC(),
+		Payer:     payer,
+		Payee:     payee,
+		Amount:    amount,
+		Submitter: submitter,
+	})
+	if err != nil {
+		return err
+	}
+	transferKey, err := ctx.GetStub().CreateCompositeKey(transferObjectType, []string{txID})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(transferKey, recordJSON)
+	if err != nil {
+		return err
+	}
+
+	// A nil value would delete the index entry, so the null character is stored instead
+	for _, account := range []string{payer, payee} {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(accountIndex, []string{account, txID})
+		if err != nil {
+			return err
+		}
+		err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,4 @@
+package chaincode
+
+// MarbleQueryGuard exposes the query guard to the tests
+var MarbleQueryGuard = marbleQueryGuard
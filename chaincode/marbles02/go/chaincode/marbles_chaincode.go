@@ -0,0 +1,618 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+// ====CHAINCODE EXECUTION SAMPLES (CLI) ==================
+
+// ==== Invoke marbles ====
+// peer chaincode invoke -C myc1 -n marbles -c '{"Args":["InitMarble","marble1","blue","35","tom"]}'
+// peer chaincode invoke -C myc1 -n marbles -c '{"Args":["InitMarble","marble2","red","50","tom"]}'
+// peer chaincode invoke -C myc1 -n marbles -c '{"Args":["InitMarble","marble3","blue","70","tom"]}'
+// peer chaincode invoke -C myc1 -n marbles -c '{"Args":["TransferMarble","marble2","jerry"]}'
+// peer chaincode invoke -C myc1 -n marbles -c '{"Args":["TransferMarblesBasedOnColor","blue","jerry"]}'
+// peer chaincode invoke -C myc1 -n marbles -c '{"Args":["TransferMarblesBasedOnColorWithPagination","blue","jerry","10",""]}'
+// peer chaincode invoke -C myc1 -n marbles -c '{"Args":["Delete","marble1"]}'
+
+// ==== Query marbles ====
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["ReadMarble","marble1"]}'
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["GetMarblesByRange","marble1","marble3"]}'
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["GetMarblesByRangeWithPagination","marble1","marble9","2",""]}'
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["GetHistoryForMarble","marble1"]}'
+
+// Rich Query (Evaluated by the chaincode if LevelDB is used as state database):
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["QueryMarblesByOwner","tom"]}'
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["QueryMarbles","{\"selector\":{\"docType\":\"marble\",\"owner\":\"tom\"}}"]}'
+
+// Rich Query with Pagination (Evaluated by the chaincode if LevelDB is used as state database):
+// peer chaincode query -C myc1 -n marbles -c '{"Args":["QueryMarblesWithPagination","{\"selector\":{\"docType\":\"marble\",\"owner\":\"tom\"}}","3",""]}'
+
+// INDEXES TO SUPPORT COUCHDB RICH QUERIES
+//
+// Indexes in CouchDB are required in order to make JSON queries efficient and are required for
+// any JSON query with a sort. As of Hyperledger Fabric 1.1, indexes may be packaged alongside
+// chaincode in a META-INF/statedb/couchdb/indexes directory. Each index must be defined in its own
+// text file with extension *.json with the index definition formatted in JSON following the
+// CouchDB index JSON syntax as documented at:
+// http://docs.couchdb.org/en/2.1.1/api/database/find.html#db-index
+//
+// This marbles02 example chaincode demonstrates packaged indexes
+// which you can find in META-INF/statedb/couchdb/indexes/indexOwner.json and indexSize.json.
+// Ad hoc queries are only accepted if one of the packaged indexes serves them, see marbleQueryGuard.
+// For deployment of chaincode to production environments, it is recommended
+// to define any indexes alongside chaincode so that the chaincode and supporting indexes
+// are deployed automatically as a unit, once the chaincode has been installed on a peer and
+// instantiated on a channel. See Hyperledger Fabric documentation for more details.
+//
+// If you have access to the your peer's CouchDB state database in a development environment,
+// you may want to iteratively test various indexes in support of your chaincode queries.  You
+// can use the CouchDB Fauxton interface or a command line curl utility to create and update
+// indexes. Then once you finalize an index, include the index definition alongside your
+// chaincode in the META-INF/statedb/couchdb/indexes directory, for packaging and deployment
+// to managed environments.
+//
+// In the examples below you can find index definitions that support marbles02
+// chaincode queries, along with the syntax that you can use in development environments
+// to create the indexes in the CouchDB Fauxton interface or a curl command line utility.
+//
+
+//Example hostname:port configurations to access CouchDB.
+//
+//To access CouchDB docker container from within another docker container or from vagrant environments:
+// http://couchdb:5984/
+//
+//Inside couchdb docker container
+// http://127.0.0.1:5984/
+
+// Index for docType, owner.
+//
+// Example curl command line to define index in the CouchDB channel_chaincode database
+// curl -i -X POST -H "Content-Type: application/json" -d "{\"index\":{\"fields\":[\"docType\",\"owner\"]},\"name\":\"indexOwner\",\"ddoc\":\"indexOwnerDoc\",\"type\":\"json\"}" http://hostname:port/myc1_marbles/_index
+//
+
+// Index for docType, size.
+//
+// Example curl command line to define index in the CouchDB channel_chaincode database
+// curl -i -X POST -H "Content-Type: application/json" -d "{\"index\":{\"fields\":[\"docType\",\"size\"]},\"name\":\"indexSize\",\"ddoc\":\"indexSizeDoc\",\"type\":\"json\"}" http://hostname:port/myc1_marbles/_index
+
+// Rich Query with index design doc and index name specified (Only supported if CouchDB is used as state database):
+//   peer chaincode query -C myc1 -n marbles -c '{"Args":["QueryMarbles","{\"selector\":{\"docType\":\"marble\",\"owner\":\"tom\"}, \"use_index\":[\"_design/indexOwnerDoc\", \"indexOwner\"]}"]}'
+
+// Rich Query with index design doc specified only (Only supported if CouchDB is used as state database):
+//   peer chaincode query -C myc1 -n marbles -c '{"Args":["QueryMarbles","{\"selector\":{\"docType\":{\"$eq\":\"marble\"},\"owner\":{\"$eq\":\"tom\"},\"size\":{\"$gt\":0}},\"fields\":[\"docType\",\"owner\",\"size\"],\"sort\":[{\"docType\":\"desc\"},{\"size\":\"desc\"}],\"use_index\":\"_design/indexSizeDoc\"}"]}'
+
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery"
+)
+
+const index = "color~name"
+
+// MarblesContract provides functions for managing marbles
+type MarblesContract struct {
+	contractapi.Contract
+}
+
+// Marble describes the marbles stored in the world state
+type Marble struct {
+	ObjectType string `json:"docType"` //docType is used to distinguish the various types of objects in state database
+	Name       string `json:"name"`    //the fieldtags are needed to keep case from bouncing around
+	Color      string `json:"color"`
+	Size       int    `json:"size"`
+	Owner      string `json:"owner"`
+}
+
+// HistoryQueryResult structure used for returning result of history query
+type HistoryQueryResult struct {
+	Record    *Marble   `json:"record"`
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// PaginatedQueryResult structure used for returning paginated query results and metadata
+type PaginatedQueryResult struct {
+	Records             []*Marble `json:"records"`
+	FetchedRecordsCount int32     `json:"fetchedRecordsCount"`
+	Bookmark            string    `json:"bookmark"`
+}
+
+// ColorTransferResult is returned by TransferMarblesBasedOnColorWithPagination
+type ColorTransferResult struct {
+	TransferredCount int                    `json:"transferredCount"`
+	Failures         []ColorTransferFailure `json:"failures"`
+	Bookmark         string                 `json:"bookmark"` //name of the next marble to transfer, empty once all marbles were processed
+}
+
+// ColorTransferFailure describes a marble that could not be transferred
+type ColorTransferFailure struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// marbleQueryGuard restricts the ad hoc queries of QueryMarbles and QueryMarblesWithPagination
+// to marbles, and requires them to use one of the indexes in META-INF/statedb/couchdb/indexes.
+// The indexes below are checked against the packaged index definitions by the tests.
+var marbleQueryGuard = &richquery.Guard{
+	DocTypeField:  "docType",
+	DocType:       "marble",
+	AllowedFields: []string{"docType", "name", "color", "size", "owner"},
+	MaxLimit:      100,
+	Indexes: []richquery.Index{
+		{DesignDoc: "indexOwnerDoc", Name: "indexOwner", Fields: []string{"docType", "owner"}},
+		{DesignDoc: "indexSizeDoc", Name: "indexSize", Fields: []string{"docType", "size"}},
+	},
+}
+
+// marbleQueryFallback evaluates rich queries in the chaincode if the state database does not
+// support them. It reads at most 1000 keys, so it is only suitable for development
+var marbleQueryFallback = &richquery.Fallback{MaxKeys: 1000}
+
+// ============================================================
+// InitMarble - create a new marble, store into chaincode state
+// ============================================================
+func (t *MarblesContract) InitMarble(ctx contractapi.TransactionContextInterface, marbleName, color string, size int, owner string) error {
+	// ==== Input sanitation ====
+	if len(marbleName) == 0 {
+		return fmt.Errorf("marble name must be a non-empty string")
+	}
+	if len(color) == 0 {
+		return fmt.Errorf("color must be a non-empty string")
+	}
+	if size <= 0 {
+		return fmt.Errorf("size must be a positive integer")
+	}
+	if len(owner) == 0 {
+		return fmt.Errorf("owner must be a non-empty string")
+	}
+
+	// ==== Check if marble already exists ====
+	marbleAsBytes, err := ctx.GetStub().GetState(marbleName)
+	if err != nil {
+		return fmt.Errorf("failed to get marble: %v", err)
+	} else if marbleAsBytes != nil {
+		return fmt.Errorf("this marble already exists: %s", marbleName)
+	}
+
+	// ==== Create marble object and marshal to JSON ====
+	marble := &Marble{
+		ObjectType: "marble",
+		Name:       marbleName,
+		Color:      strings.ToLower(color),
+		Size:       size,
+		Owner:      strings.ToLower(owner),
+	}
+	marbleJSONasBytes, err := json.Marshal(marble)
+	if err != nil {
+		return err
+	}
+
+	// === Save marble to state ===
+	err = ctx.GetStub().PutState(marbleName, marbleJSONasBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put marble: %v", err)
+	}
+
+	//  ==== Index the marble to enable color-based range queries, e.g. return all blue marbles ====
+	//  An 'index' is a normal key/value entry in state.
+	//  The key is a composite key, with the elements that you want to range query on listed first.
+	//  In our case, the composite key is based on indexName~color~name.
+	//  This will enable very efficient state range queries based on composite keys matching indexName~color~*
+	colorNameIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{marble.Color, marble.Name})
+	if err != nil {
+		return err
+	}
+	//  Save index entry to state. Only the key name is needed, no need to store a duplicate copy of the marble.
+	//  Note - passing a 'nil' value will effectively delete the key from state, therefore we pass null character as value
+	value := []byte{0x00}
+	return ctx.GetStub().PutState(colorNameIndexKey, value)
+}
+
+// ===============================================
+// ReadMarble - read a marble from chaincode state
+// ===============================================
+func (t *MarblesContract) ReadMarble(ctx contractapi.TransactionContextInterface, marbleName string) (*Marble, error) {
+	marbleAsBytes, err := ctx.GetStub().GetState(marbleName) //get the marble from chaincode state
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state for %s: %v", marbleName, err)
+	} else if marbleAsBytes == nil {
+		return nil, fmt.Errorf("marble does not exist: %s", marbleName)
+	}
+
+	marble := new(Marble)
+	err = json.Unmarshal(marbleAsBytes, marble)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JSON of %s: %v", marbleName, err)
+	}
+
+	return marble, nil
+}
+
+// ==================================================
+// Delete - remove a marble key/value pair from state
+// ==================================================
+func (t *MarblesContract) Delete(ctx contractapi.TransactionContextInterface, marbleName string) error {
+	// to maintain the color~name index, we need to read the marble first and get its color
+	marble, err := t.ReadMarble(ctx, marbleName)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(marbleName) //remove the marble from chaincode state
+	if err != nil {
+		return fmt.Errorf("failed to delete state: %v", err)
+	}
+
+	// maintain the index
+	colorNameIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{marble.Color, marble.Name})
+	if err != nil {
+		return err
+	}
+
+	//  Delete index entry to state.
+	err = ctx.GetStub().DelState(colorNameIndexKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete state: %v", err)
+	}
+	return nil
+}
+
+// ===========================================================
+// TransferMarble - transfer a marble by setting a new owner name on the marble
+// ===========================================================
+func (t *MarblesContract) TransferMarble(ctx contractapi.TransactionContextInterface, marbleName, newOwner string) error {
+	marbleToTransfer, err := t.ReadMarble(ctx, marbleName)
+	if err != nil {
+		return err
+	}
+	marbleToTransfer.Owner = strings.ToLower(newOwner) //change the owner
+
+	marbleJSONasBytes, err := json.Marshal(marbleToTransfer)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(marbleName, marbleJSONasBytes) //rewrite the marble
+}
+
+// ===========================================================================================
+// constructQueryResponseFromIterator constructs a slice of marbles from the resultsIterator
+// ===========================================================================================
+func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]*Marble, error) {
+	marbles := []*Marble{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		marble := new(Marble)
+		err = json.Unmarshal(queryResponse.Value, marble)
+		if err != nil {
+			return nil, err
+		}
+		marbles = append(marbles, marble)
+	}
+
+	return marbles, nil
+}
+
+// ===========================================================================================
+// GetMarblesByRange performs a range query based on the start and end keys provided.
+
+// Read-only function results are not typically submitted to ordering. If the read-only
+// results are submitted to ordering, or if the query is used in an update transaction
+// and submitted to ordering, then the committing peers will re-execute to guarantee that
+// result sets are stable between endorsement time and commit time. The transaction is
+// invalidated by the committing peers if the result set has changed between endorsement
+// time and commit time.
+// Therefore, range queries are a safe option for performing update transactions based on query results.
+// ===========================================================================================
+func (t *MarblesContract) GetMarblesByRange(ctx contractapi.TransactionContextInterface, startKey, endKey string) ([]*Marble, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructQueryResponseFromIterator(resultsIterator)
+}
+
+// ==== Example: GetStateByPartialCompositeKey/RangeQuery =========================================
+// TransferMarblesBasedOnColor will transfer marbles of a given color to a certain new owner,
+// and returns the number of transferred marbles.
+// Uses a GetStateByPartialCompositeKey (range query) against color~name 'index'.
+// Committing peers will re-execute range queries to guarantee that result sets are stable
+// between endorsement time and commit time. The transaction is invalidated by the
+// committing peers if the result set has changed between endorsement time and commit time.
+// Therefore, range queries are a safe option for performing update transactions based on query results.
+// ===========================================================================================
+func (t *MarblesContract) TransferMarblesBasedOnColor(ctx contractapi.TransactionContextInterface, color, newOwner string) (int, error) {
+	// Query the color~name index by color
+	// This will execute a key range query on all keys starting with 'color'
+	coloredMarbleResultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{color})
+	if err != nil {
+		return 0, err
+	}
+	defer coloredMarbleResultsIterator.Close()
+
+	// Iterate through result set and for each marble found, transfer to newOwner
+	var i int
+	for i = 0; coloredMarbleResultsIterator.HasNext(); i++ {
+		// Note that we don't get the value (2nd return variable), we'll just get the marble name from the composite key
+		responseRange, err := coloredMarbleResultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+
+		// get the color and name from color~name composite key
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return 0, err
+		}
+		returnedMarbleName := compositeKeyParts[1]
+
+		// Now call the transfer function for the found marble.
+		// Re-use the same function that is used to transfer individual marbles
+		err = t.TransferMarble(ctx, returnedMarbleName, newOwner)
+		// if the transfer failed break out of loop and return error
+		if err != nil {
+			return 0, fmt.Errorf("transfer failed: %v", err)
+		}
+	}
+
+	return i, nil
+}
+
+// ==== Example: Paged update with GetStateByPartialCompositeKey ==================================
+// TransferMarblesBasedOnColorWithPagination transfers at most pageSize marbles of a given color
+// to a new owner, starting from the marble named by the bookmark, so that large color groups can be
+// transferred in several transactions. Marbles that fail to transfer are reported in the result
+// rather than aborting the transaction, and the returned bookmark is passed to the next call.
+// Paginated queries are only valid for read only transactions, and GetStateByRange rejects composite
+// keys, so the range cannot start at the bookmark. The marbles before the bookmark are skipped while
+// iterating over the color~name 'index' instead, which only reads their index keys. Only the keys read
+// up to the first marble of the next page are part of the range query re-executed by the committing peers.
+// ===========================================================================================
+func (t *MarblesContract) TransferMarblesBasedOnColorWithPagination(ctx contractapi.TransactionContextInterface, color, newOwner string, pageSize int, bookmark string) (*ColorTransferResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be a positive integer")
+	}
+
+	coloredMarbleResultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{color})
+	if err != nil {
+		return nil, err
+	}
+	defer coloredMarbleResultsIterator.Close()
+
+	result := &ColorTransferResult{Failures: []ColorTransferFailure{}}
+	processed := 0
+	for coloredMarbleResultsIterator.HasNext() {
+		responseRange, err := coloredMarbleResultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		returnedMarbleName := compositeKeyParts[1]
+
+		// Skip the marbles transferred by the previous pages
+		if returnedMarbleName < bookmark {
+			continue
+		}
+		// Stop at the first marble of the next page
+		if processed == pageSize {
+			result.Bookmark = returnedMarbleName
+			break
+		}
+		processed++
+
+		err = t.TransferMarble(ctx, returnedMarbleName, newOwner)
+		if err != nil {
+			result.Failures = append(result.Failures, ColorTransferFailure{Name: returnedMarbleName, Error: err.Error()})
+			continue
+		}
+		result.TransferredCount++
+	}
+
+	return result, nil
+}
+
+// =======Rich queries =========================================================================
+// Two examples of rich queries are provided below (parameterized query and ad hoc query).
+// Rich queries pass a query string to the state database.
+// Rich queries are only supported by state database implementations
+//  that support rich query (e.g. CouchDB).
+// The query string is in the syntax of the underlying state database.
+// On other state databases (e.g. LevelDB) the richquery package evaluates the
+//  query in the chaincode instead, by reading the keys of the namespace up to
+//  the limit of marbleQueryFallback. This is only suitable for development and small data sets.
+// With rich queries there is no guarantee that the result set hasn't changed between
+//  endorsement time and commit time, aka 'phantom reads'.
+// Therefore, rich queries should not be used in update transactions, unless the
+// application handles the possibility of result set changes between endorsement and commit time.
+// Rich queries can be used for point-in-time queries against a peer.
+// ============================================================================================
+
+// ===== Example: Parameterized rich query =================================================
+// QueryMarblesByOwner queries for marbles based on a passed in owner.
+// This is an example of a parameterized query where the query logic is baked into the chaincode,
+// and accepting a single query parameter (owner).
+// Only available on state databases that support rich query (e.g. CouchDB)
+// =========================================================================================
+func (t *MarblesContract) QueryMarblesByOwner(ctx contractapi.TransactionContextInterface, owner string) ([]*Marble, error) {
+	queryString := fmt.Sprintf("{\"selector\":{\"docType\":\"marble\",\"owner\":\"%s\"}}", strings.ToLower(owner))
+
+	return getQueryResultForQueryString(ctx, queryString)
+}
+
+// ===== Example: Ad hoc rich query ========================================================
+// QueryMarbles uses a query string to perform a query for marbles.
+// Query string matching state database syntax is passed in and executed as is.
+// Supports ad hoc queries that can be defined at runtime by the client.
+// If this is not desired, follow the QueryMarblesByOwner example for parameterized queries.
+// The query must select marbles using a packaged index, and its limit is capped by marbleQueryGuard.
+// Only available on state databases that support rich query (e.g. CouchDB)
+// =========================================================================================
+func (t *MarblesContract) QueryMarbles(ctx contractapi.TransactionContextInterface, queryString string) ([]*Marble, error) {
+	queryString, err := marbleQueryGuard.Check(queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := richquery.GetQueryResult(ctx.GetStub(), queryString, marbleQueryFallback)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	// CouchDB ignores the limit of queries that are not paginated
+	return constructQueryResponseFromIterator(marbleQueryGuard.Limit(resultsIterator))
+}
+
+// =========================================================================================
+// getQueryResultForQueryString executes the passed in query string.
+// =========================================================================================
+func getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, queryString string) ([]*Marble, error) {
+	resultsIterator, err := richquery.GetQueryResult(ctx.GetStub(), queryString, marbleQueryFallback)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return constructQueryResponseFromIterator(resultsIterator)
+}
+
+// ====== Pagination =========================================================================
+// Pagination provides a method to retrieve records with a defined pagesize and
+// start point (bookmark).  An empty string bookmark defines the first "page" of a query
+// result.  Paginated queries return a bookmark that can be used in
+// the next query to retrieve the next page of results.  Paginated queries extend
+// rich queries and range queries to include a pagesize and bookmark.
+//
+// Two examples are provided in this example.  The first is GetMarblesByRangeWithPagination
+// which executes a paginated range query.
+// The second example is a paginated query for rich ad-hoc queries.
+// =========================================================================================
+
+// ====== Example: Pagination with Range Query ===============================================
+// GetMarblesByRangeWithPagination performs a range query based on the start & end key,
+// page size and a bookmark.
+
+// The number of fetched records will be equal to or lesser than the page size.
+// Paginated range queries are only valid for read only transactions.
+// ===========================================================================================
+func (t *MarblesContract) GetMarblesByRangeWithPagination(ctx contractapi.TransactionContextInterface, startKey, endKey string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	marbles, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             marbles,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// ===== Example: Pagination with Ad hoc Rich Query ========================================================
+// QueryMarblesWithPagination uses a query string, page size and a bookmark to perform a query
+// for marbles. Query string matching state database syntax is passed in and executed as is.
+// The number of fetched records would be equal to or lesser than the specified page size.
+// Supports ad hoc queries that can be defined at runtime by the client.
+// If this is not desired, follow the QueryMarblesByOwner example for parameterized queries.
+// The query and page size are checked by marbleQueryGuard like for QueryMarbles.
+// Only available on state databases that support rich query (e.g. CouchDB)
+// Paginated queries are only valid for read only transactions.
+// =========================================================================================
+func (t *MarblesContract) QueryMarblesWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	queryString, err := marbleQueryGuard.Check(queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	return getQueryResultForQueryStringWithPagination(ctx, queryString, marbleQueryGuard.PageSize(int32(pageSize)), bookmark)
+}
+
+// =========================================================================================
+// getQueryResultForQueryStringWithPagination executes the passed in query string with
+// pagination info.
+// =========================================================================================
+func getQueryResultForQueryStringWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	resultsIterator, responseMetadata, err := richquery.GetQueryResultWithPagination(ctx.GetStub(), queryString, pageSize, bookmark, marbleQueryFallback)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	marbles, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             marbles,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// =========================================================================================
+// GetHistoryForMarble returns the values of a marble over time. The record of a
+// deletion only contains the name of the marble
+// =========================================================================================
+func (t *MarblesContract) GetHistoryForMarble(ctx contractapi.TransactionContextInterface, marbleName string) ([]HistoryQueryResult, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(marbleName)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	records := []HistoryQueryResult{}
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// if it was a delete operation on given key, then there is no value to decode
+		marble := &Marble{Name: marbleName}
+		if !response.IsDelete {
+			err = json.Unmarshal(response.Value, marble)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		timestamp, err := ptypes.Timestamp(response.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, HistoryQueryResult{
+			Record:    marble,
+			TxId:      response.TxId,
+			Timestamp: timestamp,
+			IsDelete:  response.IsDelete,
+		})
+	}
+
+	return records, nil
+}
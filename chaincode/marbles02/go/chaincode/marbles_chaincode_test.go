@@ -0,0 +1,499 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/chaincode/marbles02/go/chaincode"
+	"github.com/hyperledger/fabric-samples/chaincode/marbles02/go/chaincode/mocks"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery/richquerytest"
+	"github.com/stretchr/testify/require"
+)
+
+//go:generate counterfeiter -o mocks/transaction.go -fake-name TransactionContext . transactionContext
+type transactionContext interface {
+	contractapi.TransactionContextInterface
+}
+
+//go:generate counterfeiter -o mocks/chaincodestub.go -fake-name ChaincodeStub . chaincodeStub
+type chaincodeStub interface {
+	shim.ChaincodeStubInterface
+}
+
+//go:generate counterfeiter -o mocks/statequeryiterator.go -fake-name StateQueryIterator . stateQueryIterator
+type stateQueryIterator interface {
+	shim.StateQueryIteratorInterface
+}
+
+//go:generate counterfeiter -o mocks/historyqueryiterator.go -fake-name HistoryQueryIterator . historyQueryIterator
+type historyQueryIterator interface {
+	shim.HistoryQueryIteratorInterface
+}
+
+func prepMocks() (*mocks.TransactionContext, *mocks.ChaincodeStub) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.CreateCompositeKeyStub = shim.CreateCompositeKey
+	chaincodeStub.SplitCompositeKeyStub = (&shim.ChaincodeStub{}).SplitCompositeKey
+
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	return transactionContext, chaincodeStub
+}
+
+func marbleBytes(t *testing.T, marble *chaincode.Marble) []byte {
+	bytes, err := json.Marshal(marble)
+	require.NoError(t, err)
+	return bytes
+}
+
+// iteratorOver returns an iterator over the marbles, keyed by their names
+func iteratorOver(t *testing.T, marbles ...*chaincode.Marble) *mocks.StateQueryIterator {
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextStub = func() bool {
+		return iterator.NextCallCount() < len(marbles)
+	}
+	iterator.NextStub = func() (*queryresult.KV, error) {
+		marble := marbles[iterator.NextCallCount()-1]
+		return &queryresult.KV{Key: marble.Name, Value: marbleBytes(t, marble)}, nil
+	}
+	return iterator
+}
+
+// richQueryContext returns a transaction context over a stub holding the marbles,
+// that evaluates rich queries against its state
+func richQueryContext(t *testing.T, marbles ...*chaincode.Marble) *contractapi.TransactionContext {
+	stub := richquerytest.NewMockStub("marbles", nil)
+	stub.MockTransactionStart("tx1")
+	for _, marble := range marbles {
+		require.NoError(t, stub.PutState(marble.Name, marbleBytes(t, marble)))
+		colorNameIndexKey, err := stub.CreateCompositeKey("color~name", []string{marble.Color, marble.Name})
+		require.NoError(t, err)
+		require.NoError(t, stub.PutState(colorNameIndexKey, []byte{0x00}))
+	}
+	stub.MockTransactionEnd("tx1")
+
+	transactionContext := new(contractapi.TransactionContext)
+	transactionContext.SetStub(stub)
+	return transactionContext
+}
+
+// colorIndexIterator returns an iterator over the color~name index entries of the marbles
+func colorIndexIterator(t *testing.T, color string, names ...string) *mocks.StateQueryIterator {
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextStub = func() bool {
+		return iterator.NextCallCount() < len(names)
+	}
+	iterator.NextStub = func() (*queryresult.KV, error) {
+		key, err := shim.CreateCompositeKey("color~name", []string{color, names[iterator.NextCallCount()-1]})
+		require.NoError(t, err)
+		return &queryresult.KV{Key: key, Value: []byte{0x00}}, nil
+	}
+	return iterator
+}
+
+func TestInitMarble(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	err := marbles.InitMarble(transactionContext, "marble1", "Blue", 35, "Tom")
+	require.NoError(t, err)
+	require.Equal(t, 2, chaincodeStub.PutStateCallCount())
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, "marble1", key)
+	require.JSONEq(t, `{"docType":"marble","name":"marble1","color":"blue","size":35,"owner":"tom"}`, string(value))
+	key, value = chaincodeStub.PutStateArgsForCall(1)
+	require.Equal(t, "\x00color~name\x00blue\x00marble1\x00", key)
+	require.Equal(t, []byte{0x00}, value)
+
+	err = marbles.InitMarble(transactionContext, "", "blue", 35, "tom")
+	require.EqualError(t, err, "marble name must be a non-empty string")
+	err = marbles.InitMarble(transactionContext, "marble1", "", 35, "tom")
+	require.EqualError(t, err, "color must be a non-empty string")
+	err = marbles.InitMarble(transactionContext, "marble1", "blue", 0, "tom")
+	require.EqualError(t, err, "size must be a positive integer")
+	err = marbles.InitMarble(transactionContext, "marble1", "blue", 35, "")
+	require.EqualError(t, err, "owner must be a non-empty string")
+
+	chaincodeStub.GetStateReturns([]byte{}, nil)
+	err = marbles.InitMarble(transactionContext, "marble1", "blue", 35, "tom")
+	require.EqualError(t, err, "this marble already exists: marble1")
+
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve marble"))
+	err = marbles.InitMarble(transactionContext, "marble1", "blue", 35, "tom")
+	require.EqualError(t, err, "failed to get marble: unable to retrieve marble")
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	chaincodeStub.PutStateReturns(fmt.Errorf("failed inserting key"))
+	err = marbles.InitMarble(transactionContext, "marble1", "blue", 35, "tom")
+	require.EqualError(t, err, "failed to put marble: failed inserting key")
+}
+
+func TestReadMarble(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	expectedMarble := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	chaincodeStub.GetStateReturns(marbleBytes(t, expectedMarble), nil)
+	marble, err := marbles.ReadMarble(transactionContext, "marble1")
+	require.NoError(t, err)
+	require.Equal(t, expectedMarble, marble)
+
+	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve marble"))
+	_, err = marbles.ReadMarble(transactionContext, "marble1")
+	require.EqualError(t, err, "failed to get state for marble1: unable to retrieve marble")
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	marble, err = marbles.ReadMarble(transactionContext, "marble1")
+	require.EqualError(t, err, "marble does not exist: marble1")
+	require.Nil(t, marble)
+
+	chaincodeStub.GetStateReturns([]byte("{"), nil)
+	_, err = marbles.ReadMarble(transactionContext, "marble1")
+	require.EqualError(t, err, "failed to decode JSON of marble1: unexpected end of JSON input")
+}
+
+func TestDelete(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	chaincodeStub.GetStateReturns(marbleBytes(t, marble), nil)
+	err := marbles.Delete(transactionContext, "marble1")
+	require.NoError(t, err)
+	require.Equal(t, 2, chaincodeStub.DelStateCallCount())
+	require.Equal(t, "marble1", chaincodeStub.DelStateArgsForCall(0))
+	require.Equal(t, "\x00color~name\x00blue\x00marble1\x00", chaincodeStub.DelStateArgsForCall(1))
+
+	chaincodeStub.DelStateReturns(fmt.Errorf("failed deleting key"))
+	err = marbles.Delete(transactionContext, "marble1")
+	require.EqualError(t, err, "failed to delete state: failed deleting key")
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	err = marbles.Delete(transactionContext, "marble1")
+	require.EqualError(t, err, "marble does not exist: marble1")
+}
+
+func TestTransferMarble(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	chaincodeStub.GetStateReturns(marbleBytes(t, marble), nil)
+	err := marbles.TransferMarble(transactionContext, "marble1", "Jerry")
+	require.NoError(t, err)
+	key, value := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, "marble1", key)
+	require.JSONEq(t, `{"docType":"marble","name":"marble1","color":"blue","size":35,"owner":"jerry"}`, string(value))
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	err = marbles.TransferMarble(transactionContext, "marble1", "jerry")
+	require.EqualError(t, err, "marble does not exist: marble1")
+}
+
+func TestTransferMarblesBasedOnColor(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	chaincodeStub.GetStateReturns(marbleBytes(t, marble), nil)
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(colorIndexIterator(t, "blue", "marble1", "marble2"), nil)
+	count, err := marbles.TransferMarblesBasedOnColor(transactionContext, "blue", "jerry")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	objectType, attributes := chaincodeStub.GetStateByPartialCompositeKeyArgsForCall(0)
+	require.Equal(t, "color~name", objectType)
+	require.Equal(t, []string{"blue"}, attributes)
+	require.Equal(t, 2, chaincodeStub.PutStateCallCount())
+	key, _ := chaincodeStub.PutStateArgsForCall(1)
+	require.Equal(t, "marble2", key)
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(colorIndexIterator(t, "blue", "marble1"), nil)
+	_, err = marbles.TransferMarblesBasedOnColor(transactionContext, "blue", "jerry")
+	require.EqualError(t, err, "transfer failed: marble does not exist: marble1")
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturns(true)
+	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(iterator, nil)
+	_, err = marbles.TransferMarblesBasedOnColor(transactionContext, "blue", "jerry")
+	require.EqualError(t, err, "failed retrieving next item")
+
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(nil, fmt.Errorf("failed retrieving all marbles"))
+	_, err = marbles.TransferMarblesBasedOnColor(transactionContext, "blue", "jerry")
+	require.EqualError(t, err, "failed retrieving all marbles")
+}
+
+func TestTransferMarblesBasedOnColorWithPagination(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble := &chaincode.Marble{ObjectType: "marble", Name: "marble", Color: "blue", Size: 35, Owner: "tom"}
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		if key == "marble3" {
+			return nil, nil
+		}
+		return marbleBytes(t, marble), nil
+	}
+
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(colorIndexIterator(t, "blue", "marble1", "marble2", "marble3", "marble4"), nil)
+	result, err := marbles.TransferMarblesBasedOnColorWithPagination(transactionContext, "blue", "jerry", 2, "")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.ColorTransferResult{TransferredCount: 2, Failures: []chaincode.ColorTransferFailure{}, Bookmark: "marble3"}, result)
+
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(colorIndexIterator(t, "blue", "marble1", "marble2", "marble3", "marble4"), nil)
+	result, err = marbles.TransferMarblesBasedOnColorWithPagination(transactionContext, "blue", "jerry", 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.ColorTransferResult{
+		TransferredCount: 1,
+		Failures:         []chaincode.ColorTransferFailure{{Name: "marble3", Error: "marble does not exist: marble3"}},
+	}, result)
+	require.Equal(t, 3, chaincodeStub.PutStateCallCount())
+
+	_, err = marbles.TransferMarblesBasedOnColorWithPagination(transactionContext, "blue", "jerry", 0, "")
+	require.EqualError(t, err, "page size must be a positive integer")
+
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(nil, fmt.Errorf("failed retrieving all marbles"))
+	_, err = marbles.TransferMarblesBasedOnColorWithPagination(transactionContext, "blue", "jerry", 2, "")
+	require.EqualError(t, err, "failed retrieving all marbles")
+}
+
+func TestTransferMarblesBasedOnColorPages(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	names := []string{"marble1", "marble2", "marble3", "marble4", "marble5"}
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		return marbleBytes(t, &chaincode.Marble{ObjectType: "marble", Name: key, Color: "blue", Size: 35, Owner: "tom"}), nil
+	}
+
+	// Page through the blue marbles, checking that no index key past the next page is read
+	var pages [][]string
+	bookmark := ""
+	for {
+		iterator := colorIndexIterator(t, "blue", names...)
+		chaincodeStub.GetStateByPartialCompositeKeyReturns(iterator, nil)
+		putCount := chaincodeStub.PutStateCallCount()
+
+		result, err := marbles.TransferMarblesBasedOnColorWithPagination(transactionContext, "blue", "jerry", 2, bookmark)
+		require.NoError(t, err)
+		require.Empty(t, result.Failures)
+
+		var page []string
+		for i := putCount; i < chaincodeStub.PutStateCallCount(); i++ {
+			key, _ := chaincodeStub.PutStateArgsForCall(i)
+			page = append(page, key)
+		}
+		pages = append(pages, page)
+
+		if result.Bookmark == "" {
+			require.Equal(t, len(names), iterator.NextCallCount())
+			break
+		}
+		require.Equal(t, result.Bookmark, names[iterator.NextCallCount()-1])
+		bookmark = result.Bookmark
+	}
+
+	require.Equal(t, [][]string{{"marble1", "marble2"}, {"marble3", "marble4"}, {"marble5"}}, pages)
+}
+
+func TestGetMarblesByRange(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble1 := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	marble2 := &chaincode.Marble{ObjectType: "marble", Name: "marble2", Color: "red", Size: 50, Owner: "tom"}
+	chaincodeStub.GetStateByRangeReturns(iteratorOver(t, marble1, marble2), nil)
+	result, err := marbles.GetMarblesByRange(transactionContext, "marble1", "marble3")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Marble{marble1, marble2}, result)
+
+	chaincodeStub.GetStateByRangeReturns(iteratorOver(t), nil)
+	result, err = marbles.GetMarblesByRange(transactionContext, "marble1", "marble3")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Marble{}, result)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturns(true)
+	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
+	chaincodeStub.GetStateByRangeReturns(iterator, nil)
+	_, err = marbles.GetMarblesByRange(transactionContext, "marble1", "marble3")
+	require.EqualError(t, err, "failed retrieving next item")
+
+	chaincodeStub.GetStateByRangeReturns(nil, fmt.Errorf("failed retrieving all marbles"))
+	_, err = marbles.GetMarblesByRange(transactionContext, "marble1", "marble3")
+	require.EqualError(t, err, "failed retrieving all marbles")
+}
+
+func TestGetMarblesByRangeWithPagination(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble1 := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	chaincodeStub.GetStateByRangeWithPaginationReturns(iteratorOver(t, marble1), &peer.QueryResponseMetadata{FetchedRecordsCount: 1, Bookmark: "marble2"}, nil)
+	result, err := marbles.GetMarblesByRangeWithPagination(transactionContext, "marble1", "marble3", 1, "")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.PaginatedQueryResult{Records: []*chaincode.Marble{marble1}, FetchedRecordsCount: 1, Bookmark: "marble2"}, result)
+	startKey, endKey, pageSize, bookmark := chaincodeStub.GetStateByRangeWithPaginationArgsForCall(0)
+	require.Equal(t, []interface{}{"marble1", "marble3", int32(1), ""}, []interface{}{startKey, endKey, pageSize, bookmark})
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(nil, nil, fmt.Errorf("failed retrieving all marbles"))
+	_, err = marbles.GetMarblesByRangeWithPagination(transactionContext, "marble1", "marble3", 1, "")
+	require.EqualError(t, err, "failed retrieving all marbles")
+}
+
+func TestQueryMarblesByOwner(t *testing.T) {
+	marbles := chaincode.MarblesContract{}
+
+	marble1 := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	marble2 := &chaincode.Marble{ObjectType: "marble", Name: "marble2", Color: "red", Size: 50, Owner: "jerry"}
+	marble3 := &chaincode.Marble{ObjectType: "marble", Name: "marble3", Color: "red", Size: 10, Owner: "tom"}
+	transactionContext := richQueryContext(t, marble1, marble2, marble3)
+	result, err := marbles.QueryMarblesByOwner(transactionContext, "Tom")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Marble{marble1, marble3}, result)
+
+	result, err = marbles.QueryMarblesByOwner(transactionContext, "alice")
+	require.NoError(t, err)
+	require.Empty(t, result)
+
+	mockContext, chaincodeStub := prepMocks()
+	chaincodeStub.GetQueryResultReturns(nil, fmt.Errorf("failed retrieving all marbles"))
+	_, err = marbles.QueryMarblesByOwner(mockContext, "tom")
+	require.EqualError(t, err, "failed retrieving all marbles")
+}
+
+func TestQueryMarbles(t *testing.T) {
+	marbles := chaincode.MarblesContract{}
+
+	marble1 := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	marble2 := &chaincode.Marble{ObjectType: "marble", Name: "marble2", Color: "red", Size: 50, Owner: "jerry"}
+	marble3 := &chaincode.Marble{ObjectType: "marble", Name: "marble3", Color: "red", Size: 10, Owner: "tom"}
+	transactionContext := richQueryContext(t, marble1, marble2, marble3)
+	result, err := marbles.QueryMarbles(transactionContext, `{"selector":{"docType":"marble","owner":"tom"}}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Marble{marble1, marble3}, result)
+
+	result, err = marbles.QueryMarbles(transactionContext, `{"selector":{"docType":"marble","size":{"$gte":35}},"sort":[{"size":"desc"}]}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Marble{marble2, marble1}, result)
+
+	_, err = marbles.QueryMarbles(transactionContext, `{"selector":{"docType":"marble","color":"blue"}}`)
+	require.EqualError(t, err, "query is not served by any of the indexes packaged with the chaincode")
+}
+
+func TestQueryMarblesCapsResults(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	// CouchDB ignores the limit of queries that are not paginated
+	var owned []*chaincode.Marble
+	for i := 0; i < 150; i++ {
+		owned = append(owned, &chaincode.Marble{ObjectType: "marble", Name: fmt.Sprintf("marble%03d", i), Color: "blue", Size: 35, Owner: "tom"})
+	}
+	chaincodeStub.GetQueryResultReturns(iteratorOver(t, owned...), nil)
+	result, err := marbles.QueryMarbles(transactionContext, `{"selector":{"docType":"marble","owner":"tom"}}`)
+	require.NoError(t, err)
+	require.Equal(t, owned[:100], result)
+}
+
+func TestMarbleQueryGuardMatchesPackagedIndexes(t *testing.T) {
+	indexes, err := richquerytest.ReadIndexes("../META-INF/statedb/couchdb/indexes")
+	require.NoError(t, err)
+	require.NotEmpty(t, indexes)
+	require.ElementsMatch(t, indexes, chaincode.MarbleQueryGuard.Indexes)
+}
+
+func TestQueryMarblesOnLevelDB(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble1 := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	marble2 := &chaincode.Marble{ObjectType: "marble", Name: "marble2", Color: "red", Size: 50, Owner: "jerry"}
+	chaincodeStub.GetQueryResultReturns(nil, fmt.Errorf("ExecuteQuery not supported for leveldb"))
+	chaincodeStub.GetStateByRangeReturns(iteratorOver(t, marble1, marble2), nil)
+	result, err := marbles.QueryMarbles(transactionContext, `{"selector":{"docType":"marble","owner":"jerry"}}`)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Marble{marble2}, result)
+}
+
+func TestQueryMarblesWithPagination(t *testing.T) {
+	marbles := chaincode.MarblesContract{}
+
+	marble1 := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	marble2 := &chaincode.Marble{ObjectType: "marble", Name: "marble2", Color: "red", Size: 50, Owner: "jerry"}
+	marble3 := &chaincode.Marble{ObjectType: "marble", Name: "marble3", Color: "red", Size: 10, Owner: "tom"}
+	transactionContext := richQueryContext(t, marble1, marble2, marble3)
+	query := `{"selector":{"docType":"marble","size":{"$gt":5}}}`
+	result, err := marbles.QueryMarblesWithPagination(transactionContext, query, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.PaginatedQueryResult{Records: []*chaincode.Marble{marble1, marble2}, FetchedRecordsCount: 2, Bookmark: "marble3"}, result)
+
+	result, err = marbles.QueryMarblesWithPagination(transactionContext, query, 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.PaginatedQueryResult{Records: []*chaincode.Marble{marble3}, FetchedRecordsCount: 1, Bookmark: ""}, result)
+
+	_, err = marbles.QueryMarblesWithPagination(transactionContext, `{"selector":{"owner":"tom"}}`, 10, "")
+	require.EqualError(t, err, "query must select documents with docType marble")
+
+	// The page size is capped by the guard
+	mockContext, chaincodeStub := prepMocks()
+	chaincodeStub.GetQueryResultWithPaginationReturns(iteratorOver(t, marble1), &peer.QueryResponseMetadata{FetchedRecordsCount: 1, Bookmark: "next"}, nil)
+	_, err = marbles.QueryMarblesWithPagination(mockContext, query, 1000, "")
+	require.NoError(t, err)
+	_, pageSize, _ := chaincodeStub.GetQueryResultWithPaginationArgsForCall(0)
+	require.Equal(t, int32(100), pageSize)
+
+	chaincodeStub.GetQueryResultWithPaginationReturns(nil, nil, fmt.Errorf("failed retrieving all marbles"))
+	_, err = marbles.QueryMarblesWithPagination(mockContext, query, 10, "")
+	require.EqualError(t, err, "failed retrieving all marbles")
+}
+
+func TestGetHistoryForMarble(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocks()
+	marbles := chaincode.MarblesContract{}
+
+	marble := &chaincode.Marble{ObjectType: "marble", Name: "marble1", Color: "blue", Size: 35, Owner: "tom"}
+	timestamp := time.Unix(1588000000, 0).UTC()
+	protoTimestamp, err := ptypes.TimestampProto(timestamp)
+	require.NoError(t, err)
+
+	modifications := []*queryresult.KeyModification{
+		{TxId: "tx1", Value: marbleBytes(t, marble), Timestamp: protoTimestamp},
+		{TxId: "tx2", IsDelete: true, Timestamp: protoTimestamp},
+	}
+	iterator := &mocks.HistoryQueryIterator{}
+	iterator.HasNextStub = func() bool {
+		return iterator.NextCallCount() < len(modifications)
+	}
+	iterator.NextStub = func() (*queryresult.KeyModification, error) {
+		return modifications[iterator.NextCallCount()-1], nil
+	}
+	chaincodeStub.GetHistoryForKeyReturns(iterator, nil)
+
+	history, err := marbles.GetHistoryForMarble(transactionContext, "marble1")
+	require.NoError(t, err)
+	require.Equal(t, []chaincode.HistoryQueryResult{
+		{Record: marble, TxId: "tx1", Timestamp: timestamp},
+		{Record: &chaincode.Marble{Name: "marble1"}, TxId: "tx2", Timestamp: timestamp, IsDelete: true},
+	}, history)
+	require.Equal(t, "marble1", chaincodeStub.GetHistoryForKeyArgsForCall(0))
+
+	iterator = &mocks.HistoryQueryIterator{}
+	iterator.HasNextReturns(true)
+	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
+	chaincodeStub.GetHistoryForKeyReturns(iterator, nil)
+	_, err = marbles.GetHistoryForMarble(transactionContext, "marble1")
+	require.EqualError(t, err, "failed retrieving next item")
+
+	chaincodeStub.GetHistoryForKeyReturns(nil, fmt.Errorf("failed retrieving history"))
+	_, err = marbles.GetHistoryForMarble(transactionContext, "marble1")
+	require.EqualError(t, err, "failed retrieving history")
+}
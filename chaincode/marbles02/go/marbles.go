@@ -0,0 +1,23 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/marbles02/go/chaincode"
+)
+
+func main() {
+	marblesChaincode, err := contractapi.NewChaincode(&chaincode.MarblesContract{})
+	if err != nil {
+		log.Panicf("Error creating marbles chaincode: %v", err)
+	}
+
+	if err := marblesChaincode.Start(); err != nil {
+		log.Panicf("Error starting marbles chaincode: %v", err)
+	}
+}
@@ -23,6 +23,8 @@ SPDX-License-Identifier: Apache-2.0
 // export MARBLE_DELETE=$(echo -n "{\"name\":\"marble1\"}" | base64 | tr -d \\n)
 // peer chaincode invoke -C mychannel -n marblesp -c '{"Args":["Delete"]}' --transient "{\"marble_delete\":\"$MARBLE_DELETE\"}"
 
+// See marbles_trading.go to list marbles for sale and trade them at a private price
+
 // ==== Query marbles, since queries are not recorded on chain we don't need to hide private data in transient map ====
 // peer chaincode query -C mychannel -n marblesp -c '{"Args":["ReadMarble","marble1"]}'
 // peer chaincode query -C mychannel -n marblesp -c '{"Args":["ReadMarblePrivateDetails","marble1"]}'
@@ -31,7 +33,11 @@ SPDX-License-Identifier: Apache-2.0
 // Query a marble's public data hash
 //	peer chaincode query -C mychannel -n marblesp -c '{"Args":["GetMarbleHash","collectionMarbles","marble1"]}'
 
-// Rich Query (Only supported if CouchDB is used as state database):
+// Verify private data of a marble against its public data hash, from any organization
+//	export MARBLE_VERIFY=$(echo -n "{\"docType\":\"MarblePrivateDetails\",\"name\":\"marble1\",\"price\":99}" | base64 | tr -d \\n)
+//	peer chaincode query -C mychannel -n marblesp -c '{"Args":["VerifyMarble","collectionMarblePrivateDetails"]}' --transient "{\"marble_verify\":\"$MARBLE_VERIFY\"}"
+
+// Rich Query (Evaluated by the chaincode if LevelDB is used as state database):
 //   peer chaincode query -C mychannel -n marblesp -c '{"Args":["QueryMarblesByOwner","tom"]}'
 //   peer chaincode query -C mychannel -n marblesp -c '{"Args":["QueryMarbles","{\"selector\":{\"owner\":\"tom\"}}"]}'
 
@@ -94,11 +100,15 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery"
 )
 
 type Marble struct {
@@ -107,6 +117,7 @@ type Marble struct {
 	Color      string `json:"color"`
 	Size       int    `json:"size"`
 	Owner      string `json:"owner"`
+	OwnerMSP   string `json:"ownerMSP,omitempty"` //the organization of the owner, only this organization can list the marble for sale
 }
 
 type MarblePrivateDetails struct {
@@ -119,6 +130,10 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
+// marbleQueryFallback evaluates rich queries in the chaincode if the state database does not
+// support them. It reads at most 1000 keys, so it is only suitable for development
+var marbleQueryFallback = &richquery.Fallback{MaxKeys: 1000}
+
 
 // ============================================================
 // initMarble - create a new marble, store into chaincode state
@@ -166,6 +181,12 @@ func (s *SmartContract) InitMarble(ctx contractapi.TransactionContextInterface)
 		return fmt.Errorf("price field must be a positive integer")
 	}
 
+	// The marble is owned by the organization of the client that creates it
+	ownerMSP, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get verified MSPID: %s", err.Error())
+	}
+
 	// ==== Check if marble already exists ====
 	marbleAsBytes, err := ctx.GetStub().GetPrivateData("collectionMarbles", marbleInput.Name)
 	if err != nil {
@@ -182,6 +203,7 @@ func (s *SmartContract) InitMarble(ctx contractapi.TransactionContextInterface)
 		Color:      marbleInput.Color,
 		Size:       marbleInput.Size,
 		Owner:      marbleInput.Owner,
+		OwnerMSP:   ownerMSP,
 	}
 	marbleJSONasBytes, err := json.Marshal(marble)
 	if err != nil {
@@ -315,6 +337,12 @@ func (s *SmartContract) Delete(ctx contractapi.TransactionContextInterface) erro
 		return fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
 	}
 
+	// A marble, which may be listed for sale, can only be deleted by the organization of its owner
+	err = checkOwnerMSP(ctx, &marbleToDelete)
+	if err != nil {
+		return err
+	}
+
 	// delete the marble from state
 	err = ctx.GetStub().DelPrivateData("collectionMarbles", marbleDeleteInput.Name)
 	if err != nil {
@@ -338,7 +366,8 @@ func (s *SmartContract) Delete(ctx contractapi.TransactionContextInterface) erro
 			return err
 	}
 
-	return nil
+	// A deleted marble can no longer be sold
+	return delMarbleListing(ctx, marbleDeleteInput.Name)
 
 }
 
@@ -390,7 +419,15 @@ func (s *SmartContract) TransferMarble(ctx contractapi.TransactionContextInterfa
 			return fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
 		}
 
+	// A marble, which may be listed for sale, can only be transferred by the organization of its owner
+	err = checkOwnerMSP(ctx, &marbleToTransfer)
+	if err != nil {
+		return err
+	}
+
 	marbleToTransfer.Owner = marbleTransferInput.Owner //change the owner
+	// The organization of the new owner is not verified, so the marble cannot be listed for sale
+	marbleToTransfer.OwnerMSP = ""
 
 	marbleJSONasBytes, _ := json.Marshal(marbleToTransfer)
 	err = ctx.GetStub().PutPrivateData("collectionMarbles", marbleToTransfer.Name, marbleJSONasBytes) //rewrite the marble
@@ -398,7 +435,8 @@ func (s *SmartContract) TransferMarble(ctx contractapi.TransactionContextInterfa
 				return err
 		}
 
-	return nil
+	// The listing of the previous owner no longer applies
+	return delMarbleListing(ctx, marbleToTransfer.Name)
 
 }
 
@@ -450,6 +488,9 @@ func (s *SmartContract) GetMarblesByRange(ctx contractapi.TransactionContextInte
 // Rich queries are only supported by state database implementations
 //  that support rich query (e.g. CouchDB).
 // The query string is in the syntax of the underlying state database.
+// On other state databases (e.g. LevelDB) the richquery package evaluates the
+//  query in the chaincode instead, by reading the keys of the collection up to
+//  the limit of marbleQueryFallback. This is only suitable for development and small data sets.
 // With rich queries there is no guarantee that the result set hasn't changed between
 //  endorsement time and commit time, aka 'phantom reads'.
 // Therefore, rich queries should not be used in update transactions, unless the
@@ -498,7 +539,7 @@ func (s *SmartContract) QueryMarbles(ctx contractapi.TransactionContextInterface
 // =========================================================================================
 func (s *SmartContract) getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, queryString string) ([]Marble, error) {
 
-	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult("collectionMarbles", queryString)
+	resultsIterator, err := richquery.GetPrivateDataQueryResult(ctx.GetStub(), "collectionMarbles", queryString, marbleQueryFallback)
 	if err != nil {
 		return nil, err
 	}
@@ -526,7 +567,7 @@ func (s *SmartContract) getQueryResultForQueryString(ctx contractapi.Transaction
 
 // ===============================================
 // getMarbleHash - use the public data hash to verify a private marble
-// Result is the hash on the public ledger of a marble stored a private data collection
+// Result is the hex encoded hash on the public ledger of a marble stored a private data collection
 // ===============================================
 func (s *SmartContract) GetMarbleHash(ctx contractapi.TransactionContextInterface, collection string, marbleID string,) (string, error) {
 
@@ -538,7 +579,119 @@ func (s *SmartContract) GetMarbleHash(ctx contractapi.TransactionContextInterfac
 		return "", fmt.Errorf("Marble does not exist: " + marbleID)
 	}
 
-	return string(hashAsbytes), nil
+	return hex.EncodeToString(hashAsbytes), nil
+}
+
+// MarbleVerification is the result of checking private data of a marble against its public data hash
+type MarbleVerification struct {
+	Collection    string `json:"collection"`
+	Key           string `json:"key"`
+	Verified      bool   `json:"verified"`
+	CandidateHash string `json:"candidateHash"`
+	LedgerHash    string `json:"ledgerHash"` //empty if there is no data for the key in the collection
+}
+
+// ===============================================
+// VerifyMarble - check private data of a marble, passed in the transient field, against the public data hash.
+// Any organization can verify data from any collection, for example to check the price claimed by a seller.
+// The data is canonicalized as written by this chaincode, so that its field order and spacing do not matter
+// ===============================================
+func (s *SmartContract) VerifyMarble(ctx contractapi.TransactionContextInterface, collection string) (*MarbleVerification, error) {
+
+	if len(collection) == 0 {
+		return nil, fmt.Errorf("collection must be a non-empty string")
+	}
+
+	transMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting transient: " + err.Error())
+	}
+
+	transientVerifyJSON, ok := transMap["marble_verify"]
+	if !ok {
+		return nil, fmt.Errorf("marble to verify not found in the transient map")
+	}
+
+	key, canonicalJSON, err := canonicalizeMarbleJSON(ctx, transientVerifyJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	hashAsbytes, err := ctx.GetStub().GetPrivateDataHash(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get public data hash for marble:" + err.Error())
+	}
+
+	candidateHash := sha256.Sum256(canonicalJSON)
+
+	return &MarbleVerification{
+		Collection:    collection,
+		Key:           key,
+		Verified:      hashAsbytes != nil && bytes.Equal(hashAsbytes, candidateHash[:]),
+		CandidateHash: hex.EncodeToString(candidateHash[:]),
+		LedgerHash:    hex.EncodeToString(hashAsbytes),
+	}, nil
+}
+
+// canonicalizeMarbleJSON returns the key and the JSON of a marble, marble private details or marble offer,
+// encoded exactly as this chaincode writes them to private data collections. The type of the data is given by
+// its docType field. Unknown fields are rejected, since they would be dropped from the canonical JSON
+func canonicalizeMarbleJSON(ctx contractapi.TransactionContextInterface, marbleJSON []byte) (string, []byte, error) {
+
+	var docType struct {
+		ObjectType string `json:"docType"`
+	}
+	err := json.Unmarshal(marbleJSON, &docType)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+
+	var value interface{}
+	switch docType.ObjectType {
+	case "Marble":
+		value = &Marble{}
+	case "MarblePrivateDetails":
+		value = &MarblePrivateDetails{}
+	case offerObjectType:
+		value = &MarbleOffer{}
+	default:
+		return "", nil, fmt.Errorf("docType field must be one of Marble, MarblePrivateDetails or %s", offerObjectType)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(marbleJSON))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(value)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+
+	canonicalJSON, err := json.Marshal(value)
+	if err != nil {
+		return "", nil, fmt.Errorf(err.Error())
+	}
+
+	// Offers are stored under a composite key, the other types under the name of the marble
+	var name string
+	switch value := value.(type) {
+	case *Marble:
+		name = value.Name
+	case *MarblePrivateDetails:
+		name = value.Name
+	case *MarbleOffer:
+		name = value.Name
+	}
+	if len(name) == 0 {
+		return "", nil, fmt.Errorf("name field must be a non-empty string")
+	}
+	if docType.ObjectType == offerObjectType {
+		key, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{name})
+		if err != nil {
+			return "", nil, err
+		}
+		return key, canonicalJSON, nil
+	}
+
+	return name, canonicalJSON, nil
 }
 
 func main() {
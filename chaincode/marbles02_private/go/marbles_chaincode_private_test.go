@@ -0,0 +1,109 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+// verifyAs returns the context of a client of an organization, passing the marble to verify as is
+func (n *network) verifyAs(t *testing.T, mspID string, marbleJSON string) contractapi.TransactionContextInterface {
+	ctx := n.as(t, mspID, "", nil)
+	n.stub.transient = map[string][]byte{"marble_verify": []byte(marbleJSON)}
+	return ctx
+}
+
+func TestGetMarbleHash(t *testing.T) {
+	n := newNetwork()
+	initListedMarble(t, n)
+
+	hash, err := n.contract.GetMarbleHash(n.as(t, org2, "", nil), "collectionMarblePrivateDetails", "marble1")
+	require.NoError(t, err)
+	expected := sha256.Sum256(n.stub.PvtState["collectionMarblePrivateDetails"]["marble1"])
+	require.Equal(t, hex.EncodeToString(expected[:]), hash)
+
+	_, err = n.contract.GetMarbleHash(n.as(t, org2, "", nil), "collectionMarblePrivateDetails", "marble2")
+	require.EqualError(t, err, "Marble does not exist: marble2")
+}
+
+func TestVerifyMarble(t *testing.T) {
+	n := newNetwork()
+	initListedMarble(t, n)
+
+	// The field order and spacing of the data do not matter
+	details := `{ "price": 99,
+		"name": "marble1", "docType": "MarblePrivateDetails" }`
+	verification, err := n.contract.VerifyMarble(n.verifyAs(t, org2, details), "collectionMarblePrivateDetails")
+	require.NoError(t, err)
+	require.True(t, verification.Verified)
+	require.Equal(t, "marble1", verification.Key)
+	require.Equal(t, verification.LedgerHash, verification.CandidateHash)
+
+	marble := `{"ownerMSP":"Org1MSP","owner":"tom","size":35,"color":"blue","name":"marble1","docType":"Marble"}`
+	verification, err = n.contract.VerifyMarble(n.verifyAs(t, org2, marble), "collectionMarbles")
+	require.NoError(t, err)
+	require.True(t, verification.Verified)
+
+	// A different price is not verified
+	details = `{"docType":"MarblePrivateDetails","name":"marble1","price":80}`
+	verification, err = n.contract.VerifyMarble(n.verifyAs(t, org2, details), "collectionMarblePrivateDetails")
+	require.NoError(t, err)
+	require.False(t, verification.Verified)
+	require.NotEqual(t, verification.LedgerHash, verification.CandidateHash)
+}
+
+func TestVerifyMarbleOffer(t *testing.T) {
+	n := newNetwork()
+	initListedMarble(t, n)
+	require.NoError(t, n.contract.MakeOffer(n.as(t, org2, "marble_offer", offerTerms(120, salt))))
+
+	// Offers are stored under a composite key in the collection of the buyer
+	offer := `{"salt":"` + salt + `","price":120,"owner":"jerry","name":"marble1","docType":"MarbleOffer"}`
+	verification, err := n.contract.VerifyMarble(n.verifyAs(t, org1, offer), "Org2MSPPrivateCollection")
+	require.NoError(t, err)
+	offerKey, err := n.stub.CreateCompositeKey(offerObjectType, []string{"marble1"})
+	require.NoError(t, err)
+	require.Equal(t, offerKey, verification.Key)
+	require.True(t, verification.Verified)
+}
+
+func TestVerifyMarbleWithoutLedgerHash(t *testing.T) {
+	n := newNetwork()
+
+	details := `{"docType":"MarblePrivateDetails","name":"marble1","price":99}`
+	verification, err := n.contract.VerifyMarble(n.verifyAs(t, org2, details), "collectionMarblePrivateDetails")
+	require.NoError(t, err)
+	require.False(t, verification.Verified)
+	require.Equal(t, "", verification.LedgerHash)
+	require.NotEmpty(t, verification.CandidateHash)
+}
+
+func TestVerifyMarbleBadInput(t *testing.T) {
+	n := newNetwork()
+
+	_, err := n.contract.VerifyMarble(n.verifyAs(t, org2, `{}`), "")
+	require.EqualError(t, err, "collection must be a non-empty string")
+
+	_, err = n.contract.VerifyMarble(n.as(t, org2, "marble", nil), "collectionMarbles")
+	require.EqualError(t, err, "marble to verify not found in the transient map")
+
+	_, err = n.contract.VerifyMarble(n.verifyAs(t, org2, `{"docType":"marble","name":"marble1"}`), "collectionMarbles")
+	require.EqualError(t, err, "docType field must be one of Marble, MarblePrivateDetails or MarbleOffer")
+
+	// Unknown fields would be dropped from the canonical JSON, so they are rejected
+	details := `{"docType":"MarblePrivateDetails","name":"marble1","price":99,"discount":10}`
+	_, err = n.contract.VerifyMarble(n.verifyAs(t, org2, details), "collectionMarblePrivateDetails")
+	require.EqualError(t, err, `failed to unmarshal JSON: json: unknown field "discount"`)
+
+	_, err = n.contract.VerifyMarble(n.verifyAs(t, org2, `{"docType":"Marble","color":"blue"}`), "collectionMarbles")
+	require.EqualError(t, err, "name field must be a non-empty string")
+}
@@ -0,0 +1,517 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// ==== Marble trading =====================================================================
+// The organization that owns a marble lists it for sale on the public ledger. A marble is owned
+// by the organization of the client that created it, or that bought it. The price stays private:
+// a potential buyer records an offer in the private data collection of its organization,
+// and shares the terms of the offer with the seller off chain. When the seller accepts the
+// offer, the terms are checked against the hash of the offer on the public ledger, before
+// the marble is transferred and its price details are moved to the collection of the buyer.
+//
+// ==== Invoke marbles trading, pass private data as base64 encoded bytes in transient map ====
+//
+// export MARBLE_LISTING=$(echo -n "{\"name\":\"marble1\"}" | base64 | tr -d \\n)
+// peer chaincode invoke -C mychannel -n marblesp -c '{"Args":["ListMarble"]}' --transient "{\"marble_listing\":\"$MARBLE_LISTING\"}"
+//
+// The buyer adds a random salt to the offer, so that the price cannot be guessed from the hash of the offer
+// export SALT=$(openssl rand -hex 16)
+// export MARBLE_OFFER=$(echo -n "{\"name\":\"marble1\",\"owner\":\"jerry\",\"price\":120,\"salt\":\"$SALT\"}" | base64 | tr -d \\n)
+// peer chaincode invoke -C mychannel -n marblesp -c '{"Args":["MakeOffer"]}' --transient "{\"marble_offer\":\"$MARBLE_OFFER\"}"
+//
+// export MARBLE_ACCEPT=$(echo -n "{\"name\":\"marble1\",\"owner\":\"jerry\",\"price\":120,\"salt\":\"$SALT\",\"buyerMSP\":\"Org2MSP\"}" | base64 | tr -d \\n)
+// peer chaincode invoke -C mychannel -n marblesp -c '{"Args":["AcceptOffer"]}' --transient "{\"marble_accept\":\"$MARBLE_ACCEPT\"}"
+//
+// ==== Query marbles trading ====
+// peer chaincode query -C mychannel -n marblesp -c '{"Args":["ReadMarbleListing","marble1"]}'
+// peer chaincode query -C mychannel -n marblesp -c '{"Args":["ReadMarbleOffer","marble1"]}'
+// peer chaincode query -C mychannel -n marblesp -c '{"Args":["ReadOrgMarblePrivateDetails","marble1"]}'
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	listingObjectType = "MarbleListing"
+	offerObjectType   = "MarbleOffer"
+
+	// minSaltLength is the length of 16 random bytes encoded in hex
+	minSaltLength = 32
+)
+
+// MarbleListing is stored on the public ledger when a marble is offered for sale
+type MarbleListing struct {
+	ObjectType string `json:"docType"`
+	Name       string `json:"name"`
+	Color      string `json:"color"`
+	Size       int    `json:"size"`
+	SellerMSP  string `json:"sellerMSP"`
+}
+
+// MarbleOffer is stored in the private data collection of the organization of the buyer.
+// The seller accepts an offer by presenting the same terms, which must hash to the
+// value recorded on the public ledger, so the fields and their order must not change.
+// The random salt keeps the price from being found by hashing guessed offers
+type MarbleOffer struct {
+	ObjectType string `json:"docType"`
+	Name       string `json:"name"`
+	Owner      string `json:"owner"`
+	Price      int    `json:"price"`
+	Salt       string `json:"salt"`
+}
+
+// ===============================================
+// ListMarble - offer a marble for sale on the public ledger
+// ===============================================
+func (s *SmartContract) ListMarble(ctx contractapi.TransactionContextInterface) error {
+
+	transMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("Error getting transient: " + err.Error())
+	}
+
+	// The name of the marble gets passed in transient field like the other trading inputs
+	transientListingJSON, ok := transMap["marble_listing"]
+	if !ok {
+		return fmt.Errorf("marble listing not found in the transient map")
+	}
+
+	type marbleListingTransientInput struct {
+		Name string `json:"name"`
+	}
+
+	var listingInput marbleListingTransientInput
+	err = json.Unmarshal(transientListingJSON, &listingInput)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+
+	if len(listingInput.Name) == 0 {
+		return fmt.Errorf("name field must be a non-empty string")
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	sellerMSP, err := verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("ListMarble cannot be performed: %s", err.Error())
+	}
+
+	marble, err := s.ReadMarble(ctx, listingInput.Name)
+	if err != nil {
+		return err
+	}
+	// Only the organization that owns the marble can list it
+	if marble.OwnerMSP != sellerMSP {
+		return fmt.Errorf("marble %s is not owned by %s", listingInput.Name, sellerMSP)
+	}
+
+	listing, err := s.ReadMarbleListing(ctx, listingInput.Name)
+	if err != nil {
+		return err
+	}
+	if listing != nil {
+		return fmt.Errorf("marble %s is already listed", listingInput.Name)
+	}
+
+	listing = &MarbleListing{
+		ObjectType: listingObjectType,
+		Name:       marble.Name,
+		Color:      marble.Color,
+		Size:       marble.Size,
+		SellerMSP:  sellerMSP,
+	}
+	listingJSONasBytes, err := json.Marshal(listing)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	listingKey, err := ctx.GetStub().CreateCompositeKey(listingObjectType, []string{listing.Name})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(listingKey, listingJSONasBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put marble listing: %s", err.Error())
+	}
+
+	return nil
+}
+
+// ===============================================
+// ReadMarbleListing - read the public listing of a marble, nil if the marble is not listed
+// ===============================================
+func (s *SmartContract) ReadMarbleListing(ctx contractapi.TransactionContextInterface, marbleID string) (*MarbleListing, error) {
+
+	listingKey, err := ctx.GetStub().CreateCompositeKey(listingObjectType, []string{marbleID})
+	if err != nil {
+		return nil, err
+	}
+
+	listingJSON, err := ctx.GetStub().GetState(listingKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read marble listing: %s", err.Error())
+	}
+	if listingJSON == nil {
+		return nil, nil
+	}
+
+	listing := new(MarbleListing)
+	err = json.Unmarshal(listingJSON, listing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+
+	return listing, nil
+}
+
+// ===============================================
+// MakeOffer - record an offer for a listed marble in the collection of the buyer's organization.
+// A new offer replaces the previous offer of the organization
+// ===============================================
+func (s *SmartContract) MakeOffer(ctx contractapi.TransactionContextInterface) error {
+
+	transMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("Error getting transient: " + err.Error())
+	}
+
+	// The price is private, therefore it gets passed in transient field
+	transientOfferJSON, ok := transMap["marble_offer"]
+	if !ok {
+		return fmt.Errorf("marble offer not found in the transient map")
+	}
+
+	offer, err := parseOffer(transientOfferJSON)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	buyerMSP, err := verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("MakeOffer cannot be performed: %s", err.Error())
+	}
+
+	listing, err := s.ReadMarbleListing(ctx, offer.Name)
+	if err != nil {
+		return err
+	}
+	if listing == nil {
+		return fmt.Errorf("marble %s is not listed", offer.Name)
+	}
+
+	offerJSONasBytes, err := json.Marshal(offer)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{offer.Name})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutPrivateData(buildCollectionName(buyerMSP), offerKey, offerJSONasBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put marble offer: %s", err.Error())
+	}
+
+	return nil
+}
+
+// ===============================================
+// ReadMarbleOffer - read the offer of the client's organization for a marble
+// ===============================================
+func (s *SmartContract) ReadMarbleOffer(ctx contractapi.TransactionContextInterface, marbleID string) (*MarbleOffer, error) {
+
+	collection, err := getClientCollectionName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offerKey, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{marbleID})
+	if err != nil {
+		return nil, err
+	}
+
+	offerJSON, err := ctx.GetStub().GetPrivateData(collection, offerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read marble offer: %s", err.Error())
+	}
+	if offerJSON == nil {
+		return nil, fmt.Errorf("no offer for %s in collection %s", marbleID, collection)
+	}
+
+	offer := new(MarbleOffer)
+	err = json.Unmarshal(offerJSON, offer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+
+	return offer, nil
+}
+
+// ===============================================
+// AcceptOffer - accept the offer of a buyer's organization for a listed marble.
+// The terms of the offer must match the hash of the offer recorded by the buyer
+// ===============================================
+func (s *SmartContract) AcceptOffer(ctx contractapi.TransactionContextInterface) error {
+
+	transMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return fmt.Errorf("Error getting transient: " + err.Error())
+	}
+
+	// The terms of the offer are private, therefore they get passed in transient field
+	transientAcceptJSON, ok := transMap["marble_accept"]
+	if !ok {
+		return fmt.Errorf("marble offer to accept not found in the transient map")
+	}
+
+	type marbleAcceptTransientInput struct {
+		BuyerMSP string `json:"buyerMSP"`
+	}
+
+	var acceptInput marbleAcceptTransientInput
+	err = json.Unmarshal(transientAcceptJSON, &acceptInput)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+	if len(acceptInput.BuyerMSP) == 0 {
+		return fmt.Errorf("buyerMSP field must be a non-empty string")
+	}
+
+	offer, err := parseOffer(transientAcceptJSON)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	sellerMSP, err := verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("AcceptOffer cannot be performed: %s", err.Error())
+	}
+
+	listing, err := s.ReadMarbleListing(ctx, offer.Name)
+	if err != nil {
+		return err
+	}
+	if listing == nil {
+		return fmt.Errorf("marble %s is not listed", offer.Name)
+	}
+	if listing.SellerMSP != sellerMSP {
+		return fmt.Errorf("marble %s was listed by %s, not by %s", offer.Name, listing.SellerMSP, sellerMSP)
+	}
+
+	// Verify that the buyer made this offer, using the hash of the offer on the public ledger
+	buyerCollection := buildCollectionName(acceptInput.BuyerMSP)
+	offerKey, err := ctx.GetStub().CreateCompositeKey(offerObjectType, []string{offer.Name})
+	if err != nil {
+		return err
+	}
+	offerHash, err := ctx.GetStub().GetPrivateDataHash(buyerCollection, offerKey)
+	if err != nil {
+		return fmt.Errorf("failed to get hash of offer from collection %s: %s", buyerCollection, err.Error())
+	}
+	if offerHash == nil {
+		return fmt.Errorf("no offer for %s in collection %s", offer.Name, buyerCollection)
+	}
+
+	offerJSONasBytes, err := json.Marshal(offer)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+	acceptedHash := sha256.Sum256(offerJSONasBytes)
+	if !bytes.Equal(offerHash, acceptedHash[:]) {
+		return fmt.Errorf("hash of offer %x does not match hash of accepted terms %x", offerHash, acceptedHash)
+	}
+
+	// Transfer the marble to the buyer
+	marble, err := s.ReadMarble(ctx, offer.Name)
+	if err != nil {
+		return err
+	}
+	marble.Owner = offer.Owner
+	marble.OwnerMSP = acceptInput.BuyerMSP
+	marbleJSONasBytes, err := json.Marshal(marble)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+	err = ctx.GetStub().PutPrivateData("collectionMarbles", marble.Name, marbleJSONasBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put Marble: %s", err.Error())
+	}
+
+	// Move the price details from the collections of the seller to the collection of the buyer.
+	// The price details of a marble that was created rather than bought are in collectionMarblePrivateDetails
+	for _, collection := range []string{"collectionMarblePrivateDetails", buildCollectionName(sellerMSP)} {
+		err = ctx.GetStub().DelPrivateData(collection, marble.Name)
+		if err != nil {
+			return fmt.Errorf("failed to delete Marble private details: %s", err.Error())
+		}
+	}
+	marblePrivateDetails := &MarblePrivateDetails{
+		ObjectType: "MarblePrivateDetails",
+		Name:       marble.Name,
+		Price:      offer.Price,
+	}
+	marblePrivateDetailsAsBytes, err := json.Marshal(marblePrivateDetails)
+	if err != nil {
+		return fmt.Errorf(err.Error())
+	}
+	err = ctx.GetStub().PutPrivateData(buyerCollection, marble.Name, marblePrivateDetailsAsBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put Marble private details: %s", err.Error())
+	}
+
+	// The sale is complete, remove the offer and the listing
+	err = ctx.GetStub().DelPrivateData(buyerCollection, offerKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete marble offer: %s", err.Error())
+	}
+
+	return delMarbleListing(ctx, marble.Name)
+}
+
+// ===============================================
+// ReadOrgMarblePrivateDetails - read the price details of a marble bought by the client's organization
+// ===============================================
+func (s *SmartContract) ReadOrgMarblePrivateDetails(ctx contractapi.TransactionContextInterface, marbleID string) (*MarblePrivateDetails, error) {
+
+	collection, err := getClientCollectionName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	marbleDetailsJSON, err := ctx.GetStub().GetPrivateData(collection, marbleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from marble details %s", err.Error())
+	}
+	if marbleDetailsJSON == nil {
+		return nil, fmt.Errorf("%s does not exist in collection %s", marbleID, collection)
+	}
+
+	marbleDetails := new(MarblePrivateDetails)
+	err = json.Unmarshal(marbleDetailsJSON, marbleDetails)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+
+	return marbleDetails, nil
+}
+
+// parseOffer reads the terms of an offer from transient input
+func parseOffer(transientOfferJSON []byte) (*MarbleOffer, error) {
+
+	type marbleOfferTransientInput struct {
+		Name  string `json:"name"`
+		Owner string `json:"owner"`
+		Price int    `json:"price"`
+		Salt  string `json:"salt"`
+	}
+
+	var offerInput marbleOfferTransientInput
+	err := json.Unmarshal(transientOfferJSON, &offerInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %s", err.Error())
+	}
+
+	if len(offerInput.Name) == 0 {
+		return nil, fmt.Errorf("name field must be a non-empty string")
+	}
+	if len(offerInput.Owner) == 0 {
+		return nil, fmt.Errorf("owner field must be a non-empty string")
+	}
+	if offerInput.Price <= 0 {
+		return nil, fmt.Errorf("price field must be a positive integer")
+	}
+	if len(offerInput.Salt) < minSaltLength {
+		return nil, fmt.Errorf("salt field must be at least %d characters", minSaltLength)
+	}
+
+	return &MarbleOffer{
+		ObjectType: offerObjectType,
+		Name:       offerInput.Name,
+		Owner:      offerInput.Owner,
+		Price:      offerInput.Price,
+		Salt:       offerInput.Salt,
+	}, nil
+}
+
+// delMarbleListing removes the public listing of a marble, if any
+func delMarbleListing(ctx contractapi.TransactionContextInterface, marbleID string) error {
+
+	listingKey, err := ctx.GetStub().CreateCompositeKey(listingObjectType, []string{marbleID})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(listingKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete marble listing: %s", err.Error())
+	}
+
+	return nil
+}
+
+// checkOwnerMSP checks that the client belongs to the organization that owns the marble. The organization
+// of a marble transferred with TransferMarble is not known, and any organization can transfer or delete it
+func checkOwnerMSP(ctx contractapi.TransactionContextInterface, marble *Marble) error {
+	if marble.OwnerMSP == "" {
+		return nil
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed getting the client's MSPID: %s", err.Error())
+	}
+	if clientMSPID != marble.OwnerMSP {
+		return fmt.Errorf("marble %s is owned by %s, not by %s", marble.Name, marble.OwnerMSP, clientMSPID)
+	}
+
+	return nil
+}
+
+// getClientCollectionName returns the private data collection of the client's organization
+func getClientCollectionName(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get verified MSPID: %s", err.Error())
+	}
+
+	return buildCollectionName(clientMSPID), nil
+}
+
+// buildCollectionName returns the private data collection of an organization
+func buildCollectionName(mspID string) string {
+	return mspID + "PrivateCollection"
+}
+
+// verifyClientOrgMatchesPeerOrg checks that the client belongs to the organization of the
+// peer, which holds the private data of the organization, and returns the MSP ID of the organization
+func verifyClientOrgMatchesPeerOrg(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed getting the client's MSPID: %s", err.Error())
+	}
+	peerMSPID, err := shim.GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed getting the peer's MSPID: %s", err.Error())
+	}
+
+	if clientMSPID != peerMSPID {
+		return "", fmt.Errorf("client from org %s is not authorized to read or write private data from an org %s peer", clientMSPID, peerMSPID)
+	}
+
+	return clientMSPID, nil
+}
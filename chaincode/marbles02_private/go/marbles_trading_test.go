@@ -0,0 +1,235 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	org1 = "Org1MSP"
+	org2 = "Org2MSP"
+	salt = "6c1f9f06d5fcb7a1b8b3d1e4a2f07c95"
+)
+
+// tradingStub implements the private data functions used by marble trading that the mock stub does not
+type tradingStub struct {
+	*shimtest.MockStub
+	transient map[string][]byte
+}
+
+func (stub *tradingStub) GetTransient() (map[string][]byte, error) {
+	return stub.transient, nil
+}
+
+func (stub *tradingStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	value := stub.PvtState[collection][key]
+	if value == nil {
+		return nil, nil
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+func (stub *tradingStub) DelPrivateData(collection, key string) error {
+	delete(stub.PvtState[collection], key)
+	return nil
+}
+
+// clientIdentity is a client of an organization, submitting transactions to a peer of the same organization
+type clientIdentity struct {
+	mspID string
+}
+
+func (ci *clientIdentity) GetID() (string, error) {
+	return "x509::CN=client,OU=client::CN=ca." + ci.mspID, nil
+}
+
+func (ci *clientIdentity) GetMSPID() (string, error) {
+	return ci.mspID, nil
+}
+
+func (ci *clientIdentity) GetAttributeValue(string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (ci *clientIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	return fmt.Errorf("attribute %s not found", attrName)
+}
+
+func (ci *clientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
+type network struct {
+	stub     *tradingStub
+	contract *SmartContract
+}
+
+func newNetwork() *network {
+	stub := &tradingStub{MockStub: shimtest.NewMockStub("marblesp", nil)}
+	stub.MockTransactionStart("tx1")
+	return &network{stub: stub, contract: new(SmartContract)}
+}
+
+// as returns the context of a client of an organization, passing the transient field as JSON
+func (n *network) as(t *testing.T, mspID string, transientKey string, transientValue interface{}) contractapi.TransactionContextInterface {
+	require.NoError(t, os.Setenv("CORE_PEER_LOCALMSPID", mspID))
+
+	transientJSON, err := json.Marshal(transientValue)
+	require.NoError(t, err)
+	n.stub.transient = map[string][]byte{transientKey: transientJSON}
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(n.stub)
+	ctx.SetClientIdentity(&clientIdentity{mspID: mspID})
+	return ctx
+}
+
+func offerTerms(price int, salt string) map[string]interface{} {
+	return map[string]interface{}{"name": "marble1", "owner": "jerry", "price": price, "salt": salt}
+}
+
+func acceptTerms(price int, salt string) map[string]interface{} {
+	terms := offerTerms(price, salt)
+	terms["buyerMSP"] = org2
+	return terms
+}
+
+func initListedMarble(t *testing.T, n *network) {
+	marble := map[string]interface{}{"name": "marble1", "color": "blue", "size": 35, "owner": "tom", "price": 99}
+	require.NoError(t, n.contract.InitMarble(n.as(t, org1, "marble", marble)))
+	require.NoError(t, n.contract.ListMarble(n.as(t, org1, "marble_listing", map[string]string{"name": "marble1"})))
+}
+
+func TestListMarble(t *testing.T) {
+	n := newNetwork()
+	marble := map[string]interface{}{"name": "marble1", "color": "blue", "size": 35, "owner": "tom", "price": 99}
+	require.NoError(t, n.contract.InitMarble(n.as(t, org1, "marble", marble)))
+
+	created, err := n.contract.ReadMarble(n.as(t, org1, "", nil), "marble1")
+	require.NoError(t, err)
+	require.Equal(t, org1, created.OwnerMSP)
+
+	// Another organization cannot list the marble, whatever owner it claims
+	err = n.contract.ListMarble(n.as(t, org2, "marble_listing", map[string]string{"name": "marble1", "owner": "tom"}))
+	require.EqualError(t, err, "marble marble1 is not owned by Org2MSP")
+
+	require.NoError(t, n.contract.ListMarble(n.as(t, org1, "marble_listing", map[string]string{"name": "marble1"})))
+	listing, err := n.contract.ReadMarbleListing(n.as(t, org2, "", nil), "marble1")
+	require.NoError(t, err)
+	require.Equal(t, &MarbleListing{ObjectType: listingObjectType, Name: "marble1", Color: "blue", Size: 35, SellerMSP: org1}, listing)
+
+	err = n.contract.ListMarble(n.as(t, org1, "marble_listing", map[string]string{"name": "marble1"}))
+	require.EqualError(t, err, "marble marble1 is already listed")
+}
+
+func TestListTransferredMarble(t *testing.T) {
+	n := newNetwork()
+	marble := map[string]interface{}{"name": "marble1", "color": "blue", "size": 35, "owner": "tom", "price": 99}
+	require.NoError(t, n.contract.InitMarble(n.as(t, org1, "marble", marble)))
+	require.NoError(t, n.contract.TransferMarble(n.as(t, org1, "marble_owner", map[string]string{"name": "marble1", "owner": "jerry"})))
+
+	// The organization of the new owner is not known, so no organization can list the marble
+	for _, mspID := range []string{org1, org2} {
+		err := n.contract.ListMarble(n.as(t, mspID, "marble_listing", map[string]string{"name": "marble1"}))
+		require.EqualError(t, err, "marble marble1 is not owned by "+mspID)
+	}
+}
+
+func TestTransferAndDeleteListedMarble(t *testing.T) {
+	n := newNetwork()
+	initListedMarble(t, n)
+
+	// Only the organization of the owner can transfer or delete the marble and its listing
+	err := n.contract.TransferMarble(n.as(t, org2, "marble_owner", map[string]string{"name": "marble1", "owner": "jerry"}))
+	require.EqualError(t, err, "marble marble1 is owned by Org1MSP, not by Org2MSP")
+	err = n.contract.Delete(n.as(t, org2, "marble_delete", map[string]string{"name": "marble1"}))
+	require.EqualError(t, err, "marble marble1 is owned by Org1MSP, not by Org2MSP")
+	listing, err := n.contract.ReadMarbleListing(n.as(t, org2, "", nil), "marble1")
+	require.NoError(t, err)
+	require.NotNil(t, listing)
+
+	require.NoError(t, n.contract.Delete(n.as(t, org1, "marble_delete", map[string]string{"name": "marble1"})))
+	listing, err = n.contract.ReadMarbleListing(n.as(t, org2, "", nil), "marble1")
+	require.NoError(t, err)
+	require.Nil(t, listing)
+	require.Nil(t, n.stub.PvtState["collectionMarbles"]["marble1"])
+}
+
+func TestMakeOffer(t *testing.T) {
+	n := newNetwork()
+
+	err := n.contract.MakeOffer(n.as(t, org2, "marble_offer", offerTerms(120, salt)))
+	require.EqualError(t, err, "marble marble1 is not listed")
+
+	initListedMarble(t, n)
+
+	err = n.contract.MakeOffer(n.as(t, org2, "marble_offer", offerTerms(120, "")))
+	require.EqualError(t, err, "salt field must be at least 32 characters")
+	err = n.contract.MakeOffer(n.as(t, org2, "marble_offer", offerTerms(0, salt)))
+	require.EqualError(t, err, "price field must be a positive integer")
+
+	require.NoError(t, n.contract.MakeOffer(n.as(t, org2, "marble_offer", offerTerms(120, salt))))
+	offer, err := n.contract.ReadMarbleOffer(n.as(t, org2, "", nil), "marble1")
+	require.NoError(t, err)
+	require.Equal(t, &MarbleOffer{ObjectType: offerObjectType, Name: "marble1", Owner: "jerry", Price: 120, Salt: salt}, offer)
+
+	// The offer is only in the collection of the buyer
+	_, err = n.contract.ReadMarbleOffer(n.as(t, org1, "", nil), "marble1")
+	require.EqualError(t, err, "no offer for marble1 in collection Org1MSPPrivateCollection")
+}
+
+func TestAcceptOffer(t *testing.T) {
+	n := newNetwork()
+	initListedMarble(t, n)
+	require.NoError(t, n.contract.MakeOffer(n.as(t, org2, "marble_offer", offerTerms(120, salt))))
+
+	// Only the seller can accept an offer
+	err := n.contract.AcceptOffer(n.as(t, org2, "marble_accept", acceptTerms(120, salt)))
+	require.EqualError(t, err, "marble marble1 was listed by Org1MSP, not by Org2MSP")
+
+	// The terms must match the offer, including the salt
+	err = n.contract.AcceptOffer(n.as(t, org1, "marble_accept", acceptTerms(150, salt)))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not match hash of accepted terms")
+	err = n.contract.AcceptOffer(n.as(t, org1, "marble_accept", acceptTerms(120, "0f1e2d3c4b5a69788796a5b4c3d2e1f0")))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not match hash of accepted terms")
+
+	require.NoError(t, n.contract.AcceptOffer(n.as(t, org1, "marble_accept", acceptTerms(120, salt))))
+
+	marble, err := n.contract.ReadMarble(n.as(t, org2, "", nil), "marble1")
+	require.NoError(t, err)
+	require.Equal(t, "jerry", marble.Owner)
+	require.Equal(t, org2, marble.OwnerMSP)
+
+	details, err := n.contract.ReadOrgMarblePrivateDetails(n.as(t, org2, "", nil), "marble1")
+	require.NoError(t, err)
+	require.Equal(t, 120, details.Price)
+	require.Nil(t, n.stub.PvtState["collectionMarblePrivateDetails"]["marble1"])
+
+	listing, err := n.contract.ReadMarbleListing(n.as(t, org2, "", nil), "marble1")
+	require.NoError(t, err)
+	require.Nil(t, listing)
+	_, err = n.contract.ReadMarbleOffer(n.as(t, org2, "", nil), "marble1")
+	require.EqualError(t, err, "no offer for marble1 in collection Org2MSPPrivateCollection")
+
+	// The organization of the buyer now owns the marble and can list it again, the seller cannot
+	err = n.contract.ListMarble(n.as(t, org1, "marble_listing", map[string]string{"name": "marble1"}))
+	require.EqualError(t, err, "marble marble1 is not owned by Org1MSP")
+	require.NoError(t, n.contract.ListMarble(n.as(t, org2, "marble_listing", map[string]string{"name": "marble1"})))
+}
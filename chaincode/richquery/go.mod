@@ -0,0 +1,13 @@
+module github.com/hyperledger/fabric-samples/chaincode/richquery
+
+go 1.12
+
+require (
+	github.com/hyperledger/fabric-chaincode-go v0.0.0-20190823162523-04390e015b85
+	github.com/hyperledger/fabric-protos-go v0.0.0-20190821214336-621b908d5022
+	github.com/stretchr/testify v1.5.1
+	golang.org/x/net v0.0.0-20190813141303-74dc4d7220e7 // indirect
+	golang.org/x/sys v0.0.0-20190813064441-fde4db37ae7a // indirect
+	golang.org/x/text v0.3.2 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
+)
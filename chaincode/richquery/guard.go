@@ -0,0 +1,289 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package richquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// Index describes a CouchDB index packaged with the chaincode in META-INF/statedb/couchdb/indexes
+type Index struct {
+	DesignDoc string
+	Name      string
+	Fields    []string
+}
+
+// Guard restricts the ad hoc queries that clients may run, so that they cannot read documents
+// of other types or run queries that scan the whole state database
+type Guard struct {
+	// DocTypeField is the name of the field holding the type of a document
+	DocTypeField string
+	// DocType is the type of documents that queries must select
+	DocType string
+	// AllowedFields are the fields that may be used in the selector, fields and sort of a query
+	AllowedFields []string
+	// MaxLimit is the maximum number of results of a query
+	MaxLimit int
+	// Indexes are the indexes packaged with the chaincode. A query must be served by one of them.
+	// Use richquerytest.ReadIndexes in the tests of the chaincode to keep them in sync
+	Indexes []Index
+}
+
+// queryParameters are the query parameters accepted by the guard
+var queryParameters = map[string]bool{
+	"selector":  true,
+	"fields":    true,
+	"sort":      true,
+	"limit":     true,
+	"skip":      true,
+	"bookmark":  true,
+	"use_index": true,
+}
+
+// Check validates an ad hoc query and returns it with its limit capped to MaxLimit. CouchDB ignores
+// the limit of queries that are not paginated, so their results must also be capped with Limit
+func (g *Guard) Check(queryString string) (string, error) {
+	query, err := ParseQuery(queryString)
+	if err != nil {
+		return "", err
+	}
+
+	var params map[string]interface{}
+	err = decodeJSON([]byte(queryString), &params)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse query: %v", err)
+	}
+	for param := range params {
+		if !queryParameters[param] {
+			return "", fmt.Errorf("query parameter %s is not allowed", param)
+		}
+	}
+
+	if !g.selectsDocType(query.Selector) {
+		return "", fmt.Errorf("query must select documents with %s %s", g.DocTypeField, g.DocType)
+	}
+
+	err = g.checkSelector(query.Selector, "")
+	if err != nil {
+		return "", err
+	}
+	for _, field := range query.Fields {
+		err = g.checkField(field)
+		if err != nil {
+			return "", err
+		}
+	}
+	var sortFields []string
+	for _, field := range query.sortFields {
+		name := strings.Join(field.path, ".")
+		err = g.checkField(name)
+		if err != nil {
+			return "", err
+		}
+		sortFields = append(sortFields, name)
+	}
+
+	useIndex, err := useIndexArg(params["use_index"])
+	if err != nil {
+		return "", err
+	}
+	err = g.checkIndex(requiredFields(query.Selector), sortFields, useIndex)
+	if err != nil {
+		return "", err
+	}
+
+	if query.Limit == 0 || query.Limit > g.MaxLimit {
+		params["limit"] = g.MaxLimit
+	}
+	guardedQuery, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return string(guardedQuery), nil
+}
+
+// PageSize caps the page size of a paginated query to MaxLimit
+func (g *Guard) PageSize(pageSize int32) int32 {
+	if pageSize <= 0 || pageSize > int32(g.MaxLimit) {
+		return int32(g.MaxLimit)
+	}
+	return pageSize
+}
+
+// Limit returns an iterator over at most MaxLimit of the results of a query
+func (g *Guard) Limit(resultsIterator shim.StateQueryIteratorInterface) shim.StateQueryIteratorInterface {
+	return &limitIterator{StateQueryIteratorInterface: resultsIterator, remaining: g.MaxLimit}
+}
+
+// limitIterator stops iterating once the remaining number of results have been returned
+type limitIterator struct {
+	shim.StateQueryIteratorInterface
+	remaining int
+}
+
+func (i *limitIterator) HasNext() bool {
+	return i.remaining > 0 && i.StateQueryIteratorInterface.HasNext()
+}
+
+func (i *limitIterator) Next() (*queryresult.KV, error) {
+	if i.remaining <= 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+	i.remaining--
+	return i.StateQueryIteratorInterface.Next()
+}
+
+// selectsDocType reports whether the selector requires documents to be of the allowed type
+func (g *Guard) selectsDocType(selector map[string]interface{}) bool {
+	for key, arg := range selector {
+		switch key {
+		case g.DocTypeField:
+			if condition, ok := arg.(map[string]interface{}); ok && len(condition) == 1 {
+				arg = condition[opEq]
+			}
+			if arg == g.DocType {
+				return true
+			}
+		case opAnd:
+			for _, s := range arg.([]interface{}) {
+				if g.selectsDocType(s.(map[string]interface{})) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkSelector rejects fields that are not allowed and regular expressions, which cannot use an index
+func (g *Guard) checkSelector(selector map[string]interface{}, parent string) error {
+	for key, arg := range selector {
+		if key == opRegex {
+			return fmt.Errorf("operator %s is not allowed in queries", key)
+		}
+
+		field := parent
+		if !strings.HasPrefix(key, "$") {
+			field = key
+			if parent != "" {
+				field = parent + "." + key
+			}
+			err := g.checkField(field)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch arg := arg.(type) {
+		case map[string]interface{}:
+			if err := g.checkSelector(arg, field); err != nil {
+				return err
+			}
+		case []interface{}:
+			if key != opAnd && key != opOr && key != opNor {
+				continue
+			}
+			for _, s := range arg {
+				if err := g.checkSelector(s.(map[string]interface{}), field); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Guard) checkField(field string) error {
+	for _, allowed := range g.AllowedFields {
+		if field == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("field %s is not allowed in queries", field)
+}
+
+// checkIndex looks for an index with fields that are all required by the selector and that
+// contains the sort fields. If the query names an index with use_index, that index must match
+func (g *Guard) checkIndex(required map[string]bool, sortFields []string, useIndex []string) error {
+	for _, index := range g.Indexes {
+		if len(useIndex) > 0 && strings.TrimPrefix(useIndex[0], "_design/") != index.DesignDoc {
+			continue
+		}
+		if len(useIndex) > 1 && useIndex[1] != index.Name {
+			continue
+		}
+		if covers(required, index.Fields) && covers(toSet(index.Fields), sortFields) {
+			return nil
+		}
+	}
+
+	if len(useIndex) > 0 {
+		return fmt.Errorf("query is not served by index %s", strings.Join(useIndex, " "))
+	}
+	return fmt.Errorf("query is not served by any of the indexes packaged with the chaincode")
+}
+
+// requiredFields returns the fields that a document must contain to be selected, which are
+// the fields of the selector and of the selectors combined with $and
+func requiredFields(selector map[string]interface{}) map[string]bool {
+	fields := map[string]bool{}
+	for key, arg := range selector {
+		if !strings.HasPrefix(key, "$") {
+			fields[key] = true
+		} else if key == opAnd {
+			for _, s := range arg.([]interface{}) {
+				for field := range requiredFields(s.(map[string]interface{})) {
+					fields[field] = true
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// useIndexArg returns the design document and optional index name of use_index
+func useIndexArg(arg interface{}) ([]string, error) {
+	switch arg := arg.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{arg}, nil
+	case []interface{}:
+		var useIndex []string
+		for _, a := range arg {
+			s, ok := a.(string)
+			if !ok {
+				break
+			}
+			useIndex = append(useIndex, s)
+		}
+		if len(useIndex) == len(arg) && len(arg) > 0 && len(arg) <= 2 {
+			return useIndex, nil
+		}
+	}
+	return nil, fmt.Errorf("use_index must be a design document and optional index name")
+}
+
+func toSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+func covers(set map[string]bool, values []string) bool {
+	for _, value := range values {
+		if !set[value] {
+			return false
+		}
+	}
+	return true
+}
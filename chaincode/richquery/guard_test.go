@@ -0,0 +1,110 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package richquery
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/require"
+)
+
+var marbleGuard = &Guard{
+	DocTypeField:  "docType",
+	DocType:       "marble",
+	AllowedFields: []string{"docType", "name", "color", "size", "owner"},
+	MaxLimit:      10,
+	Indexes: []Index{
+		{DesignDoc: "indexOwnerDoc", Name: "indexOwner", Fields: []string{"docType", "owner"}},
+		{DesignDoc: "indexSizeDoc", Name: "indexSize", Fields: []string{"docType", "size"}},
+	},
+}
+
+func TestGuardAcceptsIndexedQueries(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			`{"selector":{"docType":"marble","owner":"tom"}}`,
+			`{"limit":10,"selector":{"docType":"marble","owner":"tom"}}`,
+		},
+		{
+			`{"selector":{"docType":{"$eq":"marble"},"owner":{"$in":["tom","jerry"]}},"limit":5,"use_index":["_design/indexOwnerDoc","indexOwner"]}`,
+			`{"limit":5,"selector":{"docType":{"$eq":"marble"},"owner":{"$in":["tom","jerry"]}},"use_index":["_design/indexOwnerDoc","indexOwner"]}`,
+		},
+		{
+			`{"selector":{"$and":[{"docType":"marble"},{"size":{"$gt":0}}]},"fields":["name","size"],"sort":[{"docType":"desc"},{"size":"desc"}],"limit":50}`,
+			`{"fields":["name","size"],"limit":10,"selector":{"$and":[{"docType":"marble"},{"size":{"$gt":0}}]},"sort":[{"docType":"desc"},{"size":"desc"}]}`,
+		},
+		{
+			`{"selector":{"docType":"marble","owner":"tom","color":{"$ne":"red"}},"use_index":"indexOwnerDoc","bookmark":"abc"}`,
+			`{"bookmark":"abc","limit":10,"selector":{"color":{"$ne":"red"},"docType":"marble","owner":"tom"},"use_index":"indexOwnerDoc"}`,
+		},
+	}
+
+	for _, test := range tests {
+		guardedQuery, err := marbleGuard.Check(test.query)
+		require.NoError(t, err, test.query)
+		require.Equal(t, test.expected, guardedQuery)
+	}
+}
+
+func TestGuardRejectsQueries(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`{"selector":{"docType":"marble","owner":{"$foo":1}}}`, "unsupported operator $foo"},
+		{`{"selector":{"docType":"marble","owner":"tom"},"execution_stats":true}`, "query parameter execution_stats is not allowed"},
+		{`{"selector":{"owner":"tom"}}`, "query must select documents with docType marble"},
+		{`{"selector":{"docType":"owner","owner":"tom"}}`, "query must select documents with docType marble"},
+		{`{"selector":{"$or":[{"docType":"marble"},{"owner":"tom"}]}}`, "query must select documents with docType marble"},
+		{`{"selector":{"docType":"marble","owner":"tom","secret":"x"}}`, "field secret is not allowed in queries"},
+		{`{"selector":{"docType":"marble","owner":{"name":"tom"}}}`, "field owner.name is not allowed in queries"},
+		{`{"selector":{"docType":"marble","$or":[{"owner":"tom"},{"secret":"x"}]}}`, "field secret is not allowed in queries"},
+		{`{"selector":{"docType":"marble","owner":"tom"},"fields":["secret"]}`, "field secret is not allowed in queries"},
+		{`{"selector":{"docType":"marble","owner":{"$regex":"^t"}}}`, "operator $regex is not allowed in queries"},
+		{`{"selector":{"docType":"marble","color":"red"}}`, "query is not served by any of the indexes packaged with the chaincode"},
+		{`{"selector":{"docType":"marble","$or":[{"owner":"tom"},{"size":1}]}}`, "query is not served by any of the indexes packaged with the chaincode"},
+		{`{"selector":{"docType":"marble","owner":"tom"},"sort":["size"]}`, "query is not served by any of the indexes packaged with the chaincode"},
+		{`{"selector":{"docType":"marble","owner":"tom"},"use_index":"_design/indexSizeDoc"}`, "query is not served by index _design/indexSizeDoc"},
+		{`{"selector":{"docType":"marble","owner":"tom"},"use_index":["indexOwnerDoc","other"]}`, "query is not served by index indexOwnerDoc other"},
+		{`{"selector":{"docType":"marble","owner":"tom"},"use_index":[1]}`, "use_index must be a design document and optional index name"},
+	}
+
+	for _, test := range tests {
+		_, err := marbleGuard.Check(test.query)
+		require.EqualError(t, err, test.err, test.query)
+	}
+}
+
+func TestGuardPageSize(t *testing.T) {
+	require.Equal(t, int32(5), marbleGuard.PageSize(5))
+	require.Equal(t, int32(10), marbleGuard.PageSize(0))
+	require.Equal(t, int32(10), marbleGuard.PageSize(100))
+}
+
+func TestGuardLimit(t *testing.T) {
+	var kvs []*queryresult.KV
+	for i := 0; i < 15; i++ {
+		kvs = append(kvs, &queryresult.KV{Key: fmt.Sprintf("marble%02d", i)})
+	}
+
+	resultsIterator := marbleGuard.Limit(NewIterator(kvs))
+	var keys []string
+	for resultsIterator.HasNext() {
+		kv, err := resultsIterator.Next()
+		require.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	require.Len(t, keys, 10)
+	require.Equal(t, "marble09", keys[9])
+
+	_, err := resultsIterator.Next()
+	require.EqualError(t, err, "no more results")
+	require.NoError(t, resultsIterator.Close())
+}
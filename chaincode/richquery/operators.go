@@ -0,0 +1,395 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package richquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Combination operators take selectors as argument
+const (
+	opAnd = "$and"
+	opOr  = "$or"
+	opNor = "$nor"
+	opNot = "$not"
+)
+
+// Condition operators take a value as argument
+const (
+	opEq        = "$eq"
+	opNe        = "$ne"
+	opGt        = "$gt"
+	opGte       = "$gte"
+	opLt        = "$lt"
+	opLte       = "$lte"
+	opIn        = "$in"
+	opNin       = "$nin"
+	opExists    = "$exists"
+	opType      = "$type"
+	opSize      = "$size"
+	opMod       = "$mod"
+	opRegex     = "$regex"
+	opAll       = "$all"
+	opElemMatch = "$elemMatch"
+	opAllMatch  = "$allMatch"
+)
+
+// validateSelector checks the operators of a selector and the type of their arguments
+func validateSelector(selector map[string]interface{}) error {
+	for key, arg := range selector {
+		if !strings.HasPrefix(key, "$") {
+			// A field, with either a value to compare or a nested selector
+			if nested, ok := arg.(map[string]interface{}); ok {
+				if err := validateSelector(nested); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		var err error
+		switch key {
+		case opAnd, opOr, opNor:
+			var selectors []interface{}
+			selectors, err = arrayArg(key, arg)
+			for _, s := range selectors {
+				if err != nil {
+					break
+				}
+				err = validateSelectorArg(key, s)
+			}
+		case opNot, opElemMatch, opAllMatch:
+			err = validateSelectorArg(key, arg)
+		case opEq, opNe, opGt, opGte, opLt, opLte:
+		case opIn, opNin, opAll:
+			_, err = arrayArg(key, arg)
+		case opExists:
+			if _, ok := arg.(bool); !ok {
+				err = fmt.Errorf("argument of %s must be a boolean", key)
+			}
+		case opType:
+			switch arg {
+			case "null", "boolean", "number", "string", "array", "object":
+			default:
+				err = fmt.Errorf("argument of %s must be a JSON type name", key)
+			}
+		case opSize:
+			_, err = intArg(key, arg)
+		case opMod:
+			var args []interface{}
+			args, err = arrayArg(key, arg)
+			if err == nil && len(args) != 2 {
+				err = fmt.Errorf("argument of %s must be a divisor and remainder", key)
+			}
+			for _, a := range args {
+				if err != nil {
+					break
+				}
+				_, err = intArg(key, a)
+			}
+			if err == nil {
+				if divisor, _ := intArg(key, args[0]); divisor == 0 {
+					err = fmt.Errorf("divisor of %s must not be zero", key)
+				}
+			}
+		case opRegex:
+			pattern, ok := arg.(string)
+			if !ok {
+				err = fmt.Errorf("argument of %s must be a string", key)
+			} else if _, compileErr := regexp.Compile(pattern); compileErr != nil {
+				err = fmt.Errorf("invalid %s pattern: %v", key, compileErr)
+			}
+		default:
+			err = fmt.Errorf("unsupported operator %s", key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateSelectorArg(op string, arg interface{}) error {
+	selector, ok := arg.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("argument of %s must be a selector", op)
+	}
+	return validateSelector(selector)
+}
+
+func arrayArg(op string, arg interface{}) ([]interface{}, error) {
+	array, ok := arg.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("argument of %s must be an array", op)
+	}
+	return array, nil
+}
+
+func intArg(op string, arg interface{}) (int64, error) {
+	number, ok := arg.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("argument of %s must be an integer", op)
+	}
+	i, err := number.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("argument of %s must be an integer", op)
+	}
+	return i, nil
+}
+
+// matchSelector reports whether a value is selected. Field names in the selector
+// refer to fields of the value, operators apply to the value itself
+func matchSelector(selector map[string]interface{}, value interface{}, exists bool) bool {
+	for key, arg := range selector {
+		if !strings.HasPrefix(key, "$") {
+			fieldValue, fieldExists := lookup(value, splitPath(key))
+			if !exists {
+				fieldExists = false
+			}
+			if nested, ok := arg.(map[string]interface{}); ok {
+				if !matchSelector(nested, fieldValue, fieldExists) {
+					return false
+				}
+			} else if !fieldExists || compare(fieldValue, arg) != 0 {
+				return false
+			}
+			continue
+		}
+
+		if !matchOperator(key, arg, value, exists) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchOperator evaluates a single operator. Apart from $exists and the combination
+// operators, conditions on missing fields are never met
+func matchOperator(op string, arg interface{}, value interface{}, exists bool) bool {
+	switch op {
+	case opAnd:
+		for _, s := range arg.([]interface{}) {
+			if !matchSelector(s.(map[string]interface{}), value, exists) {
+				return false
+			}
+		}
+		return true
+	case opOr:
+		for _, s := range arg.([]interface{}) {
+			if matchSelector(s.(map[string]interface{}), value, exists) {
+				return true
+			}
+		}
+		return false
+	case opNor:
+		return !matchOperator(opOr, arg, value, exists)
+	case opNot:
+		return !matchSelector(arg.(map[string]interface{}), value, exists)
+	case opExists:
+		return exists == arg.(bool)
+	}
+
+	if !exists {
+		return false
+	}
+
+	switch op {
+	case opEq:
+		return compare(value, arg) == 0
+	case opNe:
+		return compare(value, arg) != 0
+	case opGt:
+		return compare(value, arg) > 0
+	case opGte:
+		return compare(value, arg) >= 0
+	case opLt:
+		return compare(value, arg) < 0
+	case opLte:
+		return compare(value, arg) <= 0
+	case opIn:
+		return in(value, arg.([]interface{}))
+	case opNin:
+		return !in(value, arg.([]interface{}))
+	case opType:
+		return typeName(value) == arg
+	case opSize:
+		array, ok := value.([]interface{})
+		size, _ := intArg(op, arg)
+		return ok && int64(len(array)) == size
+	case opMod:
+		args := arg.([]interface{})
+		divisor, _ := intArg(op, args[0])
+		remainder, _ := intArg(op, args[1])
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		i, err := number.Int64()
+		return err == nil && i%divisor == remainder
+	case opRegex:
+		s, ok := value.(string)
+		return ok && regexp.MustCompile(arg.(string)).MatchString(s)
+	case opAll:
+		array, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, a := range arg.([]interface{}) {
+			if !in(a, array) {
+				return false
+			}
+		}
+		return true
+	case opElemMatch, opAllMatch:
+		array, ok := value.([]interface{})
+		if !ok || len(array) == 0 {
+			return false
+		}
+		for _, element := range array {
+			matched := matchSelector(arg.(map[string]interface{}), element, true)
+			if op == opElemMatch && matched {
+				return true
+			}
+			if op == opAllMatch && !matched {
+				return false
+			}
+		}
+		return op == opAllMatch
+	}
+
+	return false
+}
+
+// in reports whether the value, or any element of an array value, is one of the arguments
+func in(value interface{}, args []interface{}) bool {
+	values := []interface{}{value}
+	if array, ok := value.([]interface{}); ok {
+		values = array
+	}
+	for _, v := range values {
+		for _, a := range args {
+			if compare(v, a) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// typeRank orders values of different types as in the CouchDB collation
+func typeRank(value interface{}) int {
+	switch value := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		if value {
+			return 2
+		}
+		return 1
+	case json.Number, float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// compare returns -1, 0 or 1 depending on the collation order of two JSON values
+func compare(a, b interface{}) int {
+	rankA, rankB := typeRank(a), typeRank(b)
+	if rankA != rankB {
+		return sign(float64(rankA - rankB))
+	}
+
+	switch a := a.(type) {
+	case json.Number, float64:
+		return sign(toFloat(a) - toFloat(b))
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return sign(float64(len(a) - len(b)))
+	case map[string]interface{}:
+		// Objects are compared field by field, in the order of the field names
+		b := b.(map[string]interface{})
+		keysA, keysB := sortedKeys(a), sortedKeys(b)
+		for i := 0; i < len(keysA) && i < len(keysB); i++ {
+			if c := strings.Compare(keysA[i], keysB[i]); c != 0 {
+				return c
+			}
+			if c := compare(a[keysA[i]], b[keysB[i]]); c != 0 {
+				return c
+			}
+		}
+		return sign(float64(len(keysA) - len(keysB)))
+	}
+
+	return 0
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toFloat(value interface{}) float64 {
+	switch value := value.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			return math.NaN()
+		}
+		return f
+	case float64:
+		return value
+	}
+	return math.NaN()
+}
+
+func sign(f float64) int {
+	switch {
+	case f < 0:
+		return -1
+	case f > 0:
+		return 1
+	}
+	return 0
+}
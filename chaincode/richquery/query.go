@@ -0,0 +1,272 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package richquery evaluates CouchDB Mango queries against key value pairs held in memory,
+// so that chaincode using rich queries can run on LevelDB and be unit tested without CouchDB.
+//
+// The supported subset of the query syntax is: the selector with the operators listed in
+// operators.go, fields, sort, limit, skip and bookmark. Other query parameters such as
+// use_index are ignored. Values are compared using the CouchDB collation order of JSON types,
+// but strings are compared by their bytes rather than using the ICU collation of CouchDB.
+package richquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Query is a parsed Mango query
+type Query struct {
+	Selector map[string]interface{} `json:"selector"`
+	Fields   []string               `json:"fields"`
+	Sort     []interface{}          `json:"sort"`
+	Limit    int                    `json:"limit"`
+	Skip     int                    `json:"skip"`
+	Bookmark string                 `json:"bookmark"`
+
+	sortFields []sortField
+}
+
+type sortField struct {
+	path       []string
+	descending bool
+}
+
+// ParseQuery parses a Mango query string and validates the operators used in the selector
+func ParseQuery(queryString string) (*Query, error) {
+	var query Query
+	err := decodeJSON([]byte(queryString), &query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %v", err)
+	}
+
+	if query.Selector == nil {
+		return nil, fmt.Errorf("query must contain a selector")
+	}
+	if query.Limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+	if query.Skip < 0 {
+		return nil, fmt.Errorf("skip must not be negative")
+	}
+
+	err = validateSelector(query.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range query.Sort {
+		switch field := field.(type) {
+		case string:
+			query.sortFields = append(query.sortFields, sortField{path: splitPath(field)})
+		case map[string]interface{}:
+			if len(field) != 1 {
+				return nil, fmt.Errorf("sort field must have a single name and direction")
+			}
+			for name, direction := range field {
+				if direction != "asc" && direction != "desc" {
+					return nil, fmt.Errorf("invalid sort direction %v for field %s", direction, name)
+				}
+				query.sortFields = append(query.sortFields, sortField{path: splitPath(name), descending: direction == "desc"})
+			}
+		default:
+			return nil, fmt.Errorf("invalid sort field %v", field)
+		}
+	}
+
+	return &query, nil
+}
+
+// Matches reports whether a JSON document is selected by the query.
+// Values that are not JSON objects never match
+func (q *Query) Matches(value []byte) bool {
+	_, ok := q.match(value)
+	return ok
+}
+
+func (q *Query) match(value []byte) (map[string]interface{}, bool) {
+	var doc map[string]interface{}
+	if decodeJSON(value, &doc) != nil || doc == nil {
+		return nil, false
+	}
+
+	if !matchSelector(q.Selector, doc, true) {
+		return nil, false
+	}
+
+	// Like CouchDB indexes, documents without the sort fields are not part of a sorted result
+	for _, field := range q.sortFields {
+		if _, ok := lookup(doc, field.path); !ok {
+			return nil, false
+		}
+	}
+
+	return doc, true
+}
+
+type match struct {
+	kv  *queryresult.KV
+	doc map[string]interface{}
+}
+
+// Execute runs the query against the key value pairs, which must be sorted by key.
+// If pageSize is greater than zero it replaces the limit of the query, as with the paginated
+// query APIs of the chaincode shim. The bookmark is the key of the first result to return,
+// and the bookmark of the returned metadata is empty once there are no more results
+func (q *Query) Execute(kvs []*queryresult.KV, pageSize int32, bookmark string) ([]*queryresult.KV, *pb.QueryResponseMetadata, error) {
+	var matches []match
+	for _, kv := range kvs {
+		doc, ok := q.match(kv.Value)
+		if ok {
+			matches = append(matches, match{kv: kv, doc: doc})
+		}
+	}
+
+	if len(q.sortFields) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			for _, field := range q.sortFields {
+				a, _ := lookup(matches[i].doc, field.path)
+				b, _ := lookup(matches[j].doc, field.path)
+				c := compare(a, b)
+				if field.descending {
+					c = -c
+				}
+				if c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+	}
+
+	if bookmark == "" {
+		bookmark = q.Bookmark
+	}
+
+	start := q.Skip
+	if bookmark != "" {
+		start = -1
+		for i, m := range matches {
+			if m.kv.Key == bookmark {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			return nil, nil, fmt.Errorf("invalid bookmark %s", bookmark)
+		}
+	}
+	if start > len(matches) {
+		start = len(matches)
+	}
+	matches = matches[start:]
+
+	limit := q.Limit
+	if pageSize > 0 {
+		limit = int(pageSize)
+	}
+
+	metadata := &pb.QueryResponseMetadata{}
+	if limit > 0 && len(matches) > limit {
+		metadata.Bookmark = matches[limit].kv.Key
+		matches = matches[:limit]
+	}
+
+	results := make([]*queryresult.KV, 0, len(matches))
+	for _, m := range matches {
+		kv := m.kv
+		if len(q.Fields) > 0 {
+			value, err := json.Marshal(q.project(m.doc))
+			if err != nil {
+				return nil, nil, err
+			}
+			kv = &queryresult.KV{Namespace: kv.Namespace, Key: kv.Key, Value: value}
+		}
+		results = append(results, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(results))
+
+	return results, metadata, nil
+}
+
+// project returns the document restricted to the fields of the query
+func (q *Query) project(doc map[string]interface{}) map[string]interface{} {
+	projection := map[string]interface{}{}
+	for _, field := range q.Fields {
+		path := splitPath(field)
+		value, ok := lookup(doc, path)
+		if !ok {
+			continue
+		}
+
+		parent := projection
+		for _, name := range path[:len(path)-1] {
+			child, ok := parent[name].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				parent[name] = child
+			}
+			parent = child
+		}
+		parent[path[len(path)-1]] = value
+	}
+	return projection
+}
+
+// ExecuteQuery parses and runs a query against the key value pairs, see Query.Execute
+func ExecuteQuery(queryString string, kvs []*queryresult.KV, pageSize int32, bookmark string) ([]*queryresult.KV, *pb.QueryResponseMetadata, error) {
+	query, err := ParseQuery(queryString)
+	if err != nil {
+		return nil, nil, err
+	}
+	return query.Execute(kvs, pageSize, bookmark)
+}
+
+// StateToKVs returns the entries of a state map as key value pairs sorted by key
+func StateToKVs(namespace string, state map[string][]byte) []*queryresult.KV {
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]*queryresult.KV, 0, len(keys))
+	for _, key := range keys {
+		kvs = append(kvs, &queryresult.KV{Namespace: namespace, Key: key, Value: state[key]})
+	}
+	return kvs
+}
+
+// decodeJSON decodes numbers as json.Number so that they are returned unchanged by projections
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// splitPath splits a field name into the names of the nested fields
+func splitPath(field string) []string {
+	return strings.Split(field, ".")
+}
+
+// lookup returns the value of a nested field of a document
+func lookup(value interface{}, path []string) (interface{}, bool) {
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,164 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package richquery
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/require"
+)
+
+var marbles = map[string][]byte{
+	"marble1": []byte(`{"docType":"marble","name":"marble1","color":"blue","size":35,"owner":"tom","tags":["shiny","round"]}`),
+	"marble2": []byte(`{"docType":"marble","name":"marble2","color":"red","size":50,"owner":"tom"}`),
+	"marble3": []byte(`{"docType":"marble","name":"marble3","color":"blue","size":70,"owner":"jerry","details":{"origin":"glass"}}`),
+	"marble4": []byte(`{"docType":"marble","name":"marble4","color":"green","size":10.5,"owner":"jerry","tags":["round"]}`),
+	"owner~1": []byte{0x00},
+	"other":   []byte(`{"docType":"owner","name":"tom"}`),
+}
+
+func keys(kvs []*queryresult.KV) []string {
+	result := []string{}
+	for _, kv := range kvs {
+		result = append(result, kv.Key)
+	}
+	return result
+}
+
+func TestSelectorOperators(t *testing.T) {
+	tests := []struct {
+		selector string
+		expected []string
+	}{
+		{`{"owner":"tom"}`, []string{"marble1", "marble2"}},
+		{`{"docType":"marble","owner":{"$eq":"jerry"}}`, []string{"marble3", "marble4"}},
+		{`{"owner":{"$ne":"tom"},"docType":"marble"}`, []string{"marble3", "marble4"}},
+		{`{"size":{"$gt":35}}`, []string{"marble2", "marble3"}},
+		{`{"size":{"$gte":35,"$lt":70}}`, []string{"marble1", "marble2"}},
+		{`{"size":{"$lte":10.5}}`, []string{"marble4"}},
+		{`{"color":{"$in":["red","green"]}}`, []string{"marble2", "marble4"}},
+		{`{"docType":"marble","color":{"$nin":["red","green"]}}`, []string{"marble1", "marble3"}},
+		{`{"tags":{"$in":["shiny"]}}`, []string{"marble1"}},
+		{`{"tags":{"$exists":true}}`, []string{"marble1", "marble4"}},
+		{`{"docType":"marble","tags":{"$exists":false}}`, []string{"marble2", "marble3"}},
+		{`{"$and":[{"color":"blue"},{"owner":"jerry"}]}`, []string{"marble3"}},
+		{`{"$or":[{"color":"red"},{"size":{"$lt":20}}]}`, []string{"marble2", "marble4"}},
+		{`{"docType":"marble","$nor":[{"color":"blue"},{"owner":"jerry"}]}`, []string{"marble2"}},
+		{`{"docType":"marble","$not":{"owner":"tom"}}`, []string{"marble3", "marble4"}},
+		{`{"name":{"$regex":"^marble[13]$"}}`, []string{"marble1", "marble3"}},
+		{`{"color":{"$regex":"^b"},"size":{"$gt":50}}`, []string{"marble3"}},
+		{`{"details":{"origin":"glass"}}`, []string{"marble3"}},
+		{`{"details.origin":{"$eq":"glass"}}`, []string{"marble3"}},
+		{`{"tags":{"$all":["round","shiny"]}}`, []string{"marble1"}},
+		{`{"tags":{"$size":1}}`, []string{"marble4"}},
+		{`{"tags":{"$elemMatch":{"$eq":"round"}}}`, []string{"marble1", "marble4"}},
+		{`{"size":{"$type":"number"},"owner":"jerry"}`, []string{"marble3", "marble4"}},
+		{`{"size":{"$mod":[10,0]}}`, []string{"marble2", "marble3"}},
+		{`{"owner":{"$gt":5}}`, []string{"marble1", "marble2", "marble3", "marble4"}},
+		{`{"color":"purple"}`, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.selector, func(t *testing.T) {
+			results, _, err := ExecuteQuery(`{"selector":`+test.selector+`}`, StateToKVs("", marbles), 0, "")
+			require.NoError(t, err)
+			require.Equal(t, test.expected, keys(results))
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`not json`, "failed to parse query: invalid character 'o' in literal null (expecting 'u')"},
+		{`{"fields":["name"]}`, "query must contain a selector"},
+		{`{"selector":{"size":{"$near":5}}}`, "unsupported operator $near"},
+		{`{"selector":{"$or":{"color":"red"}}}`, "argument of $or must be an array"},
+		{`{"selector":{"color":{"$in":"red"}}}`, "argument of $in must be an array"},
+		{`{"selector":{"name":{"$regex":"("}}}`, "invalid $regex pattern: error parsing regexp: missing closing ): `(`"},
+		{`{"selector":{"size":{"$mod":[0,1]}}}`, "divisor of $mod must not be zero"},
+		{`{"selector":{},"sort":[{"size":"up"}]}`, "invalid sort direction up for field size"},
+		{`{"selector":{},"limit":-1}`, "limit must not be negative"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseQuery(test.query)
+		require.EqualError(t, err, test.err, test.query)
+	}
+}
+
+func TestSortFieldsAndLimit(t *testing.T) {
+	kvs := StateToKVs("", marbles)
+
+	results, _, err := ExecuteQuery(`{"selector":{"docType":"marble"},"sort":[{"size":"desc"}],"fields":["name","details.origin"]}`, kvs, 0, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble3", "marble2", "marble1", "marble4"}, keys(results))
+	require.Equal(t, `{"details":{"origin":"glass"},"name":"marble3"}`, string(results[0].Value))
+	require.Equal(t, `{"name":"marble2"}`, string(results[1].Value))
+
+	// Documents without a sort field are not part of the result, ties are ordered by key
+	results, _, err = ExecuteQuery(`{"selector":{},"sort":["owner",{"color":"asc"}]}`, kvs, 0, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble3", "marble4", "marble1", "marble2"}, keys(results))
+
+	results, metadata, err := ExecuteQuery(`{"selector":{"docType":"marble"},"limit":3,"skip":1}`, kvs, 0, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble2", "marble3", "marble4"}, keys(results))
+	require.Equal(t, int32(3), metadata.FetchedRecordsCount)
+	require.Equal(t, "", metadata.Bookmark)
+}
+
+func TestPagination(t *testing.T) {
+	kvs := StateToKVs("", marbles)
+	query := `{"selector":{"docType":"marble"},"sort":[{"size":"asc"}],"limit":1}`
+
+	// The page size replaces the limit of the query
+	var pages [][]string
+	bookmark := ""
+	for {
+		results, metadata, err := ExecuteQuery(query, kvs, 3, bookmark)
+		require.NoError(t, err)
+		require.Equal(t, int32(len(results)), metadata.FetchedRecordsCount)
+		pages = append(pages, keys(results))
+		if metadata.Bookmark == "" {
+			break
+		}
+		bookmark = metadata.Bookmark
+	}
+	require.Equal(t, [][]string{{"marble4", "marble1", "marble2"}, {"marble3"}}, pages)
+
+	// The bookmark can also be passed in the query
+	results, _, err := ExecuteQuery(`{"selector":{"docType":"marble"},"bookmark":"marble3"}`, kvs, 0, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble3", "marble4"}, keys(results))
+
+	_, _, err = ExecuteQuery(query, kvs, 3, "marble9")
+	require.EqualError(t, err, "invalid bookmark marble9")
+}
+
+func TestMatches(t *testing.T) {
+	query, err := ParseQuery(`{"selector":{"owner":"tom","size":{"$gt":40}}}`)
+	require.NoError(t, err)
+
+	require.True(t, query.Matches(marbles["marble2"]))
+	require.False(t, query.Matches(marbles["marble1"]))
+	require.False(t, query.Matches(marbles["owner~1"]))
+	require.False(t, query.Matches([]byte(`["owner","tom"]`)))
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{`null`, `false`, `true`, `-1`, `2.5`, `10`, `"10"`, `"a"`, `"b"`, `[]`, `[1]`, `[1,2]`, `[2]`, `{}`, `{"a":1}`}
+	for i := range ordered {
+		for j := range ordered {
+			var a, b interface{}
+			require.NoError(t, decodeJSON([]byte(ordered[i]), &a))
+			require.NoError(t, decodeJSON([]byte(ordered[j]), &b))
+			require.Equal(t, sign(float64(i-j)), compare(a, b), "%s %s", ordered[i], ordered[j])
+		}
+	}
+}
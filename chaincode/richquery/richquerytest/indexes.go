@@ -0,0 +1,73 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package richquerytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric-samples/chaincode/richquery"
+)
+
+// indexDefinition is a CouchDB index definition as packaged in META-INF/statedb/couchdb/indexes
+type indexDefinition struct {
+	Index struct {
+		Fields []interface{} `json:"fields"`
+	} `json:"index"`
+	DesignDoc string `json:"ddoc"`
+	Name      string `json:"name"`
+}
+
+// ReadIndexes reads the index definitions in a META-INF/statedb/couchdb/indexes directory, so that
+// the tests of a chaincode can check that the indexes of its Guard match the packaged indexes
+func ReadIndexes(dir string) ([]richquery.Index, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []richquery.Index
+	for _, file := range files {
+		definitionJSON, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var definition indexDefinition
+		err = json.Unmarshal(definitionJSON, &definition)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse index %s: %v", file, err)
+		}
+
+		index := richquery.Index{DesignDoc: definition.DesignDoc, Name: definition.Name}
+		for _, field := range definition.Index.Fields {
+			name, err := fieldName(field)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse index %s: %v", file, err)
+			}
+			index.Fields = append(index.Fields, name)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// fieldName returns the name of an index field, which is either a name
+// or an object mapping the name to the sort direction
+func fieldName(field interface{}) (string, error) {
+	switch field := field.(type) {
+	case string:
+		return field, nil
+	case map[string]interface{}:
+		if len(field) == 1 {
+			for name := range field {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid index field %v", field)
+}
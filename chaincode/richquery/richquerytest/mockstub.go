@@ -0,0 +1,81 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package richquerytest provides a mock stub for unit testing chaincode that uses rich queries
+package richquerytest
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/hyperledger/fabric-samples/chaincode/richquery"
+)
+
+// MockStub is a shimtest.MockStub that evaluates rich queries against its in-memory
+// public and private state, and supports range queries on private data
+type MockStub struct {
+	*shimtest.MockStub
+}
+
+// proxy passes the MockStub, rather than the embedded shimtest.MockStub, to the chaincode
+type proxy struct {
+	cc   shim.Chaincode
+	stub *MockStub
+}
+
+func (p *proxy) Init(shim.ChaincodeStubInterface) pb.Response {
+	return p.cc.Init(p.stub)
+}
+
+func (p *proxy) Invoke(shim.ChaincodeStubInterface) pb.Response {
+	return p.cc.Invoke(p.stub)
+}
+
+// NewMockStub creates a MockStub for the chaincode
+func NewMockStub(name string, cc shim.Chaincode) *MockStub {
+	stub := &MockStub{}
+	stub.MockStub = shimtest.NewMockStub(name, &proxy{cc: cc, stub: stub})
+	return stub
+}
+
+// GetQueryResult evaluates the query against the state
+func (stub *MockStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	results, _, err := richquery.ExecuteQuery(query, richquery.StateToKVs(stub.Name, stub.State), 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return richquery.NewIterator(results), nil
+}
+
+// GetQueryResultWithPagination evaluates the query against the state, returning a page of results
+func (stub *MockStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	results, metadata, err := richquery.ExecuteQuery(query, richquery.StateToKVs(stub.Name, stub.State), pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	return richquery.NewIterator(results), metadata, nil
+}
+
+// GetPrivateDataQueryResult evaluates the query against the private state of the collection
+func (stub *MockStub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	results, _, err := richquery.ExecuteQuery(query, richquery.StateToKVs(stub.Name, stub.PvtState[collection]), 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return richquery.NewIterator(results), nil
+}
+
+// GetPrivateDataByRange returns the private state of the collection from startKey (inclusive)
+// to endKey (exclusive). Empty keys leave the range open
+func (stub *MockStub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	var results []*queryresult.KV
+	for _, kv := range richquery.StateToKVs(stub.Name, stub.PvtState[collection]) {
+		if kv.Key >= startKey && (endKey == "" || kv.Key < endKey) {
+			results = append(results, kv)
+		}
+	}
+	return richquery.NewIterator(results), nil
+}
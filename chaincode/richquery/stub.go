@@ -0,0 +1,146 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package richquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// IsRichQueryUnsupported reports whether an error was returned by a query because
+// the state database of the peer does not support rich queries, as is the case for LevelDB.
+// The peer does not report the type of its state database, so the error message is matched
+func IsRichQueryUnsupported(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not supported for leveldb")
+}
+
+// Fallback enables the evaluation of rich queries in the chaincode when the state database of the
+// peer does not support them. The query is evaluated against the keys from StartKey (inclusive) to
+// EndKey (exclusive), where empty keys leave the range open, and fails if the range holds more than
+// MaxKeys keys. Since the keys are read for every query, it is only suitable for small data sets
+type Fallback struct {
+	StartKey string
+	EndKey   string
+	MaxKeys  int
+}
+
+// GetQueryResult performs a rich query against the state database. If the state database does not
+// support rich queries, the query is evaluated against the key range of the fallback instead.
+// Without a fallback, the error of the state database is returned
+func GetQueryResult(stub shim.ChaincodeStubInterface, query string, fallback *Fallback) (shim.StateQueryIteratorInterface, error) {
+	resultsIterator, err := stub.GetQueryResult(query)
+	if fallback == nil || !IsRichQueryUnsupported(err) {
+		return resultsIterator, err
+	}
+
+	kvs, err := fallback.readAll(stub.GetStateByRange(fallback.StartKey, fallback.EndKey))
+	if err != nil {
+		return nil, err
+	}
+
+	results, _, err := ExecuteQuery(query, kvs, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewIterator(results), nil
+}
+
+// GetQueryResultWithPagination performs a paginated rich query against the state database,
+// falling back to evaluating the query against the key range of the fallback like GetQueryResult.
+// The bookmarks of the fallback are the keys of the first result of the next page, and cannot be
+// used with CouchDB
+func GetQueryResultWithPagination(stub shim.ChaincodeStubInterface, query string, pageSize int32, bookmark string, fallback *Fallback) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	resultsIterator, metadata, err := stub.GetQueryResultWithPagination(query, pageSize, bookmark)
+	if fallback == nil || !IsRichQueryUnsupported(err) {
+		return resultsIterator, metadata, err
+	}
+
+	kvs, err := fallback.readAll(stub.GetStateByRange(fallback.StartKey, fallback.EndKey))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, metadata, err := ExecuteQuery(query, kvs, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewIterator(results), metadata, nil
+}
+
+// GetPrivateDataQueryResult performs a rich query against a private data collection, falling
+// back to evaluating the query against the key range of the fallback in the collection like GetQueryResult
+func GetPrivateDataQueryResult(stub shim.ChaincodeStubInterface, collection string, query string, fallback *Fallback) (shim.StateQueryIteratorInterface, error) {
+	resultsIterator, err := stub.GetPrivateDataQueryResult(collection, query)
+	if fallback == nil || !IsRichQueryUnsupported(err) {
+		return resultsIterator, err
+	}
+
+	kvs, err := fallback.readAll(stub.GetPrivateDataByRange(collection, fallback.StartKey, fallback.EndKey))
+	if err != nil {
+		return nil, err
+	}
+
+	results, _, err := ExecuteQuery(query, kvs, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return NewIterator(results), nil
+}
+
+// readAll reads the keys of the range, failing once more than MaxKeys keys have been read
+func (f *Fallback) readAll(resultsIterator shim.StateQueryIteratorInterface, err error) ([]*queryresult.KV, error) {
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state for query: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var kvs []*queryresult.KV
+	for resultsIterator.HasNext() {
+		if len(kvs) >= f.MaxKeys {
+			return nil, fmt.Errorf("query cannot be evaluated by the chaincode as it reads more than %d keys", f.MaxKeys)
+		}
+		kv, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs, nil
+}
+
+// Iterator iterates over query results held in memory
+type Iterator struct {
+	kvs []*queryresult.KV
+}
+
+// NewIterator returns an iterator over the key value pairs
+func NewIterator(kvs []*queryresult.KV) *Iterator {
+	return &Iterator{kvs: kvs}
+}
+
+// HasNext returns true if the iterator contains more results
+func (i *Iterator) HasNext() bool {
+	return len(i.kvs) > 0
+}
+
+// Next returns the next result
+func (i *Iterator) Next() (*queryresult.KV, error) {
+	if len(i.kvs) == 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+	kv := i.kvs[0]
+	i.kvs = i.kvs[1:]
+	return kv, nil
+}
+
+// Close releases the remaining results
+func (i *Iterator) Close() error {
+	i.kvs = nil
+	return nil
+}
@@ -0,0 +1,188 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package richquery_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hyperledger/fabric-samples/chaincode/richquery"
+	"github.com/hyperledger/fabric-samples/chaincode/richquery/richquerytest"
+)
+
+var errLevelDB = errors.New("ExecuteQuery not supported for leveldb")
+
+// levelDBStub fails rich queries like a peer using LevelDB as state database
+type levelDBStub struct {
+	*richquerytest.MockStub
+}
+
+func (stub *levelDBStub) GetQueryResult(string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errLevelDB
+}
+
+func (stub *levelDBStub) GetQueryResultWithPagination(string, int32, string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return nil, nil, errLevelDB
+}
+
+func (stub *levelDBStub) GetPrivateDataQueryResult(string, string) (shim.StateQueryIteratorInterface, error) {
+	return nil, errLevelDB
+}
+
+// queryChaincode returns the keys selected by the rich query passed as argument
+type queryChaincode struct{}
+
+func (cc *queryChaincode) Init(shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (cc *queryChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	_, args := stub.GetFunctionAndParameters()
+	resultsIterator, err := stub.GetQueryResult(args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	defer resultsIterator.Close()
+
+	var keys string
+	for resultsIterator.HasNext() {
+		kv, err := resultsIterator.Next()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		keys += kv.Key + ","
+	}
+	return shim.Success([]byte(keys))
+}
+
+func newLevelDBStub(t *testing.T) *levelDBStub {
+	stub := &levelDBStub{richquerytest.NewMockStub("marbles", nil)}
+	stub.MockTransactionStart("tx1")
+	for key, value := range map[string]string{
+		"marble1": `{"docType":"marble","color":"blue","size":35,"owner":"tom"}`,
+		"marble2": `{"docType":"marble","color":"red","size":50,"owner":"tom"}`,
+		"marble3": `{"docType":"marble","color":"blue","size":70,"owner":"jerry"}`,
+	} {
+		require.NoError(t, stub.PutState(key, []byte(value)))
+		require.NoError(t, stub.PutPrivateData("collectionMarbles", key, []byte(value)))
+	}
+	stub.MockTransactionEnd("tx1")
+	return stub
+}
+
+func readKeys(t *testing.T, resultsIterator shim.StateQueryIteratorInterface) []string {
+	defer resultsIterator.Close()
+
+	keys := []string{}
+	for resultsIterator.HasNext() {
+		kv, err := resultsIterator.Next()
+		require.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+func TestGetQueryResultFallback(t *testing.T) {
+	stub := newLevelDBStub(t)
+	fallback := &richquery.Fallback{MaxKeys: 10}
+
+	resultsIterator, err := richquery.GetQueryResult(stub, `{"selector":{"owner":"tom"},"sort":[{"size":"desc"}]}`, fallback)
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble2", "marble1"}, readKeys(t, resultsIterator))
+
+	resultsIterator, metadata, err := richquery.GetQueryResultWithPagination(stub, `{"selector":{"docType":"marble"}}`, 2, "", fallback)
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble1", "marble2"}, readKeys(t, resultsIterator))
+	require.Equal(t, "marble3", metadata.Bookmark)
+
+	resultsIterator, metadata, err = richquery.GetQueryResultWithPagination(stub, `{"selector":{"docType":"marble"}}`, 2, metadata.Bookmark, fallback)
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble3"}, readKeys(t, resultsIterator))
+	require.Equal(t, "", metadata.Bookmark)
+
+	resultsIterator, err = richquery.GetPrivateDataQueryResult(stub, "collectionMarbles", `{"selector":{"color":{"$in":["blue"]}}}`, fallback)
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble1", "marble3"}, readKeys(t, resultsIterator))
+
+	_, err = richquery.GetQueryResult(stub, `{"selector":{"size":{"$near":5}}}`, fallback)
+	require.EqualError(t, err, "unsupported operator $near")
+}
+
+func TestGetQueryResultFallbackIsOptIn(t *testing.T) {
+	stub := newLevelDBStub(t)
+
+	_, err := richquery.GetQueryResult(stub, `{"selector":{"owner":"tom"}}`, nil)
+	require.Equal(t, errLevelDB, err)
+
+	_, _, err = richquery.GetQueryResultWithPagination(stub, `{"selector":{"owner":"tom"}}`, 2, "", nil)
+	require.Equal(t, errLevelDB, err)
+
+	_, err = richquery.GetPrivateDataQueryResult(stub, "collectionMarbles", `{"selector":{"owner":"tom"}}`, nil)
+	require.Equal(t, errLevelDB, err)
+}
+
+func TestGetQueryResultFallbackIsBounded(t *testing.T) {
+	stub := newLevelDBStub(t)
+
+	// Only the keys in the range of the fallback are queried
+	resultsIterator, err := richquery.GetQueryResult(stub, `{"selector":{"owner":"tom"}}`, &richquery.Fallback{StartKey: "marble2", EndKey: "marble3", MaxKeys: 10})
+	require.NoError(t, err)
+	require.Equal(t, []string{"marble2"}, readKeys(t, resultsIterator))
+
+	_, err = richquery.GetQueryResult(stub, `{"selector":{"owner":"tom"}}`, &richquery.Fallback{MaxKeys: 2})
+	require.EqualError(t, err, "query cannot be evaluated by the chaincode as it reads more than 2 keys")
+
+	_, err = richquery.GetPrivateDataQueryResult(stub, "collectionMarbles", `{"selector":{"owner":"tom"}}`, &richquery.Fallback{MaxKeys: 2})
+	require.EqualError(t, err, "query cannot be evaluated by the chaincode as it reads more than 2 keys")
+}
+
+func TestGetQueryResultPassesOtherErrors(t *testing.T) {
+	stub := richquerytest.NewMockStub("marbles", nil)
+
+	_, err := richquery.GetQueryResult(stub, `{"selector":`, &richquery.Fallback{MaxKeys: 10})
+	require.EqualError(t, err, "failed to parse query: unexpected EOF")
+}
+
+func TestMockStub(t *testing.T) {
+	stub := richquerytest.NewMockStub("marbles", &queryChaincode{})
+	stub.MockTransactionStart("tx1")
+	require.NoError(t, stub.PutState("marble1", []byte(`{"owner":"tom"}`)))
+	require.NoError(t, stub.PutState("marble2", []byte(`{"owner":"jerry"}`)))
+	stub.MockTransactionEnd("tx1")
+
+	response := stub.MockInvoke("tx2", [][]byte{[]byte("query"), []byte(`{"selector":{"owner":"jerry"}}`)})
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+	require.Equal(t, "marble2,", string(response.Payload))
+}
+
+func TestReadIndexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "indexes")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "indexOwner.json"), []byte(`{"index":{"fields":["docType","owner"]},"ddoc":"indexOwnerDoc","name":"indexOwner","type":"json"}`), 0644)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, "indexSize.json"), []byte(`{"index":{"fields":[{"docType":"desc"},{"size":"desc"}]},"ddoc":"indexSizeDoc","name":"indexSize","type":"json"}`), 0644)
+	require.NoError(t, err)
+
+	indexes, err := richquerytest.ReadIndexes(dir)
+	require.NoError(t, err)
+	require.Equal(t, []richquery.Index{
+		{DesignDoc: "indexOwnerDoc", Name: "indexOwner", Fields: []string{"docType", "owner"}},
+		{DesignDoc: "indexSizeDoc", Name: "indexSize", Fields: []string{"docType", "size"}},
+	}, indexes)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "indexBad.json"), []byte(`{"index":{"fields":[1]},"ddoc":"indexBadDoc","name":"indexBad","type":"json"}`), 0644)
+	require.NoError(t, err)
+	_, err = richquerytest.ReadIndexes(dir)
+	require.EqualError(t, err, "failed to parse index "+filepath.Join(dir, "indexBad.json")+": invalid index field 1")
+}
@@ -3,6 +3,7 @@ module github.com/hyperledger/fabric-samples/chaincode/sacc
 go 1.12
 
 require (
+	github.com/golang/protobuf v1.3.2
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20190823162523-04390e015b85
 	github.com/hyperledger/fabric-protos-go v0.0.0-20190821214336-621b908d5022
 	golang.org/x/net v0.0.0-20190813141303-74dc4d7220e7 // indirect
@@ -7,7 +7,12 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/peer"
@@ -17,6 +22,43 @@ import (
 type SimpleAsset struct {
 }
 
+// The value of an asset is stored under its key, so that it can be read like any other
+// state. Its version and expiry are stored separately, under a composite key
+const metadataIndex = "sacc~metadata"
+
+// defaultPageSize is the number of assets listed by keys if no page size is given
+const defaultPageSize = 100
+
+// Entry is an asset with its version and expiry. The version starts at 1 when the asset
+// is created and is incremented by every update. An asset without expiry never expires
+type Entry struct {
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	Version   uint64     `json:"version"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Page is a page of assets listed by keys. The bookmark is the key to resume the listing
+// from, and is empty on the last page
+type Page struct {
+	Entries  []*Entry `json:"entries"`
+	Bookmark string   `json:"bookmark"`
+}
+
+// HistoryEntry is a past value of an asset
+type HistoryEntry struct {
+	TxID      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     string    `json:"value"`
+	IsDelete  bool      `json:"isDelete"`
+}
+
+// metadata is the part of an entry that is not stored under the key of the asset
+type metadata struct {
+	Version   uint64     `json:"version"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
 // Init is called during chaincode instantiation to initialize any
 // data. Note that chaincode upgrade also calls this function to reset
 // or to migrate data.
@@ -30,7 +72,7 @@ func (t *SimpleAsset) Init(stub shim.ChaincodeStubInterface) peer.Response {
 	// Set up any variables or assets here by calling stub.PutState()
 
 	// We store the key and the value on the ledger
-	err := stub.PutState(args[0], []byte(args[1]))
+	_, err := putEntry(stub, args[0], args[1], 0)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("Failed to create asset: %s", args[0]))
 	}
@@ -38,7 +80,8 @@ func (t *SimpleAsset) Init(stub shim.ChaincodeStubInterface) peer.Response {
 }
 
 // Invoke is called per transaction on the chaincode. Each transaction is
-// either a 'get' or a 'set' on the asset created by Init function. The Set
+// a 'get' or a 'set' on the asset created by Init function, or one of the
+// functions that manage the versions, expiry and history of assets. The Set
 // method may create a new asset by specifying a new key-value pair.
 func (t *SimpleAsset) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 	// Extract the function and args from the transaction proposal
@@ -46,9 +89,20 @@ func (t *SimpleAsset) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 
 	var result string
 	var err error
-	if fn == "set" {
+	switch fn {
+	case "set":
 		result, err = set(stub, args)
-	} else { // assume 'get' even if fn is nil
+	case "del":
+		result, err = del(stub, args)
+	case "cas":
+		result, err = cas(stub, args)
+	case "entry":
+		result, err = entry(stub, args)
+	case "keys":
+		result, err = keys(stub, args)
+	case "history":
+		result, err = history(stub, args)
+	default: // assume 'get' even if fn is nil
 		result, err = get(stub, args)
 	}
 	if err != nil {
@@ -60,13 +114,22 @@ func (t *SimpleAsset) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 }
 
 // Set stores the asset (both key and value) on the ledger. If the key exists,
-// it will override the value with the new one
+// it will override the value with the new one. An optional third argument
+// is the number of seconds after which the asset expires
 func set(stub shim.ChaincodeStubInterface, args []string) (string, error) {
-	if len(args) != 2 {
+	if len(args) != 2 && len(args) != 3 {
 		return "", fmt.Errorf("Incorrect arguments. Expecting a key and a value")
 	}
 
-	err := stub.PutState(args[0], []byte(args[1]))
+	var ttl int64
+	if len(args) == 3 {
+		var err error
+		if ttl, err = parseTTL(args[2]); err != nil {
+			return "", err
+		}
+	}
+
+	_, err := putEntry(stub, args[0], args[1], ttl)
 	if err != nil {
 		return "", fmt.Errorf("Failed to set asset: %s", args[0])
 	}
@@ -79,6 +142,38 @@ func get(stub shim.ChaincodeStubInterface, args []string) (string, error) {
 		return "", fmt.Errorf("Incorrect arguments. Expecting a key")
 	}
 
+	e, err := getEntry(stub, args[0])
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "", fmt.Errorf("Asset not found: %s", args[0])
+	}
+	return e.Value, nil
+}
+
+// Entry returns the asset with its version and expiry as JSON
+func entry(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Incorrect arguments. Expecting a key")
+	}
+
+	e, err := getEntry(stub, args[0])
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "", fmt.Errorf("Asset not found: %s", args[0])
+	}
+	return marshal(e)
+}
+
+// Del removes the asset from the ledger. Expired assets can be removed too
+func del(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Incorrect arguments. Expecting a key")
+	}
+
 	value, err := stub.GetState(args[0])
 	if err != nil {
 		return "", fmt.Errorf("Failed to get asset: %s with error: %s", args[0], err)
@@ -86,7 +181,291 @@ func get(stub shim.ChaincodeStubInterface, args []string) (string, error) {
 	if value == nil {
 		return "", fmt.Errorf("Asset not found: %s", args[0])
 	}
-	return string(value), nil
+
+	metadataKey, err := stub.CreateCompositeKey(metadataIndex, []string{args[0]})
+	if err != nil {
+		return "", err
+	}
+	if err := stub.DelState(args[0]); err != nil {
+		return "", fmt.Errorf("Failed to delete asset: %s", args[0])
+	}
+	if err := stub.DelState(metadataKey); err != nil {
+		return "", fmt.Errorf("Failed to delete asset: %s", args[0])
+	}
+	return "", nil
+}
+
+// Cas sets the asset only if its current value or version is the expected one, and
+// returns the updated asset as JSON. The arguments are the key, "value" or "version",
+// the expected value or version, the new value and an optional expiry in seconds.
+// Version 0 is expected of an asset that does not exist
+func cas(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	if len(args) != 4 && len(args) != 5 {
+		return "", fmt.Errorf("Incorrect arguments. Expecting a key, value or version, the expected value or version and a new value")
+	}
+	key, mode, expected, value := args[0], args[1], args[2], args[3]
+
+	var ttl int64
+	if len(args) == 5 {
+		var err error
+		if ttl, err = parseTTL(args[4]); err != nil {
+			return "", err
+		}
+	}
+
+	current, err := getEntry(stub, key)
+	if err != nil {
+		return "", err
+	}
+
+	switch mode {
+	case "value":
+		if current == nil {
+			return "", fmt.Errorf("Asset not found: %s", key)
+		}
+		if current.Value != expected {
+			return "", fmt.Errorf("Compare-and-set failed: value of %s is %q, expected %q", key, current.Value, expected)
+		}
+	case "version":
+		version, err := strconv.ParseUint(expected, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("Expecting integer value for version, got %q", expected)
+		}
+		var currentVersion uint64
+		if current != nil {
+			currentVersion = current.Version
+		}
+		if currentVersion != version {
+			return "", fmt.Errorf("Compare-and-set failed: version of %s is %d, expected %d", key, currentVersion, version)
+		}
+	default:
+		return "", fmt.Errorf("Incorrect arguments. Expecting value or version, got %q", mode)
+	}
+
+	e, err := putEntry(stub, key, value, ttl)
+	if err != nil {
+		return "", fmt.Errorf("Failed to set asset: %s", key)
+	}
+	return marshal(e)
+}
+
+// Keys lists the assets whose key starts with a prefix, in key order and without the
+// expired assets. The optional arguments are the prefix, the page size and the bookmark
+// returned with the previous page
+func keys(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	if len(args) > 3 {
+		return "", fmt.Errorf("Incorrect arguments. Expecting a prefix, a page size and a bookmark")
+	}
+
+	var prefix, bookmark string
+	pageSize := defaultPageSize
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+	if len(args) > 1 {
+		size, err := strconv.Atoi(args[1])
+		if err != nil || size <= 0 {
+			return "", fmt.Errorf("Expecting positive integer value for page size, got %q", args[1])
+		}
+		pageSize = size
+	}
+	if len(args) > 2 {
+		bookmark = args[2]
+	}
+
+	// As in the shim, an empty prefix starts after the composite keys, which start with 0x00
+	startKey := prefix
+	if startKey == "" {
+		startKey = "\x01"
+	}
+	if bookmark != "" {
+		if len(bookmark) < len(prefix) || bookmark[:len(prefix)] != prefix {
+			return "", fmt.Errorf("Bookmark %s does not start with prefix %s", bookmark, prefix)
+		}
+		startKey = bookmark
+	}
+	endKey := prefix + string(utf8.MaxRune)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return "", err
+	}
+	resultsIterator, err := stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return "", fmt.Errorf("Failed to list assets: %s", err)
+	}
+	defer resultsIterator.Close()
+
+	page := &Page{Entries: []*Entry{}}
+	for resultsIterator.HasNext() {
+		kv, err := resultsIterator.Next()
+		if err != nil {
+			return "", err
+		}
+		e, err := readEntry(stub, kv.Key, kv.Value)
+		if err != nil {
+			return "", err
+		}
+		if e.expired(now) {
+			continue
+		}
+		if len(page.Entries) == pageSize {
+			page.Bookmark = e.Key
+			break
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	return marshal(page)
+}
+
+// History returns the past values of the asset, in the order the ledger returns them
+func history(stub shim.ChaincodeStubInterface, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("Incorrect arguments. Expecting a key")
+	}
+
+	resultsIterator, err := stub.GetHistoryForKey(args[0])
+	if err != nil {
+		return "", fmt.Errorf("Failed to get history of asset: %s with error: %s", args[0], err)
+	}
+	defer resultsIterator.Close()
+
+	entries := []*HistoryEntry{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return "", err
+		}
+		record := &HistoryEntry{
+			TxID:     modification.TxId,
+			Value:    string(modification.Value),
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			record.Timestamp = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC()
+		}
+		entries = append(entries, record)
+	}
+	return marshal(entries)
+}
+
+// expired reports whether the entry has expired at the given time
+func (e *Entry) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// getEntry returns the asset, or nil if it does not exist or has expired
+func getEntry(stub shim.ChaincodeStubInterface, key string) (*Entry, error) {
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get asset: %s with error: %s", key, err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	e, err := readEntry(stub, key, value)
+	if err != nil {
+		return nil, err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	if e.expired(now) {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// readEntry adds the version and expiry to the value of an asset. Assets stored without
+// metadata, such as those of earlier versions of this chaincode, are at version 1
+func readEntry(stub shim.ChaincodeStubInterface, key string, value []byte) (*Entry, error) {
+	metadataKey, err := stub.CreateCompositeKey(metadataIndex, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	metadataJSON, err := stub.GetState(metadataKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get asset: %s with error: %s", key, err)
+	}
+
+	m := &metadata{Version: 1}
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, m); err != nil {
+			return nil, fmt.Errorf("Invalid metadata of asset %s: %s", key, err)
+		}
+	}
+	return &Entry{Key: key, Value: string(value), Version: m.Version, ExpiresAt: m.ExpiresAt}, nil
+}
+
+// putEntry writes the asset with the next version. An asset that has expired is
+// created anew at version 1. A positive ttl sets the expiry to ttl seconds after
+// the transaction timestamp, otherwise the asset does not expire
+func putEntry(stub shim.ChaincodeStubInterface, key string, value string, ttl int64) (*Entry, error) {
+	current, err := getEntry(stub, key)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{Key: key, Value: value, Version: 1}
+	if current != nil {
+		e.Version = current.Version + 1
+	}
+	if ttl > 0 {
+		ts, err := stub.GetTxTimestamp()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get transaction timestamp: %s", err)
+		}
+		if ts.Seconds > math.MaxInt64-ttl {
+			return nil, fmt.Errorf("Expiry of asset %s is out of range", key)
+		}
+		expiresAt := time.Unix(ts.Seconds+ttl, int64(ts.Nanos)).UTC()
+		e.ExpiresAt = &expiresAt
+	}
+
+	metadataJSON, err := json.Marshal(&metadata{Version: e.Version, ExpiresAt: e.ExpiresAt})
+	if err != nil {
+		return nil, err
+	}
+	metadataKey, err := stub.CreateCompositeKey(metadataIndex, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	if err := stub.PutState(key, []byte(value)); err != nil {
+		return nil, err
+	}
+	if err := stub.PutState(metadataKey, metadataJSON); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// parseTTL parses an expiry in seconds, which must be positive
+func parseTTL(value string) (int64, error) {
+	ttl, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("Expecting positive integer value for expiry in seconds, got %q", value)
+	}
+	return ttl, nil
+}
+
+// txTime returns the transaction timestamp, against which expiry is checked so that
+// all peers endorsing the transaction agree on which assets have expired
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Failed to get transaction timestamp: %s", err)
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
+}
+
+func marshal(v interface{}) (string, error) {
+	result, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
 }
 
 // main function starts up the chaincode in the container during instantiate
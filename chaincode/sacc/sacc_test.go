@@ -9,9 +9,12 @@ package main
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 )
 
 func checkInit(t *testing.T, stub *shimtest.MockStub, args [][]byte) {
@@ -172,3 +175,269 @@ func TestSacc_InvokeWithIncorrectArguments(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func invoke(stub *shimtest.MockStub, args ...string) (int32, string, string) {
+	var byteArgs [][]byte
+	for _, arg := range args {
+		byteArgs = append(byteArgs, []byte(arg))
+	}
+	res := stub.MockInvoke("1", byteArgs)
+	return res.Status, res.Message, string(res.Payload)
+}
+
+// invokeAt calls a function of the chaincode in a transaction with the given timestamp
+func invokeAt(stub *shimtest.MockStub, at time.Time, fn func(shim.ChaincodeStubInterface, []string) (string, error), args ...string) (string, error) {
+	stub.MockTransactionStart("1")
+	defer stub.MockTransactionEnd("1")
+	stub.TxTimestamp = &timestamp.Timestamp{Seconds: at.Unix(), Nanos: int32(at.Nanosecond())}
+	return fn(stub, args)
+}
+
+func checkEntry(t *testing.T, stub *shimtest.MockStub, name string, expected string) {
+	status, message, payload := invoke(stub, "entry", name)
+	if status != shim.OK {
+		fmt.Println("Entry", name, "failed", message)
+		t.FailNow()
+	}
+	if payload != expected {
+		fmt.Println("Entry", name, "was", payload, "instead of", expected)
+		t.FailNow()
+	}
+}
+
+func TestSacc_Versions(t *testing.T) {
+	cc := new(SimpleAsset)
+	stub := shimtest.NewMockStub("sacc", cc)
+
+	// Init a=10
+	checkInit(t, stub, [][]byte{[]byte("a"), []byte("10")})
+	checkEntry(t, stub, "a", `{"key":"a","value":"10","version":1}`)
+
+	// Every set increments the version
+	checkInvoke(t, stub, [][]byte{[]byte("set"), []byte("a"), []byte("20")})
+	checkInvoke(t, stub, [][]byte{[]byte("set"), []byte("a"), []byte("30")})
+	checkEntry(t, stub, "a", `{"key":"a","value":"30","version":3}`)
+	checkState(t, stub, "a", "30")
+
+	// Assets stored without metadata are at version 1
+	stub.MockTransactionStart("1")
+	stub.PutState("b", []byte("legacy"))
+	stub.MockTransactionEnd("1")
+	checkEntry(t, stub, "b", `{"key":"b","value":"legacy","version":1}`)
+}
+
+func TestSacc_Delete(t *testing.T) {
+	cc := new(SimpleAsset)
+	stub := shimtest.NewMockStub("sacc", cc)
+
+	// Init a=10
+	checkInit(t, stub, [][]byte{[]byte("a"), []byte("10")})
+	checkInvoke(t, stub, [][]byte{[]byte("set"), []byte("a"), []byte("20")})
+
+	checkInvoke(t, stub, [][]byte{[]byte("del"), []byte("a")})
+	if len(stub.State) != 0 {
+		fmt.Println("State was not empty after delete:", stub.State)
+		t.FailNow()
+	}
+
+	status, message, _ := invoke(stub, "del", "a")
+	if status != shim.ERROR || message != "Asset not found: a" {
+		fmt.Println("Unexpected response to delete of a missing asset:", status, message)
+		t.FailNow()
+	}
+
+	// A deleted asset is created anew
+	checkInvoke(t, stub, [][]byte{[]byte("set"), []byte("a"), []byte("30")})
+	checkEntry(t, stub, "a", `{"key":"a","value":"30","version":1}`)
+}
+
+func TestSacc_CompareAndSet(t *testing.T) {
+	cc := new(SimpleAsset)
+	stub := shimtest.NewMockStub("sacc", cc)
+
+	// Init a=10
+	checkInit(t, stub, [][]byte{[]byte("a"), []byte("10")})
+
+	tests := []struct {
+		args     []string
+		status   int32
+		response string
+	}{
+		{[]string{"a", "value", "10", "20"}, shim.OK, `{"key":"a","value":"20","version":2}`},
+		{[]string{"a", "value", "10", "30"}, shim.ERROR, `Compare-and-set failed: value of a is "20", expected "10"`},
+		{[]string{"a", "version", "2", "30"}, shim.OK, `{"key":"a","value":"30","version":3}`},
+		{[]string{"a", "version", "2", "40"}, shim.ERROR, "Compare-and-set failed: version of a is 3, expected 2"},
+		{[]string{"a", "version", "0", "40"}, shim.ERROR, "Compare-and-set failed: version of a is 3, expected 0"},
+		{[]string{"b", "version", "0", "1"}, shim.OK, `{"key":"b","value":"1","version":1}`},
+		{[]string{"c", "value", "", "1"}, shim.ERROR, "Asset not found: c"},
+		{[]string{"a", "version", "latest", "40"}, shim.ERROR, `Expecting integer value for version, got "latest"`},
+		{[]string{"a", "hash", "x", "40"}, shim.ERROR, `Incorrect arguments. Expecting value or version, got "hash"`},
+		{[]string{"a", "version", "3"}, shim.ERROR, "Incorrect arguments. Expecting a key, value or version, the expected value or version and a new value"},
+	}
+	for _, test := range tests {
+		status, message, payload := invoke(stub, append([]string{"cas"}, test.args...)...)
+		response := payload
+		if status != shim.OK {
+			response = message
+		}
+		if status != test.status || response != test.response {
+			fmt.Println("Unexpected response to cas", test.args, ":", status, response)
+			t.FailNow()
+		}
+	}
+
+	checkQuery(t, stub, "a", "30")
+}
+
+func TestSacc_Keys(t *testing.T) {
+	cc := new(SimpleAsset)
+	stub := shimtest.NewMockStub("sacc", cc)
+
+	// Init a=10
+	checkInit(t, stub, [][]byte{[]byte("a"), []byte("10")})
+	for _, key := range []string{"car1", "car2", "car3", "cart", "bike1"} {
+		checkInvoke(t, stub, [][]byte{[]byte("set"), []byte(key), []byte(key)})
+	}
+
+	// The metadata of the assets is not listed
+	_, _, payload := invoke(stub, "keys")
+	expected := `{"entries":[{"key":"a","value":"10","version":1},{"key":"bike1","value":"bike1","version":1},` +
+		`{"key":"car1","value":"car1","version":1},{"key":"car2","value":"car2","version":1},` +
+		`{"key":"car3","value":"car3","version":1},{"key":"cart","value":"cart","version":1}],"bookmark":""}`
+	if payload != expected {
+		fmt.Println("Unexpected listing of all keys:", payload)
+		t.FailNow()
+	}
+
+	_, _, payload = invoke(stub, "keys", "car", "2")
+	expected = `{"entries":[{"key":"car1","value":"car1","version":1},{"key":"car2","value":"car2","version":1}],"bookmark":"car3"}`
+	if payload != expected {
+		fmt.Println("Unexpected first page:", payload)
+		t.FailNow()
+	}
+	_, _, payload = invoke(stub, "keys", "car", "2", "car3")
+	expected = `{"entries":[{"key":"car3","value":"car3","version":1},{"key":"cart","value":"cart","version":1}],"bookmark":""}`
+	if payload != expected {
+		fmt.Println("Unexpected last page:", payload)
+		t.FailNow()
+	}
+
+	_, _, payload = invoke(stub, "keys", "truck")
+	if payload != `{"entries":[],"bookmark":""}` {
+		fmt.Println("Unexpected listing of missing prefix:", payload)
+		t.FailNow()
+	}
+
+	status, message, _ := invoke(stub, "keys", "car", "0")
+	if status != shim.ERROR || message != `Expecting positive integer value for page size, got "0"` {
+		fmt.Println("Unexpected response to page size 0:", status, message)
+		t.FailNow()
+	}
+	status, message, _ = invoke(stub, "keys", "car", "2", "bike1")
+	if status != shim.ERROR || message != "Bookmark bike1 does not start with prefix car" {
+		fmt.Println("Unexpected response to foreign bookmark:", status, message)
+		t.FailNow()
+	}
+}
+
+func TestSacc_Expiry(t *testing.T) {
+	cc := new(SimpleAsset)
+	stub := shimtest.NewMockStub("sacc", cc)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := invokeAt(stub, start, set, "a", "10", "60"); err != nil {
+		fmt.Println("Set with expiry failed", err)
+		t.FailNow()
+	}
+	if _, err := invokeAt(stub, start, set, "b", "20"); err != nil {
+		fmt.Println("Set failed", err)
+		t.FailNow()
+	}
+
+	payload, err := invokeAt(stub, start.Add(59*time.Second), entry, "a")
+	if err != nil || payload != `{"key":"a","value":"10","version":1,"expiresAt":"2020-01-01T00:01:00Z"}` {
+		fmt.Println("Unexpected entry before expiry:", payload, err)
+		t.FailNow()
+	}
+
+	// At the expiry, the asset is no longer found or listed
+	expiry := start.Add(time.Minute)
+	if _, err := invokeAt(stub, expiry, get, "a"); err == nil || err.Error() != "Asset not found: a" {
+		fmt.Println("Expired asset was found:", err)
+		t.FailNow()
+	}
+	payload, err = invokeAt(stub, expiry, keys)
+	if err != nil || payload != `{"entries":[{"key":"b","value":"20","version":1}],"bookmark":""}` {
+		fmt.Println("Unexpected listing after expiry:", payload, err)
+		t.FailNow()
+	}
+
+	// An expired asset is created anew, and set without expiry does not expire
+	payload, err = invokeAt(stub, expiry, cas, "a", "version", "0", "30")
+	if err != nil || payload != `{"key":"a","value":"30","version":1}` {
+		fmt.Println("Unexpected cas of expired asset:", payload, err)
+		t.FailNow()
+	}
+	if _, err := invokeAt(stub, expiry.Add(24*time.Hour), get, "a"); err != nil {
+		fmt.Println("Asset without expiry was not found:", err)
+		t.FailNow()
+	}
+
+	if _, err := invokeAt(stub, start, set, "a", "10", "-1"); err == nil || err.Error() != `Expecting positive integer value for expiry in seconds, got "-1"` {
+		fmt.Println("Negative expiry accepted:", err)
+		t.FailNow()
+	}
+}
+
+// historyStub returns a fixed history, which the mock stub does not implement
+type historyStub struct {
+	*shimtest.MockStub
+	modifications []*queryresult.KeyModification
+}
+
+func (s *historyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{modifications: s.modifications}, nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (i *historyIterator) HasNext() bool {
+	return len(i.modifications) > 0
+}
+
+func (i *historyIterator) Next() (*queryresult.KeyModification, error) {
+	modification := i.modifications[0]
+	i.modifications = i.modifications[1:]
+	return modification, nil
+}
+
+func (i *historyIterator) Close() error {
+	return nil
+}
+
+func TestSacc_History(t *testing.T) {
+	stub := &historyStub{
+		MockStub: shimtest.NewMockStub("sacc", new(SimpleAsset)),
+		modifications: []*queryresult.KeyModification{
+			{TxId: "tx1", Value: []byte("10"), Timestamp: &timestamp.Timestamp{Seconds: 1577836800}},
+			{TxId: "tx2", Value: []byte("20"), Timestamp: &timestamp.Timestamp{Seconds: 1577836860}},
+			{TxId: "tx3", IsDelete: true, Timestamp: &timestamp.Timestamp{Seconds: 1577836920}},
+		},
+	}
+
+	payload, err := history(stub, []string{"a"})
+	expected := `[{"txId":"tx1","timestamp":"2020-01-01T00:00:00Z","value":"10","isDelete":false},` +
+		`{"txId":"tx2","timestamp":"2020-01-01T00:01:00Z","value":"20","isDelete":false},` +
+		`{"txId":"tx3","timestamp":"2020-01-01T00:02:00Z","value":"","isDelete":true}]`
+	if err != nil || payload != expected {
+		fmt.Println("Unexpected history:", payload, err)
+		t.FailNow()
+	}
+
+	if _, err := history(stub, []string{"a", "b"}); err == nil || err.Error() != "Incorrect arguments. Expecting a key" {
+		fmt.Println("Unexpected response to history with incorrect arguments:", err)
+		t.FailNow()
+	}
+}
@@ -25,3 +25,12 @@ type StateInterface interface {
 	GetSplitKey() []string
 	Serialize() ([]byte, error)
 }
+
+// VersionedStateInterface interface states implement
+// to have their version checked when updated. The
+// version must be included when the state is serialized
+type VersionedStateInterface interface {
+	StateInterface
+	GetVersion() uint
+	SetVersion(uint)
+}
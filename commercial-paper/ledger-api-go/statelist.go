@@ -0,0 +1,533 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package ledgerapi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// StateListInterface functions that a state list
+// should have
+type StateListInterface interface {
+	AddState(StateInterface) error
+	GetState(string, StateInterface) error
+	UpdateState(StateInterface) error
+	DeleteState(StateInterface) error
+	Exists(string) (bool, error)
+	GetStatesByPartialKey([]string, int32, string) ([]StateInterface, string, error)
+	GetStatesByRange([]string, string, string) ([]StateInterface, error)
+	GetStatesByIndex(string, []string, int32, string) ([]StateInterface, string, error)
+	QueryStates(string, int32, string) ([]StateInterface, string, error)
+	GetStateHistory(string) ([]StateHistory, error)
+}
+
+// StateNotFoundError returned when getting a
+// state which does not exist in the world state
+type StateNotFoundError struct {
+	Key string
+}
+
+func (e *StateNotFoundError) Error() string {
+	return fmt.Sprintf("No state found for %s", e.Key)
+}
+
+// StateExistsError returned when adding a state
+// which already exists in the world state
+type StateExistsError struct {
+	Key string
+}
+
+func (e *StateExistsError) Error() string {
+	return fmt.Sprintf("State already exists for %s", e.Key)
+}
+
+// VersionMismatchError returned when updating a
+// versioned state whose version does not match
+// the version in the world state
+type VersionMismatchError struct {
+	Key      string
+	Expected uint
+	Actual   uint
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("Version mismatch for %s. Expected version %d but world state has version %d", e.Key, e.Expected, e.Actual)
+}
+
+// Index a secondary index maintained by a state list.
+// Values returns the values a state is indexed under
+type Index struct {
+	Name   string
+	Values func(StateInterface) []string
+}
+
+// StateHistory a single modification of a state
+// as recorded in the ledger history. State is nil
+// when the modification deleted the state
+type StateHistory struct {
+	TxID      string
+	Timestamp time.Time
+	IsDelete  bool
+	State     StateInterface
+}
+
+// StateList useful for managing putting data in and out
+// of the ledger. Implementation of StateListInterface.
+// NewState creates the empty states that are filled by
+// Deserialize when returning states from queries, and
+// when checking versions and indexes on update
+type StateList struct {
+	Ctx         contractapi.TransactionContextInterface
+	Name        string
+	Deserialize func([]byte, StateInterface) error
+	NewState    func() StateInterface
+	Indexes     []Index
+}
+
+// AddState puts a new state into world state. Errors
+// if a state with the same key already exists. The version
+// of versioned states is set to 1
+func (sl *StateList) AddState(state StateInterface) error {
+	key := sl.ledgerKey(state.GetSplitKey())
+	existing, err := sl.Ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return err
+	} else if existing != nil {
+		return &StateExistsError{Key: MakeKey(state.GetSplitKey()...)}
+	}
+
+	if versioned, ok := state.(VersionedStateInterface); ok {
+		versioned.SetVersion(1)
+	}
+
+	err = sl.putState(key, state)
+
+	if err != nil {
+		return err
+	}
+
+	return sl.putIndexes(state)
+}
+
+// GetState returns state from world state. Unmarshalls the JSON
+// into passed state. Key is the split key value used in Add/Update
+// joined using a colon
+func (sl *StateList) GetState(key string, state StateInterface) error {
+	data, err := sl.Ctx.GetStub().GetState(sl.ledgerKey(SplitKey(key)))
+
+	if err != nil {
+		return err
+	} else if data == nil {
+		return &StateNotFoundError{Key: key}
+	}
+
+	return sl.Deserialize(data, state)
+}
+
+// UpdateState puts an existing state into world state. Errors
+// if the state does not exist. The version of versioned states
+// must match the version in the world state and is incremented
+func (sl *StateList) UpdateState(state StateInterface) error {
+	key := sl.ledgerKey(state.GetSplitKey())
+	previous, err := sl.getPrevious(key, state)
+
+	if err != nil {
+		return err
+	}
+
+	if versioned, ok := state.(VersionedStateInterface); ok {
+		version := previous.(VersionedStateInterface).GetVersion()
+
+		if versioned.GetVersion() != version {
+			return &VersionMismatchError{Key: MakeKey(state.GetSplitKey()...), Expected: versioned.GetVersion(), Actual: version}
+		}
+
+		versioned.SetVersion(version + 1)
+	}
+
+	err = sl.delIndexes(previous)
+
+	if err != nil {
+		return err
+	}
+
+	err = sl.putState(key, state)
+
+	if err != nil {
+		return err
+	}
+
+	return sl.putIndexes(state)
+}
+
+// DeleteState removes state and its index entries from world
+// state. Errors if the state does not exist
+func (sl *StateList) DeleteState(state StateInterface) error {
+	key := sl.ledgerKey(state.GetSplitKey())
+	previous, err := sl.getPrevious(key, state)
+
+	if err != nil {
+		return err
+	}
+
+	err = sl.delIndexes(previous)
+
+	if err != nil {
+		return err
+	}
+
+	return sl.Ctx.GetStub().DelState(key)
+}
+
+// Exists returns whether a state exists in world state. Key
+// is the split key value used in Add/Update joined using a colon
+func (sl *StateList) Exists(key string) (bool, error) {
+	data, err := sl.Ctx.GetStub().GetState(sl.ledgerKey(SplitKey(key)))
+
+	if err != nil {
+		return false, err
+	}
+
+	return data != nil, nil
+}
+
+// GetStatesByPartialKey returns a page of the states in the list whose
+// split key starts with the passed key parts, and the bookmark to pass
+// to retrieve the next page. A page size of 0 returns all states
+// without paging, which unlike paging is allowed in transactions
+// that update the world state
+func (sl *StateList) GetStatesByPartialKey(keyParts []string, pageSize int32, bookmark string) ([]StateInterface, string, error) {
+	iterator, nextBookmark, err := sl.iteratePartialKey(sl.Name, keyParts, pageSize, bookmark)
+
+	if err != nil {
+		return nil, "", err
+	}
+	defer iterator.Close()
+
+	states, err := sl.collect(iterator, func(kv *queryresult.KV) (bool, error) { return true, nil })
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return states, nextBookmark, nil
+}
+
+// GetStatesByRange returns the states in the list whose split key starts
+// with the passed key parts and whose next key part is in the range from
+// startKey (inclusive) to endKey (exclusive). An empty startKey or endKey
+// leaves the range open on that side
+func (sl *StateList) GetStatesByRange(keyParts []string, startKey string, endKey string) ([]StateInterface, error) {
+	iterator, err := sl.Ctx.GetStub().GetStateByPartialCompositeKey(sl.Name, keyParts)
+
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	return sl.collect(iterator, func(kv *queryresult.KV) (bool, error) {
+		_, attributes, err := sl.Ctx.GetStub().SplitCompositeKey(kv.GetKey())
+
+		if err != nil {
+			return false, err
+		}
+
+		if len(attributes) <= len(keyParts) {
+			return false, nil
+		}
+
+		keyPart := attributes[len(keyParts)]
+
+		return keyPart >= startKey && (endKey == "" || keyPart < endKey), nil
+	})
+}
+
+// GetStatesByIndex returns a page of the states in the list indexed under
+// values starting with the passed values in the named index, and the bookmark
+// to pass to retrieve the next page. A page size of 0 returns all states
+// without paging
+func (sl *StateList) GetStatesByIndex(name string, values []string, pageSize int32, bookmark string) ([]StateInterface, string, error) {
+	index, err := sl.getIndex(name)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	iterator, nextBookmark, err := sl.iteratePartialKey(sl.indexName(index), values, pageSize, bookmark)
+
+	if err != nil {
+		return nil, "", err
+	}
+	defer iterator.Close()
+
+	states := []StateInterface{}
+
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		data, err := sl.Ctx.GetStub().GetState(string(kv.GetValue()))
+
+		if err != nil {
+			return nil, "", err
+		} else if data == nil {
+			return nil, "", fmt.Errorf("Index %s references missing state", name)
+		}
+
+		state, err := sl.deserializeNew(data)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nextBookmark, nil
+}
+
+// QueryStates returns a page of the states matching the passed rich
+// query, and the bookmark to pass to retrieve the next page. A page
+// size of 0 returns all states without paging. Requires a state
+// database supporting rich queries e.g. CouchDB
+func (sl *StateList) QueryStates(query string, pageSize int32, bookmark string) ([]StateInterface, string, error) {
+	var iterator shim.StateQueryIteratorInterface
+	var metadata *peer.QueryResponseMetadata
+	var err error
+
+	if pageSize > 0 {
+		iterator, metadata, err = sl.Ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	} else {
+		iterator, err = sl.Ctx.GetStub().GetQueryResult(query)
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+	defer iterator.Close()
+
+	states, err := sl.collect(iterator, func(kv *queryresult.KV) (bool, error) { return true, nil })
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return states, metadata.GetBookmark(), nil
+}
+
+// GetStateHistory returns the modifications made to the state
+// with the passed key in the order the ledger returns them. Key
+// is the split key value used in Add/Update joined using a colon
+func (sl *StateList) GetStateHistory(key string) ([]StateHistory, error) {
+	iterator, err := sl.Ctx.GetStub().GetHistoryForKey(sl.ledgerKey(SplitKey(key)))
+
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	history := []StateHistory{}
+
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry := StateHistory{TxID: modification.GetTxId(), IsDelete: modification.GetIsDelete()}
+
+		if ts := modification.GetTimestamp(); ts != nil {
+			entry.Timestamp = time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC()
+		}
+
+		if !entry.IsDelete {
+			entry.State, err = sl.deserializeNew(modification.GetValue())
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+func (sl *StateList) ledgerKey(splitKey []string) string {
+	key, _ := sl.Ctx.GetStub().CreateCompositeKey(sl.Name, splitKey)
+
+	return key
+}
+
+func (sl *StateList) indexName(index Index) string {
+	return sl.Name + "~" + index.Name
+}
+
+func (sl *StateList) getIndex(name string) (Index, error) {
+	for _, index := range sl.Indexes {
+		if index.Name == name {
+			return index, nil
+		}
+	}
+
+	return Index{}, fmt.Errorf("No index %s for state list %s", name, sl.Name)
+}
+
+func (sl *StateList) putState(key string, state StateInterface) error {
+	data, err := state.Serialize()
+
+	if err != nil {
+		return err
+	}
+
+	return sl.Ctx.GetStub().PutState(key, data)
+}
+
+// getPrevious returns the state stored under the key. Only reads
+// the stored state into a new state when versions or indexes need
+// to be checked, otherwise returns the passed state
+func (sl *StateList) getPrevious(key string, state StateInterface) (StateInterface, error) {
+	data, err := sl.Ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, err
+	} else if data == nil {
+		return nil, &StateNotFoundError{Key: MakeKey(state.GetSplitKey()...)}
+	}
+
+	if _, ok := state.(VersionedStateInterface); !ok && len(sl.Indexes) == 0 {
+		return state, nil
+	}
+
+	return sl.deserializeNew(data)
+}
+
+func (sl *StateList) indexKeys(state StateInterface) ([]string, error) {
+	keys := []string{}
+
+	for _, index := range sl.Indexes {
+		attributes := append(append([]string{}, index.Values(state)...), state.GetSplitKey()...)
+		key, err := sl.Ctx.GetStub().CreateCompositeKey(sl.indexName(index), attributes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (sl *StateList) putIndexes(state StateInterface) error {
+	keys, err := sl.indexKeys(state)
+
+	if err != nil {
+		return err
+	}
+
+	stateKey := sl.ledgerKey(state.GetSplitKey())
+
+	for _, key := range keys {
+		err = sl.Ctx.GetStub().PutState(key, []byte(stateKey))
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sl *StateList) delIndexes(state StateInterface) error {
+	keys, err := sl.indexKeys(state)
+
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err = sl.Ctx.GetStub().DelState(key)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// iteratePartialKey iterates the composite keys of the object type
+// starting with the passed attributes. Pages unless the page size is 0
+func (sl *StateList) iteratePartialKey(objectType string, attributes []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, string, error) {
+	if pageSize <= 0 {
+		iterator, err := sl.Ctx.GetStub().GetStateByPartialCompositeKey(objectType, attributes)
+
+		return iterator, "", err
+	}
+
+	iterator, metadata, err := sl.Ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, attributes, pageSize, bookmark)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return iterator, metadata.GetBookmark(), nil
+}
+
+// collect deserializes the values of the iterator
+// for which include returns true into new states
+func (sl *StateList) collect(iterator shim.StateQueryIteratorInterface, include func(*queryresult.KV) (bool, error)) ([]StateInterface, error) {
+	states := []StateInterface{}
+
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := include(kv)
+
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+
+		state, err := sl.deserializeNew(kv.GetValue())
+
+		if err != nil {
+			return nil, err
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+func (sl *StateList) deserializeNew(data []byte) (StateInterface, error) {
+	state := sl.NewState()
+	err := sl.Deserialize(data, state)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
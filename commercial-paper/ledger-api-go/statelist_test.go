@@ -0,0 +1,181 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package ledgerapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+)
+
+type testState struct {
+	ID      string `json:"id"`
+	Colour  string `json:"colour"`
+	Version uint   `json:"version"`
+}
+
+func (ts *testState) GetSplitKey() []string {
+	return []string{"owner", ts.ID}
+}
+
+func (ts *testState) Serialize() ([]byte, error) {
+	return json.Marshal(ts)
+}
+
+func (ts *testState) GetVersion() uint {
+	return ts.Version
+}
+
+func (ts *testState) SetVersion(version uint) {
+	ts.Version = version
+}
+
+func newTestStateList() (*StateList, *shimtest.MockStub) {
+	stub := shimtest.NewMockStub("test", nil)
+	stub.MockTransactionStart("tx1")
+
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+
+	sl := &StateList{
+		Ctx:  ctx,
+		Name: "org.test.statelist",
+		Deserialize: func(data []byte, state StateInterface) error {
+			return json.Unmarshal(data, state)
+		},
+		NewState: func() StateInterface {
+			return new(testState)
+		},
+		Indexes: []Index{
+			{Name: "colour", Values: func(state StateInterface) []string {
+				return []string{state.(*testState).Colour}
+			}},
+		},
+	}
+
+	return sl, stub
+}
+
+func TestSplitKey(t *testing.T) {
+	assert.Equal(t, []string{"someString"}, SplitKey("someString"), "should return single element array for key with no colon")
+	assert.Equal(t, []string{"some", "string"}, SplitKey("some:string"), "should split key on colon")
+}
+
+func TestMakeKey(t *testing.T) {
+	assert.Equal(t, "some:string", MakeKey("some", "string"), "should join key parts using colon")
+}
+
+func TestAddState(t *testing.T) {
+	sl, _ := newTestStateList()
+
+	state := &testState{ID: "1", Colour: "blue"}
+	err := sl.AddState(state)
+	assert.Nil(t, err, "should add state")
+	assert.Equal(t, uint(1), state.Version, "should set version of new state")
+
+	exists, err := sl.Exists("owner:1")
+	assert.Nil(t, err, "should check existence")
+	assert.True(t, exists, "should find added state")
+
+	err = sl.AddState(&testState{ID: "1", Colour: "red"})
+	assert.EqualError(t, err, "State already exists for owner:1", "should error when state exists")
+	assert.IsType(t, new(StateExistsError), err, "should return StateExistsError")
+}
+
+func TestGetState(t *testing.T) {
+	sl, _ := newTestStateList()
+	sl.AddState(&testState{ID: "1", Colour: "blue"})
+
+	state := new(testState)
+	err := sl.GetState("owner:1", state)
+	assert.Nil(t, err, "should get state")
+	assert.Equal(t, &testState{ID: "1", Colour: "blue", Version: 1}, state, "should deserialize stored state")
+
+	err = sl.GetState("owner:2", new(testState))
+	assert.EqualError(t, err, "No state found for owner:2", "should error when state missing")
+	assert.IsType(t, new(StateNotFoundError), err, "should return StateNotFoundError")
+}
+
+func TestUpdateState(t *testing.T) {
+	sl, _ := newTestStateList()
+
+	err := sl.UpdateState(&testState{ID: "1", Colour: "blue"})
+	assert.IsType(t, new(StateNotFoundError), err, "should error when state missing")
+
+	state := &testState{ID: "1", Colour: "blue"}
+	sl.AddState(state)
+
+	state.Colour = "red"
+	err = sl.UpdateState(state)
+	assert.Nil(t, err, "should update state")
+	assert.Equal(t, uint(2), state.Version, "should increment version")
+
+	err = sl.UpdateState(&testState{ID: "1", Colour: "green", Version: 1})
+	assert.EqualError(t, err, "Version mismatch for owner:1. Expected version 1 but world state has version 2", "should error on stale version")
+
+	blue, _, _ := sl.GetStatesByIndex("colour", []string{"blue"}, 0, "")
+	assert.Len(t, blue, 0, "should remove old index entry")
+	red, _, _ := sl.GetStatesByIndex("colour", []string{"red"}, 0, "")
+	assert.Equal(t, []StateInterface{&testState{ID: "1", Colour: "red", Version: 2}}, red, "should add new index entry")
+}
+
+func TestDeleteState(t *testing.T) {
+	sl, _ := newTestStateList()
+
+	err := sl.DeleteState(&testState{ID: "1"})
+	assert.IsType(t, new(StateNotFoundError), err, "should error when state missing")
+
+	sl.AddState(&testState{ID: "1", Colour: "blue"})
+	err = sl.DeleteState(&testState{ID: "1"})
+	assert.Nil(t, err, "should delete state")
+
+	exists, _ := sl.Exists("owner:1")
+	assert.False(t, exists, "should not find deleted state")
+	blue, _, _ := sl.GetStatesByIndex("colour", []string{"blue"}, 0, "")
+	assert.Len(t, blue, 0, "should remove index entry")
+}
+
+func TestGetStatesByPartialKey(t *testing.T) {
+	sl, _ := newTestStateList()
+	sl.AddState(&testState{ID: "1", Colour: "blue"})
+	sl.AddState(&testState{ID: "2", Colour: "red"})
+
+	states, _, err := sl.GetStatesByPartialKey([]string{"owner"}, 0, "")
+	assert.Nil(t, err, "should get states")
+	assert.Len(t, states, 2, "should return states with key prefix")
+
+	states, _, _ = sl.GetStatesByPartialKey([]string{"someoneelse"}, 0, "")
+	assert.Len(t, states, 0, "should not return states with other prefix")
+}
+
+func TestGetStatesByRange(t *testing.T) {
+	sl, _ := newTestStateList()
+	sl.AddState(&testState{ID: "a", Colour: "blue"})
+	sl.AddState(&testState{ID: "b", Colour: "blue"})
+	sl.AddState(&testState{ID: "c", Colour: "blue"})
+
+	states, err := sl.GetStatesByRange([]string{"owner"}, "b", "c")
+	assert.Nil(t, err, "should get states")
+	assert.Equal(t, []StateInterface{&testState{ID: "b", Colour: "blue", Version: 1}}, states, "should return states in range")
+
+	states, _ = sl.GetStatesByRange([]string{"owner"}, "b", "")
+	assert.Len(t, states, 2, "should leave range open when end key empty")
+}
+
+func TestGetStatesByIndex(t *testing.T) {
+	sl, _ := newTestStateList()
+	sl.AddState(&testState{ID: "1", Colour: "blue"})
+	sl.AddState(&testState{ID: "2", Colour: "red"})
+
+	states, _, err := sl.GetStatesByIndex("colour", []string{"red"}, 0, "")
+	assert.Nil(t, err, "should get states")
+	assert.Equal(t, []StateInterface{&testState{ID: "2", Colour: "red", Version: 1}}, states, "should return indexed states")
+
+	_, _, err = sl.GetStatesByIndex("size", []string{"big"}, 0, "")
+	assert.EqualError(t, err, "No index size for state list org.test.statelist", "should error for unknown index")
+}
@@ -0,0 +1,60 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package commercialpaper
+
+import (
+	"testing"
+
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateHoldingKey(t *testing.T) {
+	assert.Equal(t, ledgerapi.MakeKey("someissuer", "somepaper", "someowner"), CreateHoldingKey("someissuer", "somepaper", "someowner"), "should return key comprised of passed values")
+}
+
+func TestHoldingGetSplitKey(t *testing.T) {
+	h := new(Holding)
+	h.Issuer = "someissuer"
+	h.PaperNumber = "somepaper"
+	h.Owner = "someowner"
+
+	assert.Equal(t, []string{"someissuer", "somepaper", "someowner"}, h.GetSplitKey(), "should return issuer, paper number and owner as split key")
+}
+
+func TestHoldingSerialize(t *testing.T) {
+	h := new(Holding)
+	h.Issuer = "someissuer"
+	h.PaperNumber = "somepaper"
+	h.Owner = "someowner"
+	h.OwnerMSP = "someownermsp"
+	h.FaceValue = 1000
+
+	bytes, err := h.Serialize()
+	assert.Nil(t, err, "should not error on serialize")
+	assert.Equal(t, `{"issuer":"someissuer","paperNumber":"somepaper","owner":"someowner","ownerMSP":"someownermsp","faceValue":1000,"redeemed":false,"class":"org.papernet.commercialpaperholding","key":"someissuer:somepaper:someowner"}`, string(bytes), "should return JSON formatted value")
+
+	h.PurchaseRequest = &PurchaseRequest{NewOwner: "someotherowner", NewOwnerMSP: "someotherownermsp", FaceValue: 400, Price: 390, PurchaseDateTime: "sometime"}
+	bytes, err = h.Serialize()
+	assert.Nil(t, err, "should not error on serialize")
+	assert.Equal(t, `{"issuer":"someissuer","paperNumber":"somepaper","owner":"someowner","ownerMSP":"someownermsp","faceValue":1000,"redeemed":false,"purchaseRequest":{"newOwner":"someotherowner","newOwnerMSP":"someotherownermsp","faceValue":400,"price":390,"purchaseDateTime":"sometime"},"class":"org.papernet.commercialpaperholding","key":"someissuer:somepaper:someowner"}`, string(bytes), "should include buy request")
+}
+
+func TestDeserializeHolding(t *testing.T) {
+	var h *Holding
+	var err error
+
+	goodJSON := `{"issuer":"someissuer","paperNumber":"somepaper","owner":"someowner","ownerMSP":"someownermsp","faceValue":1000,"redeemed":true,"class":"org.papernet.commercialpaperholding","key":"someissuer:somepaper:someowner"}`
+	expectedHolding := &Holding{Issuer: "someissuer", PaperNumber: "somepaper", Owner: "someowner", OwnerMSP: "someownermsp", FaceValue: 1000, Redeemed: true}
+	h = new(Holding)
+	err = DeserializeHolding([]byte(goodJSON), h)
+	assert.Nil(t, err, "should not return error for deserialize")
+	assert.Equal(t, expectedHolding, h, "should create expected holding")
+
+	badJSON := `{"issuer":"someissuer","paperNumber":"somepaper","owner":"someowner","ownerMSP":"someownermsp","faceValue":"NaN"}`
+	h = new(Holding)
+	err = DeserializeHolding([]byte(badJSON), h)
+	assert.EqualError(t, err, "Error deserializing holding. json: cannot unmarshal string into Go struct field Holding.faceValue of type int", "should return error for bad data")
+}
@@ -0,0 +1,133 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package commercialpaper
+
+import (
+	"errors"
+	"testing"
+
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAddHolding(t *testing.T) {
+	holding := new(Holding)
+
+	list := new(holdingList)
+	msl := new(MockStateList)
+	msl.On("AddState", holding).Return(errors.New("Called add state correctly"))
+	list.stateList = msl
+
+	err := list.AddHolding(holding)
+	assert.EqualError(t, err, "Called add state correctly", "should call state list add state with holding")
+}
+
+func TestGetHolding(t *testing.T) {
+	var h *Holding
+	var err error
+
+	list := new(holdingList)
+	msl := new(MockStateList)
+	msl.On("GetState", CreateHoldingKey("someissuer", "somepaper", "someowner"), mock.MatchedBy(func(state ledgerapi.StateInterface) bool { _, ok := state.(*Holding); return ok })).Return(nil)
+	msl.On("GetState", CreateHoldingKey("someotherissuer", "someotherpaper", "someowner"), mock.MatchedBy(func(state ledgerapi.StateInterface) bool { _, ok := state.(*Holding); return ok })).Return(errors.New("GetState error"))
+	list.stateList = msl
+
+	h, err = list.GetHolding("someissuer", "somepaper", "someowner")
+	assert.Nil(t, err, "should not error when get state on state list does not error")
+	assert.Equal(t, h.PaperNumber, "somepaper", "should use state list GetState to fill holding")
+
+	h, err = list.GetHolding("someotherissuer", "someotherpaper", "someowner")
+	assert.EqualError(t, err, "GetState error", "should return error when state list get state errors")
+	assert.Nil(t, h, "should not return holding on error")
+}
+
+func TestUpdateHolding(t *testing.T) {
+	holding := new(Holding)
+
+	list := new(holdingList)
+	msl := new(MockStateList)
+	msl.On("UpdateState", holding).Return(errors.New("Called update state correctly"))
+	list.stateList = msl
+
+	err := list.UpdateHolding(holding)
+	assert.EqualError(t, err, "Called update state correctly", "should call state list update state with holding")
+}
+
+func TestDeleteHolding(t *testing.T) {
+	holding := new(Holding)
+
+	list := new(holdingList)
+	msl := new(MockStateList)
+	msl.On("DeleteState", holding).Return(errors.New("Called delete state correctly"))
+	list.stateList = msl
+
+	err := list.DeleteHolding(holding)
+	assert.EqualError(t, err, "Called delete state correctly", "should call state list delete state with holding")
+}
+
+func TestGetHoldingsByPaper(t *testing.T) {
+	var page *HoldingPage
+	var err error
+
+	holding := new(Holding)
+	var noStates []ledgerapi.StateInterface
+
+	list := new(holdingList)
+	msl := new(MockStateList)
+	msl.On("GetStatesByPartialKey", []string{"someissuer", "somepaper"}, int32(10), "").Return([]ledgerapi.StateInterface{holding}, "somebookmark", nil)
+	msl.On("GetStatesByPartialKey", []string{"someotherissuer", "someotherpaper"}, int32(10), "").Return(noStates, "", errors.New("GetStatesByPartialKey error"))
+	list.stateList = msl
+
+	page, err = list.GetHoldingsByPaper("someissuer", "somepaper", 10, "")
+	assert.Nil(t, err, "should not error when state list does not error")
+	assert.Equal(t, &HoldingPage{Holdings: []*Holding{holding}, Bookmark: "somebookmark"}, page, "should return holdings and bookmark from state list")
+
+	page, err = list.GetHoldingsByPaper("someotherissuer", "someotherpaper", 10, "")
+	assert.EqualError(t, err, "GetStatesByPartialKey error", "should return error when state list errors")
+	assert.Nil(t, page, "should not return page on error")
+}
+
+func TestGetHoldingsByOwner(t *testing.T) {
+	var page *HoldingPage
+	var err error
+
+	holding := new(Holding)
+	var noStates []ledgerapi.StateInterface
+
+	list := new(holdingList)
+	msl := new(MockStateList)
+	msl.On("GetStatesByIndex", "owner", []string{"someowner"}, int32(10), "").Return([]ledgerapi.StateInterface{holding}, "somebookmark", nil)
+	msl.On("GetStatesByIndex", "owner", []string{"someotherowner"}, int32(10), "").Return(noStates, "", errors.New("GetStatesByIndex error"))
+	list.stateList = msl
+
+	page, err = list.GetHoldingsByOwner("someowner", 10, "")
+	assert.Nil(t, err, "should not error when state list does not error")
+	assert.Equal(t, &HoldingPage{Holdings: []*Holding{holding}, Bookmark: "somebookmark"}, page, "should return holdings and bookmark from state list")
+
+	page, err = list.GetHoldingsByOwner("someotherowner", 10, "")
+	assert.EqualError(t, err, "GetStatesByIndex error", "should return error when state list errors")
+	assert.Nil(t, page, "should not return page on error")
+}
+
+func TestNewHoldingList(t *testing.T) {
+	ctx := new(TransactionContext)
+	list := newHoldingList(ctx)
+	stateList, ok := list.stateList.(*ledgerapi.StateList)
+
+	assert.True(t, ok, "should make statelist of type ledgerapi.StateList")
+	assert.Equal(t, ctx, stateList.Ctx, "should set the context to passed context")
+	assert.Equal(t, "org.papernet.commercialpaperholdinglist", stateList.Name, "should set the name for the list")
+
+	expectedErr := DeserializeHolding([]byte("bad json"), new(Holding))
+	err := stateList.Deserialize([]byte("bad json"), new(Holding))
+	assert.EqualError(t, err, expectedErr.Error(), "should call DeserializeHolding when stateList.Deserialize called")
+
+	assert.Equal(t, new(Holding), stateList.NewState(), "should create empty holdings for the list")
+
+	assert.Len(t, stateList.Indexes, 1, "should declare one index")
+	assert.Equal(t, "owner", stateList.Indexes[0].Name, "should index holdings by owner")
+	assert.Equal(t, []string{"someowner"}, stateList.Indexes[0].Values(&Holding{Owner: "someowner"}), "should index holdings under their owner")
+}
@@ -7,8 +7,9 @@ package commercialpaper
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
-	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/organization/digibank/contract-go/ledger-api"
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
 )
 
 // State enum for commercial paper state property
@@ -33,6 +34,35 @@ func (state State) String() string {
 	return names[state-1]
 }
 
+// ParseState returns the state with the passed name
+func ParseState(name string) (State, error) {
+	for state := ISSUED; state <= REDEEMED; state++ {
+		if state.String() == name {
+			return state, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown commercial paper state %s", name)
+}
+
+const paperClass = "org.papernet.commercialpaper"
+
+// dateTimeLayouts formats accepted for the date
+// times of a commercial paper
+var dateTimeLayouts = []string{"2006-01-02", time.RFC3339}
+
+// ParseDateTime parses a commercial paper date time
+// given either as a date or in RFC3339 format
+func ParseDateTime(value string) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid date time %s. Expected format YYYY-MM-DD or RFC3339", value)
+}
+
 // CreateCommercialPaperKey creates a key for commercial papers
 func CreateCommercialPaperKey(issuer string, paperNumber string) string {
 	return ledgerapi.MakeKey(issuer, paperNumber)
@@ -47,17 +77,20 @@ type jsonCommercialPaper struct {
 	Key   string `json:"key"`
 }
 
-// CommercialPaper defines a commercial paper
+// CommercialPaper defines a commercial paper. The face value
+// is split between the holdings of the owners of the paper
 type CommercialPaper struct {
-	PaperNumber      string `json:"paperNumber"`
-	Issuer           string `json:"issuer"`
-	IssueDateTime    string `json:"issueDateTime"`
-	FaceValue        int    `json:"faceValue"`
-	MaturityDateTime string `json:"maturityDateTime"`
-	Owner            string `json:"owner"`
-	state            State  `metadata:"currentState"`
-	class            string `metadata:"class"`
-	key              string `metadata:"key"`
+	PaperNumber       string `json:"paperNumber"`
+	Issuer            string `json:"issuer"`
+	IssuerMSP         string `json:"issuerMSP"`
+	IssueDateTime     string `json:"issueDateTime"`
+	FaceValue         int    `json:"faceValue"`
+	MaturityDateTime  string `json:"maturityDateTime"`
+	RedeemedFaceValue int    `json:"redeemedFaceValue"`
+	Version           uint   `json:"version"`
+	state             State  `metadata:"currentState"`
+	class             string `metadata:"class"`
+	key               string `metadata:"key"`
 }
 
 // UnmarshalJSON special handler for managing JSON marshalling
@@ -77,7 +110,7 @@ func (cp *CommercialPaper) UnmarshalJSON(data []byte) error {
 
 // MarshalJSON special handler for managing JSON marshalling
 func (cp CommercialPaper) MarshalJSON() ([]byte, error) {
-	jcp := jsonCommercialPaper{commercialPaperAlias: (*commercialPaperAlias)(&cp), State: cp.state, Class: "org.papernet.commercialpaper", Key: ledgerapi.MakeKey(cp.Issuer, cp.PaperNumber)}
+	jcp := jsonCommercialPaper{commercialPaperAlias: (*commercialPaperAlias)(&cp), State: cp.state, Class: paperClass, Key: ledgerapi.MakeKey(cp.Issuer, cp.PaperNumber)}
 
 	return json.Marshal(&jcp)
 }
@@ -117,6 +150,34 @@ func (cp *CommercialPaper) IsRedeemed() bool {
 	return cp.state == REDEEMED
 }
 
+// IsMatured returns true if the paper has reached
+// maturity at the passed date time
+func (cp *CommercialPaper) IsMatured(dateTime string) (bool, error) {
+	maturity, err := ParseDateTime(cp.MaturityDateTime)
+
+	if err != nil {
+		return false, err
+	}
+
+	t, err := ParseDateTime(dateTime)
+
+	if err != nil {
+		return false, err
+	}
+
+	return !t.Before(maturity), nil
+}
+
+// GetVersion returns the version of the paper in the world state
+func (cp *CommercialPaper) GetVersion() uint {
+	return cp.Version
+}
+
+// SetVersion sets the version of the paper in the world state
+func (cp *CommercialPaper) SetVersion(version uint) {
+	cp.Version = version
+}
+
 // GetSplitKey returns values which should be used to form key
 func (cp *CommercialPaper) GetSplitKey() []string {
 	return []string{cp.Issuer, cp.PaperNumber}
@@ -7,7 +7,7 @@ package commercialpaper
 import (
 	"testing"
 
-	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/organization/digibank/contract-go/ledger-api"
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,3 +29,22 @@ func TestGetPaperList(t *testing.T) {
 	tc.paperList = expectedPaperList
 	assert.Equal(t, expectedPaperList, tc.GetPaperList(), "should return set paper list when already set")
 }
+
+func TestGetHoldingList(t *testing.T) {
+	var tc *TransactionContext
+	var expectedHoldingList *holdingList
+
+	tc = new(TransactionContext)
+	expectedHoldingList = newHoldingList(tc)
+	actualList := tc.GetHoldingList().(*holdingList)
+	assert.Equal(t, expectedHoldingList.stateList.(*ledgerapi.StateList).Name, actualList.stateList.(*ledgerapi.StateList).Name, "should configure holding list when one not already configured")
+
+	tc = new(TransactionContext)
+	expectedHoldingList = new(holdingList)
+	expectedStateList := new(ledgerapi.StateList)
+	expectedStateList.Ctx = tc
+	expectedStateList.Name = "existing holding list"
+	expectedHoldingList.stateList = expectedStateList
+	tc.holdingList = expectedHoldingList
+	assert.Equal(t, expectedHoldingList, tc.GetHoldingList(), "should return set holding list when already set")
+}
@@ -6,8 +6,10 @@ package commercialpaper
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
 )
 
 // Contract chaincode that defines
@@ -22,12 +24,48 @@ func (c *Contract) Instantiate() {
 	fmt.Println("Instantiated")
 }
 
-// Issue creates a new commercial paper and stores it in the world state
+// Issue creates a new commercial paper and stores it in the world state together with
+// a holding of the full face value for the issuer. The calling organisation becomes the
+// organisation of the issuer
 func (c *Contract) Issue(ctx TransactionContextInterface, issuer string, paperNumber string, issueDateTime string, maturityDateTime string, faceValue int) (*CommercialPaper, error) {
-	paper := CommercialPaper{PaperNumber: paperNumber, Issuer: issuer, IssueDateTime: issueDateTime, FaceValue: faceValue, MaturityDateTime: maturityDateTime, Owner: issuer}
+	issued, err := ParseDateTime(issueDateTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matures, err := ParseDateTime(maturityDateTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !matures.After(issued) {
+		return nil, fmt.Errorf("Paper %s:%s must mature after it is issued", issuer, paperNumber)
+	}
+
+	if faceValue <= 0 {
+		return nil, fmt.Errorf("Paper %s:%s must have a positive face value", issuer, paperNumber)
+	}
+
+	mspID, err := getClientMSPID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	paper := CommercialPaper{PaperNumber: paperNumber, Issuer: issuer, IssuerMSP: mspID, IssueDateTime: issueDateTime, FaceValue: faceValue, MaturityDateTime: maturityDateTime}
 	paper.SetIssued()
 
-	err := ctx.GetPaperList().AddPaper(&paper)
+	err = ctx.GetPaperList().AddPaper(&paper)
+
+	if err != nil {
+		return nil, err
+	}
+
+	holding := Holding{Issuer: issuer, PaperNumber: paperNumber, Owner: issuer, OwnerMSP: mspID, FaceValue: faceValue}
+
+	err = ctx.GetHoldingList().AddHolding(&holding)
 
 	if err != nil {
 		return nil, err
@@ -36,16 +74,99 @@ func (c *Contract) Issue(ctx TransactionContextInterface, issuer string, paperNu
 	return &paper, nil
 }
 
-// Buy updates a commercial paper to be in trading status and sets the new owner
-func (c *Contract) Buy(ctx TransactionContextInterface, issuer string, paperNumber string, currentOwner string, newOwner string, price int, purchaseDateTime string) (*CommercialPaper, error) {
+// BuyRequest records a request by the calling organisation for the new owner to buy
+// a portion of the face value held by the current owner of a commercial paper. The
+// portion only changes owner once the organisation of the current owner approves
+// the request using Transfer. The purchase date time is recorded with the request,
+// but whether the paper has matured is decided by the transaction timestamp
+func (c *Contract) BuyRequest(ctx TransactionContextInterface, issuer string, paperNumber string, currentOwner string, newOwner string, faceValue int, price int, purchaseDateTime string) (*Holding, error) {
 	paper, err := ctx.GetPaperList().GetPaper(issuer, paperNumber)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if paper.Owner != currentOwner {
-		return nil, fmt.Errorf("Paper %s:%s is not owned by %s", issuer, paperNumber, currentOwner)
+	if !paper.IsIssued() && !paper.IsTrading() {
+		return nil, fmt.Errorf("Paper %s:%s is not trading. Current state = %s", issuer, paperNumber, paper.GetState())
+	}
+
+	_, err = ParseDateTime(purchaseDateTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matured, err := isMatured(ctx, paper)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if matured {
+		return nil, fmt.Errorf("Paper %s:%s can not be bought after maturity %s", issuer, paperNumber, paper.MaturityDateTime)
+	}
+
+	holding, err := getHolding(ctx, issuer, paperNumber, currentOwner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if newOwner == currentOwner {
+		return nil, fmt.Errorf("Paper %s:%s is already owned by %s", issuer, paperNumber, newOwner)
+	}
+
+	if faceValue <= 0 || faceValue > holding.FaceValue {
+		return nil, fmt.Errorf("Paper %s:%s held by %s has face value %d. Can not buy %d", issuer, paperNumber, currentOwner, holding.FaceValue, faceValue)
+	}
+
+	if holding.PurchaseRequest != nil {
+		return nil, fmt.Errorf("Paper %s:%s held by %s already has a pending buy request from %s", issuer, paperNumber, currentOwner, holding.PurchaseRequest.NewOwner)
+	}
+
+	mspID, err := getClientMSPID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	holding.PurchaseRequest = &PurchaseRequest{NewOwner: newOwner, NewOwnerMSP: mspID, FaceValue: faceValue, Price: price, PurchaseDateTime: purchaseDateTime}
+
+	err = ctx.GetHoldingList().UpdateHolding(holding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return holding, nil
+}
+
+// Transfer approves the pending buy request of the new owner for a portion of the face
+// value held by the current owner of a commercial paper. Moves the portion to the holding
+// of the new owner and the paper to trading status. Must be called by the organisation of
+// the current owner. Returns the holding of the new owner
+func (c *Contract) Transfer(ctx TransactionContextInterface, issuer string, paperNumber string, currentOwner string, newOwner string) (*Holding, error) {
+	paper, err := ctx.GetPaperList().GetPaper(issuer, paperNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	holding, err := getPendingHolding(ctx, issuer, paperNumber, currentOwner, newOwner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The paper may have matured since the buy request was made
+	matured, err := isMatured(ctx, paper)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if matured {
+		return nil, fmt.Errorf("Paper %s:%s can not be transferred after maturity %s", issuer, paperNumber, paper.MaturityDateTime)
 	}
 
 	if paper.IsIssued() {
@@ -56,7 +177,45 @@ func (c *Contract) Buy(ctx TransactionContextInterface, issuer string, paperNumb
 		return nil, fmt.Errorf("Paper %s:%s is not trading. Current state = %s", issuer, paperNumber, paper.GetState())
 	}
 
-	paper.Owner = newOwner
+	request := holding.PurchaseRequest
+
+	newHolding, err := ctx.GetHoldingList().GetHolding(issuer, paperNumber, newOwner)
+	isNewHolding := false
+
+	if _, ok := err.(*ledgerapi.StateNotFoundError); ok {
+		newHolding = &Holding{Issuer: issuer, PaperNumber: paperNumber, Owner: newOwner, OwnerMSP: request.NewOwnerMSP}
+		isNewHolding = true
+	} else if err != nil {
+		return nil, err
+	} else if newHolding.OwnerMSP != request.NewOwnerMSP {
+		return nil, fmt.Errorf("Paper %s:%s held by %s is not owned by organisation %s", issuer, paperNumber, newOwner, request.NewOwnerMSP)
+	} else if newHolding.Redeemed {
+		return nil, fmt.Errorf("Paper %s:%s held by %s is already redeemed", issuer, paperNumber, newOwner)
+	}
+
+	newHolding.FaceValue += request.FaceValue
+	holding.FaceValue -= request.FaceValue
+	holding.PurchaseRequest = nil
+
+	if holding.FaceValue == 0 {
+		err = ctx.GetHoldingList().DeleteHolding(holding)
+	} else {
+		err = ctx.GetHoldingList().UpdateHolding(holding)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isNewHolding {
+		err = ctx.GetHoldingList().AddHolding(newHolding)
+	} else {
+		err = ctx.GetHoldingList().UpdateHolding(newHolding)
+	}
+
+	if err != nil {
+		return nil, err
+	}
 
 	err = ctx.GetPaperList().UpdatePaper(paper)
 
@@ -64,27 +223,88 @@ func (c *Contract) Buy(ctx TransactionContextInterface, issuer string, paperNumb
 		return nil, err
 	}
 
-	return paper, nil
+	return newHolding, nil
 }
 
-// Redeem updates a commercial paper status to be redeemed
-func (c *Contract) Redeem(ctx TransactionContextInterface, issuer string, paperNumber string, redeemingOwner string, redeenDateTime string) (*CommercialPaper, error) {
-	paper, err := ctx.GetPaperList().GetPaper(issuer, paperNumber)
+// RejectBuyRequest removes the pending buy request of the new owner for a portion of the
+// face value held by the current owner of a commercial paper. Must be called by the
+// organisation of the current owner
+func (c *Contract) RejectBuyRequest(ctx TransactionContextInterface, issuer string, paperNumber string, currentOwner string, newOwner string) (*Holding, error) {
+	holding, err := getPendingHolding(ctx, issuer, paperNumber, currentOwner, newOwner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	holding.PurchaseRequest = nil
+
+	err = ctx.GetHoldingList().UpdateHolding(holding)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if paper.Owner != redeemingOwner {
-		return nil, fmt.Errorf("Paper %s:%s is not owned by %s", issuer, paperNumber, redeemingOwner)
+	return holding, nil
+}
+
+// Redeem redeems the holding of the redeeming owner in a commercial paper, paying out
+// the face value of the holding. The paper is redeemed once all of its face value has
+// been redeemed. Must be called by the organisation of the redeeming owner on or after
+// the maturity date time, according to the transaction timestamp. The redeem date time
+// is only validated, and kept for existing clients
+func (c *Contract) Redeem(ctx TransactionContextInterface, issuer string, paperNumber string, redeemingOwner string, redeemDateTime string) (*Holding, error) {
+	paper, err := ctx.GetPaperList().GetPaper(issuer, paperNumber)
+
+	if err != nil {
+		return nil, err
 	}
 
 	if paper.IsRedeemed() {
 		return nil, fmt.Errorf("Paper %s:%s is already redeemed", issuer, paperNumber)
 	}
 
-	paper.Owner = paper.Issuer
-	paper.SetRedeemed()
+	holding, err := getHolding(ctx, issuer, paperNumber, redeemingOwner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkOwnerMSP(ctx, holding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ParseDateTime(redeemDateTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	matured, err := isMatured(ctx, paper)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !matured {
+		return nil, fmt.Errorf("Paper %s:%s can not be redeemed before maturity %s", issuer, paperNumber, paper.MaturityDateTime)
+	}
+
+	holding.Redeemed = true
+	holding.PurchaseRequest = nil
+
+	err = ctx.GetHoldingList().UpdateHolding(holding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	paper.RedeemedFaceValue += holding.FaceValue
+
+	if paper.RedeemedFaceValue >= paper.FaceValue {
+		paper.SetRedeemed()
+	}
 
 	err = ctx.GetPaperList().UpdatePaper(paper)
 
@@ -92,5 +312,119 @@ func (c *Contract) Redeem(ctx TransactionContextInterface, issuer string, paperN
 		return nil, err
 	}
 
-	return paper, nil
+	return holding, nil
+}
+
+// getHolding returns the holding of the owner in a paper. A redeemed holding has
+// been paid out, so it can neither be sold nor redeemed again
+func getHolding(ctx TransactionContextInterface, issuer string, paperNumber string, owner string) (*Holding, error) {
+	holding, err := ctx.GetHoldingList().GetHolding(issuer, paperNumber, owner)
+
+	if _, ok := err.(*ledgerapi.StateNotFoundError); ok {
+		return nil, fmt.Errorf("Paper %s:%s is not owned by %s", issuer, paperNumber, owner)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if holding.Redeemed {
+		return nil, fmt.Errorf("Paper %s:%s held by %s is already redeemed", issuer, paperNumber, owner)
+	}
+
+	return holding, nil
+}
+
+// getPendingHolding returns the holding of the current owner in a paper with a pending
+// buy request from the new owner after checking the client belongs to the organisation
+// of the current owner
+func getPendingHolding(ctx TransactionContextInterface, issuer string, paperNumber string, currentOwner string, newOwner string) (*Holding, error) {
+	holding, err := getHolding(ctx, issuer, paperNumber, currentOwner)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkOwnerMSP(ctx, holding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if holding.PurchaseRequest == nil || holding.PurchaseRequest.NewOwner != newOwner {
+		return nil, fmt.Errorf("Paper %s:%s held by %s has no pending buy request from %s", issuer, paperNumber, currentOwner, newOwner)
+	}
+
+	return holding, nil
+}
+
+// isMatured returns true if the paper has reached its maturity date time at the
+// transaction timestamp. The timestamp comes from the transaction proposal, so every
+// endorser checks maturity against the same time, which the caller can not choose
+// as it can the date times passed to the contract
+func isMatured(ctx TransactionContextInterface, paper *CommercialPaper) (bool, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to get transaction timestamp. %s", err.Error())
+	}
+
+	txTime := time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)).UTC()
+
+	return paper.IsMatured(txTime.Format(time.RFC3339Nano))
+}
+
+func getClientMSPID(ctx TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to get client MSP ID. %s", err.Error())
+	}
+
+	return mspID, nil
+}
+
+// checkOwnerMSP errors unless the client belongs to
+// the organisation of the owner of the holding
+func checkOwnerMSP(ctx TransactionContextInterface, holding *Holding) error {
+	mspID, err := getClientMSPID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if holding.OwnerMSP != mspID {
+		return fmt.Errorf("Paper %s:%s held by %s is not owned by organisation %s", holding.Issuer, holding.PaperNumber, holding.Owner, mspID)
+	}
+
+	return nil
+}
+
+// QueryPapersByIssuer returns a page of the commercial papers issued by the issuer
+func (c *Contract) QueryPapersByIssuer(ctx TransactionContextInterface, issuer string, pageSize int32, bookmark string) (*PaperPage, error) {
+	return ctx.GetPaperList().GetPapersByIssuer(issuer, pageSize, bookmark)
+}
+
+// QueryHoldingsByOwner returns a page of the holdings of the owner in commercial papers
+func (c *Contract) QueryHoldingsByOwner(ctx TransactionContextInterface, owner string, pageSize int32, bookmark string) (*HoldingPage, error) {
+	return ctx.GetHoldingList().GetHoldingsByOwner(owner, pageSize, bookmark)
+}
+
+// QueryPaperHoldings returns a page of the holdings in a commercial paper
+func (c *Contract) QueryPaperHoldings(ctx TransactionContextInterface, issuer string, paperNumber string, pageSize int32, bookmark string) (*HoldingPage, error) {
+	return ctx.GetHoldingList().GetHoldingsByPaper(issuer, paperNumber, pageSize, bookmark)
+}
+
+// QueryPapersByState returns a page of the commercial papers in the state (ISSUED, TRADING or REDEEMED)
+func (c *Contract) QueryPapersByState(ctx TransactionContextInterface, state string, pageSize int32, bookmark string) (*PaperPage, error) {
+	paperState, err := ParseState(state)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.GetPaperList().GetPapersByState(paperState, pageSize, bookmark)
+}
+
+// QueryPaperHistory returns the history of changes made to a commercial paper
+func (c *Contract) QueryPaperHistory(ctx TransactionContextInterface, issuer string, paperNumber string) ([]PaperHistory, error) {
+	return ctx.GetPaperList().GetPaperHistory(issuer, paperNumber)
 }
@@ -4,7 +4,31 @@
 
 package commercialpaper
 
-import ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/organization/digibank/contract-go/ledger-api"
+import (
+	"time"
+
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
+)
+
+const paperStateIndex = "currentState"
+
+// PaperPage a page of commercial papers returned
+// by a query and the bookmark to pass to retrieve
+// the next page
+type PaperPage struct {
+	Papers   []*CommercialPaper `json:"papers"`
+	Bookmark string             `json:"bookmark"`
+}
+
+// PaperHistory a single modification of a
+// commercial paper. Paper is nil when the
+// modification deleted the paper
+type PaperHistory struct {
+	TxID      string           `json:"txId"`
+	Timestamp string           `json:"timestamp"`
+	IsDelete  bool             `json:"isDelete"`
+	Paper     *CommercialPaper `json:"paper,omitempty" metadata:",optional"`
+}
 
 // ListInterface defines functionality needed
 // to interact with the world state on behalf
@@ -13,6 +37,9 @@ type ListInterface interface {
 	AddPaper(*CommercialPaper) error
 	GetPaper(string, string) (*CommercialPaper, error)
 	UpdatePaper(*CommercialPaper) error
+	GetPapersByIssuer(string, int32, string) (*PaperPage, error)
+	GetPapersByState(State, int32, string) (*PaperPage, error)
+	GetPaperHistory(string, string) ([]PaperHistory, error)
 }
 
 type list struct {
@@ -39,6 +66,60 @@ func (cpl *list) UpdatePaper(paper *CommercialPaper) error {
 	return cpl.stateList.UpdateState(paper)
 }
 
+func (cpl *list) GetPapersByIssuer(issuer string, pageSize int32, bookmark string) (*PaperPage, error) {
+	states, nextBookmark, err := cpl.stateList.GetStatesByPartialKey([]string{issuer}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaperPage(states, nextBookmark), nil
+}
+
+func (cpl *list) GetPapersByState(state State, pageSize int32, bookmark string) (*PaperPage, error) {
+	states, nextBookmark, err := cpl.stateList.GetStatesByIndex(paperStateIndex, []string{state.String()}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newPaperPage(states, nextBookmark), nil
+}
+
+func (cpl *list) GetPaperHistory(issuer string, paperNumber string) ([]PaperHistory, error) {
+	states, err := cpl.stateList.GetStateHistory(CreateCommercialPaperKey(issuer, paperNumber))
+
+	if err != nil {
+		return nil, err
+	}
+
+	history := []PaperHistory{}
+
+	for _, state := range states {
+		entry := PaperHistory{TxID: state.TxID, Timestamp: state.Timestamp.Format(time.RFC3339Nano), IsDelete: state.IsDelete}
+
+		if state.State != nil {
+			entry.Paper = state.State.(*CommercialPaper)
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+func newPaperPage(states []ledgerapi.StateInterface, bookmark string) *PaperPage {
+	page := new(PaperPage)
+	page.Papers = []*CommercialPaper{}
+	page.Bookmark = bookmark
+
+	for _, state := range states {
+		page.Papers = append(page.Papers, state.(*CommercialPaper))
+	}
+
+	return page
+}
+
 // NewList create a new list from context
 func newList(ctx TransactionContextInterface) *list {
 	stateList := new(ledgerapi.StateList)
@@ -47,6 +128,14 @@ func newList(ctx TransactionContextInterface) *list {
 	stateList.Deserialize = func(bytes []byte, state ledgerapi.StateInterface) error {
 		return Deserialize(bytes, state.(*CommercialPaper))
 	}
+	stateList.NewState = func() ledgerapi.StateInterface {
+		return new(CommercialPaper)
+	}
+	stateList.Indexes = []ledgerapi.Index{
+		{Name: paperStateIndex, Values: func(state ledgerapi.StateInterface) []string {
+			return []string{state.(*CommercialPaper).GetState().String()}
+		}},
+	}
 
 	list := new(list)
 	list.stateList = stateList
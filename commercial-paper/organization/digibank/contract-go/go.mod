@@ -4,10 +4,15 @@ go 1.13
 
 require (
 	github.com/go-openapi/jsonreference v0.19.3 // indirect
+	github.com/golang/protobuf v1.3.2
+	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200424173110-d7076418f212
 	github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go v0.0.0
 	github.com/mailru/easyjson v0.7.0 // indirect
 	github.com/stretchr/testify v1.5.1
 	golang.org/x/net v0.0.0-20191028085509-fe3aa8a45271 // indirect
 	google.golang.org/genproto v0.0.0-20191028173616-919d9bdd9fe6 // indirect
 	google.golang.org/grpc v1.24.0 // indirect
 )
+
+replace github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go => ../../../ledger-api-go
@@ -0,0 +1,80 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package commercialpaper
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
+)
+
+const holdingClass = "org.papernet.commercialpaperholding"
+
+// CreateHoldingKey creates a key for holdings of commercial papers
+func CreateHoldingKey(issuer string, paperNumber string, owner string) string {
+	return ledgerapi.MakeKey(issuer, paperNumber, owner)
+}
+
+// Used for adding class and key to the holding in world state
+type holdingAlias Holding
+type jsonHolding struct {
+	*holdingAlias
+	Class string `json:"class"`
+	Key   string `json:"key"`
+}
+
+// PurchaseRequest defines a request to buy a portion of the
+// face value of a holding which awaits approval by the
+// organisation of the owner of the holding
+type PurchaseRequest struct {
+	NewOwner         string `json:"newOwner"`
+	NewOwnerMSP      string `json:"newOwnerMSP"`
+	FaceValue        int    `json:"faceValue"`
+	Price            int    `json:"price"`
+	PurchaseDateTime string `json:"purchaseDateTime"`
+}
+
+// Holding defines the portion of the face value of
+// a commercial paper held by an owner
+type Holding struct {
+	Issuer          string           `json:"issuer"`
+	PaperNumber     string           `json:"paperNumber"`
+	Owner           string           `json:"owner"`
+	OwnerMSP        string           `json:"ownerMSP"`
+	FaceValue       int              `json:"faceValue"`
+	Redeemed        bool             `json:"redeemed"`
+	PurchaseRequest *PurchaseRequest `json:"purchaseRequest,omitempty" metadata:",optional"`
+	class           string           `metadata:"class"`
+	key             string           `metadata:"key"`
+}
+
+// MarshalJSON special handler for managing JSON marshalling
+func (h Holding) MarshalJSON() ([]byte, error) {
+	jh := jsonHolding{holdingAlias: (*holdingAlias)(&h), Class: holdingClass, Key: CreateHoldingKey(h.Issuer, h.PaperNumber, h.Owner)}
+
+	return json.Marshal(&jh)
+}
+
+// GetSplitKey returns values which should be used to form key
+func (h *Holding) GetSplitKey() []string {
+	return []string{h.Issuer, h.PaperNumber, h.Owner}
+}
+
+// Serialize formats the holding as JSON bytes
+func (h *Holding) Serialize() ([]byte, error) {
+	return json.Marshal(h)
+}
+
+// DeserializeHolding formats the holding from JSON bytes
+func DeserializeHolding(bytes []byte, h *Holding) error {
+	err := json.Unmarshal(bytes, h)
+
+	if err != nil {
+		return fmt.Errorf("Error deserializing holding. %s", err.Error())
+	}
+
+	return nil
+}
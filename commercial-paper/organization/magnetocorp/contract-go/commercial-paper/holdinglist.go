@@ -0,0 +1,113 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package commercialpaper
+
+import (
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
+)
+
+const holdingOwnerIndex = "owner"
+
+// HoldingPage a page of holdings returned by
+// a query and the bookmark to pass to retrieve
+// the next page
+type HoldingPage struct {
+	Holdings []*Holding `json:"holdings"`
+	Bookmark string     `json:"bookmark"`
+}
+
+// HoldingListInterface defines functionality needed
+// to interact with the world state on behalf
+// of the holdings of a commercial paper
+type HoldingListInterface interface {
+	AddHolding(*Holding) error
+	GetHolding(string, string, string) (*Holding, error)
+	UpdateHolding(*Holding) error
+	DeleteHolding(*Holding) error
+	GetHoldingsByPaper(string, string, int32, string) (*HoldingPage, error)
+	GetHoldingsByOwner(string, int32, string) (*HoldingPage, error)
+}
+
+type holdingList struct {
+	stateList ledgerapi.StateListInterface
+}
+
+func (hl *holdingList) AddHolding(holding *Holding) error {
+	return hl.stateList.AddState(holding)
+}
+
+func (hl *holdingList) GetHolding(issuer string, paperNumber string, owner string) (*Holding, error) {
+	h := new(Holding)
+
+	err := hl.stateList.GetState(CreateHoldingKey(issuer, paperNumber, owner), h)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (hl *holdingList) UpdateHolding(holding *Holding) error {
+	return hl.stateList.UpdateState(holding)
+}
+
+func (hl *holdingList) DeleteHolding(holding *Holding) error {
+	return hl.stateList.DeleteState(holding)
+}
+
+func (hl *holdingList) GetHoldingsByPaper(issuer string, paperNumber string, pageSize int32, bookmark string) (*HoldingPage, error) {
+	states, nextBookmark, err := hl.stateList.GetStatesByPartialKey([]string{issuer, paperNumber}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newHoldingPage(states, nextBookmark), nil
+}
+
+func (hl *holdingList) GetHoldingsByOwner(owner string, pageSize int32, bookmark string) (*HoldingPage, error) {
+	states, nextBookmark, err := hl.stateList.GetStatesByIndex(holdingOwnerIndex, []string{owner}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newHoldingPage(states, nextBookmark), nil
+}
+
+func newHoldingPage(states []ledgerapi.StateInterface, bookmark string) *HoldingPage {
+	page := new(HoldingPage)
+	page.Holdings = []*Holding{}
+	page.Bookmark = bookmark
+
+	for _, state := range states {
+		page.Holdings = append(page.Holdings, state.(*Holding))
+	}
+
+	return page
+}
+
+func newHoldingList(ctx TransactionContextInterface) *holdingList {
+	stateList := new(ledgerapi.StateList)
+	stateList.Ctx = ctx
+	stateList.Name = "org.papernet.commercialpaperholdinglist"
+	stateList.Deserialize = func(bytes []byte, state ledgerapi.StateInterface) error {
+		return DeserializeHolding(bytes, state.(*Holding))
+	}
+	stateList.NewState = func() ledgerapi.StateInterface {
+		return new(Holding)
+	}
+	stateList.Indexes = []ledgerapi.Index{
+		{Name: holdingOwnerIndex, Values: func(state ledgerapi.StateInterface) []string {
+			return []string{state.(*Holding).Owner}
+		}},
+	}
+
+	list := new(holdingList)
+	list.stateList = stateList
+
+	return list
+}
@@ -6,8 +6,9 @@ package commercialpaper
 
 import (
 	"testing"
+	"time"
 
-	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/organization/magnetocorp/contract-go/ledger-api"
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,6 +19,42 @@ func TestString(t *testing.T) {
 	assert.Equal(t, "UNKNOWN", State(REDEEMED+1).String(), "should return unknown when not one of constants")
 }
 
+func TestParseState(t *testing.T) {
+	var state State
+	var err error
+
+	state, err = ParseState("ISSUED")
+	assert.Nil(t, err, "should not error for issued")
+	assert.Equal(t, ISSUED, state, "should return issued")
+
+	state, err = ParseState("TRADING")
+	assert.Nil(t, err, "should not error for trading")
+	assert.Equal(t, TRADING, state, "should return trading")
+
+	state, err = ParseState("REDEEMED")
+	assert.Nil(t, err, "should not error for redeemed")
+	assert.Equal(t, REDEEMED, state, "should return redeemed")
+
+	_, err = ParseState("UNKNOWN")
+	assert.EqualError(t, err, "Unknown commercial paper state UNKNOWN", "should error when not one of constants")
+}
+
+func TestParseDateTime(t *testing.T) {
+	var dateTime time.Time
+	var err error
+
+	dateTime, err = ParseDateTime("2020-11-30")
+	assert.Nil(t, err, "should not error for date")
+	assert.Equal(t, time.Date(2020, 11, 30, 0, 0, 0, 0, time.UTC), dateTime, "should parse date")
+
+	dateTime, err = ParseDateTime("2020-11-30T10:00:00Z")
+	assert.Nil(t, err, "should not error for RFC3339 date time")
+	assert.Equal(t, time.Date(2020, 11, 30, 10, 0, 0, 0, time.UTC), dateTime, "should parse RFC3339 date time")
+
+	_, err = ParseDateTime("sometime")
+	assert.EqualError(t, err, "Invalid date time sometime. Expected format YYYY-MM-DD or RFC3339", "should error for unknown format")
+}
+
 func TestCreateCommercialPaperKey(t *testing.T) {
 	assert.Equal(t, ledgerapi.MakeKey("someissuer", "somepaper"), CreateCommercialPaperKey("someissuer", "somepaper"), "should return key comprised of passed values")
 }
@@ -77,6 +114,37 @@ func TestIsRedeemed(t *testing.T) {
 	assert.False(t, cp.IsRedeemed(), "should be false when status not set to redeemed")
 }
 
+func TestIsMatured(t *testing.T) {
+	var matured bool
+	var err error
+
+	cp := new(CommercialPaper)
+	cp.MaturityDateTime = "2020-11-30"
+
+	matured, err = cp.IsMatured("2020-11-29T23:59:59Z")
+	assert.Nil(t, err, "should not error for valid date times")
+	assert.False(t, matured, "should be false before maturity")
+
+	matured, err = cp.IsMatured("2020-11-30")
+	assert.Nil(t, err, "should not error for valid date times")
+	assert.True(t, matured, "should be true on maturity")
+
+	_, err = cp.IsMatured("sometime")
+	assert.EqualError(t, err, "Invalid date time sometime. Expected format YYYY-MM-DD or RFC3339", "should error for invalid date time")
+
+	cp.MaturityDateTime = "somelatertime"
+	_, err = cp.IsMatured("2020-11-30")
+	assert.EqualError(t, err, "Invalid date time somelatertime. Expected format YYYY-MM-DD or RFC3339", "should error for invalid maturity date time")
+}
+
+func TestVersion(t *testing.T) {
+	cp := new(CommercialPaper)
+	cp.SetVersion(2)
+
+	assert.Equal(t, uint(2), cp.Version, "should set version")
+	assert.Equal(t, uint(2), cp.GetVersion(), "should return version")
+}
+
 func TestGetSplitKey(t *testing.T) {
 	cp := new(CommercialPaper)
 	cp.PaperNumber = "somepaper"
@@ -89,36 +157,40 @@ func TestSerialize(t *testing.T) {
 	cp := new(CommercialPaper)
 	cp.PaperNumber = "somepaper"
 	cp.Issuer = "someissuer"
+	cp.IssuerMSP = "someissuermsp"
 	cp.IssueDateTime = "sometime"
 	cp.FaceValue = 1000
 	cp.MaturityDateTime = "somelatertime"
-	cp.Owner = "someowner"
+	cp.RedeemedFaceValue = 400
+	cp.Version = 3
 	cp.state = TRADING
 
 	bytes, err := cp.Serialize()
 	assert.Nil(t, err, "should not error on serialize")
-	assert.Equal(t, `{"paperNumber":"somepaper","issuer":"someissuer","issueDateTime":"sometime","faceValue":1000,"maturityDateTime":"somelatertime","owner":"someowner","currentState":2,"class":"org.papernet.commercialpaper","key":"someissuer:somepaper"}`, string(bytes), "should return JSON formatted value")
+	assert.Equal(t, `{"paperNumber":"somepaper","issuer":"someissuer","issuerMSP":"someissuermsp","issueDateTime":"sometime","faceValue":1000,"maturityDateTime":"somelatertime","redeemedFaceValue":400,"version":3,"currentState":2,"class":"org.papernet.commercialpaper","key":"someissuer:somepaper"}`, string(bytes), "should return JSON formatted value")
 }
 
 func TestDeserialize(t *testing.T) {
 	var cp *CommercialPaper
 	var err error
 
-	goodJSON := `{"paperNumber":"somepaper","issuer":"someissuer","issueDateTime":"sometime","faceValue":1000,"maturityDateTime":"somelatertime","owner":"someowner","currentState":2,"class":"org.papernet.commercialpaper","key":"someissuer:somepaper"}`
+	goodJSON := `{"paperNumber":"somepaper","issuer":"someissuer","issuerMSP":"someissuermsp","issueDateTime":"sometime","faceValue":1000,"maturityDateTime":"somelatertime","redeemedFaceValue":400,"version":3,"currentState":2,"class":"org.papernet.commercialpaper","key":"someissuer:somepaper"}`
 	expectedCp := new(CommercialPaper)
 	expectedCp.PaperNumber = "somepaper"
 	expectedCp.Issuer = "someissuer"
+	expectedCp.IssuerMSP = "someissuermsp"
 	expectedCp.IssueDateTime = "sometime"
 	expectedCp.FaceValue = 1000
 	expectedCp.MaturityDateTime = "somelatertime"
-	expectedCp.Owner = "someowner"
+	expectedCp.RedeemedFaceValue = 400
+	expectedCp.Version = 3
 	expectedCp.state = TRADING
 	cp = new(CommercialPaper)
 	err = Deserialize([]byte(goodJSON), cp)
 	assert.Nil(t, err, "should not return error for deserialize")
 	assert.Equal(t, expectedCp, cp, "should create expected commercial paper")
 
-	badJSON := `{"paperNumber":"somepaper","issuer":"someissuer","issueDateTime":"sometime","faceValue":"NaN","maturityDateTime":"somelatertime","owner":"someowner","currentState":2,"class":"org.papernet.commercialpaper","key":"someissuer:somepaper"}`
+	badJSON := `{"paperNumber":"somepaper","issuer":"someissuer","issueDateTime":"sometime","faceValue":"NaN","maturityDateTime":"somelatertime","currentState":2,"class":"org.papernet.commercialpaper","key":"someissuer:somepaper"}`
 	cp = new(CommercialPaper)
 	err = Deserialize([]byte(badJSON), cp)
 	assert.EqualError(t, err, "Error deserializing commercial paper. json: cannot unmarshal string into Go struct field jsonCommercialPaper.faceValue of type int", "should return error for bad data")
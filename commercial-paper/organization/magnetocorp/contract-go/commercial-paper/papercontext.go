@@ -15,6 +15,7 @@ import (
 type TransactionContextInterface interface {
 	contractapi.TransactionContextInterface
 	GetPaperList() ListInterface
+	GetHoldingList() HoldingListInterface
 }
 
 // TransactionContext implementation of
@@ -22,7 +23,8 @@ type TransactionContextInterface interface {
 // commercial paper contract
 type TransactionContext struct {
 	contractapi.TransactionContext
-	paperList *list
+	paperList   *list
+	holdingList *holdingList
 }
 
 // GetPaperList return paper list
@@ -33,3 +35,12 @@ func (tc *TransactionContext) GetPaperList() ListInterface {
 
 	return tc.paperList
 }
+
+// GetHoldingList return holding list
+func (tc *TransactionContext) GetHoldingList() HoldingListInterface {
+	if tc.holdingList == nil {
+		tc.holdingList = newHoldingList(tc)
+	}
+
+	return tc.holdingList
+}
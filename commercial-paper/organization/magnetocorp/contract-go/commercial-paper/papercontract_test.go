@@ -5,10 +5,14 @@
 package commercialpaper
 
 import (
+	"crypto/x509"
 	"errors"
 	"testing"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,20 +42,168 @@ func (mpl *MockPaperList) UpdatePaper(paper *CommercialPaper) error {
 	return args.Error(0)
 }
 
+func (mpl *MockPaperList) GetPapersByIssuer(issuer string, pageSize int32, bookmark string) (*PaperPage, error) {
+	args := mpl.Called(issuer, pageSize, bookmark)
+
+	return args.Get(0).(*PaperPage), args.Error(1)
+}
+
+func (mpl *MockPaperList) GetPapersByState(state State, pageSize int32, bookmark string) (*PaperPage, error) {
+	args := mpl.Called(state, pageSize, bookmark)
+
+	return args.Get(0).(*PaperPage), args.Error(1)
+}
+
+func (mpl *MockPaperList) GetPaperHistory(issuer string, paperNumber string) ([]PaperHistory, error) {
+	args := mpl.Called(issuer, paperNumber)
+
+	return args.Get(0).([]PaperHistory), args.Error(1)
+}
+
+type MockClientIdentity struct {
+	mock.Mock
+}
+
+func (mci *MockClientIdentity) GetID() (string, error) {
+	args := mci.Called()
+
+	return args.String(0), args.Error(1)
+}
+
+func (mci *MockClientIdentity) GetMSPID() (string, error) {
+	args := mci.Called()
+
+	return args.String(0), args.Error(1)
+}
+
+func (mci *MockClientIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	args := mci.Called(attrName)
+
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+func (mci *MockClientIdentity) AssertAttributeValue(attrName string, attrValue string) error {
+	args := mci.Called(attrName, attrValue)
+
+	return args.Error(0)
+}
+
+func (mci *MockClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	args := mci.Called()
+
+	return args.Get(0).(*x509.Certificate), args.Error(1)
+}
+
+type MockHoldingList struct {
+	mock.Mock
+}
+
+func (mhl *MockHoldingList) AddHolding(holding *Holding) error {
+	args := mhl.Called(holding)
+
+	return args.Error(0)
+}
+
+func (mhl *MockHoldingList) GetHolding(issuer string, paperNumber string, owner string) (*Holding, error) {
+	args := mhl.Called(issuer, paperNumber, owner)
+
+	return args.Get(0).(*Holding), args.Error(1)
+}
+
+func (mhl *MockHoldingList) UpdateHolding(holding *Holding) error {
+	args := mhl.Called(holding)
+
+	return args.Error(0)
+}
+
+func (mhl *MockHoldingList) DeleteHolding(holding *Holding) error {
+	args := mhl.Called(holding)
+
+	return args.Error(0)
+}
+
+func (mhl *MockHoldingList) GetHoldingsByPaper(issuer string, paperNumber string, pageSize int32, bookmark string) (*HoldingPage, error) {
+	args := mhl.Called(issuer, paperNumber, pageSize, bookmark)
+
+	return args.Get(0).(*HoldingPage), args.Error(1)
+}
+
+func (mhl *MockHoldingList) GetHoldingsByOwner(owner string, pageSize int32, bookmark string) (*HoldingPage, error) {
+	args := mhl.Called(owner, pageSize, bookmark)
+
+	return args.Get(0).(*HoldingPage), args.Error(1)
+}
+
+type MockStub struct {
+	shim.ChaincodeStubInterface
+	mock.Mock
+}
+
+func (ms *MockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	args := ms.Called()
+
+	return args.Get(0).(*timestamp.Timestamp), args.Error(1)
+}
+
 type MockTransactionContext struct {
 	contractapi.TransactionContext
-	paperList *MockPaperList
+	paperList   *MockPaperList
+	holdingList *MockHoldingList
 }
 
 func (mtc *MockTransactionContext) GetPaperList() ListInterface {
 	return mtc.paperList
 }
 
+func (mtc *MockTransactionContext) GetHoldingList() HoldingListInterface {
+	return mtc.holdingList
+}
+
+// setClientMSPID configures the context's client identity
+// to belong to the passed organisation
+func setClientMSPID(ctx *MockTransactionContext, mspID string) {
+	mci := new(MockClientIdentity)
+	mci.On("GetMSPID").Return(mspID, nil)
+	ctx.SetClientIdentity(mci)
+}
+
+// setTxDateTime configures the context's stub to
+// return the date time as the transaction timestamp
+func setTxDateTime(ctx *MockTransactionContext, dateTime string) {
+	t, _ := ParseDateTime(dateTime)
+	ms := new(MockStub)
+	ms.On("GetTxTimestamp").Return(&timestamp.Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}, nil)
+	ctx.SetStub(ms)
+}
+
 func resetPaper(paper *CommercialPaper) {
-	paper.Owner = "someowner"
+	paper.Issuer = "someissuer"
+	paper.IssuerMSP = "someissuermsp"
+	paper.PaperNumber = "somepaper"
+	paper.FaceValue = 1000
+	paper.MaturityDateTime = "2020-11-30"
+	paper.RedeemedFaceValue = 0
 	paper.SetTrading()
 }
 
+func resetHolding(holding *Holding, owner string, faceValue int) {
+	holding.Issuer = "someissuer"
+	holding.PaperNumber = "somepaper"
+	holding.Owner = owner
+	holding.OwnerMSP = owner + "msp"
+	holding.FaceValue = faceValue
+	holding.Redeemed = false
+	holding.PurchaseRequest = nil
+}
+
+func newMockContext() (*MockTransactionContext, *MockPaperList, *MockHoldingList) {
+	ctx := new(MockTransactionContext)
+	ctx.paperList = new(MockPaperList)
+	ctx.holdingList = new(MockHoldingList)
+
+	return ctx, ctx.paperList, ctx.holdingList
+}
+
 // #########
 // TESTS
 // #########
@@ -60,126 +212,468 @@ func TestIssue(t *testing.T) {
 	var paper *CommercialPaper
 	var err error
 
-	mpl := new(MockPaperList)
-	ctx := new(MockTransactionContext)
-	ctx.paperList = mpl
+	ctx, mpl, mhl := newMockContext()
+	setClientMSPID(ctx, "someissuermsp")
 
 	contract := new(Contract)
 
 	var sentPaper *CommercialPaper
+	var sentHolding *Holding
 
 	mpl.On("AddPaper", mock.MatchedBy(func(paper *CommercialPaper) bool { sentPaper = paper; return paper.Issuer == "someissuer" })).Return(nil)
 	mpl.On("AddPaper", mock.MatchedBy(func(paper *CommercialPaper) bool { sentPaper = paper; return paper.Issuer == "someotherissuer" })).Return(errors.New("AddPaper error"))
+	mhl.On("AddHolding", mock.MatchedBy(func(holding *Holding) bool { sentHolding = holding; return holding.PaperNumber == "somepaper" })).Return(nil)
+	mhl.On("AddHolding", mock.MatchedBy(func(holding *Holding) bool { sentHolding = holding; return holding.PaperNumber == "someotherpaper" })).Return(errors.New("AddHolding error"))
+
+	paper, err = contract.Issue(ctx, "someissuer", "somepaper", "someissuedate", "2020-11-30", 1000)
+	assert.EqualError(t, err, "Invalid date time someissuedate. Expected format YYYY-MM-DD or RFC3339", "should error when issue date time invalid")
+	assert.Nil(t, paper, "should not return paper when issue date time invalid")
+
+	paper, err = contract.Issue(ctx, "someissuer", "somepaper", "2020-11-30", "2020-05-31", 1000)
+	assert.EqualError(t, err, "Paper someissuer:somepaper must mature after it is issued", "should error when paper matures before it is issued")
+	assert.Nil(t, paper, "should not return paper when paper matures before it is issued")
 
-	expectedPaper := CommercialPaper{PaperNumber: "somepaper", Issuer: "someissuer", IssueDateTime: "someissuedate", FaceValue: 1000, MaturityDateTime: "somematuritydate", Owner: "someissuer", state: 1}
-	paper, err = contract.Issue(ctx, "someissuer", "somepaper", "someissuedate", "somematuritydate", 1000)
+	paper, err = contract.Issue(ctx, "someissuer", "somepaper", "2020-05-31", "2020-11-30", 0)
+	assert.EqualError(t, err, "Paper someissuer:somepaper must have a positive face value", "should error when face value not positive")
+	assert.Nil(t, paper, "should not return paper when face value not positive")
+
+	expectedPaper := CommercialPaper{PaperNumber: "somepaper", Issuer: "someissuer", IssuerMSP: "someissuermsp", IssueDateTime: "2020-05-31", FaceValue: 1000, MaturityDateTime: "2020-11-30", state: 1}
+	expectedHolding := Holding{Issuer: "someissuer", PaperNumber: "somepaper", Owner: "someissuer", OwnerMSP: "someissuermsp", FaceValue: 1000}
+	paper, err = contract.Issue(ctx, "someissuer", "somepaper", "2020-05-31", "2020-11-30", 1000)
 	assert.Nil(t, err, "should not error when add paper does not error")
 	assert.Equal(t, sentPaper, paper, "should send the same paper as it returns to add paper")
 	assert.Equal(t, expectedPaper, *paper, "should correctly configure paper")
+	assert.Equal(t, expectedHolding, *sentHolding, "should add holding of full face value for issuer")
 
-	paper, err = contract.Issue(ctx, "someotherissuer", "somepaper", "someissuedate", "somematuritydate", 1000)
+	paper, err = contract.Issue(ctx, "someotherissuer", "somepaper", "2020-05-31", "2020-11-30", 1000)
 	assert.EqualError(t, err, "AddPaper error", "should return error when add paper fails")
 	assert.Nil(t, paper, "should not return paper when fails")
+
+	paper, err = contract.Issue(ctx, "someissuer", "someotherpaper", "2020-05-31", "2020-11-30", 1000)
+	assert.EqualError(t, err, "AddHolding error", "should return error when add holding fails")
+	assert.Nil(t, paper, "should not return paper when add holding fails")
 }
 
-func TestBuy(t *testing.T) {
-	var paper *CommercialPaper
+func TestBuyRequest(t *testing.T) {
+	var holding *Holding
 	var err error
 
-	mpl := new(MockPaperList)
-	ctx := new(MockTransactionContext)
-	ctx.paperList = mpl
+	ctx, mpl, mhl := newMockContext()
+	setClientMSPID(ctx, "someotherownermsp")
+	setTxDateTime(ctx, "2020-05-31")
 
 	contract := new(Contract)
 
 	wsPaper := new(CommercialPaper)
 	resetPaper(wsPaper)
+	wsHolding := new(Holding)
+	resetHolding(wsHolding, "someowner", 1000)
 
-	var sentPaper *CommercialPaper
+	var sentHolding *Holding
 	var emptyPaper *CommercialPaper
+	var emptyHolding *Holding
 	shouldError := false
 
 	mpl.On("GetPaper", "someissuer", "somepaper").Return(wsPaper, nil)
 	mpl.On("GetPaper", "someotherissuer", "someotherpaper").Return(emptyPaper, errors.New("GetPaper error"))
-	mpl.On("UpdatePaper", mock.MatchedBy(func(paper *CommercialPaper) bool { return shouldError })).Return(errors.New("UpdatePaper error"))
-	mpl.On("UpdatePaper", mock.MatchedBy(func(paper *CommercialPaper) bool { sentPaper = paper; return !shouldError })).Return(nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someowner").Return(wsHolding, nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someotherowner").Return(emptyHolding, &ledgerapi.StateNotFoundError{Key: "someissuer:somepaper:someotherowner"})
+	mhl.On("UpdateHolding", mock.MatchedBy(func(holding *Holding) bool { return shouldError })).Return(errors.New("UpdateHolding error"))
+	mhl.On("UpdateHolding", mock.MatchedBy(func(holding *Holding) bool { sentHolding = holding; return !shouldError })).Return(nil)
 
-	paper, err = contract.Buy(ctx, "someotherissuer", "someotherpaper", "someowner", "someotherowner", 100, "2019-12-10:10:00")
+	holding, err = contract.BuyRequest(ctx, "someotherissuer", "someotherpaper", "someowner", "someotherowner", 400, 390, "2020-05-31")
 	assert.EqualError(t, err, "GetPaper error", "should return error when GetPaper errors")
-	assert.Nil(t, paper, "should return nil for paper when GetPaper errors")
+	assert.Nil(t, holding, "should return nil for holding when GetPaper errors")
 
-	paper, err = contract.Buy(ctx, "someissuer", "somepaper", "someotherowner", "someowner", 100, "2019-12-10:10:00")
-	assert.EqualError(t, err, "Paper someissuer:somepaper is not owned by someotherowner", "should error when sent owner not correct")
-	assert.Nil(t, paper, "should not return paper for bad owner error")
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someotherowner", "someowner", 400, 390, "2020-05-31")
+	assert.EqualError(t, err, "Paper someissuer:somepaper is not owned by someotherowner", "should error when sent owner has no holding")
+	assert.Nil(t, holding, "should not return holding for bad owner error")
 
-	resetPaper(wsPaper)
 	wsPaper.SetRedeemed()
-	paper, err = contract.Buy(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 100, "2019-12-10:10:00")
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 400, 390, "2020-05-31")
 	assert.EqualError(t, err, "Paper someissuer:somepaper is not trading. Current state = REDEEMED")
-	assert.Nil(t, paper, "should not return paper for bad state error")
-
+	assert.Nil(t, holding, "should not return holding for bad state error")
 	resetPaper(wsPaper)
+
+	setTxDateTime(ctx, "2020-11-30")
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 400, 390, "2020-05-31")
+	assert.EqualError(t, err, "Paper someissuer:somepaper can not be bought after maturity 2020-11-30", "should error when transaction is at maturity whatever the purchase date time")
+	assert.Nil(t, holding, "should not return holding for matured paper error")
+	setTxDateTime(ctx, "2020-05-31")
+
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 400, 390, "2019-12-10:10:00")
+	assert.EqualError(t, err, "Invalid date time 2019-12-10:10:00. Expected format YYYY-MM-DD or RFC3339", "should error when purchase date time invalid")
+	assert.Nil(t, holding, "should not return holding for invalid purchase date time")
+
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someowner", 400, 390, "2020-05-31")
+	assert.EqualError(t, err, "Paper someissuer:somepaper is already owned by someowner", "should error when owner buys from itself")
+	assert.Nil(t, holding, "should not return holding when owner buys from itself")
+
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 1001, 990, "2020-05-31")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner has face value 1000. Can not buy 1001", "should error when buying more than held")
+	assert.Nil(t, holding, "should not return holding when buying more than held")
+
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 0, 0, "2020-05-31")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner has face value 1000. Can not buy 0", "should error when buying nothing")
+	assert.Nil(t, holding, "should not return holding when buying nothing")
+
+	wsHolding.Redeemed = true
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 400, 390, "2020-05-31")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner is already redeemed", "should error when holding already redeemed")
+	assert.Nil(t, holding, "should not return holding when holding already redeemed")
+	resetHolding(wsHolding, "someowner", 1000)
+
+	wsHolding.PurchaseRequest = &PurchaseRequest{NewOwner: "yetanotherowner"}
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 400, 390, "2020-05-31")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner already has a pending buy request from yetanotherowner", "should error when a buy request is pending")
+	assert.Nil(t, holding, "should not return holding for pending buy request error")
+	resetHolding(wsHolding, "someowner", 1000)
+
 	shouldError = true
-	paper, err = contract.Buy(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 100, "2019-12-10:10:00")
-	assert.EqualError(t, err, "UpdatePaper error", "should error when update paper fails")
-	assert.Nil(t, paper, "should not return paper for bad state error")
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 400, 390, "2020-05-31")
+	assert.EqualError(t, err, "UpdateHolding error", "should error when update holding fails")
+	assert.Nil(t, holding, "should not return holding when update holding fails")
 	shouldError = false
+	resetHolding(wsHolding, "someowner", 1000)
 
-	resetPaper(wsPaper)
 	wsPaper.SetIssued()
-	paper, err = contract.Buy(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 100, "2019-12-10:10:00")
+	holding, err = contract.BuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner", 400, 390, "2020-05-31")
 	assert.Nil(t, err, "should not error when good paper and owner")
-	assert.Equal(t, "someotherowner", paper.Owner, "should update the owner of the paper")
-	assert.True(t, paper.IsTrading(), "should mark issued paper as trading")
-	assert.Equal(t, sentPaper, paper, "should update same paper as it returns in the world state")
+	assert.Equal(t, 1000, holding.FaceValue, "should not change the face value of the holding")
+	assert.Equal(t, &PurchaseRequest{NewOwner: "someotherowner", NewOwnerMSP: "someotherownermsp", FaceValue: 400, Price: 390, PurchaseDateTime: "2020-05-31"}, holding.PurchaseRequest, "should record the buy request from the client organisation")
+	assert.True(t, wsPaper.IsIssued(), "should not change the state of the paper")
+	assert.Equal(t, sentHolding, holding, "should update same holding as it returns in the world state")
+}
+
+func TestTransfer(t *testing.T) {
+	var holding *Holding
+	var err error
+
+	ctx, mpl, mhl := newMockContext()
+	setTxDateTime(ctx, "2020-06-30")
+
+	contract := new(Contract)
+
+	wsPaper := new(CommercialPaper)
+	wsHolding := new(Holding)
+	wsNewHolding := new(Holding)
+	var emptyPaper *CommercialPaper
+
+	var sentPaper *CommercialPaper
+	var updatedHoldings []*Holding
+	var deletedHolding *Holding
+	shouldError := false
+
+	mpl.On("GetPaper", "someissuer", "somepaper").Return(wsPaper, nil)
+	mpl.On("GetPaper", "someotherissuer", "someotherpaper").Return(emptyPaper, errors.New("GetPaper error"))
+	mpl.On("UpdatePaper", mock.MatchedBy(func(paper *CommercialPaper) bool { sentPaper = paper; return true })).Return(nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someowner").Return(wsHolding, nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someotherowner").Return(wsNewHolding, nil)
+	mhl.On("UpdateHolding", mock.MatchedBy(func(holding *Holding) bool { return shouldError })).Return(errors.New("UpdateHolding error"))
+	mhl.On("UpdateHolding", mock.MatchedBy(func(holding *Holding) bool { updatedHoldings = append(updatedHoldings, holding); return !shouldError })).Return(nil)
+	mhl.On("DeleteHolding", mock.MatchedBy(func(holding *Holding) bool { deletedHolding = holding; return true })).Return(nil)
+
+	reset := func(requestedFaceValue int) {
+		resetPaper(wsPaper)
+		resetHolding(wsHolding, "someowner", 1000)
+		wsHolding.PurchaseRequest = &PurchaseRequest{NewOwner: "someotherowner", NewOwnerMSP: "someotherownermsp", FaceValue: requestedFaceValue, Price: 390, PurchaseDateTime: "2020-05-31"}
+		resetHolding(wsNewHolding, "someotherowner", 100)
+		updatedHoldings = nil
+		deletedHolding = nil
+		sentPaper = nil
+	}
+
+	setClientMSPID(ctx, "someownermsp")
+	holding, err = contract.Transfer(ctx, "someotherissuer", "someotherpaper", "someowner", "someotherowner")
+	assert.EqualError(t, err, "GetPaper error", "should return error when GetPaper errors")
+	assert.Nil(t, holding, "should return nil for holding when GetPaper errors")
+
+	reset(400)
+	setClientMSPID(ctx, "someotherownermsp")
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner is not owned by organisation someotherownermsp", "should error when client not in owner organisation")
+	assert.Nil(t, holding, "should not return holding for bad organisation error")
+	setClientMSPID(ctx, "someownermsp")
+
+	reset(400)
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "yetanotherowner")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner has no pending buy request from yetanotherowner", "should error when no buy request from new owner pending")
+	assert.Nil(t, holding, "should not return holding for missing buy request error")
+
+	reset(400)
+	wsHolding.Redeemed = true
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner is already redeemed", "should error when holding of current owner already redeemed")
+	assert.Nil(t, holding, "should not return holding when holding of current owner already redeemed")
+
+	reset(400)
+	setTxDateTime(ctx, "2020-11-30")
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.EqualError(t, err, "Paper someissuer:somepaper can not be transferred after maturity 2020-11-30", "should error when paper matured since the buy request")
+	assert.Nil(t, holding, "should not return holding for matured paper error")
+	setTxDateTime(ctx, "2020-06-30")
+
+	reset(400)
+	wsNewHolding.OwnerMSP = "yetanotherownermsp"
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someotherowner is not owned by organisation someotherownermsp", "should error when existing holding of new owner belongs to other organisation")
+	assert.Nil(t, holding, "should not return holding for organisation mismatch")
+
+	reset(400)
+	shouldError = true
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.EqualError(t, err, "UpdateHolding error", "should error when update holding fails")
+	assert.Nil(t, holding, "should not return holding when update holding fails")
+	shouldError = false
+
+	reset(400)
+	wsPaper.SetIssued()
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.Nil(t, err, "should not error for partial transfer")
+	assert.Equal(t, wsNewHolding, holding, "should return holding of new owner")
+	assert.Equal(t, 500, holding.FaceValue, "should add transferred face value to holding of new owner")
+	assert.Equal(t, 600, wsHolding.FaceValue, "should subtract transferred face value from holding of current owner")
+	assert.Nil(t, wsHolding.PurchaseRequest, "should clear the buy request")
+	assert.Equal(t, []*Holding{wsHolding, wsNewHolding}, updatedHoldings, "should update both holdings")
+	assert.Nil(t, deletedHolding, "should not delete holding with remaining face value")
+	assert.True(t, sentPaper.IsTrading(), "should mark issued paper as trading")
+
+	reset(1000)
+	holding, err = contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.Nil(t, err, "should not error for full transfer")
+	assert.Equal(t, 1100, holding.FaceValue, "should add transferred face value to holding of new owner")
+	assert.Equal(t, wsHolding, deletedHolding, "should delete emptied holding of current owner")
+	assert.Equal(t, []*Holding{wsNewHolding}, updatedHoldings, "should only update holding of new owner")
+}
+
+func TestTransferToNewHolder(t *testing.T) {
+	ctx, mpl, mhl := newMockContext()
+	setClientMSPID(ctx, "someownermsp")
+	setTxDateTime(ctx, "2020-06-30")
+
+	contract := new(Contract)
+
+	wsPaper := new(CommercialPaper)
+	resetPaper(wsPaper)
+	wsHolding := new(Holding)
+	resetHolding(wsHolding, "someowner", 1000)
+	wsHolding.PurchaseRequest = &PurchaseRequest{NewOwner: "someotherowner", NewOwnerMSP: "someotherownermsp", FaceValue: 400, Price: 390, PurchaseDateTime: "2020-05-31"}
+	var emptyHolding *Holding
+
+	mpl.On("GetPaper", "someissuer", "somepaper").Return(wsPaper, nil)
+	mpl.On("UpdatePaper", wsPaper).Return(nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someowner").Return(wsHolding, nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someotherowner").Return(emptyHolding, &ledgerapi.StateNotFoundError{Key: "someissuer:somepaper:someotherowner"})
+	mhl.On("UpdateHolding", wsHolding).Return(nil)
+	mhl.On("AddHolding", mock.Anything).Return(nil)
+
+	holding, err := contract.Transfer(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.Nil(t, err, "should not error when new owner has no holding")
+	assert.Equal(t, &Holding{Issuer: "someissuer", PaperNumber: "somepaper", Owner: "someotherowner", OwnerMSP: "someotherownermsp", FaceValue: 400}, holding, "should create holding for new owner in the requesting organisation")
+	mhl.AssertCalled(t, "AddHolding", holding)
+	assert.Equal(t, 600, wsHolding.FaceValue, "should subtract transferred face value from holding of current owner")
+}
+
+func TestRejectBuyRequest(t *testing.T) {
+	var holding *Holding
+	var err error
+
+	ctx, _, mhl := newMockContext()
+
+	contract := new(Contract)
+
+	wsHolding := new(Holding)
+	resetHolding(wsHolding, "someowner", 1000)
+
+	var sentHolding *Holding
+
+	mhl.On("GetHolding", "someissuer", "somepaper", "someowner").Return(wsHolding, nil)
+	mhl.On("UpdateHolding", mock.MatchedBy(func(holding *Holding) bool { sentHolding = holding; return true })).Return(nil)
+
+	wsHolding.PurchaseRequest = &PurchaseRequest{NewOwner: "someotherowner", NewOwnerMSP: "someotherownermsp", FaceValue: 400, Price: 390, PurchaseDateTime: "2020-05-31"}
+	setClientMSPID(ctx, "someotherownermsp")
+	holding, err = contract.RejectBuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner is not owned by organisation someotherownermsp", "should error when client not in owner organisation")
+	assert.Nil(t, holding, "should not return holding for bad organisation error")
+
+	setClientMSPID(ctx, "someownermsp")
+	holding, err = contract.RejectBuyRequest(ctx, "someissuer", "somepaper", "someowner", "someotherowner")
+	assert.Nil(t, err, "should not error when good holding and organisation")
+	assert.Equal(t, 1000, holding.FaceValue, "should not change the face value of the holding")
+	assert.Nil(t, holding.PurchaseRequest, "should clear the buy request")
+	assert.Equal(t, sentHolding, holding, "should update same holding as it returns in the world state")
 }
 
 func TestRedeem(t *testing.T) {
-	var paper *CommercialPaper
+	var holding *Holding
 	var err error
 
-	mpl := new(MockPaperList)
-	ctx := new(MockTransactionContext)
-	ctx.paperList = mpl
+	ctx, mpl, mhl := newMockContext()
+	setClientMSPID(ctx, "someownermsp")
+	setTxDateTime(ctx, "2021-12-10")
 
 	contract := new(Contract)
 
 	var sentPaper *CommercialPaper
+	var sentHolding *Holding
 	wsPaper := new(CommercialPaper)
 	resetPaper(wsPaper)
+	wsHolding := new(Holding)
+	resetHolding(wsHolding, "someowner", 400)
 
 	var emptyPaper *CommercialPaper
+	var emptyHolding *Holding
 	shouldError := false
 
 	mpl.On("GetPaper", "someissuer", "somepaper").Return(wsPaper, nil)
 	mpl.On("GetPaper", "someotherissuer", "someotherpaper").Return(emptyPaper, errors.New("GetPaper error"))
-	mpl.On("UpdatePaper", mock.MatchedBy(func(paper *CommercialPaper) bool { return shouldError })).Return(errors.New("UpdatePaper error"))
-	mpl.On("UpdatePaper", mock.MatchedBy(func(paper *CommercialPaper) bool { sentPaper = paper; return !shouldError })).Return(nil)
+	mpl.On("UpdatePaper", mock.MatchedBy(func(paper *CommercialPaper) bool { sentPaper = paper; return true })).Return(nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someowner").Return(wsHolding, nil)
+	mhl.On("GetHolding", "someissuer", "somepaper", "someotherowner").Return(emptyHolding, &ledgerapi.StateNotFoundError{Key: "someissuer:somepaper:someotherowner"})
+	mhl.On("UpdateHolding", mock.MatchedBy(func(holding *Holding) bool { return shouldError })).Return(errors.New("UpdateHolding error"))
+	mhl.On("UpdateHolding", mock.MatchedBy(func(holding *Holding) bool { sentHolding = holding; return !shouldError })).Return(nil)
 
-	paper, err = contract.Redeem(ctx, "someotherissuer", "someotherpaper", "someowner", "2021-12-10:10:00")
+	holding, err = contract.Redeem(ctx, "someotherissuer", "someotherpaper", "someowner", "2021-12-10")
 	assert.EqualError(t, err, "GetPaper error", "should error when GetPaper errors")
-	assert.Nil(t, paper, "should not return paper when GetPaper errors")
+	assert.Nil(t, holding, "should not return holding when GetPaper errors")
 
-	paper, err = contract.Redeem(ctx, "someissuer", "somepaper", "someotherowner", "2021-12-10:10:00")
-	assert.EqualError(t, err, "Paper someissuer:somepaper is not owned by someotherowner", "should error when paper owned by someone else")
-	assert.Nil(t, paper, "should not return paper when errors as owned by someone else")
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someotherowner", "2021-12-10")
+	assert.EqualError(t, err, "Paper someissuer:somepaper is not owned by someotherowner", "should error when redeeming owner has no holding")
+	assert.Nil(t, holding, "should not return holding when errors as owned by someone else")
 
-	resetPaper(wsPaper)
 	wsPaper.SetRedeemed()
-	paper, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10:10:00")
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10")
 	assert.EqualError(t, err, "Paper someissuer:somepaper is already redeemed", "should error when paper already redeemed")
-	assert.Nil(t, paper, "should not return paper when errors as already redeemed")
+	assert.Nil(t, holding, "should not return holding when errors as already redeemed")
+	resetPaper(wsPaper)
+
+	wsHolding.Redeemed = true
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner is already redeemed", "should error when holding already redeemed")
+	assert.Nil(t, holding, "should not return holding when errors as holding already redeemed")
+	resetHolding(wsHolding, "someowner", 400)
+
+	setClientMSPID(ctx, "someotherownermsp")
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10")
+	assert.EqualError(t, err, "Paper someissuer:somepaper held by someowner is not owned by organisation someotherownermsp", "should error when client not in owner organisation")
+	assert.Nil(t, holding, "should not return holding when errors as client not in owner organisation")
+	setClientMSPID(ctx, "someownermsp")
+
+	setTxDateTime(ctx, "2020-11-29T23:59:59Z")
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10")
+	assert.EqualError(t, err, "Paper someissuer:somepaper can not be redeemed before maturity 2020-11-30", "should error when transaction is before maturity whatever the redeem date time")
+	assert.Nil(t, holding, "should not return holding when errors as not matured")
+	setTxDateTime(ctx, "2021-12-10")
+
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "20211210")
+	assert.EqualError(t, err, "Invalid date time 20211210. Expected format YYYY-MM-DD or RFC3339", "should error when redeem date time invalid")
+	assert.Nil(t, holding, "should not return holding for invalid redeem date time")
 
 	shouldError = true
-	resetPaper(wsPaper)
-	paper, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10:10:00")
-	assert.EqualError(t, err, "UpdatePaper error", "should error when update paper errors")
-	assert.Nil(t, paper, "should not return paper when UpdatePaper errors")
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10")
+	assert.EqualError(t, err, "UpdateHolding error", "should error when update holding errors")
+	assert.Nil(t, holding, "should not return holding when UpdateHolding errors")
 	shouldError = false
+	resetHolding(wsHolding, "someowner", 400)
 
-	resetPaper(wsPaper)
-	paper, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2021-12-10:10:00")
+	setTxDateTime(ctx, "2020-11-30")
+	wsHolding.PurchaseRequest = &PurchaseRequest{NewOwner: "someotherowner"}
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2020-11-30")
 	assert.Nil(t, err, "should not error on good redeem")
-	assert.True(t, paper.IsRedeemed(), "should return redeemed paper")
-	assert.Equal(t, sentPaper, paper, "should update same paper as it returns in the world state")
+	assert.True(t, holding.Redeemed, "should return redeemed holding")
+	assert.Equal(t, 400, holding.FaceValue, "should pay out the face value of the holding")
+	assert.Nil(t, holding.PurchaseRequest, "should clear any pending buy request")
+	assert.Equal(t, sentHolding, holding, "should update same holding as it returns in the world state")
+	assert.Equal(t, 400, sentPaper.RedeemedFaceValue, "should add face value of holding to redeemed face value of paper")
+	assert.True(t, sentPaper.IsTrading(), "should not redeem paper while face value remains unredeemed")
+
+	resetHolding(wsHolding, "someowner", 600)
+	holding, err = contract.Redeem(ctx, "someissuer", "somepaper", "someowner", "2020-11-30")
+	assert.Nil(t, err, "should not error on good redeem")
+	assert.Equal(t, 1000, sentPaper.RedeemedFaceValue, "should add face value of holding to redeemed face value of paper")
+	assert.True(t, sentPaper.IsRedeemed(), "should redeem paper once all face value redeemed")
+}
+
+func TestQueryPapersByIssuer(t *testing.T) {
+	mpl := new(MockPaperList)
+	ctx := new(MockTransactionContext)
+	ctx.paperList = mpl
+
+	contract := new(Contract)
+
+	expectedPage := &PaperPage{Papers: []*CommercialPaper{new(CommercialPaper)}, Bookmark: "somebookmark"}
+	mpl.On("GetPapersByIssuer", "someissuer", int32(10), "").Return(expectedPage, nil)
+
+	page, err := contract.QueryPapersByIssuer(ctx, "someissuer", 10, "")
+	assert.Nil(t, err, "should not error when paper list does not error")
+	assert.Equal(t, expectedPage, page, "should return page from paper list")
+}
+
+func TestQueryHoldingsByOwner(t *testing.T) {
+	ctx, _, mhl := newMockContext()
+
+	contract := new(Contract)
+
+	expectedPage := &HoldingPage{Holdings: []*Holding{new(Holding)}, Bookmark: "somebookmark"}
+	mhl.On("GetHoldingsByOwner", "someowner", int32(10), "").Return(expectedPage, nil)
+
+	page, err := contract.QueryHoldingsByOwner(ctx, "someowner", 10, "")
+	assert.Nil(t, err, "should not error when holding list does not error")
+	assert.Equal(t, expectedPage, page, "should return page from holding list")
+}
+
+func TestQueryPaperHoldings(t *testing.T) {
+	ctx, _, mhl := newMockContext()
+
+	contract := new(Contract)
+
+	expectedPage := &HoldingPage{Holdings: []*Holding{new(Holding)}, Bookmark: "somebookmark"}
+	mhl.On("GetHoldingsByPaper", "someissuer", "somepaper", int32(10), "").Return(expectedPage, nil)
+
+	page, err := contract.QueryPaperHoldings(ctx, "someissuer", "somepaper", 10, "")
+	assert.Nil(t, err, "should not error when holding list does not error")
+	assert.Equal(t, expectedPage, page, "should return page from holding list")
+}
+
+func TestQueryPapersByState(t *testing.T) {
+	var page *PaperPage
+	var err error
+
+	mpl := new(MockPaperList)
+	ctx := new(MockTransactionContext)
+	ctx.paperList = mpl
+
+	contract := new(Contract)
+
+	var emptyPage *PaperPage
+	expectedPage := &PaperPage{Papers: []*CommercialPaper{new(CommercialPaper)}, Bookmark: "somebookmark"}
+	mpl.On("GetPapersByState", TRADING, int32(10), "").Return(expectedPage, nil)
+	mpl.On("GetPapersByState", REDEEMED, int32(10), "").Return(emptyPage, errors.New("GetPapersByState error"))
+
+	page, err = contract.QueryPapersByState(ctx, "UNKNOWN", 10, "")
+	assert.EqualError(t, err, "Unknown commercial paper state UNKNOWN", "should error for unknown state")
+	assert.Nil(t, page, "should not return page for unknown state")
+
+	page, err = contract.QueryPapersByState(ctx, "REDEEMED", 10, "")
+	assert.EqualError(t, err, "GetPapersByState error", "should error when paper list errors")
+	assert.Nil(t, page, "should not return page when paper list errors")
+
+	page, err = contract.QueryPapersByState(ctx, "TRADING", 10, "")
+	assert.Nil(t, err, "should not error when paper list does not error")
+	assert.Equal(t, expectedPage, page, "should return page from paper list")
+}
+
+func TestQueryPaperHistory(t *testing.T) {
+	mpl := new(MockPaperList)
+	ctx := new(MockTransactionContext)
+	ctx.paperList = mpl
+
+	contract := new(Contract)
+
+	expectedHistory := []PaperHistory{{TxID: "sometx", Paper: new(CommercialPaper)}}
+	mpl.On("GetPaperHistory", "someissuer", "somepaper").Return(expectedHistory, nil)
+
+	history, err := contract.QueryPaperHistory(ctx, "someissuer", "somepaper")
+	assert.Nil(t, err, "should not error when paper list does not error")
+	assert.Equal(t, expectedHistory, history, "should return history from paper list")
 }
@@ -7,8 +7,9 @@ package commercialpaper
 import (
 	"errors"
 	"testing"
+	"time"
 
-	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/organization/magnetocorp/contract-go/ledger-api"
+	ledgerapi "github.com/hyperledger/fabric-samples/commercial-paper/ledger-api-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -30,7 +31,12 @@ func (msl *MockStateList) AddState(state ledgerapi.StateInterface) error {
 func (msl *MockStateList) GetState(key string, state ledgerapi.StateInterface) error {
 	args := msl.Called(key, state)
 
-	state.(*CommercialPaper).PaperNumber = "somepaper"
+	switch s := state.(type) {
+	case *CommercialPaper:
+		s.PaperNumber = "somepaper"
+	case *Holding:
+		s.PaperNumber = "somepaper"
+	}
 
 	return args.Error(0)
 }
@@ -41,6 +47,48 @@ func (msl *MockStateList) UpdateState(state ledgerapi.StateInterface) error {
 	return args.Error(0)
 }
 
+func (msl *MockStateList) DeleteState(state ledgerapi.StateInterface) error {
+	args := msl.Called(state)
+
+	return args.Error(0)
+}
+
+func (msl *MockStateList) Exists(key string) (bool, error) {
+	args := msl.Called(key)
+
+	return args.Bool(0), args.Error(1)
+}
+
+func (msl *MockStateList) GetStatesByPartialKey(keyParts []string, pageSize int32, bookmark string) ([]ledgerapi.StateInterface, string, error) {
+	args := msl.Called(keyParts, pageSize, bookmark)
+
+	return args.Get(0).([]ledgerapi.StateInterface), args.String(1), args.Error(2)
+}
+
+func (msl *MockStateList) GetStatesByRange(keyParts []string, startKey string, endKey string) ([]ledgerapi.StateInterface, error) {
+	args := msl.Called(keyParts, startKey, endKey)
+
+	return args.Get(0).([]ledgerapi.StateInterface), args.Error(1)
+}
+
+func (msl *MockStateList) GetStatesByIndex(name string, values []string, pageSize int32, bookmark string) ([]ledgerapi.StateInterface, string, error) {
+	args := msl.Called(name, values, pageSize, bookmark)
+
+	return args.Get(0).([]ledgerapi.StateInterface), args.String(1), args.Error(2)
+}
+
+func (msl *MockStateList) QueryStates(query string, pageSize int32, bookmark string) ([]ledgerapi.StateInterface, string, error) {
+	args := msl.Called(query, pageSize, bookmark)
+
+	return args.Get(0).([]ledgerapi.StateInterface), args.String(1), args.Error(2)
+}
+
+func (msl *MockStateList) GetStateHistory(key string) ([]ledgerapi.StateHistory, error) {
+	args := msl.Called(key)
+
+	return args.Get(0).([]ledgerapi.StateHistory), args.Error(1)
+}
+
 // #########
 // TESTS
 // #########
@@ -88,6 +136,73 @@ func TestUpdatePaper(t *testing.T) {
 	assert.EqualError(t, err, "Called update state correctly", "should call state list update state with paper")
 }
 
+func TestGetPapersByIssuer(t *testing.T) {
+	var page *PaperPage
+	var err error
+
+	paper := new(CommercialPaper)
+	var noStates []ledgerapi.StateInterface
+
+	list := new(list)
+	msl := new(MockStateList)
+	msl.On("GetStatesByPartialKey", []string{"someissuer"}, int32(10), "").Return([]ledgerapi.StateInterface{paper}, "somebookmark", nil)
+	msl.On("GetStatesByPartialKey", []string{"someotherissuer"}, int32(10), "").Return(noStates, "", errors.New("GetStatesByPartialKey error"))
+	list.stateList = msl
+
+	page, err = list.GetPapersByIssuer("someissuer", 10, "")
+	assert.Nil(t, err, "should not error when state list does not error")
+	assert.Equal(t, &PaperPage{Papers: []*CommercialPaper{paper}, Bookmark: "somebookmark"}, page, "should return papers and bookmark from state list")
+
+	page, err = list.GetPapersByIssuer("someotherissuer", 10, "")
+	assert.EqualError(t, err, "GetStatesByPartialKey error", "should return error when state list errors")
+	assert.Nil(t, page, "should not return page on error")
+}
+
+func TestGetPapersByState(t *testing.T) {
+	var page *PaperPage
+	var err error
+
+	paper := new(CommercialPaper)
+	var noStates []ledgerapi.StateInterface
+
+	list := new(list)
+	msl := new(MockStateList)
+	msl.On("GetStatesByIndex", "currentState", []string{"TRADING"}, int32(10), "").Return([]ledgerapi.StateInterface{paper}, "somebookmark", nil)
+	msl.On("GetStatesByIndex", "currentState", []string{"REDEEMED"}, int32(10), "").Return(noStates, "", errors.New("GetStatesByIndex error"))
+	list.stateList = msl
+
+	page, err = list.GetPapersByState(TRADING, 10, "")
+	assert.Nil(t, err, "should not error when state list does not error")
+	assert.Equal(t, &PaperPage{Papers: []*CommercialPaper{paper}, Bookmark: "somebookmark"}, page, "should return papers and bookmark from state list")
+
+	page, err = list.GetPapersByState(REDEEMED, 10, "")
+	assert.EqualError(t, err, "GetStatesByIndex error", "should return error when state list errors")
+	assert.Nil(t, page, "should not return page on error")
+}
+
+func TestGetPaperHistory(t *testing.T) {
+	var history []PaperHistory
+	var err error
+
+	paper := new(CommercialPaper)
+	timestamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var noHistory []ledgerapi.StateHistory
+
+	list := new(list)
+	msl := new(MockStateList)
+	msl.On("GetStateHistory", CreateCommercialPaperKey("someissuer", "somepaper")).Return([]ledgerapi.StateHistory{{TxID: "sometx", Timestamp: timestamp, State: paper}, {TxID: "someothertx", Timestamp: timestamp, IsDelete: true}}, nil)
+	msl.On("GetStateHistory", CreateCommercialPaperKey("someotherissuer", "someotherpaper")).Return(noHistory, errors.New("GetStateHistory error"))
+	list.stateList = msl
+
+	history, err = list.GetPaperHistory("someissuer", "somepaper")
+	assert.Nil(t, err, "should not error when state list does not error")
+	assert.Equal(t, []PaperHistory{{TxID: "sometx", Timestamp: "2020-01-02T03:04:05Z", Paper: paper}, {TxID: "someothertx", Timestamp: "2020-01-02T03:04:05Z", IsDelete: true}}, history, "should return history from state list")
+
+	history, err = list.GetPaperHistory("someotherissuer", "someotherpaper")
+	assert.EqualError(t, err, "GetStateHistory error", "should return error when state list errors")
+	assert.Nil(t, history, "should not return history on error")
+}
+
 func TestNewStateList(t *testing.T) {
 	ctx := new(TransactionContext)
 	list := newList(ctx)
@@ -100,4 +215,12 @@ func TestNewStateList(t *testing.T) {
 	expectedErr := Deserialize([]byte("bad json"), new(CommercialPaper))
 	err := stateList.Deserialize([]byte("bad json"), new(CommercialPaper))
 	assert.EqualError(t, err, expectedErr.Error(), "should call Deserialize when stateList.Deserialize called")
+
+	assert.Equal(t, new(CommercialPaper), stateList.NewState(), "should create empty commercial papers for the list")
+
+	paper := new(CommercialPaper)
+	paper.SetTrading()
+	assert.Len(t, stateList.Indexes, 1, "should declare one index")
+	assert.Equal(t, "currentState", stateList.Indexes[0].Name, "should index papers by state")
+	assert.Equal(t, []string{"TRADING"}, stateList.Indexes[0].Values(paper), "should index papers under the name of their state")
 }
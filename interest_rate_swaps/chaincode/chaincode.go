@@ -7,8 +7,15 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"strconv"
 	"time"
 
@@ -35,6 +42,20 @@ type InterestRateSwap struct {
 	ReferenceRate   string
 }
 
+// RateAttestation is the record of the last reference rate accepted from a
+// provider. The signature covers the reference rate ID, the rate, the
+// timestamp and the nonce (see attestationDigest).
+type RateAttestation struct {
+	RateBPS   uint64
+	Timestamp time.Time
+	Nonce     string
+	Signature []byte
+}
+
+// maxAttestationAge is the maximum difference between the timestamp of a
+// reference rate attestation and the timestamp of the transaction submitting it.
+const maxAttestationAge = 5 * time.Minute
+
 /*
 SwapManager is the chaincode that handles interest rate swaps.
 The chaincode endorsement policy includes an auditing organization.
@@ -42,12 +63,16 @@ It provides the following functions:
 -) createSwap: create swap with participants
 -) calculatePayment: calculate what needs to be paid
 -) settlePayment: mark payment done
--) setReferenceRate: for providers to set the reference rate
+-) setProviderKey: for providers to register the key signing their rate attestations
+-) setReferenceRate: for providers to set the reference rate from a signed attestation
 
-The SwapManager stores three different kinds of information on the ledger:
+The SwapManager stores the following kinds of information on the ledger:
 -) the actual swap data ("swap" + ID)
 -) the payment information ("payment" + ID), if "none", the payment has been settled
 -) the reference rate ("rr" + ID)
+-) the reference rate provider's public key ("provider" + ID), if "none", no key has been registered
+-) the last accepted reference rate attestation ("attestation" + ID)
+-) the nonces of accepted attestations (composite key "nonce" ~ ID ~ nonce)
 */
 type SwapManager struct {
 }
@@ -81,14 +106,20 @@ func (cc *SwapManager) Init(stub shim.ChaincodeStubInterface) pb.Response {
 		return shim.Error(err.Error())
 	}
 
-	// create the reference rates, require them to be endorsed by the provider
+	// create the reference rates and provider key entries, require them to be
+	// endorsed by the provider
 	for i := 3; i+1 < len(args); i += 2 {
 		org := string(args[i])
 		rrID := "rr" + string(args[i+1])
+		providerID := "provider" + string(args[i+1])
 		err = stub.PutState(rrID, []byte("0"))
 		if err != nil {
 			return shim.Error(err.Error())
 		}
+		err = stub.PutState(providerID, []byte("none"))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 		ep, err := statebased.NewStateEP(nil)
 		if err != nil {
 			return shim.Error(err.Error())
@@ -105,6 +136,10 @@ func (cc *SwapManager) Init(stub shim.ChaincodeStubInterface) pb.Response {
 		if err != nil {
 			return shim.Error(err.Error())
 		}
+		err = stub.SetStateValidationParameter(providerID, epBytes)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 
 	return shim.Success([]byte{})
@@ -124,6 +159,7 @@ var functions = map[string]func(stub shim.ChaincodeStubInterface) pb.Response{
 	"createSwap":       createSwap,
 	"calculatePayment": calculatePayment,
 	"settlePayment":    settlePayment,
+	"setProviderKey":   setProviderKey,
 	"setReferenceRate": setReferenceRate,
 }
 
@@ -290,21 +326,193 @@ func settlePayment(stub shim.ChaincodeStubInterface) pb.Response {
 	return shim.Success([]byte{})
 }
 
-// Set the reference rate for a given rate provider
-func setReferenceRate(stub shim.ChaincodeStubInterface) pb.Response {
+// Register the public key of a reference rate provider.
+// The key entry is created in init with the provider's state-based endorsement
+// policy, so only the provider can register or rotate its key.
+// Parameters: reference rate ID, PEM encoded ECDSA public key
+func setProviderKey(stub shim.ChaincodeStubInterface) pb.Response {
 	_, parameters := stub.GetFunctionAndParameters()
 	if len(parameters) != 2 {
-		return shim.Error("Wrong number of arguments supplied. Expected: <reference_rate_ID> <reference_rate_BPS>")
+		return shim.Error("Wrong number of arguments supplied. Expected: <reference_rate_ID> <public_key_PEM>")
+	}
+
+	providerID := "provider" + parameters[0]
+	current, err := stub.GetState(providerID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if current == nil {
+		return shim.Error(fmt.Sprintf("Reference rate %s not found", parameters[0]))
+	}
+	_, err = parsePublicKey([]byte(parameters[1]))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(providerID, []byte(parameters[1]))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success([]byte{})
+}
+
+// Set the reference rate for a given rate provider.
+// The rate has to be accompanied by an attestation signed with the key the
+// provider registered through setProviderKey. Attestations are rejected if
+// their timestamp is more than maxAttestationAge away from the transaction
+// timestamp, if they are not newer than the last accepted attestation, or if
+// their nonce has been used before.
+// Parameters: reference rate ID, rate in BPS, RFC3339 timestamp, nonce, signature
+// (base64 encoded ASN.1 ECDSA signature over attestationDigest)
+func setReferenceRate(stub shim.ChaincodeStubInterface) pb.Response {
+	_, parameters := stub.GetFunctionAndParameters()
+	if len(parameters) != 5 {
+		return shim.Error("Wrong number of arguments supplied. Expected: <reference_rate_ID> <reference_rate_BPS> <timestamp> <nonce> <signature>")
+	}
+	rrIDParam, rate, timestamp, nonce := parameters[0], parameters[1], parameters[2], parameters[3]
+
+	rateBPS, err := strconv.ParseUint(rate, 10, 64)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	attestedAt, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if nonce == "" {
+		return shim.Error("Nonce must not be empty")
+	}
+	signature, err := base64.StdEncoding.DecodeString(parameters[4])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// verify the signature against the registered provider key
+	keyPEM, err := stub.GetState("provider" + rrIDParam)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if keyPEM == nil {
+		return shim.Error(fmt.Sprintf("Reference rate %s not found", rrIDParam))
+	}
+	if string(keyPEM) == "none" {
+		return shim.Error(fmt.Sprintf("No provider key registered for reference rate %s", rrIDParam))
+	}
+	publicKey, err := parsePublicKey(keyPEM)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = verifySignature(publicKey, attestationDigest(rrIDParam, rate, timestamp, nonce), signature)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// reject stale attestations
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	txTime := time.Unix(txTimestamp.GetSeconds(), int64(txTimestamp.GetNanos())).UTC()
+	if age := txTime.Sub(attestedAt); age > maxAttestationAge || age < -maxAttestationAge {
+		return shim.Error(fmt.Sprintf("Attestation timestamp %s is not within %v of transaction timestamp %s", timestamp, maxAttestationAge, txTime.Format(time.RFC3339)))
+	}
+	attestationID := "attestation" + rrIDParam
+	lastJSON, err := stub.GetState(attestationID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if lastJSON != nil {
+		var last RateAttestation
+		err = json.Unmarshal(lastJSON, &last)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if !attestedAt.After(last.Timestamp) {
+			return shim.Error(fmt.Sprintf("Attestation timestamp %s is not after the last accepted attestation %s", timestamp, last.Timestamp.Format(time.RFC3339)))
+		}
+	}
+
+	// reject replayed attestations
+	nonceKey, err := stub.CreateCompositeKey("nonce", []string{rrIDParam, nonce})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	used, err := stub.GetState(nonceKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if used != nil {
+		return shim.Error(fmt.Sprintf("Nonce %s has already been used for reference rate %s", nonce, rrIDParam))
+	}
+	err = stub.PutState(nonceKey, []byte(timestamp))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	attestationJSON, err := json.Marshal(RateAttestation{
+		RateBPS:   rateBPS,
+		Timestamp: attestedAt,
+		Nonce:     nonce,
+		Signature: signature,
+	})
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	err = stub.PutState(attestationID, attestationJSON)
+	if err != nil {
+		return shim.Error(err.Error())
 	}
 
-	rrID := "rr" + parameters[0]
-	err := stub.PutState(rrID, []byte(parameters[1]))
+	rrID := "rr" + rrIDParam
+	err = stub.PutState(rrID, []byte(strconv.FormatUint(rateBPS, 10)))
 	if err != nil {
 		return shim.Error(err.Error())
 	}
 	return shim.Success([]byte{})
 }
 
+// attestationDigest returns the SHA-256 digest a provider signs for a
+// reference rate attestation. The fields are joined by newlines, e.g. for
+// use with "openssl dgst -sha256 -sign".
+func attestationDigest(rrID, rate, timestamp, nonce string) []byte {
+	digest := sha256.Sum256([]byte(rrID + "\n" + rate + "\n" + timestamp + "\n" + nonce))
+	return digest[:]
+}
+
+// parsePublicKey parses a PEM encoded PKIX ECDSA public key
+func parsePublicKey(keyPEM []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("Provider key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Provider key is not an ECDSA public key")
+	}
+	return publicKey, nil
+}
+
+// verifySignature checks an ASN.1 encoded ECDSA signature over digest
+func verifySignature(publicKey *ecdsa.PublicKey, digest []byte, signature []byte) error {
+	var sig struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(signature, &sig)
+	if err != nil {
+		return fmt.Errorf("Malformed attestation signature: %s", err)
+	}
+	if len(rest) != 0 {
+		return fmt.Errorf("Malformed attestation signature: trailing data")
+	}
+	if !ecdsa.Verify(publicKey, digest, sig.R, sig.S) {
+		return fmt.Errorf("Invalid attestation signature")
+	}
+	return nil
+}
+
 func main() {
 	err := shim.Start(new(SwapManager))
 	if err != nil {
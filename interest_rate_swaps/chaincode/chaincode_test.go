@@ -0,0 +1,145 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func newProviderKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, rrID, rate, timestamp, nonce string) string {
+	r, s, err := ecdsa.Sign(rand.Reader, key, attestationDigest(rrID, rate, timestamp, nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func invoke(stub *shimtest.MockStub, args ...string) (int32, string) {
+	byteArgs := make([][]byte, len(args))
+	for i, arg := range args {
+		byteArgs[i] = []byte(arg)
+	}
+	res := stub.MockInvoke("1", byteArgs)
+	return res.Status, res.Message
+}
+
+func newSwapManager(t *testing.T) *shimtest.MockStub {
+	stub := shimtest.NewMockStub("irs", new(SwapManager))
+	res := stub.MockInit("1", [][]byte{[]byte("init"), []byte("auditor"), []byte("1000000"), []byte("rrprovider"), []byte("myrr")})
+	if res.Status != shim.OK {
+		t.Fatalf("Init failed: %s", res.Message)
+	}
+	return stub
+}
+
+func TestSetReferenceRate(t *testing.T) {
+	stub := newSwapManager(t)
+	key, keyPEM := newProviderKey(t)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	if status, msg := invoke(stub, "setReferenceRate", "myrr", "300", now, "n1", sign(t, key, "myrr", "300", now, "n1")); status == shim.OK || !strings.Contains(msg, "No provider key registered") {
+		t.Fatalf("expected rejection without provider key, got %d %s", status, msg)
+	}
+
+	if status, msg := invoke(stub, "setProviderKey", "myrr", keyPEM); status != shim.OK {
+		t.Fatalf("setProviderKey failed: %s", msg)
+	}
+	if status, msg := invoke(stub, "setReferenceRate", "myrr", "300", now, "n1", sign(t, key, "myrr", "300", now, "n1")); status != shim.OK {
+		t.Fatalf("setReferenceRate failed: %s", msg)
+	}
+	if rate := string(stub.State["rrmyrr"]); rate != "300" {
+		t.Fatalf("expected reference rate 300, got %s", rate)
+	}
+	var attestation RateAttestation
+	if err := json.Unmarshal(stub.State["attestationmyrr"], &attestation); err != nil {
+		t.Fatal(err)
+	}
+	if attestation.RateBPS != 300 || attestation.Nonce != "n1" {
+		t.Fatalf("unexpected attestation %+v", attestation)
+	}
+}
+
+func TestSetReferenceRateRejectsInvalidAttestations(t *testing.T) {
+	stub := newSwapManager(t)
+	key, keyPEM := newProviderKey(t)
+	otherKey, _ := newProviderKey(t)
+	if status, msg := invoke(stub, "setProviderKey", "myrr", keyPEM); status != shim.OK {
+		t.Fatalf("setProviderKey failed: %s", msg)
+	}
+
+	now := time.Now().UTC()
+	ts := now.Format(time.RFC3339)
+	stale := now.Add(-2 * maxAttestationAge).Format(time.RFC3339)
+	later := now.Add(time.Second).Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"wrong key", []string{"myrr", "300", ts, "n1", sign(t, otherKey, "myrr", "300", ts, "n1")}, "Invalid attestation signature"},
+		{"tampered rate", []string{"myrr", "900", ts, "n1", sign(t, key, "myrr", "300", ts, "n1")}, "Invalid attestation signature"},
+		{"stale", []string{"myrr", "300", stale, "n1", sign(t, key, "myrr", "300", stale, "n1")}, "is not within"},
+		{"unknown rate", []string{"otherrr", "300", ts, "n1", sign(t, key, "otherrr", "300", ts, "n1")}, "Reference rate otherrr not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, msg := invoke(stub, append([]string{"setReferenceRate"}, tt.args...)...)
+			if status == shim.OK || !strings.Contains(msg, tt.err) {
+				t.Fatalf("expected error containing %q, got %d %s", tt.err, status, msg)
+			}
+		})
+	}
+
+	if status, msg := invoke(stub, "setReferenceRate", "myrr", "300", ts, "n1", sign(t, key, "myrr", "300", ts, "n1")); status != shim.OK {
+		t.Fatalf("setReferenceRate failed: %s", msg)
+	}
+	// the same attestation must not be accepted twice
+	if status, msg := invoke(stub, "setReferenceRate", "myrr", "300", ts, "n1", sign(t, key, "myrr", "300", ts, "n1")); status == shim.OK || !strings.Contains(msg, "is not after the last accepted attestation") {
+		t.Fatalf("expected replay rejection, got %d %s", status, msg)
+	}
+	// a newer attestation reusing the nonce is rejected as well
+	if status, msg := invoke(stub, "setReferenceRate", "myrr", "310", later, "n1", sign(t, key, "myrr", "310", later, "n1")); status == shim.OK || !strings.Contains(msg, "Nonce n1 has already been used") {
+		t.Fatalf("expected nonce rejection, got %d %s", status, msg)
+	}
+	if status, msg := invoke(stub, "setReferenceRate", "myrr", "310", later, "n2", sign(t, key, "myrr", "310", later, "n2")); status != shim.OK {
+		t.Fatalf("setReferenceRate failed: %s", msg)
+	}
+	if rate := string(stub.State["rrmyrr"]); rate != "310" {
+		t.Fatalf("expected reference rate 310, got %s", rate)
+	}
+}
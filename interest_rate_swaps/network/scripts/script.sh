@@ -101,12 +101,26 @@ initChaincode() {
 		echo "===================== Chaincode initialized ===================== "
 }
 
+setProviderKey() {
+	CORE_PEER_LOCALMSPID=rrprovider
+	CORE_PEER_ADDRESS=irs-rrprovider:7051
+	CORE_PEER_MSPCONFIGPATH=/opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/peerOrganizations/rrprovider.example.com/users/User1@rrprovider.example.com/msp
+	openssl ecparam -name prime256v1 -genkey -noout -out rrprovider.key
+	openssl ec -in rrprovider.key -pubout -out rrprovider.pub
+	echo "===================== Invoking chaincode ===================== "
+	peer chaincode invoke -o irs-orderer:7050 -C irs --waitForEvent -n irscc --peerAddresses irs-rrprovider:7051 -c "{\"Args\":[\"setProviderKey\",\"myrr\",\"$(awk '{printf "%s\\n", $0}' rrprovider.pub)\"]}"
+	echo "===================== Chaincode invoked ===================== "
+}
+
 setReferenceRate() {
 	CORE_PEER_LOCALMSPID=rrprovider
 	CORE_PEER_ADDRESS=irs-rrprovider:7051
 	CORE_PEER_MSPCONFIGPATH=/opt/gopath/src/github.com/hyperledger/fabric/peer/crypto/peerOrganizations/rrprovider.example.com/users/User1@rrprovider.example.com/msp
+	TIMESTAMP=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+	NONCE=$(openssl rand -hex 16)
+	SIGNATURE=$(printf "myrr\n300\n%s\n%s" "$TIMESTAMP" "$NONCE" | openssl dgst -sha256 -sign rrprovider.key | base64 | tr -d '\n')
 	echo "===================== Invoking chaincode ===================== "
-	peer chaincode invoke -o irs-orderer:7050 -C irs --waitForEvent -n irscc --peerAddresses irs-rrprovider:7051 -c '{"Args":["setReferenceRate","myrr","300"]}'
+	peer chaincode invoke -o irs-orderer:7050 -C irs --waitForEvent -n irscc --peerAddresses irs-rrprovider:7051 -c "{\"Args\":[\"setReferenceRate\",\"myrr\",\"300\",\"$TIMESTAMP\",\"$NONCE\",\"$SIGNATURE\"]}"
 	echo "===================== Chaincode invoked ===================== "
 }
 
@@ -176,8 +190,11 @@ commitChaincode
 echo "Initialize chaincode..."
 initChaincode
 
-echo "Setting myrr reference rate"
+echo "Registering myrr provider key"
 sleep 3
+setProviderKey
+
+echo "Setting myrr reference rate"
 setReferenceRate
 
 echo "Creating swap between A and B"
@@ -94,9 +94,9 @@ async function main() {
             var randomOwner = Math.floor(Math.random() * (11));
             var randomSize = Math.floor(Math.random() * (10));
 
-            // Submit the 'initMarble' transaction to the smart contract, and wait for it
+            // Submit the 'InitMarble' transaction to the smart contract, and wait for it
             // to be committed to the ledger.
-            await contract.submitTransaction('initMarble', docType+counter, colors[randomColor], ''+sizes[randomSize], owners[randomOwner]);
+            await contract.submitTransaction('InitMarble', docType+counter, colors[randomColor], ''+sizes[randomSize], owners[randomOwner]);
             console.log("Adding marble: " + docType + counter + "   owner:"  + owners[randomOwner] + "   color:" + colors[randomColor] + "   size:" + '' + sizes[randomSize] );
 
         }
@@ -55,7 +55,7 @@ async function main() {
         // Get the smart contract from the network channel.
         const contract = network.getContract('marbles');
 
-        await contract.submitTransaction('delete', deletekey);
+        await contract.submitTransaction('Delete', deletekey);
         console.log("Deleted marble: " + deletekey);
 
         await gateway.disconnect();
@@ -68,7 +68,6 @@ peer lifecycle chaincode approveformyorg \
     --channelID mychannel \
     --name marbles \
     --version 1.0 \
-    --init-required \
     --signature-policy AND"('Org1MSP.member','Org2MSP.member')" \
     --sequence 1 \
     --package-id $PACKAGE_ID \
@@ -85,7 +84,6 @@ peer lifecycle chaincode approveformyorg \
     --channelID mychannel \
     --name marbles \
     --version 1.0 \
-    --init-required \
     --signature-policy AND"('Org1MSP.member','Org2MSP.member')" \
     --sequence 1 \
     --package-id $PACKAGE_ID \
@@ -100,7 +98,6 @@ peer lifecycle chaincode checkcommitreadiness \
     --version 1.0 \
     --sequence 1 \
     --output json \
-    --init-required \
     --signature-policy AND"('Org1MSP.member','Org2MSP.member')" >&log.txt
 
 rc=0
@@ -125,7 +122,6 @@ peer lifecycle chaincode commit \
     --channelID mychannel \
     --name marbles \
     --version 1.0 \
-    --init-required \
     --signature-policy AND"('Org1MSP.member','Org2MSP.member')" \
     --sequence 1 \
     --tls \
@@ -148,19 +144,6 @@ else
   sleep 10
 fi
 
-echo "invoke the marbles chaincode init function ... "
-
-peer chaincode invoke \
-        -o localhost:7050 \
-        --ordererTLSHostnameOverride orderer.example.com \
-        -C mychannel \
-        -n marbles \
-        --isInit \
-        -c '{"Args":["Init"]}' \
-        --tls \
-        --cafile ${ORDERER_CA} \
-        $PEER_CONN_PARMS
-
 rm log.txt
 
 popd
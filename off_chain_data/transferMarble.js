@@ -55,7 +55,7 @@ async function main() {
         // Get the smart contract from the network channel.
         const contract = network.getContract('marbles');
 
-        await contract.submitTransaction('transferMarble', updatekey, newowner);
+        await contract.submitTransaction('TransferMarble', updatekey, newowner);
         console.log("Transferred marble " + updatekey + " to " + newowner);
 
         await gateway.disconnect();